@@ -22,3 +22,6 @@ package assets
 //go:generate ../../utils/generate.sh --out-var FlannelManifestCode --out-package assets --out-file generated_flannel_manifest.go ./static/kube-flannel.yml
 //go:generate ../../utils/generate.sh --out-var CloudProviderCode --out-package assets --out-file cloud_provider_manifest.go ./static/cloud-provider.yml
 //go:generate ../../utils/generate.sh --out-var WeaveManifestCode --out-package assets --out-file weave_manifest.go ./static/weave.yml
+//go:generate ../../utils/generate.sh --out-var MultusManifestCode --out-package assets --out-file multus_manifest.go ./static/multus.yml
+//go:generate ../../utils/generate.sh --out-var KonnectivityServerManifestCode --out-package assets --out-file konnectivity_server_manifest.go ./static/konnectivity-server.yml
+//go:generate ../../utils/generate.sh --out-var KonnectivityAgentManifestCode --out-package assets --out-file konnectivity_agent_manifest.go ./static/konnectivity-agent.yml
@@ -128,7 +128,7 @@ data:
     }
   net-conf.json: |
     {
-      "Network": "{{.cni_pod_cidr}}",
+      "Network": "{{.cni_pod_cidr_v4}}",
       "Backend": {
         "Type": "{{.flannel_backend}}"
       }
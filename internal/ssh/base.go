@@ -73,16 +73,22 @@ func (ae ActionError) Error() string {
 	return string(ae)
 }
 
+// interruptedError returns the error for a cancelled context
+func interruptedError(ctx context.Context) ActionError {
+	return ActionError(fmt.Sprintf("interrupted: %s", ctx.Err()))
+}
+
 // IsError returns True if it is an error
 func IsError(a Action) bool {
-	if a == nil {
+	switch t := a.(type) {
+	case nil:
 		return false
+	case ActionError:
+		return t.Error() != ""
+	case *ExecError:
+		return t != nil
 	}
-	t, ok := a.(ActionError)
-	if !ok {
-		return false
-	}
-	return t.Error() != ""
+	return false
 }
 
 ///////////////////////////////////////////////////////////////////////////////////////////////
@@ -101,6 +107,11 @@ func applyList(ctx context.Context, actions ActionList, ignoreErrors bool) Actio
 			}
 		}
 
+		// do not start anything else once the context has been cancelled
+		if ctx.Err() != nil {
+			return interruptedError(ctx)
+		}
+
 		// otherwise, consume from the queue: pop the first element
 		cur := actions[0]
 		actions = actions[1:]
@@ -228,16 +239,29 @@ func (f CheckerFunc) Check(ctx context.Context) (bool, error) {
 }
 
 // DoWithCleanup runs some action(s) and
-// 1) despite the result, runs the cleanup function
+// 1) despite the result, runs the cleanup function (even when interrupted)
 // 2) returns the actions result
 func DoWithCleanup(actions Action, cleanup Action) Action {
 	return ActionFunc(func(ctx context.Context) Action {
 		res := ActionList{actions}.Apply(ctx)
-		_ = ActionList{cleanup}.Apply(ctx)
+		_ = ActionList{cleanup}.Apply(WithoutCancel(ctx))
 		return res
 	})
 }
 
+// DoWithTimeout runs some action(s), interrupting them (and returning an error)
+// when they take longer than `timeout`
+func DoWithTimeout(timeout time.Duration, action Action) Action {
+	if timeout <= 0 {
+		return action
+	}
+	return ActionFunc(func(ctx context.Context) Action {
+		timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		return ActionList{action}.Apply(timeoutCtx)
+	})
+}
+
 // DoWithException runs some action and
 // 1) if some error happens, runs the exception handler
 // 2) returns the error
@@ -318,24 +342,41 @@ type Retry struct {
 
 	// Interval is the time between trials
 	Interval time.Duration
+
+	// Backoff is the factor the Interval is multiplied by after each trial (when > 1)
+	Backoff float64
+
+	// Retryable (when not nil) decides if some failure can be retried
+	Retryable func(res Action) bool
 }
 
 // DoRetry runs an action `n` times until it succeedes
 func DoRetry(run Retry, actions ...Action) ActionFunc {
-	interval := 1 * time.Second
-	if run.Interval > 0 {
-		interval = run.Interval
-	}
-
 	return ActionFunc(func(ctx context.Context) Action {
+		interval := 1 * time.Second
+		if run.Interval > 0 {
+			interval = run.Interval
+		}
+
 		count := run.Times
 		var res Action
 		for count > 0 {
 			res = ActionList(actions).Apply(ctx)
 			if IsError(res) {
-				_ = DoMessageWarn("failed... retrying in %d seconds...", interval/time.Second).Apply(ctx)
-				time.Sleep(interval)
+				if run.Retryable != nil && !run.Retryable(res) {
+					return res
+				}
 				count--
+				if count == 0 {
+					break
+				}
+				_ = DoMessageWarn("failed... retrying in %d seconds...", interval/time.Second).Apply(ctx)
+				if !sleepWithContext(ctx, interval) {
+					return interruptedError(ctx)
+				}
+				if run.Backoff > 1 {
+					interval = time.Duration(float64(interval) * run.Backoff)
+				}
 			} else {
 				return res
 			}
@@ -344,6 +385,52 @@ func DoRetry(run Retry, actions ...Action) ActionFunc {
 	})
 }
 
+// DefHeartbeatInterval is the default interval between heartbeat messages
+const DefHeartbeatInterval = 15 * time.Second
+
+// DoWithHeartbeat runs some (long running) action(s), printing a
+// "still working..." message every `interval` until the actions are done,
+// so users can distinguish a slow operation from a stuck one.
+// A zero (or negative) interval disables the heartbeat.
+func DoWithHeartbeat(descr string, interval time.Duration, actions Action) Action {
+	if interval <= 0 {
+		return actions
+	}
+
+	return ActionFunc(func(ctx context.Context) Action {
+		userOutput := GetUserOutputFromContext(ctx)
+		done := make(chan struct{})
+		stopped := make(chan struct{})
+
+		go func() {
+			defer close(stopped)
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+
+			start := time.Now()
+			for {
+				select {
+				case <-ticker.C:
+					elapsed := time.Since(start).Round(time.Second)
+					msg := fmt.Sprintf("%s: still working... (%s elapsed)", descr, elapsed)
+					userOutput.Output(commonMsgPrefix + color.FgGreen.Render(msg))
+				case <-done:
+					return
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+
+		res := ActionList{actions}.Apply(ctx)
+
+		// stop the heartbeat (and wait for it) before returning
+		close(done)
+		<-stopped
+		return res
+	})
+}
+
 // DoSendingExecOutputToFunc runs some action redirecting all the Do***Exec outputs
 // to some function
 // Some notes:
@@ -351,6 +438,7 @@ func DoRetry(run Retry, actions ...Action) ActionFunc {
 func DoSendingExecOutputToFunc(action Action, interceptor OutputFunc) Action {
 	return ActionFunc(func(ctx context.Context) Action {
 		newCtx := WithValues(ctx, GetUserOutputFromContext(ctx), interceptor, GetCommFromContext(ctx), GetUseSudoFromContext(ctx))
+		getSSHContext(newCtx).tmpDir = GetTmpDirFromContext(ctx)
 		return ActionList{action}.Apply(newCtx)
 	})
 }
@@ -366,6 +454,19 @@ func DoSendingExecOutputToWriter(action Action, writer io.Writer) Action {
 	})
 }
 
+// DoCopyingExecOutputToWriter runs some action copying all the Do***Exec outputs
+// to some io.Writer (while still sending them to the current output)
+func DoCopyingExecOutputToWriter(action Action, writer io.Writer) Action {
+	return ActionFunc(func(ctx context.Context) Action {
+		execOutput := GetExecOutputFromContext(ctx)
+		return DoSendingExecOutputToFunc(action, func(s string) {
+			c := strings.ReplaceAll(s, "\r", "\n")
+			_, _ = writer.Write([]byte(c))
+			execOutput.Output(s)
+		})
+	})
+}
+
 // DoSendingExecOutputToDevNull runs some action redirecting all the Do***Exec outputs
 // to /dev/null
 // Some notes:
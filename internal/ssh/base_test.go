@@ -17,6 +17,7 @@ package ssh
 import (
 	"bytes"
 	"context"
+	"sync"
 	"testing"
 	"time"
 )
@@ -430,6 +431,108 @@ func TestDoRetry(t *testing.T) {
 	}
 }
 
+func TestDoRetryNotRetryable(t *testing.T) {
+	count := 0
+	actions := ActionList{
+		DoRetry(Retry{
+			Times:    3,
+			Interval: 10 * time.Millisecond,
+			Backoff:  2,
+			Retryable: func(res Action) bool {
+				return res.Error() != "a fatal error"
+			}},
+			ActionFunc(func(context.Context) Action {
+				count++
+				if count == 2 {
+					return ActionError("a fatal error")
+				}
+				return ActionError("an error")
+			}),
+		),
+	}
+
+	ctx := NewTestingContext()
+	res := actions.Apply(ctx)
+	if !IsError(res) {
+		t.Fatalf("Error: error detected: %s", res)
+	}
+	if count != 2 {
+		t.Fatalf("Error: unexpected number of retries: %d, expected: %d", count, 2)
+	}
+}
+
+func TestDoWithTimeout(t *testing.T) {
+	count := 0
+	cleanedUp := false
+	actions := DoWithTimeout(50*time.Millisecond,
+		DoWithCleanup(
+			DoRetry(Retry{Times: 10, Interval: 20 * time.Millisecond},
+				ActionFunc(func(context.Context) Action {
+					count++
+					return ActionError("an error")
+				}),
+			),
+			ActionFunc(func(context.Context) Action {
+				cleanedUp = true
+				return nil
+			})))
+
+	start := time.Now()
+	res := actions.Apply(NewTestingContext())
+	if !IsError(res) {
+		t.Fatalf("Error: expected an error after the timeout")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Error: the action was not interrupted (took %s)", elapsed)
+	}
+	if count >= 10 {
+		t.Fatalf("Error: unexpected number of retries: %d", count)
+	}
+	if !cleanedUp {
+		t.Fatalf("Error: cleanup not run after the interruption")
+	}
+}
+
+func TestDoWithHeartbeat(t *testing.T) {
+	var mu sync.Mutex
+	beats := 0
+	out := OutputFunc(func(s string) {
+		mu.Lock()
+		defer mu.Unlock()
+		beats++
+	})
+
+	actions := ActionList{
+		DoWithHeartbeat("sleeping", 20*time.Millisecond,
+			ActionFunc(func(context.Context) Action {
+				time.Sleep(110 * time.Millisecond)
+				return nil
+			}),
+		),
+	}
+
+	ctx := WithValues(context.Background(), out, out, DummyCommunicator{}, false)
+	res := actions.Apply(ctx)
+	if IsError(res) {
+		t.Fatalf("Error: error detected: %s", res)
+	}
+
+	mu.Lock()
+	got := beats
+	mu.Unlock()
+	if got < 2 {
+		t.Fatalf("Error: unexpected number of heartbeats: %d", got)
+	}
+
+	// the heartbeat must stop once the action is done
+	time.Sleep(60 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if beats != got {
+		t.Fatalf("Error: heartbeat still running after the action finished: %d != %d", beats, got)
+	}
+}
+
 func doEcho(msg string) Action {
 	return DoLocalExec("/bin/echo", msg)
 }
@@ -69,8 +69,12 @@ func DoExec(command string) Action {
 		outDoneCh := make(chan struct{})
 		errDoneCh := make(chan struct{})
 
-		go copyOutput(execOutput, outR, outDoneCh)
-		go copyOutput(execOutput, errR, errDoneCh)
+		// keep the last part of the output, so it can be included in errors
+		outBuf, _ := circbuf.NewBuffer(maxBufSize)
+		errBuf, _ := circbuf.NewBuffer(maxBufSize)
+
+		go copyOutput(execOutput, io.TeeReader(outR, outBuf), outDoneCh)
+		go copyOutput(execOutput, io.TeeReader(errR, errBuf), errDoneCh)
 
 		cmd := &remote.Cmd{
 			Command: command,
@@ -81,15 +85,20 @@ func DoExec(command string) Action {
 		if err := comm.Start(cmd); err != nil {
 			return ActionError(fmt.Sprintf("Error executing command %q: %v", cmd.Command, err))
 		}
-		waitResult := cmd.Wait()
-		if waitResult != nil {
-			cmdError, _ := waitResult.(*remote.ExitError)
-			if cmdError.ExitStatus != 0 {
-				msg := fmt.Sprintf("Command %q exited with non-zero exit status: %d", cmdError.Command, cmdError.ExitStatus)
-				Debug(msg)
-				res = ActionError(msg)
-			}
-			// otherwise, it is a communicator error
+		// wait for the command, but do not wait forever if we are interrupted:
+		// we cannot kill a remote command, but disconnecting drops its session
+		waitCh := make(chan error, 1)
+		go func() { waitCh <- cmd.Wait() }()
+
+		var waitResult error
+		select {
+		case waitResult = <-waitCh:
+		case <-ctx.Done():
+			Debug("interrupted while running %q: disconnecting", command)
+			_ = comm.Disconnect()
+			_ = outW.Close()
+			_ = errW.Close()
+			return interruptedError(ctx)
 		}
 
 		_ = outW.Close()
@@ -103,24 +112,40 @@ func DoExec(command string) Action {
 		case <-ctx.Done():
 		}
 
+		if waitResult != nil {
+			cmdError, ok := waitResult.(*remote.ExitError)
+			if ok && cmdError.ExitStatus != 0 {
+				execErr := &ExecError{
+					Action:   cmdError.Command,
+					ExitCode: cmdError.ExitStatus,
+					Output:   outBuf.String() + errBuf.String(),
+				}
+				Debug(execErr.Error())
+				res = execErr
+			}
+			// otherwise, it is a communicator error
+		}
+
 		return
 	})
 }
 
-// DoExecScript is a runner for a script (with some random path in /tmp)
+// DoExecScript is a runner for a script (with some random path in the remote temporary directory)
 func DoExecScript(contents []byte) Action {
-	path, err := GetTempFilename()
-	if err != nil {
-		return ActionError(fmt.Sprintf("Could not create temporary file: %s", err))
-	}
-	return DoWithCleanup(
-		ActionList{
-			doRealUploadFile(contents, path),
-			DoExec(fmt.Sprintf("sh %s", path)),
-		},
-		ActionList{
-			DoTry(DoDeleteFile(path)),
-		})
+	return ActionFunc(func(ctx context.Context) Action {
+		path, err := GetTempFilenameFromContext(ctx)
+		if err != nil {
+			return ActionError(fmt.Sprintf("Could not create temporary file: %s", err))
+		}
+		return DoWithCleanup(
+			ActionList{
+				doRealUploadFile(contents, path),
+				DoExec(fmt.Sprintf("sh %s", path)),
+			},
+			ActionList{
+				DoTry(DoDeleteFile(path)),
+			})
+	})
 }
 
 // DoLocalExec executes a local command
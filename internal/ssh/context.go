@@ -16,6 +16,7 @@ package ssh
 
 import (
 	"context"
+	"time"
 
 	"github.com/hashicorp/terraform/communicator"
 )
@@ -43,6 +44,7 @@ type cache map[string]interface{}
 // sshContext is the "internal" context we pass around
 type sshContext struct {
 	useSudo    bool
+	tmpDir     string
 	userOutput UIOutput
 	execOutput UIOutput
 	comm       communicator.Communicator
@@ -54,6 +56,7 @@ type sshContext struct {
 func WithValues(ctx context.Context, userOutput UIOutput, execOutput UIOutput, comm communicator.Communicator, useSudo bool) context.Context {
 	return context.WithValue(ctx, sshContextKey, &sshContext{
 		useSudo:    useSudo,
+		tmpDir:     defaultRemoteTmp,
 		userOutput: userOutput,
 		execOutput: execOutput,
 		comm:       comm,
@@ -75,6 +78,11 @@ func GetUseSudoFromContext(ctx context.Context) bool {
 	return getSSHContext(ctx).useSudo
 }
 
+// GetTmpDirFromContext gets the remote directory used for temporary files
+func GetTmpDirFromContext(ctx context.Context) string {
+	return getSSHContext(ctx).tmpDir
+}
+
 // GetUserOutputFromContext gets the user output
 func GetUserOutputFromContext(ctx context.Context) UIOutput {
 	return getSSHContext(ctx).userOutput
@@ -94,3 +102,34 @@ func GetCommFromContext(ctx context.Context) communicator.Communicator {
 func getCacheFromContext(ctx context.Context) cache {
 	return getSSHContext(ctx).cache
 }
+
+///////////////////////////////////////////////////////////////////////////////////////////////
+
+// detachedContext is a context with the values of some parent context but
+// that is never cancelled (ie, for running cleanups after an interruption)
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+
+func (c detachedContext) Value(key interface{}) interface{} {
+	return c.parent.Value(key)
+}
+
+// WithoutCancel returns a copy of the context that is not cancelled when the parent is
+func WithoutCancel(ctx context.Context) context.Context {
+	return detachedContext{parent: ctx}
+}
+
+// sleepWithContext waits for some time, returning false if the context is done before
+func sleepWithContext(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
@@ -15,7 +15,12 @@
 package ssh
 
 import (
+	"context"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
 )
 
 // DoMkdir creates a remote directory
@@ -40,3 +45,115 @@ func DoMkdirOnce(dir string) Action {
 func CheckDirExists(path string) CheckerFunc {
 	return CheckExec(fmt.Sprintf("[ -d '%s' ]", path))
 }
+
+// DoDeleteDir removes a remote directory (and all its contents)
+func DoDeleteDir(path string) Action {
+	if path == "" || path == "/" {
+		return ActionError(fmt.Sprintf("invalid remote directory to remove: %q", path))
+	}
+	return ActionList{
+		DoExec(fmt.Sprintf("rm -rf %q", path)),
+		DoRemoveFromCache(CacheRemoteDirExistsPrefix + "-" + path),
+	}
+}
+
+// DoUploadDirToDir uploads a local directory tree to a remote directory
+// (uploading all the files with `DoUploadBytesToFile`)
+func DoUploadDirToDir(local string, remote string) Action {
+	if local == "" {
+		return ActionError("empty local directory name to upload")
+	}
+	if remote == "" {
+		return ActionError("empty remote directory name to upload")
+	}
+
+	return ActionFunc(func(ctx context.Context) Action {
+		// note: we walk the tree inside the ActionFunc, as we must delay the operation
+		// just in case the directory does not exists yet
+		actions := ActionList{}
+		err := filepath.Walk(local, func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+			rel, err := filepath.Rel(local, p)
+			if err != nil {
+				return err
+			}
+			contents, err := ioutil.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			actions = append(actions, DoUploadBytesToFile(contents, path.Join(remote, filepath.ToSlash(rel))))
+			return nil
+		})
+		if err != nil {
+			return ActionError(fmt.Sprintf("could not read local directory %q for uploading to %q: %s", local, remote, err))
+		}
+		return actions
+	})
+}
+
+// DoSetupTmpDir creates a private (ie, mode 0700) directory inside `parent` and uses
+// it as the remote temporary directory for all the subsequent actions.
+// The directory is created by the current user, so files can be uploaded there
+// before being moved to their final destination.
+func DoSetupTmpDir(parent string) Action {
+	if parent == "" {
+		parent = defaultRemoteTmp
+	}
+
+	return ActionFunc(func(ctx context.Context) Action {
+		dir, err := randomPath(parent, "kubeadm", "d")
+		if err != nil {
+			return ActionError(fmt.Sprintf("Could not get a temporary directory name: %s", err))
+		}
+
+		return ActionList{
+			DoMessageDebug(fmt.Sprintf("Creating remote temporary directory %q", dir)),
+			DoWithoutSudo(DoExec(fmt.Sprintf("mkdir -p -m 0700 %q", dir))),
+			ActionFunc(func(ctx context.Context) Action {
+				getSSHContext(ctx).tmpDir = dir
+				return nil
+			}),
+		}
+	})
+}
+
+// doRecreateTmpDir recreates the remote temporary directory created with `DoSetupTmpDir`
+// (ie, after a reboot), as the current user, so files can still be uploaded there
+func doRecreateTmpDir() Action {
+	return ActionFunc(func(ctx context.Context) Action {
+		dir := GetTmpDirFromContext(ctx)
+		if dir == defaultRemoteTmp {
+			return nil
+		}
+
+		return ActionList{
+			DoMessageDebug(fmt.Sprintf("Recreating remote temporary directory %q", dir)),
+			DoWithoutSudo(DoExec(fmt.Sprintf("mkdir -p -m 0700 %q", dir))),
+		}
+	})
+}
+
+// DoCleanupTmpDir removes the remote temporary directory created with
+// `DoSetupTmpDir`, with all the (maybe sensitive) files that could remain there
+func DoCleanupTmpDir() Action {
+	return ActionFunc(func(ctx context.Context) Action {
+		dir := GetTmpDirFromContext(ctx)
+		if dir == defaultRemoteTmp {
+			return nil
+		}
+
+		return ActionList{
+			DoMessageDebug(fmt.Sprintf("Removing remote temporary directory %q", dir)),
+			DoDeleteDir(dir),
+			ActionFunc(func(ctx context.Context) Action {
+				getSSHContext(ctx).tmpDir = defaultRemoteTmp
+				return nil
+			}),
+		}
+	})
+}
@@ -0,0 +1,78 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ExecError is the error returned when some remote command fails
+type ExecError struct {
+	// Action is the name of the action that failed (ie, the command)
+	Action string
+
+	// Phase is the phase (ie, "kubeadm init") where the action was run (if known)
+	Phase string
+
+	// ExitCode is the exit code of the remote command
+	ExitCode int
+
+	// Output is the (last part of the) output of the remote command
+	Output string
+}
+
+// Apply applies an action
+func (e *ExecError) Apply(context.Context) Action {
+	return e
+}
+
+func (e *ExecError) Error() string {
+	msg := fmt.Sprintf("Command %q exited with non-zero exit status: %d", e.Action, e.ExitCode)
+	if e.Phase != "" {
+		msg = fmt.Sprintf("%s: %s", e.Phase, msg)
+	}
+	if last := lastLine(e.Output); last != "" {
+		msg = fmt.Sprintf("%s (%s)", msg, last)
+	}
+	return msg
+}
+
+// lastLine returns the last non-empty line in some text
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// GetExecError returns the ExecError in the result of some action, if any
+func GetExecError(res Action) (*ExecError, bool) {
+	e, ok := res.(*ExecError)
+	return e, ok && e != nil
+}
+
+// DoInPhase runs some action(s), setting the `phase` in any ExecError
+// returned (when the error does not have a phase yet)
+func DoInPhase(phase string, action Action) Action {
+	return ActionFunc(func(ctx context.Context) Action {
+		res := ActionList{action}.Apply(ctx)
+		if e, ok := GetExecError(res); ok && e.Phase == "" {
+			ee := *e
+			ee.Phase = phase
+			return &ee
+		}
+		return res
+	})
+}
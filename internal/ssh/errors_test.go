@@ -0,0 +1,68 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform/communicator/remote"
+)
+
+type dummyFailingCommunicator struct {
+	DummyCommunicator
+
+	output   string
+	exitCode int
+}
+
+func (dc dummyFailingCommunicator) Start(cmd *remote.Cmd) error {
+	cmd.Init()
+	_, _ = cmd.Stdout.Write([]byte(dc.output))
+	cmd.SetExitStatus(dc.exitCode, nil)
+	return nil
+}
+
+func TestExecError(t *testing.T) {
+	ctx := NewTestingContextWithCommunicator(dummyFailingCommunicator{
+		output:   "some output\nlast line\n",
+		exitCode: 3,
+	})
+
+	res := DoInPhase("some phase", ActionList{
+		DoExec("some command"),
+		DoMessage("should not be reached"),
+	}).Apply(ctx)
+	if !IsError(res) {
+		t.Fatalf("Error: expected an error, got %v", res)
+	}
+
+	e, ok := GetExecError(res)
+	if !ok {
+		t.Fatalf("Error: expected an ExecError, got %T", res)
+	}
+	if e.ExitCode != 3 {
+		t.Fatalf("Error: unexpected exit code: %d", e.ExitCode)
+	}
+	if e.Phase != "some phase" {
+		t.Fatalf("Error: unexpected phase: %q", e.Phase)
+	}
+	if !strings.Contains(e.Output, "last line") {
+		t.Fatalf("Error: output not captured: %q", e.Output)
+	}
+	if !strings.HasSuffix(e.Error(), "(last line)") {
+		t.Fatalf("Error: unexpected error message: %q", e.Error())
+	}
+}
@@ -65,7 +65,7 @@ func randBytes(length int) (string, error) {
 }
 
 // randomPath gets a random Path
-func randomPath(prefix, extension string) (string, error) {
+func randomPath(dir, prefix, extension string) (string, error) {
 	r, err := randBytes(3)
 	if err != nil {
 		return "", err
@@ -73,12 +73,19 @@ func randomPath(prefix, extension string) (string, error) {
 	if len(prefix) == 0 || len(extension) == 0 {
 		return "", fmt.Errorf("can not use empty Prefix or extension")
 	}
-	return fmt.Sprintf("%s/%s-%s.%s", defaultRemoteTmp, prefix, r, extension), nil
+	return fmt.Sprintf("%s/%s-%s.%s", dir, prefix, r, extension), nil
 }
 
-// GetTempFilename returns a temporary filename (but it does not create it)
+// GetTempFilename returns a temporary filename in the default temporary directory
+// (but it does not create it)
 func GetTempFilename() (string, error) {
-	return randomPath(defTemporaryFilenamePrefix, defTemporaryFilenameExt)
+	return randomPath(defaultRemoteTmp, defTemporaryFilenamePrefix, defTemporaryFilenameExt)
+}
+
+// GetTempFilenameFromContext returns a temporary filename in the remote temporary
+// directory of the current context (but it does not create it)
+func GetTempFilenameFromContext(ctx context.Context) (string, error) {
+	return randomPath(GetTmpDirFromContext(ctx), defTemporaryFilenamePrefix, defTemporaryFilenameExt)
 }
 
 // IsTempFilename returns true if it is a temporary filename
@@ -142,19 +149,47 @@ func DoUploadBytesToFile(contents []byte, dst string) Action {
 
 	// for regular files, upload to a temp file and then move the temp file to the final destination
 	// (uploading directly to destination could need root permissions, while we can "mv" with "sudo")
-	dstTmpPath, err := GetTempFilename()
-	if err != nil {
-		return ActionError(fmt.Sprintf("Could not create temporary file: %s", err))
+	return ActionFunc(func(ctx context.Context) Action {
+		dstTmpPath, err := GetTempFilenameFromContext(ctx)
+		if err != nil {
+			return ActionError(fmt.Sprintf("Could not create temporary file: %s", err))
+		}
+
+		return DoWithCleanup(ActionList{
+			DoMessageInfo(fmt.Sprintf("Uploading to %q", dst)),
+			DoMessageDebug(fmt.Sprintf("Uploading to temporary file %q", dstTmpPath)),
+			doRealUploadFile(contents, dstTmpPath),
+			DoMessageDebug(fmt.Sprintf("... and moving to final destination %s", dst)),
+			DoMoveFile(dstTmpPath, dst),
+		}, ActionList{
+			DoTry(DoDeleteFile(dstTmpPath)),
+		})
+	})
+}
+
+// DoUploadBytesToFileWithMode uploads a file to a remote path (like `DoUploadBytesToFile`),
+// but the final file is created with the `mode` permissions, so files with secrets
+// are never readable by other users in their final destination
+func DoUploadBytesToFileWithMode(contents []byte, dst string, mode os.FileMode) Action {
+	if len(dst) == 0 {
+		return ActionError(fmt.Sprintf("internal error: empty remote path in DoUploadBytesToFileWithMode()"))
 	}
 
-	return DoWithCleanup(ActionList{
-		DoMessageInfo(fmt.Sprintf("Uploading to %q", dst)),
-		DoMessageDebug(fmt.Sprintf("Uploading to temporary file %q", dstTmpPath)),
-		doRealUploadFile(contents, dstTmpPath),
-		DoMessageDebug(fmt.Sprintf("... and moving to final destination %s", dst)),
-		DoMoveFile(dstTmpPath, dst),
-	}, ActionList{
-		DoTry(DoDeleteFile(dstTmpPath)),
+	return ActionFunc(func(ctx context.Context) Action {
+		dstTmpPath, err := GetTempFilenameFromContext(ctx)
+		if err != nil {
+			return ActionError(fmt.Sprintf("Could not create temporary file: %s", err))
+		}
+
+		return DoWithCleanup(ActionList{
+			DoMessageInfo(fmt.Sprintf("Uploading to %q", dst)),
+			DoMessageDebug(fmt.Sprintf("Uploading to temporary file %q", dstTmpPath)),
+			doRealUploadFile(contents, dstTmpPath),
+			DoMessageDebug(fmt.Sprintf("... and installing in final destination %s (mode %04o)", dst, mode)),
+			DoInstallFile(dstTmpPath, dst, mode),
+		}, ActionList{
+			DoTry(DoDeleteFile(dstTmpPath)),
+		})
 	})
 }
 
@@ -279,6 +314,12 @@ func DoMoveFile(src, dst string) Action {
 	return DoExec(fmt.Sprintf("sh -c 'mkdir -p %q && mv -f %q %q'", dstDir, src, dst))
 }
 
+// DoInstallFile copies a file to `dst`, creating it with the `mode` permissions
+func DoInstallFile(src, dst string, mode os.FileMode) Action {
+	dstDir := filepath.Dir(dst)
+	return DoExec(fmt.Sprintf("sh -c 'mkdir -p %q && install -m %04o %q %q'", dstDir, mode.Perm(), src, dst))
+}
+
 // DoMoveLocalFile moves a local file
 func DoMoveLocalFile(src, dst string) Action {
 	dstDir := filepath.Dir(dst)
@@ -16,6 +16,7 @@ package ssh
 
 import (
 	"os"
+	"path"
 	"testing"
 )
 
@@ -54,6 +55,22 @@ func TestTempFilenames(t *testing.T) {
 	}
 }
 
+func TestTempFilenamesFromContext(t *testing.T) {
+	ctx := NewTestingContext()
+	getSSHContext(ctx).tmpDir = "/var/tmp/kubeadm-abcdef.d"
+
+	name, err := GetTempFilenameFromContext(ctx)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if path.Dir(name) != "/var/tmp/kubeadm-abcdef.d" {
+		t.Fatalf("Error: %q is not in the temporary directory", name)
+	}
+	if !IsTempFilename(name) {
+		t.Fatalf("Error: %q not detected as temporary file", name)
+	}
+}
+
 func TestCheckLocalFileExists(t *testing.T) {
 	ctx := NewTestingContext()
 
@@ -15,11 +15,16 @@
 package ssh
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net/url"
+	"os"
+	"path/filepath"
 	"strings"
+	"time"
 )
 
 const (
@@ -28,6 +33,14 @@ const (
 
 	// the key in the cache for where we store the remote kubeconfig path
 	remoteKubeconfigPathKey = "remote-kubeconfig"
+
+	// max time we wait for the CRDs to be "Established"
+	crdEstablishedTimeout = 60 * time.Second
+
+	// number of times (and interval) we try to apply a manifest when
+	// it fails (maybe because some CRDs are not established yet)
+	crdApplyRetries  = 5
+	crdApplyInterval = 5 * time.Second
 )
 
 // Manifest represents a manifest, that can be a local file name, a remote URL or inlined
@@ -149,14 +162,14 @@ func doSetupRemoteKubeconfig(kubeconfig string) Action {
 					DoMessageDebug("Using kubeconfig from %q", DefAdminKubeconfig),
 					DoSetInCache(remoteKubeconfigPathKey, DefAdminKubeconfig),
 				},
-				ActionFunc(func(context.Context) Action {
+				ActionFunc(func(ctx context.Context) Action {
 					// delay the kubeconfig check:
 					if kubeconfig == "" {
 						return ActionError("no kubeconfig provided, and no remote admin.conf found")
 					}
 
 					// upload the local kubeconfig to some temporary remote file
-					remoteKubeconfig, err := GetTempFilename()
+					remoteKubeconfig, err := GetTempFilenameFromContext(ctx)
 					if err != nil {
 						return ActionError(fmt.Sprintf("Could not create temporary file: %s", err))
 					}
@@ -175,8 +188,18 @@ func doSetupRemoteKubeconfig(kubeconfig string) Action {
 		})
 }
 
+// doKubectlExec runs a kubectl command: sudo is only needed when using the "admin.conf",
+// as a user-provided kubeconfig is uploaded to a temporary file owned by the current user
+func doKubectlExec(remoteKubeconfig string, command string) Action {
+	if IsTempFilename(remoteKubeconfig) {
+		return DoWithoutSudo(DoExec(command))
+	}
+	return DoExec(command)
+}
+
 // DoRemoteKubectl runs a remote kubectl command in a remote machine
-// it takes care about uploading a valid kubeconfig file if not present in the remote machine
+// it takes care about uploading a valid kubeconfig file if not present in the remote machine.
+// The command is run just once: retries (ie, on transient errors) are left to the caller.
 func DoRemoteKubectl(kubectl string, kubeconfig string, args ...string) Action {
 	argsStr := strings.Join(args, " ")
 
@@ -184,34 +207,149 @@ func DoRemoteKubectl(kubectl string, kubeconfig string, args ...string) Action {
 		doSetupRemoteKubeconfig(kubeconfig),
 		ActionFunc(func(ctx context.Context) Action {
 			// delay the remoteKubeconfig calculation, until the kubeconfig has been uploaded...
-			return DoRetry(
-				Retry{Times: 3},
-				ActionList{
-					DoExec(fmt.Sprintf("%s --kubeconfig=%s %s", kubectl, getKubeconfigFromCache(ctx), argsStr)),
-				})
+			remoteKubeconfig := getKubeconfigFromCache(ctx)
+			return doKubectlExec(remoteKubeconfig, fmt.Sprintf("%s --kubeconfig=%s %s", kubectl, remoteKubeconfig, argsStr))
 		}),
 	}
 }
 
+// DoRemoteKubectlWithOutput runs a remote kubectl command, sending the output to `output`
+func DoRemoteKubectlWithOutput(kubectl string, kubeconfig string, output io.Writer, args ...string) Action {
+	return DoSendingExecOutputToWriter(DoRemoteKubectl(kubectl, kubeconfig, args...), output)
+}
+
+// DoRemoteKubectlWithStdin runs a remote kubectl command, using `stdin` as the standard input
+// (ie, for doing a `kubectl apply -f -`)
+func DoRemoteKubectlWithStdin(kubectl string, kubeconfig string, stdin []byte, args ...string) Action {
+	argsStr := strings.Join(args, " ")
+
+	return ActionFunc(func(ctx context.Context) Action {
+		remoteStdin, err := GetTempFilenameFromContext(ctx)
+		if err != nil {
+			return ActionError(fmt.Sprintf("Could not get a temporary filename: %s", err))
+		}
+
+		return DoWithCleanup(
+			ActionList{
+				doSetupRemoteKubeconfig(kubeconfig),
+				DoUploadBytesToFile(stdin, remoteStdin),
+				ActionFunc(func(ctx context.Context) Action {
+					remoteKubeconfig := getKubeconfigFromCache(ctx)
+					return doKubectlExec(remoteKubeconfig, fmt.Sprintf("%s --kubeconfig=%s %s < %s", kubectl, remoteKubeconfig, argsStr, remoteStdin))
+				}),
+			},
+			ActionList{
+				DoTry(DoDeleteFile(remoteStdin)),
+			})
+	})
+}
+
+// KubectlApplyOptions are some options for `kubectl apply`
+type KubectlApplyOptions struct {
+	// ServerSide enables the server-side apply
+	ServerSide bool
+
+	// FieldManager is the name of the manager used for tracking field ownership (server-side apply only)
+	FieldManager string
+
+	// ForceConflicts forces the changes against conflicts (server-side apply only)
+	ForceConflicts bool
+}
+
+// Args returns the arguments for `kubectl apply`
+func (o KubectlApplyOptions) Args() []string {
+	// we must use "validate=false" because we don'tt kow if the
+	// remote "kubectl" matches the API server deployed
+	args := []string{"apply", "--validate=false"}
+	if o.ServerSide {
+		args = append(args, "--server-side")
+		if o.FieldManager != "" {
+			args = append(args, fmt.Sprintf("--field-manager=%s", o.FieldManager))
+		}
+		if o.ForceConflicts {
+			args = append(args, "--force-conflicts")
+		}
+	}
+	return args
+}
+
+// kubectlMissingCRDErrors are the errors printed by `kubectl apply` for custom
+// resources whose CRDs are not established yet
+var kubectlMissingCRDErrors = []string{
+	"no matches for kind",
+	"ensure CRDs are installed first",
+}
+
+// isMissingCRDOutput returns true if the output of `kubectl apply` shows some
+// custom resource could not be applied because its CRD is not established yet
+func isMissingCRDOutput(output string) bool {
+	for _, e := range kubectlMissingCRDErrors {
+		if strings.Contains(output, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// doRetryOnMissingCRDs runs a `kubectl apply` action (created with `run`, that must send the
+// kubectl output to the writer provided), retrying it only when it fails because some CRDs
+// are not established yet. `onRetry` is run after these failures (ie, for waiting for the CRDs).
+func doRetryOnMissingCRDs(retry Retry, onRetry Action, run func(io.Writer) Action) Action {
+	return ActionFunc(func(ctx context.Context) Action {
+		var attemptOutput bytes.Buffer
+		retry.Retryable = func(Action) bool {
+			return isMissingCRDOutput(attemptOutput.String())
+		}
+
+		return DoRetry(retry, ActionFunc(func(ctx context.Context) Action {
+			attemptOutput.Reset()
+			res := ActionList{run(&attemptOutput)}.Apply(ctx)
+			if IsError(res) && isMissingCRDOutput(attemptOutput.String()) {
+				_ = ActionList{onRetry}.Apply(ctx)
+			}
+			return res
+		})).Apply(ctx)
+	})
+}
+
 // DoRemoteKubectlApply applies some manifests with a remote kubectl
 // manifests can be 1) a local file 2) a URL 3) in a string
 func DoRemoteKubectlApply(kubectl string, kubeconfig string, manifests []Manifest) Action {
+	return DoRemoteKubectlApplyWithOptions(kubectl, kubeconfig, manifests, KubectlApplyOptions{})
+}
+
+// DoRemoteKubectlApplyWithOptions applies some manifests with a remote kubectl,
+// using some specific options for `kubectl apply`
+func DoRemoteKubectlApplyWithOptions(kubectl string, kubeconfig string, manifests []Manifest, opts KubectlApplyOptions) Action {
 	actions := ActionList{}
 	for _, manifest := range manifests {
-		remoteManifest, err := GetTempFilename()
-		if err != nil {
-			return ActionError(fmt.Sprintf("Could not get a temporary filename: %s", err))
+		// apply a manifest, retrying when some custom resources cannot be applied: they can be
+		// in the same manifest set as their CRDs, so they could be applied before the CRDs are
+		// established. In that case we wait for the CRDs and try again.
+		// On success, we also wait for any CRD created, as the next manifests could depend on them.
+		doApply := func(target string) Action {
+			return DoWithSuccess(
+				doRetryOnMissingCRDs(
+					Retry{Times: crdApplyRetries, Interval: crdApplyInterval},
+					DoTry(DoRemoteKubectlWaitCRDsEstablished(kubectl, kubeconfig)),
+					func(w io.Writer) Action {
+						return DoCopyingExecOutputToWriter(DoRemoteKubectl(kubectl, kubeconfig, append(opts.Args(), "-f", target)...), w)
+					}),
+				DoTry(DoRemoteKubectlWaitCRDsEstablished(kubectl, kubeconfig)))
 		}
 
-		uploadAndKubectl := func(uploader Action) ActionFunc {
-			return func(context.Context) Action {
+		uploadAndKubectl := func(uploader func(remoteManifest string) Action) ActionFunc {
+			return func(ctx context.Context) Action {
+				remoteManifest, err := GetTempFilenameFromContext(ctx)
+				if err != nil {
+					return ActionError(fmt.Sprintf("Could not get a temporary filename: %s", err))
+				}
+
 				return DoWithCleanup(
 					ActionList{
-						uploader,
+						uploader(remoteManifest),
 						DoWithException(
-							// we must use "validate=false" because we don'tt kow if the
-							// remote "kubectl" matches the API server deployed
-							DoRemoteKubectl(kubectl, kubeconfig, "apply", "--validate=false", "-f", remoteManifest),
+							doApply(remoteManifest),
 							DoExec(fmt.Sprintf("echo 'Failed to apply kubernetes manifest:' && cat %s", remoteManifest))),
 					},
 					ActionList{
@@ -222,19 +360,66 @@ func DoRemoteKubectlApply(kubectl string, kubeconfig string, manifests []Manifes
 
 		switch {
 		case manifest.Inline != "":
+			inline := []byte(manifest.Inline)
 			actions = append(actions,
-				uploadAndKubectl(DoUploadBytesToFile([]byte(manifest.Inline), remoteManifest)))
+				uploadAndKubectl(func(remoteManifest string) Action {
+					return DoUploadBytesToFile(inline, remoteManifest)
+				}))
 
 		case manifest.Path != "":
+			path := manifest.Path
 			actions = append(actions,
-				uploadAndKubectl(DoUploadFileToFile(manifest.Path, remoteManifest)))
+				uploadAndKubectl(func(remoteManifest string) Action {
+					return DoUploadFileToFile(path, remoteManifest)
+				}))
 
 		case manifest.URL != "":
 			// it is an URL: just run the `kubectl apply`
 			actions = append(actions,
-				DoRemoteKubectl(kubectl, kubeconfig, "apply", "--validate=false", "-f", manifest.URL))
+				doApply(manifest.URL))
 		}
 	}
 
 	return actions
 }
+
+// IsKustomizeDir returns true if a local directory contains a kustomization file
+func IsKustomizeDir(dir string) bool {
+	for _, name := range []string{"kustomization.yaml", "kustomization.yml", "Kustomization"} {
+		if info, err := os.Stat(filepath.Join(dir, name)); err == nil && !info.IsDir() {
+			return true
+		}
+	}
+	return false
+}
+
+// DoRemoteKubectlApplyKustomize uploads a local kustomize directory and
+// applies it with a remote `kubectl apply -k`
+func DoRemoteKubectlApplyKustomize(kubectl string, kubeconfig string, dir string, opts KubectlApplyOptions) Action {
+	if !IsKustomizeDir(dir) {
+		return ActionError(fmt.Sprintf("%q does not look like a kustomize directory: no kustomization.yaml found", dir))
+	}
+
+	return ActionFunc(func(ctx context.Context) Action {
+		remoteDir, err := GetTempFilenameFromContext(ctx)
+		if err != nil {
+			return ActionError(fmt.Sprintf("Could not get a temporary filename: %s", err))
+		}
+
+		return DoWithCleanup(
+			ActionList{
+				DoUploadDirToDir(dir, remoteDir),
+				DoRemoteKubectl(kubectl, kubeconfig, append(opts.Args(), "-k", remoteDir)...),
+			},
+			ActionList{
+				DoTry(DoDeleteDir(remoteDir)),
+			})
+	})
+}
+
+// DoRemoteKubectlWaitCRDsEstablished waits until all the CRDs reach the "Established" condition
+func DoRemoteKubectlWaitCRDsEstablished(kubectl string, kubeconfig string) Action {
+	timeout := fmt.Sprintf("--timeout=%ds", crdEstablishedTimeout/time.Second)
+	return DoSendingExecOutputToDevNull(
+		DoRemoteKubectl(kubectl, kubeconfig, "wait", "--for=condition=established", timeout, "crd", "--all"))
+}
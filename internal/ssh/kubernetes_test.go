@@ -0,0 +1,77 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestIsMissingCRDOutput(t *testing.T) {
+	testsCases := []struct {
+		output   string
+		expected bool
+	}{
+		{`error: unable to recognize "manifest.yaml": no matches for kind "Certificate" in version "cert-manager.io/v1"`, true},
+		{`error: resource mapping not found for name: "ca" namespace: "" from "manifest.yaml": no matches for kind "Issuer" in version "cert-manager.io/v1"
+ensure CRDs are installed first`, true},
+		{`The Deployment "web" is invalid: spec.template.metadata.labels: Invalid value`, false},
+		{"", false},
+	}
+
+	for _, testCase := range testsCases {
+		if res := isMissingCRDOutput(testCase.output); res != testCase.expected {
+			t.Fatalf("Error: unexpected result for %q: %t", testCase.output, res)
+		}
+	}
+}
+
+func TestDoRetryOnMissingCRDs(t *testing.T) {
+	testsCases := []struct {
+		output          string
+		expectedApplies int
+		expectedWaits   int
+	}{
+		{`error: unable to recognize "manifest.yaml": no matches for kind "Certificate" in version "cert-manager.io/v1"`, 3, 3},
+		{`The Deployment "web" is invalid: spec.template.metadata.labels: Invalid value`, 1, 0},
+	}
+
+	for _, testCase := range testsCases {
+		applies, waits := 0, 0
+		action := doRetryOnMissingCRDs(
+			Retry{Times: 3, Interval: 10 * time.Millisecond},
+			ActionFunc(func(context.Context) Action {
+				waits++
+				return nil
+			}),
+			func(w io.Writer) Action {
+				return ActionFunc(func(context.Context) Action {
+					applies++
+					_, _ = w.Write([]byte(testCase.output))
+					return ActionError("kubectl apply failed")
+				})
+			})
+
+		res := action.Apply(NewTestingContext())
+		if !IsError(res) {
+			t.Fatalf("Error: no error detected for %q", testCase.output)
+		}
+		if applies != testCase.expectedApplies || waits != testCase.expectedWaits {
+			t.Fatalf("Error: unexpected number of applies/waits for %q: %d/%d", testCase.output, applies, waits)
+		}
+	}
+}
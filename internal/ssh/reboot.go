@@ -0,0 +1,135 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	// DefRebootTimeout is the default max time we wait for a machine to come back after a reboot
+	DefRebootTimeout = 5 * time.Minute
+
+	// file used for signaling that a reboot is needed: it is not in a temporary
+	// directory, so it survives a failed provisioning (and a later run can reboot)
+	rebootNeededFile = "/var/lib/terraform-provider-kubeadm/reboot-needed"
+
+	// file created by some package managers when a reboot is needed
+	rebootRequiredFile = "/var/run/reboot-required"
+
+	// command for getting a unique ID for the current boot
+	bootIDCmd = "cat /proc/sys/kernel/random/boot_id"
+
+	// command for rebooting the machine: it is run in background (with a small delay)
+	// so we can return before the connection is dropped
+	rebootCmd = "nohup sh -c 'sleep 2 && reboot' >/dev/null 2>&1 &"
+)
+
+// interval between reconnection attempts
+var rebootReconnectInterval = 10 * time.Second
+
+// DoSignalRebootNeeded signals that a reboot will be necessary, so a
+// subsequent `DoRebootIfNeeded` will reboot the machine
+func DoSignalRebootNeeded() Action {
+	return ActionList{
+		DoMkdir(path.Dir(rebootNeededFile)),
+		DoExec(fmt.Sprintf("touch %s", rebootNeededFile)),
+	}
+}
+
+// CheckRebootNeeded checks if some previous action has signaled that a reboot is needed
+// (or if the package manager requires it)
+func CheckRebootNeeded() CheckerFunc {
+	return CheckOr(
+		CheckFileExists(rebootNeededFile),
+		CheckFileExists(rebootRequiredFile))
+}
+
+// DoRebootIfNeeded reboots the machine (and waits for it) iff a reboot is needed
+func DoRebootIfNeeded(timeout time.Duration) Action {
+	return DoIf(
+		CheckRebootNeeded(),
+		DoRebootAndWait(timeout))
+}
+
+// getBootID returns a unique ID for the current boot
+func getBootID(ctx context.Context) (string, error) {
+	var buf bytes.Buffer
+	if res := DoSendingExecOutputToWriter(DoExec(bootIDCmd), &buf).Apply(ctx); IsError(res) {
+		return "", fmt.Errorf("%s", res.Error())
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// DoRebootAndWait reboots the machine and waits until we can connect again,
+// re-establishing the communicator.
+// The cache is flushed after the reboot, as things could have changed in the machine,
+// and the private temporary directory (that is usually wiped in a reboot) is recreated.
+func DoRebootAndWait(timeout time.Duration) Action {
+	if timeout <= 0 {
+		timeout = DefRebootTimeout
+	}
+
+	return ActionFunc(func(ctx context.Context) Action {
+		comm := GetCommFromContext(ctx)
+
+		prevBootID, err := getBootID(ctx)
+		if err != nil {
+			return ActionError(fmt.Sprintf("could not get the current boot ID: %s", err))
+		}
+		Debug("current boot ID: %q", prevBootID)
+
+		// note: the connection can be dropped while running the command, so we ignore any error
+		_ = ActionList{
+			DoMessageInfo("Rebooting the machine..."),
+			DoTry(DoExec(rebootCmd)),
+		}.Apply(ctx)
+		_ = comm.Disconnect()
+
+		deadline := time.Now().Add(timeout)
+		for time.Now().Before(deadline) {
+			if !sleepWithContext(ctx, rebootReconnectInterval) {
+				return ActionError("interrupted while waiting for the machine to reboot")
+			}
+
+			if err := comm.Connect(nil); err != nil {
+				Debug("could not reconnect (will retry): %s", err)
+				continue
+			}
+
+			// make sure we are not connecting to the machine before it went down
+			bootID, err := getBootID(ctx)
+			if err != nil || bootID == prevBootID {
+				Debug("machine has not been rebooted yet (boot ID: %q)", bootID)
+				_ = comm.Disconnect()
+				continue
+			}
+
+			return ActionList{
+				doRecreateTmpDir(),
+				DoFlushCache(),
+				DoTry(DoDeleteFile(rebootNeededFile)),
+				DoMessageInfo("Machine is back after the reboot"),
+			}
+		}
+
+		return ActionError(fmt.Sprintf("timeout after %s waiting for the machine to come back after a reboot", timeout))
+	})
+}
@@ -0,0 +1,87 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform/communicator/remote"
+	"github.com/hashicorp/terraform/terraform"
+)
+
+// rebootTestCommunicator records the commands run, returning a new boot ID after reconnecting
+type rebootTestCommunicator struct {
+	DummyCommunicator
+
+	commands *[]string
+	connects *int
+}
+
+func (c rebootTestCommunicator) Connect(terraform.UIOutput) error {
+	*c.connects++
+	return nil
+}
+
+func (c rebootTestCommunicator) Start(cmd *remote.Cmd) error {
+	cmd.Init()
+	*c.commands = append(*c.commands, cmd.Command)
+	if strings.Contains(cmd.Command, bootIDCmd) {
+		_, _ = cmd.Stdout.Write([]byte(fmt.Sprintf("boot-%d\n", *c.connects)))
+	}
+	cmd.SetExitStatus(0, nil)
+	return nil
+}
+
+func TestDoRebootAndWaitRecreatesTmpDir(t *testing.T) {
+	defer func(interval time.Duration) { rebootReconnectInterval = interval }(rebootReconnectInterval)
+	rebootReconnectInterval = 10 * time.Millisecond
+
+	commands, connects := []string{}, 0
+	ctx := NewTestingContextWithCommunicator(rebootTestCommunicator{commands: &commands, connects: &connects})
+
+	res := ActionList{
+		DoSetupTmpDir("/tmp"),
+		DoSetUseSudo(true),
+		DoRebootAndWait(time.Second),
+	}.Apply(ctx)
+	if IsError(res) {
+		t.Fatalf("Error: %s", res.Error())
+	}
+	if connects != 1 {
+		t.Fatalf("Error: expected 1 reconnection, got %d", connects)
+	}
+
+	tmpDir := GetTmpDirFromContext(ctx)
+	mkdir := fmt.Sprintf("mkdir -p -m 0700 %q", tmpDir)
+	rebooted, recreated := false, false
+	for _, command := range commands {
+		if strings.Contains(command, "reboot") {
+			rebooted = true
+			continue
+		}
+		if rebooted && strings.Contains(command, mkdir) {
+			if strings.HasPrefix(command, "sudo") {
+				t.Fatalf("Error: the temporary directory has been recreated with sudo: %q", command)
+			}
+			recreated = true
+		}
+	}
+	if !recreated {
+		t.Fatalf("Error: the temporary directory %q has not been recreated after the reboot: %v", tmpDir, commands)
+	}
+}
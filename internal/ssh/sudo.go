@@ -0,0 +1,93 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"strings"
+)
+
+const (
+	// SudoAuto uses sudo unless the connection is already root
+	SudoAuto = "auto"
+
+	// SudoAlways always uses sudo
+	SudoAlways = "always"
+
+	// SudoNever never uses sudo
+	SudoNever = "never"
+
+	// command for getting the current user ID
+	userIDCmd = "id -u"
+
+	// command for checking that sudo is installed
+	sudoExistsCmd = "command -v sudo"
+)
+
+// SudoModes is the list of valid sudo modes
+var SudoModes = []string{SudoAuto, SudoAlways, SudoNever}
+
+// DoWithSudo runs some actions with (or without) sudo, restoring the previous
+// value once the actions have been applied
+func DoWithSudo(useSudo bool, actions ...Action) Action {
+	return ActionFunc(func(ctx context.Context) Action {
+		sshc := getSSHContext(ctx)
+		prev := sshc.useSudo
+		sshc.useSudo = useSudo
+		defer func() { sshc.useSudo = prev }()
+
+		return ActionList(actions).Apply(ctx)
+	})
+}
+
+// DoWithoutSudo runs some actions that do not need any privilege without sudo
+func DoWithoutSudo(actions ...Action) Action {
+	return DoWithSudo(false, actions...)
+}
+
+// DoSetUseSudo sets the "should we use sudo?" value for all the subsequent actions
+func DoSetUseSudo(useSudo bool) Action {
+	return ActionFunc(func(ctx context.Context) Action {
+		getSSHContext(ctx).useSudo = useSudo
+		return nil
+	})
+}
+
+// DoDetectSudo detects if we must use sudo: it is not used when we are
+// already connected as root or when sudo is not installed.
+func DoDetectSudo() Action {
+	return ActionFunc(func(ctx context.Context) Action {
+		var buf bytes.Buffer
+		res := DoWithoutSudo(DoSendingExecOutputToWriter(DoExec(userIDCmd), &buf)).Apply(ctx)
+		if IsError(res) {
+			return DoMessageWarn("could not get the current user ID: %s", res.Error())
+		}
+		if strings.TrimSpace(buf.String()) == "0" {
+			return ActionList{
+				DoMessageDebug("connected as root: sudo will not be used"),
+				DoSetUseSudo(false),
+			}
+		}
+
+		return DoIfElse(
+			CheckAction(DoWithoutSudo(DoSendingExecOutputToDevNull(DoExec(sudoExistsCmd)))),
+			DoSetUseSudo(true),
+			ActionList{
+				DoMessageWarn("sudo is not installed in the remote machine: running commands as the current user"),
+				DoSetUseSudo(false),
+			})
+	})
+}
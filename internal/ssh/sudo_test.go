@@ -0,0 +1,42 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ssh
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDoWithSudo(t *testing.T) {
+	ctx := NewTestingContext()
+
+	usedSudo := true
+	actions := ActionList{
+		DoSetUseSudo(true),
+		DoWithoutSudo(ActionFunc(func(ctx context.Context) Action {
+			usedSudo = GetUseSudoFromContext(ctx)
+			return nil
+		})),
+	}
+	if res := actions.Apply(ctx); IsError(res) {
+		t.Fatalf("Error: %s", res.Error())
+	}
+	if usedSudo {
+		t.Fatalf("Error: sudo was used inside DoWithoutSudo()")
+	}
+	if !GetUseSudoFromContext(ctx) {
+		t.Fatalf("Error: sudo was not restored after DoWithoutSudo()")
+	}
+}
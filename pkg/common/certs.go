@@ -15,7 +15,11 @@
 package common
 
 import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"io/ioutil"
@@ -273,3 +277,18 @@ func CreateCerts(d *schema.ResourceData, initCfg *kubeadmapi.InitConfiguration)
 
 	return m, nil
 }
+
+// CACertHash returns the hash of the public key of a CA certificate (in PEM format),
+// as used in the `--discovery-token-ca-cert-hash` of `kubeadm join` (ie, "sha256:<hex>")
+func CACertHash(caCert []byte) (string, error) {
+	block, _ := pem.Decode(caCert)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return "", fmt.Errorf("no PEM certificate found in the CA certificate")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return "", fmt.Errorf("could not parse the CA certificate: %s", err)
+	}
+	hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return "sha256:" + hex.EncodeToString(hash[:]), nil
+}
@@ -15,8 +15,18 @@
 package common
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
 	"fmt"
+	"math/big"
 	"testing"
+	"time"
 
 	"github.com/davecgh/go-spew/spew"
 )
@@ -63,3 +73,37 @@ func TestCertsSerialization(t *testing.T) {
 		t.Fatalf("Error: etcd_crt does not match")
 	}
 }
+
+func TestCACertHash(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "kubernetes"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	expectedSum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	expected := "sha256:" + hex.EncodeToString(expectedSum[:])
+
+	hash, err := CACertHash(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if hash != expected {
+		t.Fatalf("Error: unexpected hash %q (expected %q)", hash, expected)
+	}
+
+	if _, err := CACertHash([]byte("-- BEGIN PUBLIC KEY ---\n SOME-CERT ...")); err == nil {
+		t.Fatalf("Error: no error for an invalid CA certificate")
+	}
+}
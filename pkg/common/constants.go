@@ -33,6 +33,12 @@ const (
 
 	DefRuntimeEngine = "docker"
 
+	// DefContainerLogMaxSize is the default max size of a container log file before it is rotated
+	DefContainerLogMaxSize = "10Mi"
+
+	// DefContainerLogMaxFiles is the default max number of log files kept for a container
+	DefContainerLogMaxFiles = 5
+
 	DefKubeadmInitConfPath = "/etc/kubernetes/kubeadm-init.conf"
 
 	DefKubeadmJoinConfPath = "/etc/kubernetes/kubeadm-join.conf"
@@ -47,6 +53,9 @@ const (
 
 	DefFlannelImageVersion = "v0.11.0"
 
+	// DefMultusImageVersion is the default version of the Multus image
+	DefMultusImageVersion = "v3.9.3"
+
 	// Full path where we should upload the kubelet sysconfig file
 	DefKubeletSysconfigPath = "/etc/sysconfig/kubelet"
 
@@ -64,14 +73,130 @@ const (
 	// manifest for loading the dashboard
 	DefDashboardManifest = "https://raw.githubusercontent.com/kubernetes/dashboard/v1.10.1/src/deploy/recommended/kubernetes-dashboard.yaml"
 
+	// DefLocalPathManifest is the manifest for the local-path-provisioner storage driver
+	DefLocalPathManifest = "https://raw.githubusercontent.com/rancher/local-path-provisioner/v0.0.24/deploy/local-path-storage.yaml"
+
+	// DefLocalPathStorageClass is the StorageClass created by the local-path-provisioner
+	DefLocalPathStorageClass = "local-path"
+
+	// DefLocalPathNamespace is the namespace where the local-path-provisioner runs
+	DefLocalPathNamespace = "local-path-storage"
+
 	// kubeadm executable in the machines (we assume it is in some standard path)
 	DefKubeadmPath = "kubeadm"
 
 	// kubectl executable in the machines (we assume it is in some standard path)
 	DefKubectlPath = "kubectl"
 
+	// crictl executable in the machines (we assume it is in some standard path)
+	DefCrictlPath = "crictl"
+
+	// cosign executable in the machines (we assume it is in some standard path)
+	DefCosignPath = "cosign"
+
 	// resolv.conf for pods when upstream servers are provided
 	DefResolvUpstreamConf = "/etc/resolv.conf-kubeadm"
+
+	// containerd configuration file
+	DefContainerdConfigPath = "/etc/containerd/config.toml"
+
+	// directory where containerd looks for the registries configuration
+	DefContainerdCertsDir = "/etc/containerd/certs.d"
+
+	// CRI-O drop-in configuration with the sandbox (pause) image
+	DefCrioSandboxImageConfPath = "/etc/crio/crio.conf.d/10-kubeadm-sandbox-image.conf"
+
+	// DefDiscoveryKubeconfigPath is the discovery kubeconfig used for joining with a discovery file
+	DefDiscoveryKubeconfigPath = "/etc/kubernetes/discovery.conf"
+
+	// DefDNSCheckImage is the image used for checking the cluster DNS
+	DefDNSCheckImage = "busybox:1.28"
+
+	// DefDNSCheckExternalName is the default external name resolved when checking the cluster DNS
+	DefDNSCheckExternalName = "kubernetes.io"
+
+	// DefQuarantineTaintKey is the default key of the taint used for quarantining new workers until they are ready
+	DefQuarantineTaintKey = "kubeadm.terraform.io/not-ready"
+
+	// DefInitRetries is the default number of times a failed "kubeadm init" is retried
+	DefInitRetries = 2
+
+	// DefKubeadmBackupsDir is the directory where the kubeadm configs applied are archived
+	DefKubeadmBackupsDir = "/etc/kubernetes/backups"
+
+	// DefRemoteTmpDir is the default remote directory for temporary files
+	DefRemoteTmpDir = "/tmp"
+
+	// DefAuditDir is the directory for the API server audit configuration
+	DefAuditDir = "/etc/kubernetes/audit"
+
+	// DefAuditPolicyPath is the API server audit policy file
+	DefAuditPolicyPath = DefAuditDir + "/policy.yaml"
+
+	// DefAuditWebhookConfigPath is the API server audit webhook (kubeconfig) file
+	DefAuditWebhookConfigPath = DefAuditDir + "/webhook.kubeconfig"
+
+	// DefAuditWebhookMode is the default mode for the audit webhook backend
+	DefAuditWebhookMode = "batch"
+
+	// DefAuditPolicy is the default audit policy: log the metadata of all the requests
+	DefAuditPolicy = `apiVersion: audit.k8s.io/v1
+kind: Policy
+rules:
+- level: Metadata
+`
+
+	// DefEgressSelectorDir is the directory for the API server egress selector configuration
+	DefEgressSelectorDir = "/etc/kubernetes/egress"
+
+	// DefEgressSelectorConfigPath is the API server egress selector configuration file
+	DefEgressSelectorConfigPath = DefEgressSelectorDir + "/egress-selector-configuration.yaml"
+
+	// DefKubeadmPatchesDir is the directory with the patches for the static pods created by kubeadm
+	DefKubeadmPatchesDir = "/etc/kubernetes/patches"
+
+	// DefEtcdPatchPath is the patch for the local etcd static pod
+	DefEtcdPatchPath = DefKubeadmPatchesDir + "/etcd+strategic.json"
+
+	// DefSchedulerConfigDir is the directory for the scheduler configuration
+	DefSchedulerConfigDir = "/etc/kubernetes/scheduler"
+
+	// DefSchedulerConfigPath is the scheduler configuration file
+	DefSchedulerConfigPath = DefSchedulerConfigDir + "/scheduler-config.yaml"
+
+	// DefSchedulerKubeconfigPath is the kubeconfig created by kubeadm for the scheduler
+	DefSchedulerKubeconfigPath = "/etc/kubernetes/scheduler.conf"
+
+	// DefKonnectivityDir is the directory for the konnectivity server socket
+	DefKonnectivityDir = "/etc/kubernetes/konnectivity-server"
+
+	// DefKonnectivitySocket is the socket used by the API server for talking to the konnectivity server
+	DefKonnectivitySocket = DefKonnectivityDir + "/konnectivity-server.socket"
+
+	// DefKonnectivityKubeconfigPath is the kubeconfig used by the konnectivity server
+	DefKonnectivityKubeconfigPath = "/etc/kubernetes/konnectivity-server.conf"
+
+	// DefKonnectivityManifestPath is the static pod manifest for the konnectivity server
+	DefKonnectivityManifestPath = "/etc/kubernetes/manifests/konnectivity-server.yaml"
+
+	// DefKonnectivityImageVersion is the default version of the konnectivity images
+	DefKonnectivityImageVersion = "v0.0.37"
+
+	// DefKonnectivityAgentPort is the default port where the konnectivity server listens for agents
+	DefKonnectivityAgentPort = 8132
+
+	// DefKonnectivityEgressSelectorConfig is the egress selector configuration used with the
+	// konnectivity server: the traffic to the cluster goes through the konnectivity socket
+	DefKonnectivityEgressSelectorConfig = `apiVersion: apiserver.k8s.io/v1beta1
+kind: EgressSelectorConfiguration
+egressSelections:
+- name: cluster
+  connection:
+    proxyProtocol: GRPC
+    transport:
+      uds:
+        udsName: ` + DefKonnectivitySocket + `
+`
 )
 
 var (
@@ -83,6 +208,40 @@ var (
 
 	// CNIPluginsList gets the list of supported CNI plugins (will be filled by the init())
 	CNIPluginsList = []string{}
+
+	// CNIPluginsNetworkPolicies are some well-known CNI plugins, and if they enforce NetworkPolicies
+	CNIPluginsNetworkPolicies = map[string]bool{
+		"flannel": false,
+		"weave":   true,
+		"calico":  true,
+		"cilium":  true,
+	}
+)
+
+// StorageDriver is a pre-defined storage driver
+type StorageDriver struct {
+	// Manifest is the manifest for the driver
+	Manifest string
+
+	// Class is the StorageClass created by the driver
+	Class string
+
+	// Namespace is the namespace where the driver pods run
+	Namespace string
+}
+
+var (
+	// StorageDrivers is the map of pre-defined storage drivers
+	StorageDrivers = map[string]StorageDriver{
+		"local-path": {
+			Manifest:  DefLocalPathManifest,
+			Class:     DefLocalPathStorageClass,
+			Namespace: DefLocalPathNamespace,
+		},
+	}
+
+	// StorageDriversList gets the list of supported storage drivers (will be filled by the init())
+	StorageDriversList = []string{}
 )
 
 var (
@@ -93,6 +252,11 @@ var (
 		"containerd": "/var/run/containerd/containerd.sock",
 	}
 
+	// DefAdmissionPlugins are the admission plugins enabled by default in the API server
+	DefAdmissionPlugins = []string{
+		"NodeRestriction",
+	}
+
 	DefIgnorePreflightChecks = []string{
 		"NumCPU",
 		"FileContent--proc-sys-net-bridge-bridge-nf-call-iptables",
@@ -136,4 +300,7 @@ func init() {
 	for k := range CNIPluginsManifestsTemplates {
 		CNIPluginsList = append(CNIPluginsList, k)
 	}
+	for k := range StorageDrivers {
+		StorageDriversList = append(StorageDriversList, k)
+	}
 }
@@ -0,0 +1,103 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"path"
+
+	"github.com/hashicorp/terraform/helper/schema"
+)
+
+const (
+	// the upstream server for the "docker.io" registry
+	dockerHubServer = "https://registry-1.docker.io"
+)
+
+// RegistryMirror is the mirrors configuration for a registry.
+// The credentials are provided in the provisioner, so they are never
+// serialized in the provisioner config.
+type RegistryMirror struct {
+	Registry  string   `json:"registry"`
+	Endpoints []string `json:"endpoints"`
+	CACert    string   `json:"ca_crt,omitempty"`
+	Username  string   `json:"-"`
+	Password  string   `json:"-"`
+}
+
+// Dir returns the directory (in the containerd "certs.d") for this registry
+func (m RegistryMirror) Dir() string {
+	return path.Join(DefContainerdCertsDir, m.Registry)
+}
+
+// CACertPath returns the path for the CA certificate for this registry
+func (m RegistryMirror) CACertPath() string {
+	return path.Join(m.Dir(), "ca.crt")
+}
+
+// HostsTOML returns the containerd "hosts.toml" for this registry
+func (m RegistryMirror) HostsTOML() string {
+	server := "https://" + m.Registry
+	if m.Registry == "docker.io" {
+		server = dockerHubServer
+	}
+
+	buf := bytes.Buffer{}
+	buf.WriteString(fmt.Sprintf("server = %q\n", server))
+	for _, endpoint := range m.Endpoints {
+		buf.WriteString(fmt.Sprintf("\n[host.%q]\n", endpoint))
+		buf.WriteString("  capabilities = [\"pull\", \"resolve\"]\n")
+		if m.CACert != "" {
+			buf.WriteString(fmt.Sprintf("  ca = %q\n", m.CACertPath()))
+		}
+		if m.Username != "" {
+			auth := base64.StdEncoding.EncodeToString([]byte(m.Username + ":" + m.Password))
+			buf.WriteString(fmt.Sprintf("  [host.%q.header]\n", endpoint))
+			buf.WriteString(fmt.Sprintf("    authorization = %q\n", "Basic "+auth))
+		}
+	}
+	return buf.String()
+}
+
+// RegistryMirrorsToString serializes a list of mirrors, so it can be stored in the provisioner config
+func RegistryMirrorsToString(mirrors []RegistryMirror) (string, error) {
+	data, err := json.Marshal(mirrors)
+	if err != nil {
+		return "", err
+	}
+	return ToTerraformSafeString(data), nil
+}
+
+// RegistryMirrorsFromResourceData gets the list of registry mirrors from the provisioner config
+func RegistryMirrorsFromResourceData(d *schema.ResourceData) ([]RegistryMirror, error) {
+	mirrors := []RegistryMirror{}
+
+	mirrorsOpt, ok := d.GetOk("config.registry_mirrors")
+	if !ok {
+		return mirrors, nil
+	}
+
+	data, err := FromTerraformSafeString(mirrorsOpt.(string))
+	if err != nil {
+		return nil, fmt.Errorf("could not decode the registry mirrors: %s", err)
+	}
+	if err := json.Unmarshal(data, &mirrors); err != nil {
+		return nil, fmt.Errorf("could not parse the registry mirrors: %s", err)
+	}
+	return mirrors, nil
+}
@@ -0,0 +1,80 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRegistryMirrorHostsTOML(t *testing.T) {
+	testsCases := []struct {
+		mirror   RegistryMirror
+		expected string
+	}{
+		{
+			RegistryMirror{
+				Registry:  "docker.io",
+				Endpoints: []string{"https://mirror.local"},
+			},
+			`server = "https://registry-1.docker.io"
+
+[host."https://mirror.local"]
+  capabilities = ["pull", "resolve"]
+`,
+		},
+		{
+			RegistryMirror{
+				Registry:  "quay.io",
+				Endpoints: []string{"https://mirror.local:5000"},
+				CACert:    "some-cert",
+				Username:  "user",
+				Password:  "pass",
+			},
+			`server = "https://quay.io"
+
+[host."https://mirror.local:5000"]
+  capabilities = ["pull", "resolve"]
+  ca = "/etc/containerd/certs.d/quay.io/ca.crt"
+  [host."https://mirror.local:5000".header]
+    authorization = "Basic dXNlcjpwYXNz"
+`,
+		},
+	}
+
+	for _, testCase := range testsCases {
+		out := testCase.mirror.HostsTOML()
+		if out != testCase.expected {
+			t.Fatalf("Error: expected output does not match:\n%s\n!=\n%s", out, testCase.expected)
+		}
+	}
+}
+
+func TestRegistryMirrorsToStringWithoutCredentials(t *testing.T) {
+	mirrors := []RegistryMirror{
+		{Registry: "quay.io", Endpoints: []string{"https://mirror.local:5000"}, Username: "user", Password: "pass"},
+	}
+	s, err := RegistryMirrorsToString(mirrors)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	data, err := FromTerraformSafeString(s)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if strings.Contains(string(data), "user") || strings.Contains(string(data), "pass") {
+		t.Fatalf("Error: the credentials have been serialized: %s", string(data))
+	}
+}
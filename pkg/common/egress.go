@@ -0,0 +1,92 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+var (
+	// egressSelectorKindRegexp matches the kind of an egress selector configuration
+	egressSelectorKindRegexp = regexp.MustCompile(`(?m)^kind:[ \t]*["']?EgressSelectorConfiguration["']?[ \t]*$`)
+
+	// egressSelectorAPIVersionRegexp matches the (top-level) apiVersion of an egress selector configuration
+	egressSelectorAPIVersionRegexp = regexp.MustCompile(`(?m)^apiVersion:[ \t]*["']?([^"'\s]+)["']?[ \t]*$`)
+
+	// egressSelectionNameRegexp matches the names of the egress selections
+	egressSelectionNameRegexp = regexp.MustCompile(`(?m)^[ \t]*-[ \t]*name:[ \t]*["']?([A-Za-z]+)["']?[ \t]*$`)
+
+	// egressProxyProtocolRegexp matches the proxy protocols used in the egress selections
+	egressProxyProtocolRegexp = regexp.MustCompile(`(?m)^[ \t]*proxyProtocol:[ \t]*["']?([A-Za-z]+)["']?[ \t]*$`)
+)
+
+var (
+	// EgressSelectorAPIVersions are the apiVersions supported for the egress selector configuration
+	EgressSelectorAPIVersions = []string{
+		"apiserver.k8s.io/v1alpha1",
+		"apiserver.k8s.io/v1beta1",
+		"apiserver.k8s.io/v1",
+	}
+
+	// EgressSelectionNames are the valid names of egress selections
+	EgressSelectionNames = []string{"cluster", "controlplane", "master", "etcd"}
+
+	// EgressProxyProtocols are the valid protocols for egress selections
+	EgressProxyProtocols = []string{"Direct", "HTTPConnect", "GRPC"}
+)
+
+// CheckEgressSelectorConfig checks an API server egress selector configuration
+// (an `EgressSelectorConfiguration`), returning an error on invalid configurations
+func CheckEgressSelectorConfig(config string) error {
+	if !egressSelectorKindRegexp.MatchString(config) {
+		return errors.New("no 'kind: EgressSelectorConfiguration' found")
+	}
+
+	m := egressSelectorAPIVersionRegexp.FindStringSubmatch(config)
+	if m == nil {
+		return errors.New("no 'apiVersion' found")
+	}
+	if !StringSliceContains(EgressSelectorAPIVersions, m[1]) {
+		return fmt.Errorf("unsupported apiVersion %q (supported: %v)", m[1], EgressSelectorAPIVersions)
+	}
+
+	names := egressSelectionNameRegexp.FindAllStringSubmatch(config, -1)
+	if len(names) == 0 {
+		return errors.New("no 'egressSelections' found")
+	}
+	seen := map[string]bool{}
+	for _, name := range names {
+		if !StringSliceContains(EgressSelectionNames, name[1]) {
+			return fmt.Errorf("unknown egress selection %q (valid: %v)", name[1], EgressSelectionNames)
+		}
+		if seen[name[1]] {
+			return fmt.Errorf("duplicate egress selection %q", name[1])
+		}
+		seen[name[1]] = true
+	}
+
+	protocols := egressProxyProtocolRegexp.FindAllStringSubmatch(config, -1)
+	if len(protocols) != len(names) {
+		return errors.New("all the egress selections must have a 'proxyProtocol'")
+	}
+	for _, protocol := range protocols {
+		if !StringSliceContains(EgressProxyProtocols, protocol[1]) {
+			return fmt.Errorf("unknown proxy protocol %q (valid: %v)", protocol[1], EgressProxyProtocols)
+		}
+	}
+	return nil
+}
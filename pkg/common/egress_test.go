@@ -0,0 +1,77 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"testing"
+)
+
+func TestCheckEgressSelectorConfig(t *testing.T) {
+	testCases := []struct {
+		config      string
+		expectedErr bool
+	}{
+		{DefKonnectivityEgressSelectorConfig, false},
+		{
+			`apiVersion: apiserver.k8s.io/v1beta1
+kind: EgressSelectorConfiguration
+egressSelections:
+- name: cluster
+  connection:
+    proxyProtocol: HTTPConnect
+    transport:
+      tcp:
+        url: https://konnectivity.local:8131
+- name: controlplane
+  connection:
+    proxyProtocol: Direct
+`, false,
+		},
+		{
+			// unknown egress selection
+			`apiVersion: apiserver.k8s.io/v1beta1
+kind: EgressSelectorConfiguration
+egressSelections:
+- name: workers
+  connection:
+    proxyProtocol: GRPC
+`, true,
+		},
+		{
+			// unknown proxy protocol
+			`apiVersion: apiserver.k8s.io/v1beta1
+kind: EgressSelectorConfiguration
+egressSelections:
+- name: cluster
+  connection:
+    proxyProtocol: SOCKS
+`, true,
+		},
+		{
+			// wrong kind
+			`apiVersion: audit.k8s.io/v1
+kind: Policy
+rules:
+- level: Metadata
+`, true,
+		},
+		{"", true},
+	}
+	for _, tc := range testCases {
+		if err := CheckEgressSelectorConfig(tc.config); (err != nil) != tc.expectedErr {
+			t.Fatalf("Error: unexpected result for %q: %v", tc.config, err)
+		}
+	}
+}
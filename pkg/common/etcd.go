@@ -0,0 +1,54 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"encoding/json"
+
+	kubeadmapi "k8s.io/kubernetes/cmd/kubeadm/app/apis/kubeadm"
+)
+
+// EtcdVolumesPatch returns a kubeadm strategic merge patch for the local etcd
+// static pod that mounts some host paths (kubeadm does not support `extraVolumes`
+// for etcd, so they are added with a patch)
+func EtcdVolumesPatch(volumes []kubeadmapi.HostPathMount) ([]byte, error) {
+	mounts := []map[string]interface{}{}
+	podVolumes := []map[string]interface{}{}
+	for _, volume := range volumes {
+		mounts = append(mounts, map[string]interface{}{
+			"name":      volume.Name,
+			"mountPath": volume.MountPath,
+			"readOnly":  volume.ReadOnly,
+		})
+		hostPath := map[string]interface{}{"path": volume.HostPath}
+		if volume.PathType != "" {
+			hostPath["type"] = string(volume.PathType)
+		}
+		podVolumes = append(podVolumes, map[string]interface{}{
+			"name":     volume.Name,
+			"hostPath": hostPath,
+		})
+	}
+
+	patch := map[string]interface{}{
+		"spec": map[string]interface{}{
+			"containers": []map[string]interface{}{
+				{"name": "etcd", "volumeMounts": mounts},
+			},
+			"volumes": podVolumes,
+		},
+	}
+	return json.Marshal(patch)
+}
@@ -0,0 +1,46 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"testing"
+
+	kubeadmapi "k8s.io/kubernetes/cmd/kubeadm/app/apis/kubeadm"
+)
+
+func TestEtcdVolumesPatch(t *testing.T) {
+	testCases := []struct {
+		volumes  []kubeadmapi.HostPathMount
+		expected string
+	}{
+		{
+			[]kubeadmapi.HostPathMount{
+				{Name: "backups", HostPath: "/var/backups/etcd", MountPath: "/backups", PathType: "DirectoryOrCreate"},
+				{Name: "tz", HostPath: "/etc/localtime", MountPath: "/etc/localtime", ReadOnly: true},
+			},
+			`{"spec":{"containers":[{"name":"etcd","volumeMounts":[{"mountPath":"/backups","name":"backups","readOnly":false},{"mountPath":"/etc/localtime","name":"tz","readOnly":true}]}],"volumes":[{"hostPath":{"path":"/var/backups/etcd","type":"DirectoryOrCreate"},"name":"backups"},{"hostPath":{"path":"/etc/localtime"},"name":"tz"}]}}`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		patch, err := EtcdVolumesPatch(testCase.volumes)
+		if err != nil {
+			t.Fatalf("Error: unexpected error: %s", err)
+		}
+		if string(patch) != testCase.expected {
+			t.Fatalf("Error: unexpected patch:\n%s\nexpected:\n%s", string(patch), testCase.expected)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"sort"
+)
+
+const (
+	// HardeningCIS is the preset with the CIS Kubernetes Benchmark recommendations
+	HardeningCIS = "cis"
+
+	// strong TLS cipher suites (CIS 1.2.29 and 4.2.13)
+	cisTLSCipherSuites = "TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256," +
+		"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384," +
+		"TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305"
+)
+
+// HardeningPreset is a curated bundle of arguments for the cluster components.
+// Explicit arguments (ie, in the "extra_args") take precedence over the preset ones.
+type HardeningPreset struct {
+	APIServerArgs         map[string]string
+	ControllerManagerArgs map[string]string
+	SchedulerArgs         map[string]string
+	KubeletArgs           map[string]string
+}
+
+// HardeningPresets is the map of hardening presets
+var HardeningPresets = map[string]HardeningPreset{
+	HardeningCIS: {
+		APIServerArgs: map[string]string{
+			"profiling":              "false",            // 1.2.15
+			"service-account-lookup": "true",             // 1.2.22
+			"tls-cipher-suites":      cisTLSCipherSuites, // 1.2.29
+		},
+		ControllerManagerArgs: map[string]string{
+			"terminated-pod-gc-threshold":     "10",        // 1.3.1
+			"profiling":                       "false",     // 1.3.2
+			"use-service-account-credentials": "true",      // 1.3.3
+			"bind-address":                    "127.0.0.1", // 1.3.7
+		},
+		SchedulerArgs: map[string]string{
+			"profiling":    "false",     // 1.4.1
+			"bind-address": "127.0.0.1", // 1.4.2
+		},
+		KubeletArgs: map[string]string{
+			"read-only-port":                    "0",                // 4.2.4
+			"streaming-connection-idle-timeout": "5m",               // 4.2.5
+			"make-iptables-util-chains":         "true",             // 4.2.7
+			"rotate-certificates":               "true",             // 4.2.11
+			"tls-cipher-suites":                 cisTLSCipherSuites, // 4.2.13
+		},
+	},
+}
+
+// HardeningPresetsList returns the (sorted) names of the hardening presets
+func HardeningPresetsList() []string {
+	names := []string{}
+	for name := range HardeningPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// MergeArgs returns the `preset` arguments overridden by the `explicit` ones
+func MergeArgs(preset map[string]string, explicit map[string]string) map[string]string {
+	merged := map[string]string{}
+	for arg, value := range preset {
+		merged[arg] = value
+	}
+	for arg, value := range explicit {
+		merged[arg] = value
+	}
+	return merged
+}
@@ -0,0 +1,35 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"testing"
+)
+
+func TestMergeArgs(t *testing.T) {
+	preset := HardeningPresets[HardeningCIS].APIServerArgs
+	explicit := map[string]string{"profiling": "true", "v": "2"}
+
+	merged := MergeArgs(preset, explicit)
+	if merged["profiling"] != "true" || merged["v"] != "2" {
+		t.Fatalf("Error: explicit args not preserved: %v", merged)
+	}
+	if merged["service-account-lookup"] != "true" {
+		t.Fatalf("Error: preset args not added: %v", merged)
+	}
+	if preset["profiling"] != "false" {
+		t.Fatalf("Error: the preset has been modified: %v", preset)
+	}
+}
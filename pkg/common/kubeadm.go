@@ -214,7 +214,9 @@ func JoinConfigFromResourceData(d *schema.ResourceData) (*kubeadmapi.JoinConfigu
 	}
 
 	// ... update some things, like the seeder, the nodename, etc
-	joinConfig.Discovery.BootstrapToken.APIServerEndpoint = AddressWithPort(seeder, DefAPIServerPort)
+	if joinConfig.Discovery.BootstrapToken != nil {
+		joinConfig.Discovery.BootstrapToken.APIServerEndpoint = AddressWithPort(seeder, DefAPIServerPort)
+	}
 	if nodenameOpt, ok := d.GetOk("nodename"); ok {
 		joinConfig.NodeRegistration.Name = nodenameOpt.(string)
 	}
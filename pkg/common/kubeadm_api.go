@@ -0,0 +1,239 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	// KubeadmAPIGroup is the API group of the kubeadm configuration
+	KubeadmAPIGroup = "kubeadm.k8s.io"
+)
+
+// kubeadmAPIVersions are the kubeadm config API versions we can generate, with the first
+// (and last, when not 0) kubeadm minor version supporting them (from older to newer)
+var kubeadmAPIVersions = []struct {
+	version  string
+	minMinor int
+	maxMinor int
+}{
+	{"v1beta1", 13, 21},
+	{"v1beta2", 15, 26},
+	{"v1beta3", 22, 0},
+	{"v1beta4", 31, 0},
+}
+
+// KubeadmAPIVersions returns the list of kubeadm config API versions we can generate
+func KubeadmAPIVersions() []string {
+	res := []string{}
+	for _, v := range kubeadmAPIVersions {
+		res = append(res, v.version)
+	}
+	return res
+}
+
+// DefKubeadmAPIVersion returns the kubeadm config API version used internally
+func DefKubeadmAPIVersion() string {
+	return apiVersion.Version
+}
+
+// kubeadmAPIVersionIndex returns the position of a version in kubeadmAPIVersions (or -1)
+func kubeadmAPIVersionIndex(version string) int {
+	for i, v := range kubeadmAPIVersions {
+		if v.version == version {
+			return i
+		}
+	}
+	return -1
+}
+
+// CheckKubeadmAPIVersion checks that some kubeadm config API version is supported
+// by some kubeadm version (ie, "v1.15.0")
+func CheckKubeadmAPIVersion(version string, kubeadmVersion string) error {
+	i := kubeadmAPIVersionIndex(version)
+	if i < 0 {
+		return fmt.Errorf("unknown kubeadm config API version %q (known versions: %s)",
+			version, strings.Join(KubeadmAPIVersions(), ", "))
+	}
+
+	major, minor, err := ParseKubeVersion(kubeadmVersion)
+	if err != nil {
+		return err
+	}
+	v := kubeadmAPIVersions[i]
+	if major != 1 || minor < v.minMinor || (v.maxMinor > 0 && minor > v.maxMinor) {
+		return fmt.Errorf("kubeadm %s does not support the config API version %s", kubeadmVersion, version)
+	}
+	return nil
+}
+
+// KubeadmAPIVersionFor returns the newest kubeadm config API version supported
+// by some kubeadm version (ie, "v1.15.0")
+func KubeadmAPIVersionFor(kubeadmVersion string) (string, error) {
+	for i := len(kubeadmAPIVersions) - 1; i >= 0; i-- {
+		if CheckKubeadmAPIVersion(kubeadmAPIVersions[i].version, kubeadmVersion) == nil {
+			return kubeadmAPIVersions[i].version, nil
+		}
+	}
+	return "", fmt.Errorf("no supported kubeadm config API version for kubeadm %s", kubeadmVersion)
+}
+
+// ConvertKubeadmConfig converts a kubeadm configuration (as generated by
+// InitConfigToYAML/JoinConfigToYAML) to some other kubeadm config API version.
+// Other documents (like a KubeletConfiguration) are not modified.
+//
+// Some notes:
+//   - the conversion is done on the text, as we cannot marshal newer versions
+//   - fields removed in the new version are dropped (ie, the "dns.type" in v1beta3, or
+//     the "apiServer.timeoutForControlPlane" and "discovery.timeout" in v1beta4, where
+//     kubeadm defaults will be used)
+//   - the "extraArgs" maps are converted to lists of name/value in v1beta4
+func ConvertKubeadmConfig(configBytes []byte, version string) ([]byte, error) {
+	target := kubeadmAPIVersionIndex(version)
+	if target < 0 {
+		return nil, fmt.Errorf("unknown kubeadm config API version %q", version)
+	}
+	if version == DefKubeadmAPIVersion() {
+		return configBytes, nil
+	}
+
+	documents := [][]string{{}}
+	for _, line := range strings.Split(string(configBytes), "\n") {
+		if strings.TrimSpace(line) == "---" {
+			documents = append(documents, []string{})
+			continue
+		}
+		documents[len(documents)-1] = append(documents[len(documents)-1], line)
+	}
+
+	res := []string{}
+	for _, document := range documents {
+		res = append(res, strings.Join(convertKubeadmDocument(document, target), "\n"))
+	}
+	return []byte(strings.Join(res, "\n---\n")), nil
+}
+
+// yamlLine is a line in a YAML document, with the path of the key in that line
+type yamlLine struct {
+	text   string
+	indent int
+	path   string
+	value  string
+}
+
+// parseYAMLLines annotates the lines in a (block style) YAML document with their key paths
+// (ie, "apiServer.extraArgs"). List items are considered children of the list key.
+func parseYAMLLines(lines []string) []yamlLine {
+	type level struct {
+		indent int
+		key    string
+	}
+	stack := []level{}
+	res := []yamlLine{}
+
+	for _, text := range lines {
+		content := strings.TrimLeft(text, " ")
+		indent := len(text) - len(content)
+		if strings.HasPrefix(content, "- ") {
+			content = strings.TrimPrefix(content, "- ")
+			indent += 2
+		}
+
+		key, value := "", ""
+		if i := strings.Index(content, ":"); i > 0 && content != "" && !strings.HasPrefix(content, "#") {
+			key, value = content[:i], strings.TrimSpace(content[i+1:])
+		}
+		if key == "" {
+			res = append(res, yamlLine{text: text, indent: indent})
+			continue
+		}
+
+		for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+		stack = append(stack, level{indent: indent, key: key})
+
+		keys := []string{}
+		for _, l := range stack {
+			keys = append(keys, l.key)
+		}
+		res = append(res, yamlLine{text: text, indent: indent, path: strings.Join(keys, "."), value: value})
+	}
+	return res
+}
+
+// convertKubeadmDocument converts a kubeadm document to the version at position `target`
+func convertKubeadmDocument(lines []string, target int) []string {
+	isKubeadm := false
+	for _, line := range lines {
+		if strings.HasPrefix(line, "apiVersion: "+KubeadmAPIGroup+"/") {
+			isKubeadm = true
+		}
+	}
+	if !isKubeadm {
+		return lines
+	}
+
+	// fields removed in each version
+	removed := map[string]bool{}
+	if target >= kubeadmAPIVersionIndex("v1beta3") {
+		removed["dns.type"] = true
+		removed["useHyperKubeImage"] = true
+	}
+	listArgs := false
+	if target >= kubeadmAPIVersionIndex("v1beta4") {
+		removed["apiServer.timeoutForControlPlane"] = true
+		removed["discovery.timeout"] = true
+		listArgs = true
+	}
+
+	res := []string{}
+	parsed := parseYAMLLines(lines)
+	for i := 0; i < len(parsed); i++ {
+		line := parsed[i]
+
+		switch {
+		case strings.HasPrefix(line.text, "apiVersion: "+KubeadmAPIGroup+"/"):
+			res = append(res, fmt.Sprintf("apiVersion: %s/%s", KubeadmAPIGroup, kubeadmAPIVersions[target].version))
+
+		case removed[line.path]:
+			// skip the line, as well as all its children
+			for i+1 < len(parsed) && parsed[i+1].indent > line.indent && parsed[i+1].text != "" {
+				i++
+			}
+
+		case listArgs && (strings.HasSuffix(line.path, "extraArgs") || strings.HasSuffix(line.path, "kubeletExtraArgs")):
+			if line.value == "{}" {
+				res = append(res, strings.Replace(line.text, "{}", "[]", 1))
+				continue
+			}
+			res = append(res, line.text)
+			for i+1 < len(parsed) && parsed[i+1].indent > line.indent && parsed[i+1].path != "" {
+				i++
+				prefix := strings.Repeat(" ", parsed[i].indent)
+				name := parsed[i].path[strings.LastIndex(parsed[i].path, ".")+1:]
+				res = append(res,
+					fmt.Sprintf("%s- name: %s", prefix, name),
+					fmt.Sprintf("%s  value: %s", prefix, parsed[i].value))
+			}
+
+		default:
+			res = append(res, line.text)
+		}
+	}
+	return res
+}
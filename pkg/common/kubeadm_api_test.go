@@ -0,0 +1,116 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"testing"
+)
+
+func TestKubeadmAPIVersionFor(t *testing.T) {
+	testsCases := []struct {
+		kubeadmVersion string
+		expected       string
+		expectedErr    bool
+	}{
+		{"v1.14.1", "v1beta1", false},
+		{"v1.15.0", "v1beta2", false},
+		{"v1.21.3", "v1beta2", false},
+		{"v1.22.0", "v1beta3", false},
+		{"v1.30.2", "v1beta3", false},
+		{"v1.31.0", "v1beta4", false},
+		{"v1.12.0", "", true},
+	}
+
+	for _, testCase := range testsCases {
+		res, err := KubeadmAPIVersionFor(testCase.kubeadmVersion)
+		if testCase.expectedErr != (err != nil) {
+			t.Fatalf("Error: unexpected error result for %s: %v", testCase.kubeadmVersion, err)
+		}
+		if res != testCase.expected {
+			t.Fatalf("Error: unexpected version for %s: %q, expected %q", testCase.kubeadmVersion, res, testCase.expected)
+		}
+	}
+
+	if err := CheckKubeadmAPIVersion("v1beta1", "v1.25.0"); err == nil {
+		t.Fatalf("Error: v1beta1 should not be supported by kubeadm v1.25.0")
+	}
+}
+
+func TestConvertKubeadmConfig(t *testing.T) {
+	config := `apiVersion: kubeadm.k8s.io/v1beta1
+kind: ClusterConfiguration
+apiServer:
+  extraArgs:
+    audit-log-path: /var/log/audit.log
+    feature-gates: "A=true"
+  timeoutForControlPlane: 4m0s
+dns:
+  type: CoreDNS
+useHyperKubeImage: false
+---
+apiVersion: kubelet.config.k8s.io/v1beta1
+kind: KubeletConfiguration
+type: something
+`
+
+	testsCases := []struct {
+		version  string
+		expected string
+	}{
+		{
+			"v1beta3",
+			`apiVersion: kubeadm.k8s.io/v1beta3
+kind: ClusterConfiguration
+apiServer:
+  extraArgs:
+    audit-log-path: /var/log/audit.log
+    feature-gates: "A=true"
+  timeoutForControlPlane: 4m0s
+dns:
+---
+apiVersion: kubelet.config.k8s.io/v1beta1
+kind: KubeletConfiguration
+type: something
+`,
+		},
+		{
+			"v1beta4",
+			`apiVersion: kubeadm.k8s.io/v1beta4
+kind: ClusterConfiguration
+apiServer:
+  extraArgs:
+    - name: audit-log-path
+      value: /var/log/audit.log
+    - name: feature-gates
+      value: "A=true"
+dns:
+---
+apiVersion: kubelet.config.k8s.io/v1beta1
+kind: KubeletConfiguration
+type: something
+`,
+		},
+	}
+
+	for _, testCase := range testsCases {
+		out, err := ConvertKubeadmConfig([]byte(config), testCase.version)
+		if err != nil {
+			t.Fatalf("Error: could not convert to %s: %s", testCase.version, err)
+		}
+		if string(out) != testCase.expected {
+			t.Fatalf("Error: unexpected conversion to %s:\n%s\n!=\n%s", testCase.version, out, testCase.expected)
+		}
+	}
+}
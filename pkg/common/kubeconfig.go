@@ -0,0 +1,153 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+const (
+	// name of the cluster in the discovery kubeconfig
+	discoveryClusterName = "kubernetes"
+
+	// name of the user in the bootstrap kubeconfig (the same used by kubeadm)
+	bootstrapUserName = "tls-bootstrap-token-user"
+)
+
+// KubeconfigNames are the names used for the cluster, context and user in a kubeconfig
+type KubeconfigNames struct {
+	Cluster string
+	Context string
+	User    string
+}
+
+// IsEmpty returns true if no names have been provided
+func (n KubeconfigNames) IsEmpty() bool {
+	return n.Cluster == "" && n.Context == "" && n.User == ""
+}
+
+// ValidateKubeconfig validates that some contents are a valid kubeconfig with some cluster
+func ValidateKubeconfig(v interface{}, k string) (ws []string, errors []error) {
+	config, err := clientcmd.Load([]byte(v.(string)))
+	if err != nil {
+		errors = append(errors, fmt.Errorf("%q is not a valid kubeconfig: %s", k, err))
+		return
+	}
+	if len(config.Clusters) == 0 {
+		errors = append(errors, fmt.Errorf("%q is not a valid kubeconfig: no clusters found", k))
+		return
+	}
+	for name, cluster := range config.Clusters {
+		if cluster.Server == "" {
+			errors = append(errors, fmt.Errorf("%q is not a valid kubeconfig: no server for cluster %q", k, name))
+		}
+	}
+	return
+}
+
+// DiscoveryKubeconfig creates a kubeconfig that can be used for joining the
+// cluster with a "discovery file": it contains the API server and the CA certificate,
+// but no credentials
+func DiscoveryKubeconfig(server string, caCert []byte) ([]byte, error) {
+	return newJoinKubeconfig(server, caCert, "")
+}
+
+// BootstrapKubeconfig creates a kubeconfig that can be used for joining the cluster
+// with a "discovery file" as well as for the TLS bootstrap of the kubelet: it contains
+// the API server, the CA certificate and a bootstrap token as credentials
+func BootstrapKubeconfig(server string, caCert []byte, token string) ([]byte, error) {
+	if token == "" {
+		return nil, fmt.Errorf("no token provided for the bootstrap kubeconfig")
+	}
+	return newJoinKubeconfig(server, caCert, token)
+}
+
+// newJoinKubeconfig creates a kubeconfig for joining the cluster, with
+// a bootstrap token as credentials when `token` is not empty
+func newJoinKubeconfig(server string, caCert []byte, token string) ([]byte, error) {
+	if server == "" {
+		return nil, fmt.Errorf("no API server provided for the discovery kubeconfig")
+	}
+	if len(caCert) == 0 {
+		return nil, fmt.Errorf("no CA certificate provided for the discovery kubeconfig")
+	}
+
+	config := clientcmdapi.Config{
+		Clusters: map[string]*clientcmdapi.Cluster{
+			discoveryClusterName: {
+				Server:                   "https://" + server,
+				CertificateAuthorityData: caCert,
+			},
+		},
+		AuthInfos: map[string]*clientcmdapi.AuthInfo{},
+		Contexts: map[string]*clientcmdapi.Context{
+			discoveryClusterName: {
+				Cluster: discoveryClusterName,
+			},
+		},
+		CurrentContext: discoveryClusterName,
+	}
+	if token != "" {
+		config.AuthInfos[bootstrapUserName] = &clientcmdapi.AuthInfo{Token: token}
+		config.Contexts[discoveryClusterName].AuthInfo = bootstrapUserName
+	}
+	return clientcmd.Write(config)
+}
+
+// RenameKubeconfig renames the cluster, context and user of the current context in a kubeconfig
+// (ie, the `kubernetes`, `kubernetes-admin@kubernetes` and `kubernetes-admin` used by kubeadm).
+// Empty names are not changed.
+func RenameKubeconfig(data []byte, names KubeconfigNames) ([]byte, error) {
+	config, err := clientcmd.Load(data)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse kubeconfig: %s", err)
+	}
+
+	kctx, ok := config.Contexts[config.CurrentContext]
+	if !ok {
+		return nil, fmt.Errorf("current context %q not found in kubeconfig", config.CurrentContext)
+	}
+
+	if names.Cluster != "" && names.Cluster != kctx.Cluster {
+		cluster, ok := config.Clusters[kctx.Cluster]
+		if !ok {
+			return nil, fmt.Errorf("cluster %q not found in kubeconfig", kctx.Cluster)
+		}
+		delete(config.Clusters, kctx.Cluster)
+		config.Clusters[names.Cluster] = cluster
+		kctx.Cluster = names.Cluster
+	}
+
+	if names.User != "" && names.User != kctx.AuthInfo {
+		user, ok := config.AuthInfos[kctx.AuthInfo]
+		if !ok {
+			return nil, fmt.Errorf("user %q not found in kubeconfig", kctx.AuthInfo)
+		}
+		delete(config.AuthInfos, kctx.AuthInfo)
+		config.AuthInfos[names.User] = user
+		kctx.AuthInfo = names.User
+	}
+
+	if names.Context != "" && names.Context != config.CurrentContext {
+		delete(config.Contexts, config.CurrentContext)
+		config.Contexts[names.Context] = kctx
+		config.CurrentContext = names.Context
+	}
+
+	return clientcmd.Write(*config)
+}
@@ -0,0 +1,144 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	kubeadmutil "k8s.io/kubernetes/cmd/kubeadm/app/util"
+)
+
+const (
+	// KubeletConfigGroup is the API group for the KubeletConfiguration
+	KubeletConfigGroup = "kubelet.config.k8s.io"
+
+	// KubeletConfigKind is the kind for the KubeletConfiguration
+	KubeletConfigKind = "KubeletConfiguration"
+)
+
+// ValidateKubeletConfig validates that some YAML is a (single) KubeletConfiguration document
+func ValidateKubeletConfig(v interface{}, k string) (ws []string, errors []error) {
+	objects, err := kubeadmutil.SplitYAMLDocuments([]byte(v.(string)))
+	if err != nil {
+		errors = append(errors, fmt.Errorf("%q is not a valid YAML document: %s", k, err))
+		return
+	}
+	if len(objects) != 1 {
+		errors = append(errors, fmt.Errorf("%q must contain exactly one %s document", k, KubeletConfigKind))
+		return
+	}
+	for gvk := range objects {
+		if gvk.Group != KubeletConfigGroup || gvk.Kind != KubeletConfigKind {
+			errors = append(errors, fmt.Errorf("%q must be a %s (with apiVersion %s/<version>): found %s/%s %s",
+				k, KubeletConfigKind, KubeletConfigGroup, gvk.Group, gvk.Version, gvk.Kind))
+		}
+	}
+	return
+}
+
+// AppendKubeletConfig appends a KubeletConfiguration document to a kubeadm configuration
+func AppendKubeletConfig(configBytes []byte, kubeletConfig []byte) []byte {
+	kubeletConfig = bytes.TrimSpace(bytes.TrimPrefix(bytes.TrimSpace(kubeletConfig), []byte("---")))
+	if len(kubeletConfig) == 0 {
+		return configBytes
+	}
+
+	buf := bytes.Buffer{}
+	buf.Write(bytes.TrimRight(configBytes, "\n"))
+	buf.WriteString("\n---\n")
+	buf.Write(kubeletConfig)
+	buf.WriteString("\n")
+	return buf.Bytes()
+}
+
+// kubeletConfigFieldRegexp returns a regexp that matches a top-level `field` in a KubeletConfiguration
+func kubeletConfigFieldRegexp(field string) *regexp.Regexp {
+	return regexp.MustCompile(fmt.Sprintf(`(?m)^%s:[ \t]*["']?([^"'\s]*)["']?[ \t]*$`, regexp.QuoteMeta(field)))
+}
+
+// KubeletConfigField returns the value of a top-level `field` in a KubeletConfiguration
+// (or "" when not set)
+func KubeletConfigField(kubeletConfig string, field string) string {
+	if m := kubeletConfigFieldRegexp(field).FindStringSubmatch(kubeletConfig); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// SetKubeletConfigField returns the KubeletConfiguration with a top-level `field`,
+// creating a new KubeletConfiguration when `kubeletConfig` is empty. It fails when
+// the `field` is already set in the `kubeletConfig` with a different value.
+func SetKubeletConfigField(kubeletConfig string, field string, value string) (string, error) {
+	if current := KubeletConfigField(kubeletConfig, field); current != "" {
+		if current != value {
+			return "", fmt.Errorf("the KubeletConfiguration has '%s: %s', but %q is required", field, current, value)
+		}
+		return kubeletConfig, nil
+	}
+
+	config := strings.TrimRight(kubeletConfig, "\n")
+	if strings.TrimSpace(config) == "" {
+		config = fmt.Sprintf("apiVersion: %s/v1beta1\nkind: %s", KubeletConfigGroup, KubeletConfigKind)
+	}
+	return config + fmt.Sprintf("\n%s: %s\n", field, value), nil
+}
+
+// KubeletConfigCgroupDriver returns the `cgroupDriver` in a KubeletConfiguration (or "" when not set)
+func KubeletConfigCgroupDriver(kubeletConfig string) string {
+	return KubeletConfigField(kubeletConfig, "cgroupDriver")
+}
+
+// CgroupDriverKubeletConfig returns the KubeletConfiguration with the `cgroupDriver`,
+// creating a new KubeletConfiguration when `kubeletConfig` is empty. It fails when
+// a different `cgroupDriver` is already set in the `kubeletConfig`.
+func CgroupDriverKubeletConfig(kubeletConfig string, driver string) (string, error) {
+	return SetKubeletConfigField(kubeletConfig, "cgroupDriver", driver)
+}
+
+// ReservedToString serializes some reserved resources (ie, {"cpu": "100m", "memory": "256Mi"})
+// in the format used by the kubelet "--system-reserved" and "--kube-reserved" flags
+// (ie, "cpu=100m,memory=256Mi"), skipping the resources with no value
+func ReservedToString(reserved map[string]string) string {
+	resources := []string{}
+	for resource, quantity := range reserved {
+		if quantity != "" {
+			resources = append(resources, fmt.Sprintf("%s=%s", resource, quantity))
+		}
+	}
+	sort.Strings(resources)
+	return strings.Join(resources, ",")
+}
+
+// ReservedFromString parses some reserved resources in the format used
+// by the kubelet "--system-reserved" and "--kube-reserved" flags
+func ReservedFromString(s string) (map[string]string, error) {
+	reserved := map[string]string{}
+	for _, r := range strings.Split(s, ",") {
+		r = strings.TrimSpace(r)
+		if r == "" {
+			continue
+		}
+		kv := strings.SplitN(r, "=", 2)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return nil, fmt.Errorf("%q is not a valid reserved resource (ie, cpu=100m)", r)
+		}
+		reserved[kv[0]] = kv[1]
+	}
+	return reserved, nil
+}
@@ -0,0 +1,135 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"testing"
+)
+
+func TestAppendKubeletConfig(t *testing.T) {
+	testsCases := []struct {
+		config        string
+		kubeletConfig string
+		expected      string
+	}{
+		{
+			"kind: InitConfiguration\n",
+			"",
+			"kind: InitConfiguration\n",
+		},
+		{
+			"kind: InitConfiguration\n",
+			"kind: KubeletConfiguration\nserializeImagePulls: false\n",
+			"kind: InitConfiguration\n---\nkind: KubeletConfiguration\nserializeImagePulls: false\n",
+		},
+		{
+			"kind: InitConfiguration\n\n",
+			"---\nkind: KubeletConfiguration\n",
+			"kind: InitConfiguration\n---\nkind: KubeletConfiguration\n",
+		},
+	}
+
+	for _, testCase := range testsCases {
+		out := string(AppendKubeletConfig([]byte(testCase.config), []byte(testCase.kubeletConfig)))
+		if out != testCase.expected {
+			t.Fatalf("Error: expected output does not match:\n%q\n!=\n%q", out, testCase.expected)
+		}
+	}
+}
+
+func TestReservedString(t *testing.T) {
+	reserved := map[string]string{"memory": "256Mi", "cpu": "100m", "ephemeral-storage": ""}
+	s := ReservedToString(reserved)
+	if s != "cpu=100m,memory=256Mi" {
+		t.Fatalf("Error: unexpected reserved resources string: %q", s)
+	}
+
+	parsed, err := ReservedFromString(s)
+	if err != nil {
+		t.Fatalf("Error: could not parse %q: %s", s, err)
+	}
+	if len(parsed) != 2 || parsed["cpu"] != "100m" || parsed["memory"] != "256Mi" {
+		t.Fatalf("Error: unexpected reserved resources parsed from %q: %v", s, parsed)
+	}
+
+	if _, err := ReservedFromString("cpu"); err == nil {
+		t.Fatalf("Error: no error when parsing an invalid reserved resources string")
+	}
+}
+
+func TestCgroupDriverKubeletConfig(t *testing.T) {
+	testsCases := []struct {
+		kubeletConfig string
+		expected      string
+		expectedError bool
+	}{
+		{
+			"",
+			"apiVersion: kubelet.config.k8s.io/v1beta1\nkind: KubeletConfiguration\ncgroupDriver: systemd\n",
+			false,
+		},
+		{
+			"kind: KubeletConfiguration\nserializeImagePulls: false\n",
+			"kind: KubeletConfiguration\nserializeImagePulls: false\ncgroupDriver: systemd\n",
+			false,
+		},
+		{
+			"kind: KubeletConfiguration\ncgroupDriver: \"systemd\"\n",
+			"kind: KubeletConfiguration\ncgroupDriver: \"systemd\"\n",
+			false,
+		},
+		{
+			"kind: KubeletConfiguration\ncgroupDriver: cgroupfs\n",
+			"",
+			true,
+		},
+	}
+
+	for i, testCase := range testsCases {
+		out, err := CgroupDriverKubeletConfig(testCase.kubeletConfig, "systemd")
+		if testCase.expectedError {
+			if err == nil {
+				t.Fatalf("Error: test case %d: no error returned", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Error: test case %d: unexpected error: %s", i, err)
+		}
+		if out != testCase.expected {
+			t.Fatalf("Error: test case %d: expected output does not match:\n%q\n!=\n%q", i, out, testCase.expected)
+		}
+	}
+}
+
+func TestSetKubeletConfigField(t *testing.T) {
+	config, err := SetKubeletConfigField("kind: KubeletConfiguration\n", "containerLogMaxSize", "50Mi")
+	if err != nil {
+		t.Fatalf("Error: unexpected error: %s", err)
+	}
+	config, err = SetKubeletConfigField(config, "containerLogMaxFiles", "3")
+	if err != nil {
+		t.Fatalf("Error: unexpected error: %s", err)
+	}
+	if config != "kind: KubeletConfiguration\ncontainerLogMaxSize: 50Mi\ncontainerLogMaxFiles: 3\n" {
+		t.Fatalf("Error: unexpected KubeletConfiguration:\n%q", config)
+	}
+	if v := KubeletConfigField(config, "containerLogMaxSize"); v != "50Mi" {
+		t.Fatalf("Error: unexpected containerLogMaxSize: %q", v)
+	}
+	if _, err := SetKubeletConfigField(config, "containerLogMaxFiles", "5"); err == nil {
+		t.Fatalf("Error: no error when setting a different containerLogMaxFiles")
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+)
+
+const (
+	// KubeProxyModeNone is the kube-proxy mode when it is not deployed (ie, replaced by the CNI plugin)
+	KubeProxyModeNone = "none"
+
+	// KubeProxyConfigAPIVersion is the apiVersion for the KubeProxyConfiguration
+	KubeProxyConfigAPIVersion = "kubeproxy.config.k8s.io/v1alpha1"
+)
+
+// KubeProxyModes are the valid modes for the kube-proxy
+var KubeProxyModes = []string{"iptables", "ipvs", KubeProxyModeNone}
+
+// AppendKubeProxyConfig appends a KubeProxyConfiguration document with the `mode` to
+// a kubeadm configuration. Nothing is appended when no mode is provided or it is "none"
+// (as the kube-proxy is not deployed).
+func AppendKubeProxyConfig(configBytes []byte, mode string) []byte {
+	if mode == "" || mode == KubeProxyModeNone {
+		return configBytes
+	}
+	kubeProxyConfig := fmt.Sprintf("apiVersion: %s\nkind: KubeProxyConfiguration\nmode: %s\n", KubeProxyConfigAPIVersion, mode)
+	return AppendKubeletConfig(configBytes, []byte(kubeProxyConfig))
+}
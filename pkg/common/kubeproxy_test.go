@@ -0,0 +1,38 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"testing"
+)
+
+func TestAppendKubeProxyConfig(t *testing.T) {
+	config := []byte("kind: ClusterConfiguration\n")
+
+	testCases := []struct {
+		mode     string
+		expected string
+	}{
+		{"", "kind: ClusterConfiguration\n"},
+		{"none", "kind: ClusterConfiguration\n"},
+		{"ipvs", "kind: ClusterConfiguration\n---\napiVersion: kubeproxy.config.k8s.io/v1alpha1\nkind: KubeProxyConfiguration\nmode: ipvs\n"},
+	}
+
+	for _, testCase := range testCases {
+		if res := string(AppendKubeProxyConfig(config, testCase.mode)); res != testCase.expected {
+			t.Fatalf("Error: for mode %q: expected %q, got %q", testCase.mode, testCase.expected, res)
+		}
+	}
+}
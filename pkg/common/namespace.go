@@ -0,0 +1,215 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// DefNamespaceQuotaName is the name of the ResourceQuota created in the namespaces
+	DefNamespaceQuotaName = "default-quota"
+
+	// DefNamespaceLimitRangeName is the name of the LimitRange created in the namespaces
+	DefNamespaceLimitRangeName = "default-limits"
+)
+
+// Namespace is a namespace created when bootstrapping the cluster
+type Namespace struct {
+	Name        string
+	Labels      map[string]string
+	Annotations map[string]string
+
+	// Quota are the hard limits of the ResourceQuota in the namespace (ie, {"pods": "20"})
+	Quota map[string]string
+
+	// LimitRange are the limits for the containers in the namespace
+	LimitRange LimitRange
+}
+
+// LimitRange are the limits for the containers in a namespace, as maps of
+// resources to quantities (ie, {"cpu": "500m", "memory": "256Mi"})
+type LimitRange struct {
+	Default        map[string]string
+	DefaultRequest map[string]string
+	Max            map[string]string
+	Min            map[string]string
+}
+
+// IsEmpty returns true when no limit has been set
+func (l LimitRange) IsEmpty() bool {
+	return len(l.Default) == 0 && len(l.DefaultRequest) == 0 && len(l.Max) == 0 && len(l.Min) == 0
+}
+
+// Check checks the limits are consistent: for each resource, min <= defaultRequest <= default <= max
+func (l LimitRange) Check() error {
+	ordered := []struct {
+		name   string
+		limits map[string]string
+	}{
+		{"min", l.Min},
+		{"default_request", l.DefaultRequest},
+		{"default", l.Default},
+		{"max", l.Max},
+	}
+
+	for i := range ordered {
+		for resource, quantity := range ordered[i].limits {
+			value, err := parseQuantity(quantity)
+			if err != nil {
+				return fmt.Errorf("invalid %s for %q: %s", ordered[i].name, resource, err)
+			}
+			for _, higher := range ordered[i+1:] {
+				higherQuantity, ok := higher.limits[resource]
+				if !ok {
+					continue
+				}
+				higherValue, err := parseQuantity(higherQuantity)
+				if err != nil {
+					return fmt.Errorf("invalid %s for %q: %s", higher.name, resource, err)
+				}
+				if value > higherValue {
+					return fmt.Errorf("the %s for %q (%s) is greater than the %s (%s)",
+						ordered[i].name, resource, quantity, higher.name, higherQuantity)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// quantityMultipliers are the multipliers for the suffixes in quantities
+var quantityMultipliers = map[string]float64{
+	"":   1,
+	"m":  1e-3,
+	"k":  1e3,
+	"M":  1e6,
+	"G":  1e9,
+	"T":  1e12,
+	"P":  1e15,
+	"Ki": 1 << 10,
+	"Mi": 1 << 20,
+	"Gi": 1 << 30,
+	"Ti": 1 << 40,
+	"Pi": 1 << 50,
+}
+
+// quantityPartsRegexp splits a quantity in its number and suffix
+var quantityPartsRegexp = regexp.MustCompile(`^([0-9]+(?:\.[0-9]+)?)([A-Za-z]*)$`)
+
+// parseQuantity parses a resource quantity (ie, "100m" or "256Mi"), for comparing quantities
+func parseQuantity(quantity string) (float64, error) {
+	m := quantityPartsRegexp.FindStringSubmatch(quantity)
+	if m == nil {
+		return 0, fmt.Errorf("%q is not a valid quantity", quantity)
+	}
+	multiplier, ok := quantityMultipliers[m[2]]
+	if !ok {
+		return 0, fmt.Errorf("unknown suffix in quantity %q", quantity)
+	}
+	value, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a valid quantity: %s", quantity, err)
+	}
+	return value * multiplier, nil
+}
+
+// writeSortedMap writes a map in a manifest (sorted by key, so the output is stable)
+func writeSortedMap(sb *strings.Builder, indent string, name string, m map[string]string) {
+	if len(m) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	sb.WriteString(fmt.Sprintf("%s%s:\n", indent, name))
+	for _, k := range keys {
+		sb.WriteString(fmt.Sprintf("%s  %q: %q\n", indent, k, m[k]))
+	}
+}
+
+// Manifest returns the manifest for the namespace
+func (n Namespace) Manifest() string {
+	var sb strings.Builder
+	sb.WriteString("apiVersion: v1\n")
+	sb.WriteString("kind: Namespace\n")
+	sb.WriteString("metadata:\n")
+	sb.WriteString(fmt.Sprintf("  name: %q\n", n.Name))
+	writeSortedMap(&sb, "  ", "labels", n.Labels)
+	writeSortedMap(&sb, "  ", "annotations", n.Annotations)
+	return sb.String()
+}
+
+// QuotaManifest returns the manifest for the ResourceQuota in the namespace (if any)
+func (n Namespace) QuotaManifest() string {
+	if len(n.Quota) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("apiVersion: v1\n")
+	sb.WriteString("kind: ResourceQuota\n")
+	sb.WriteString("metadata:\n")
+	sb.WriteString(fmt.Sprintf("  name: %q\n", DefNamespaceQuotaName))
+	sb.WriteString(fmt.Sprintf("  namespace: %q\n", n.Name))
+	sb.WriteString("spec:\n")
+	writeSortedMap(&sb, "  ", "hard", n.Quota)
+	return sb.String()
+}
+
+// LimitRangeManifest returns the manifest for the LimitRange in the namespace (if any)
+func (n Namespace) LimitRangeManifest() string {
+	if n.LimitRange.IsEmpty() {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("apiVersion: v1\n")
+	sb.WriteString("kind: LimitRange\n")
+	sb.WriteString("metadata:\n")
+	sb.WriteString(fmt.Sprintf("  name: %q\n", DefNamespaceLimitRangeName))
+	sb.WriteString(fmt.Sprintf("  namespace: %q\n", n.Name))
+	sb.WriteString("spec:\n")
+	sb.WriteString("  limits:\n")
+	sb.WriteString("  - type: Container\n")
+	writeSortedMap(&sb, "    ", "default", n.LimitRange.Default)
+	writeSortedMap(&sb, "    ", "defaultRequest", n.LimitRange.DefaultRequest)
+	writeSortedMap(&sb, "    ", "max", n.LimitRange.Max)
+	writeSortedMap(&sb, "    ", "min", n.LimitRange.Min)
+	return sb.String()
+}
+
+// NamespacesManifest returns a manifest with all the namespaces, followed
+// by their ResourceQuotas and LimitRanges
+func NamespacesManifest(namespaces []Namespace) string {
+	docs := []string{}
+	for _, n := range namespaces {
+		docs = append(docs, n.Manifest())
+	}
+	for _, n := range namespaces {
+		if quota := n.QuotaManifest(); quota != "" {
+			docs = append(docs, quota)
+		}
+		if limitRange := n.LimitRangeManifest(); limitRange != "" {
+			docs = append(docs, limitRange)
+		}
+	}
+	return strings.Join(docs, "---\n")
+}
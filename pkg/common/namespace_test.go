@@ -0,0 +1,142 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"testing"
+)
+
+func TestNamespacesManifest(t *testing.T) {
+	namespaces := []Namespace{
+		{Name: "monitoring"},
+		{
+			Name:        "apps",
+			Labels:      map[string]string{"team": "web", "env": "prod"},
+			Annotations: map[string]string{"owner": "web@example.com"},
+		},
+	}
+
+	expected := `apiVersion: v1
+kind: Namespace
+metadata:
+  name: "monitoring"
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: "apps"
+  labels:
+    "env": "prod"
+    "team": "web"
+  annotations:
+    "owner": "web@example.com"
+`
+	if manifest := NamespacesManifest(namespaces); manifest != expected {
+		t.Fatalf("Error: unexpected manifest:\n%s", manifest)
+	}
+}
+
+func TestNamespacesManifestWithQuotas(t *testing.T) {
+	namespaces := []Namespace{
+		{
+			Name:  "apps",
+			Quota: map[string]string{"pods": "20", "requests.cpu": "4"},
+			LimitRange: LimitRange{
+				Default:        map[string]string{"cpu": "500m", "memory": "256Mi"},
+				DefaultRequest: map[string]string{"cpu": "100m"},
+			},
+		},
+	}
+
+	expected := `apiVersion: v1
+kind: Namespace
+metadata:
+  name: "apps"
+---
+apiVersion: v1
+kind: ResourceQuota
+metadata:
+  name: "default-quota"
+  namespace: "apps"
+spec:
+  hard:
+    "pods": "20"
+    "requests.cpu": "4"
+---
+apiVersion: v1
+kind: LimitRange
+metadata:
+  name: "default-limits"
+  namespace: "apps"
+spec:
+  limits:
+  - type: Container
+    default:
+      "cpu": "500m"
+      "memory": "256Mi"
+    defaultRequest:
+      "cpu": "100m"
+`
+	if manifest := NamespacesManifest(namespaces); manifest != expected {
+		t.Fatalf("Error: unexpected manifest:\n%s", manifest)
+	}
+}
+
+func TestLimitRangeCheck(t *testing.T) {
+	testCases := []struct {
+		limitRange  LimitRange
+		expectedErr bool
+	}{
+		{
+			LimitRange{
+				Min:            map[string]string{"cpu": "50m"},
+				DefaultRequest: map[string]string{"cpu": "100m", "memory": "128Mi"},
+				Default:        map[string]string{"cpu": "0.5", "memory": "1Gi"},
+				Max:            map[string]string{"cpu": "2"},
+			},
+			false,
+		},
+		{
+			// default request greater than the default limit
+			LimitRange{
+				DefaultRequest: map[string]string{"memory": "1Gi"},
+				Default:        map[string]string{"memory": "512Mi"},
+			},
+			true,
+		},
+		{
+			// min greater than max
+			LimitRange{
+				Min: map[string]string{"cpu": "3"},
+				Max: map[string]string{"cpu": "2000m"},
+			},
+			true,
+		},
+		{
+			LimitRange{Max: map[string]string{"cpu": "lots"}},
+			true,
+		},
+	}
+
+	for i, testCase := range testCases {
+		err := testCase.limitRange.Check()
+		if testCase.expectedErr && err == nil {
+			t.Fatalf("Error: test case %d: error expected but not found", i)
+		}
+		if !testCase.expectedErr && err != nil {
+			t.Fatalf("Error: test case %d: unexpected error: %s", i, err)
+		}
+	}
+}
@@ -23,15 +23,16 @@ import (
 
 // AddressWithPort return an address as expectedHost:expectedPort (setting a default expectedPort p if there was no expectedPort specified)
 func AddressWithPort(name string, p int) string {
-	if strings.IndexByte(name, ':') < 0 {
+	// note: a (non-bracketed) IPv6 address will contain ':' but no port
+	if strings.IndexByte(name, ':') < 0 || net.ParseIP(name) != nil {
 		return net.JoinHostPort(name, fmt.Sprintf("%d", p))
 	}
 	return name
 }
 
 func SplitHostPort(hp string, defaultPort int) (string, int, error) {
-	if strings.Count(hp, ":") == 0 && defaultPort > 0 {
-		hp = fmt.Sprintf("%s:%d", hp, defaultPort)
+	if (strings.Count(hp, ":") == 0 || net.ParseIP(hp) != nil) && defaultPort > 0 {
+		hp = net.JoinHostPort(hp, fmt.Sprintf("%d", defaultPort))
 	}
 	h, p, err := net.SplitHostPort(hp)
 	if err != nil {
@@ -45,3 +46,57 @@ func SplitHostPort(hp string, defaultPort int) (string, int, error) {
 
 	return h, pi, nil
 }
+
+// ParseCIDRs parses a comma-separated list of CIDRs (ie, "10.244.0.0/16,fd00:10:244::/56")
+func ParseCIDRs(s string) ([]*net.IPNet, error) {
+	cidrs := []*net.IPNet{}
+	for _, c := range strings.Split(s, ",") {
+		c = strings.TrimSpace(c)
+		if c == "" {
+			continue
+		}
+		_, cidr, err := net.ParseCIDR(c)
+		if err != nil {
+			return nil, err
+		}
+		cidrs = append(cidrs, cidr)
+	}
+	return cidrs, nil
+}
+
+// SplitCIDRsByFamily returns the IPv4 and IPv6 CIDRs in a comma-separated list of CIDRs
+func SplitCIDRsByFamily(s string) (string, string) {
+	v4, v6 := "", ""
+	cidrs, err := ParseCIDRs(s)
+	if err != nil {
+		return "", ""
+	}
+	for _, cidr := range cidrs {
+		if cidr.IP.To4() != nil {
+			v4 = cidr.String()
+		} else {
+			v6 = cidr.String()
+		}
+	}
+	return v4, v6
+}
+
+// IsDualStackCIDRs returns true if a comma-separated list of CIDRs contains both IPv4 and IPv6 CIDRs
+func IsDualStackCIDRs(s string) bool {
+	v4, v6 := SplitCIDRsByFamily(s)
+	return v4 != "" && v6 != ""
+}
+
+// SameCIDRsFamilies returns true if two comma-separated lists of CIDRs contain CIDRs
+// of the same IP families (ie, both IPv4, or both IPv4 and IPv6)
+func SameCIDRsFamilies(a, b string) bool {
+	aV4, aV6 := SplitCIDRsByFamily(a)
+	bV4, bV6 := SplitCIDRsByFamily(b)
+	return (aV4 != "") == (bV4 != "") && (aV6 != "") == (bV6 != "")
+}
+
+// HasIPv6CIDRs returns true if a comma-separated list of CIDRs contains some IPv6 CIDR
+func HasIPv6CIDRs(s string) bool {
+	_, v6 := SplitCIDRsByFamily(s)
+	return v6 != ""
+}
@@ -44,6 +44,18 @@ func TestSplitHostPort(t *testing.T) {
 			"some.place",
 			2525,
 		},
+		{
+			"fd00::10",
+			6443,
+			"fd00::10",
+			6443,
+		},
+		{
+			"[fd00::10]:8443",
+			6443,
+			"fd00::10",
+			8443,
+		},
 	}
 
 	for _, testCase := range testsCases {
@@ -59,3 +71,59 @@ func TestSplitHostPort(t *testing.T) {
 		}
 	}
 }
+
+func TestSplitCIDRsByFamily(t *testing.T) {
+
+	testsCases := []struct {
+		cidrs      string
+		expectedV4 string
+		expectedV6 string
+	}{
+		{
+			"10.244.0.0/16",
+			"10.244.0.0/16",
+			"",
+		},
+		{
+			"fd00:10:244::/56",
+			"",
+			"fd00:10:244::/56",
+		},
+		{
+			"10.244.0.0/16, fd00:10:244::/56",
+			"10.244.0.0/16",
+			"fd00:10:244::/56",
+		},
+	}
+
+	for _, testCase := range testsCases {
+		v4, v6 := SplitCIDRsByFamily(testCase.cidrs)
+		if v4 != testCase.expectedV4 {
+			t.Fatalf("Error: IPv4 CIDR does not match: %q != %q", v4, testCase.expectedV4)
+		}
+		if v6 != testCase.expectedV6 {
+			t.Fatalf("Error: IPv6 CIDR does not match: %q != %q", v6, testCase.expectedV6)
+		}
+	}
+}
+
+func TestSameCIDRsFamilies(t *testing.T) {
+	testsCases := []struct {
+		a        string
+		b        string
+		expected bool
+	}{
+		{"10.244.0.0/16", "10.96.0.0/12", true},
+		{"fd00:10:244::/56", "fd00:10:96::/112", true},
+		{"10.244.0.0/16,fd00:10:244::/56", "10.96.0.0/12,fd00:10:96::/112", true},
+		{"10.244.0.0/16", "fd00:10:96::/112", false},
+		{"fd00:10:244::/56", "10.96.0.0/12", false},
+		{"10.244.0.0/16,fd00:10:244::/56", "10.96.0.0/12", false},
+	}
+
+	for _, testCase := range testsCases {
+		if res := SameCIDRsFamilies(testCase.a, testCase.b); res != testCase.expected {
+			t.Fatalf("Error: unexpected result for %q and %q: %t", testCase.a, testCase.b, res)
+		}
+	}
+}
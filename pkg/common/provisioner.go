@@ -84,11 +84,36 @@ var ProvisionerConfigElements = map[string]*schema.Schema{
 		// Computed: true,
 		Optional: true,
 	},
+	"cni_pod_cidr_v4": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the IPv4 pods subnet",
+	},
+	"cni_pod_cidr_v6": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the IPv6 pods subnet",
+	},
 	"dns_upstream": {
 		Type: schema.TypeString,
 		// Computed: true,
 		Optional: true,
 	},
+	"critical_addons_priority": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "set the priority classes of the critical addons",
+	},
+	"dns_replicas": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the number of CoreDNS replicas",
+	},
+	"dns_anti_affinity": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "spread the CoreDNS replicas across nodes",
+	},
 	"flannel_backend": {
 		Type:        schema.TypeString,
 		Optional:    true,
@@ -99,6 +124,26 @@ var ProvisionerConfigElements = map[string]*schema.Schema{
 		Optional:    true,
 		Description: "the flannel image version",
 	},
+	"multus_enabled": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "load the Multus meta-CNI plugin",
+	},
+	"multus_manifest": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the manifest for Multus (instead of the built-in one)",
+	},
+	"multus_image_version": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the Multus image version",
+	},
+	"multus_default_network": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the CNI configuration file used as the default network by Multus",
+	},
 	"helm_enabled": {
 		Type: schema.TypeBool,
 		// Computed: true,
@@ -119,6 +164,204 @@ var ProvisionerConfigElements = map[string]*schema.Schema{
 		// Computed: true,
 		Optional: true,
 	},
+	"runtime_engine": {
+		Type: schema.TypeString,
+		// Computed: true,
+		Optional: true,
+	},
+	"runtime_manage_config": {
+		Type: schema.TypeBool,
+		// Computed: true,
+		Optional: true,
+	},
+	"namespaces_manifest": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the manifest for the namespaces created after the cluster is initialized",
+	},
+	"rbac_manifests": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the RBAC manifests (and bindings) applied after the cluster is initialized",
+	},
+	"images_verify_key": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the public key for verifying the signatures of the control plane images",
+	},
+	"hardening": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "hardening preset applied to the cluster",
+	},
+	"control_plane_label": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "custom label (key[=value]) for the control plane nodes",
+	},
+	"anonymous_auth_disabled": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "anonymous requests to the API server are disabled",
+	},
+	"audit_policy": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the API server audit policy",
+	},
+	"audit_webhook_config": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the API server audit webhook config",
+	},
+	"egress_selector_config": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the API server egress selector configuration",
+	},
+	"kube_proxy_mode": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "mode of the kube-proxy (or none when it is not deployed)",
+	},
+	"scheduler_config": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the scheduler configuration",
+	},
+	"etcd_patch": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the patch for the local etcd static pod",
+	},
+	"konnectivity_enabled": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "deploy the konnectivity server and agent",
+	},
+	"konnectivity_version": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the konnectivity images version",
+	},
+	"konnectivity_agent_port": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the port where the konnectivity server listens for agents",
+	},
+	"konnectivity_server_host": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the address used by the konnectivity agents for connecting to the servers",
+	},
+	"kubeconfig_cluster": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "name for the cluster in the kubeconfig",
+	},
+	"kubeconfig_context": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "name for the context in the kubeconfig",
+	},
+	"kubeconfig_user": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "name for the user in the kubeconfig",
+	},
+	"skip_token_print": {
+		Type:        schema.TypeBool,
+		Optional:    true,
+		Description: "pass --skip-token-print to kubeadm init",
+	},
+	"discovery_file": {
+		Type:        schema.TypeBool,
+		Optional:    true,
+		Description: "join with a discovery kubeconfig file",
+	},
+	"tls_bootstrap": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "join with a bootstrap kubeconfig file (with the credentials for the TLS bootstrap)",
+	},
+	"bootstrap_kubeconfig_token": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Sensitive:   true,
+		Description: "the (short-lived) bootstrap token in the exported bootstrap kubeconfig",
+	},
+	"kubelet_serving_certs": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "approve the CSRs for the kubelet serving certificates",
+	},
+	"sandbox_image": {
+		Type: schema.TypeString,
+		// Computed: true,
+		Optional: true,
+	},
+	"registry_mirrors": {
+		Type: schema.TypeString,
+		// Computed: true,
+		Optional:  true,
+		Sensitive: true,
+	},
+	"swap": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the swap mode for the kubelet (enabled or disabled)",
+	},
+	"manage_swap": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "turn off the swap in the nodes when it is disabled",
+	},
+	"kubelet_config": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "the KubeletConfiguration for the cluster",
+	},
+	"kubelet_reserved_auto": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "reserve resources for the system and kubernetes daemons scaled to the node size",
+	},
+	"kubelet_system_reserved": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "resources reserved for the system daemons (ie, cpu=100m,memory=256Mi)",
+	},
+	"kubelet_kube_reserved": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "resources reserved for the kubernetes daemons (ie, cpu=100m,memory=256Mi)",
+	},
+	"storage_manifest": {
+		Type:     schema.TypeString,
+		Optional: true,
+	},
+	"storage_class": {
+		Type:     schema.TypeString,
+		Optional: true,
+	},
+	"storage_class_default": {
+		Type:     schema.TypeString,
+		Optional: true,
+	},
+	"storage_namespace": {
+		Type:     schema.TypeString,
+		Optional: true,
+	},
+	"network_policy_deny_ingress": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "comma-separated list of namespaces where all the ingress traffic is denied by default",
+	},
+	"network_policy_deny_egress": {
+		Type:        schema.TypeString,
+		Optional:    true,
+		Description: "comma-separated list of namespaces where all the egress traffic is denied by default",
+	},
 	"dashboard_enabled": {
 		Type: schema.TypeBool,
 		// Computed: true,
@@ -0,0 +1,117 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// RBACKinds are the kinds of objects accepted in the RBAC manifests
+var RBACKinds = []string{
+	"ServiceAccount",
+	"Role",
+	"ClusterRole",
+	"RoleBinding",
+	"ClusterRoleBinding",
+}
+
+var (
+	// rbacKindRegexp matches the (top-level) kind of an object in a manifest
+	rbacKindRegexp = regexp.MustCompile(`(?m)^kind:[ \t]*["']?([A-Za-z]+)["']?[ \t]*$`)
+
+	// yamlDocumentSeparatorRegexp matches the separator between documents in a manifest
+	yamlDocumentSeparatorRegexp = regexp.MustCompile(`(?m)^---.*$`)
+)
+
+// RBACBinding is a binding of a (cluster) role to some groups and/or users
+type RBACBinding struct {
+	Name      string
+	Role      string
+	Namespace string
+	Groups    []string
+	Users     []string
+}
+
+// GetName returns the name of the binding (generated from the role when no name has been provided)
+func (b RBACBinding) GetName() string {
+	if b.Name != "" {
+		return b.Name
+	}
+	return "kubeadm-terraform:" + b.Role
+}
+
+// Manifest returns the manifest for the binding: a `RoleBinding` when a namespace
+// is provided, or a `ClusterRoleBinding` otherwise
+func (b RBACBinding) Manifest() string {
+	var sb strings.Builder
+	sb.WriteString("apiVersion: rbac.authorization.k8s.io/v1\n")
+	if b.Namespace != "" {
+		sb.WriteString("kind: RoleBinding\n")
+	} else {
+		sb.WriteString("kind: ClusterRoleBinding\n")
+	}
+	sb.WriteString("metadata:\n")
+	sb.WriteString(fmt.Sprintf("  name: %q\n", b.GetName()))
+	if b.Namespace != "" {
+		sb.WriteString(fmt.Sprintf("  namespace: %q\n", b.Namespace))
+	}
+	sb.WriteString("  labels:\n")
+	sb.WriteString("    app.kubernetes.io/managed-by: terraform-provider-kubeadm\n")
+	sb.WriteString("roleRef:\n")
+	sb.WriteString("  apiGroup: rbac.authorization.k8s.io\n")
+	sb.WriteString("  kind: ClusterRole\n")
+	sb.WriteString(fmt.Sprintf("  name: %q\n", b.Role))
+	sb.WriteString("subjects:\n")
+	for _, group := range b.Groups {
+		sb.WriteString("- apiGroup: rbac.authorization.k8s.io\n")
+		sb.WriteString("  kind: Group\n")
+		sb.WriteString(fmt.Sprintf("  name: %q\n", group))
+	}
+	for _, user := range b.Users {
+		sb.WriteString("- apiGroup: rbac.authorization.k8s.io\n")
+		sb.WriteString("  kind: User\n")
+		sb.WriteString(fmt.Sprintf("  name: %q\n", user))
+	}
+	return sb.String()
+}
+
+// isRBACKind returns true if `kind` is one of the RBACKinds
+func isRBACKind(kind string) bool {
+	for _, k := range RBACKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckRBACManifest checks that all the objects in an (inline) manifest are RBAC objects
+func CheckRBACManifest(manifest string) error {
+	for i, doc := range yamlDocumentSeparatorRegexp.Split(manifest, -1) {
+		if strings.TrimSpace(doc) == "" {
+			continue
+		}
+		matches := rbacKindRegexp.FindAllStringSubmatch(doc, -1)
+		if len(matches) != 1 {
+			return fmt.Errorf("document %d does not have a (single) 'kind'", i+1)
+		}
+		if kind := matches[0][1]; !isRBACKind(kind) {
+			return fmt.Errorf("document %d: %q is not a RBAC kind (expected one of %s)", i+1, kind, strings.Join(RBACKinds, ", "))
+		}
+	}
+	return nil
+}
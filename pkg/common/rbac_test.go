@@ -0,0 +1,93 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckRBACManifest(t *testing.T) {
+	testCases := []struct {
+		manifest    string
+		expectedErr bool
+	}{
+		{
+			`apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: viewer
+rules:
+- apiGroups: [""]
+  resources: ["pods"]
+  verbs: ["get", "list"]
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: viewers
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: viewer
+subjects:
+- kind: Group
+  name: oidc:viewers
+`,
+			false,
+		},
+		{
+			`apiVersion: v1
+kind: Namespace
+metadata:
+  name: something
+`,
+			true,
+		},
+		{
+			`apiVersion: v1
+metadata:
+  name: something
+`,
+			true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		err := CheckRBACManifest(testCase.manifest)
+		if (err != nil) != testCase.expectedErr {
+			t.Fatalf("Error: unexpected result for manifest:\n%s\nerror: %v", testCase.manifest, err)
+		}
+	}
+}
+
+func TestRBACBindingManifest(t *testing.T) {
+	binding := RBACBinding{Role: "view", Namespace: "apps", Groups: []string{"oidc:developers"}}
+	manifest := binding.Manifest()
+	if err := CheckRBACManifest(manifest); err != nil {
+		t.Fatalf("Error: invalid binding manifest: %s\n%s", err, manifest)
+	}
+	for _, expected := range []string{"kind: RoleBinding", `namespace: "apps"`, `name: "kubeadm-terraform:view"`, `name: "oidc:developers"`} {
+		if !strings.Contains(manifest, expected) {
+			t.Fatalf("Error: %q not found in binding manifest:\n%s", expected, manifest)
+		}
+	}
+
+	binding = RBACBinding{Name: "admins", Role: "cluster-admin", Users: []string{"alice"}}
+	manifest = binding.Manifest()
+	if !strings.Contains(manifest, "kind: ClusterRoleBinding") || strings.Contains(manifest, "namespace:") {
+		t.Fatalf("Error: unexpected cluster binding manifest:\n%s", manifest)
+	}
+}
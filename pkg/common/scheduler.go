@@ -0,0 +1,84 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// schedulerKindRegexp matches the kind of a scheduler configuration
+	schedulerKindRegexp = regexp.MustCompile(`(?m)^kind:[ \t]*["']?KubeSchedulerConfiguration["']?[ \t]*$`)
+
+	// schedulerAPIVersionRegexp matches the (top-level) apiVersion of a scheduler configuration
+	schedulerAPIVersionRegexp = regexp.MustCompile(`(?m)^apiVersion:[ \t]*["']?([^"'\s]+)["']?[ \t]*$`)
+
+	// schedulerClientConnectionRegexp matches the (top-level) clientConnection of a scheduler configuration
+	schedulerClientConnectionRegexp = regexp.MustCompile(`(?m)^clientConnection:`)
+
+	// schedulerKubeconfigRegexp matches the kubeconfig in the clientConnection
+	schedulerKubeconfigRegexp = regexp.MustCompile(`(?m)^[ \t]+kubeconfig:[ \t]*\S+`)
+)
+
+// SchedulerAPIVersions are the apiVersions supported for the scheduler configuration
+var SchedulerAPIVersions = []string{
+	"kubescheduler.config.k8s.io/v1alpha1",
+	"kubescheduler.config.k8s.io/v1alpha2",
+	"kubescheduler.config.k8s.io/v1beta1",
+	"kubescheduler.config.k8s.io/v1beta2",
+	"kubescheduler.config.k8s.io/v1beta3",
+	"kubescheduler.config.k8s.io/v1",
+}
+
+// CheckSchedulerConfig checks a scheduler configuration (a `KubeSchedulerConfiguration`),
+// returning an error on invalid configurations
+func CheckSchedulerConfig(config string) error {
+	if strings.Contains(config, "\n---") {
+		return errors.New("only one document is supported")
+	}
+
+	if !schedulerKindRegexp.MatchString(config) {
+		return errors.New("no 'kind: KubeSchedulerConfiguration' found")
+	}
+
+	m := schedulerAPIVersionRegexp.FindStringSubmatch(config)
+	if m == nil {
+		return errors.New("no 'apiVersion' found")
+	}
+	if !StringSliceContains(SchedulerAPIVersions, m[1]) {
+		return fmt.Errorf("unsupported apiVersion %q (supported: %v)", m[1], SchedulerAPIVersions)
+	}
+
+	if schedulerClientConnectionRegexp.MatchString(config) && !schedulerKubeconfigRegexp.MatchString(config) {
+		return fmt.Errorf("the 'clientConnection' must have a 'kubeconfig' (ie, %s)", DefSchedulerKubeconfigPath)
+	}
+	return nil
+}
+
+// SchedulerConfigWithKubeconfig returns the scheduler configuration with a `clientConnection`
+// pointing to the kubeconfig created by kubeadm (when no `clientConnection` is provided).
+// The scheduler ignores the `--kubeconfig` flag when a `--config` is used.
+func SchedulerConfigWithKubeconfig(config string) string {
+	if schedulerClientConnectionRegexp.MatchString(config) {
+		return config
+	}
+	if !strings.HasSuffix(config, "\n") {
+		config += "\n"
+	}
+	return config + fmt.Sprintf("clientConnection:\n  kubeconfig: %s\n", DefSchedulerKubeconfigPath)
+}
@@ -0,0 +1,90 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"testing"
+)
+
+func TestCheckSchedulerConfig(t *testing.T) {
+	testCases := []struct {
+		config      string
+		expectedErr bool
+	}{
+		{
+			`apiVersion: kubescheduler.config.k8s.io/v1
+kind: KubeSchedulerConfiguration
+profiles:
+- schedulerName: default-scheduler
+  plugins:
+    score:
+      enabled:
+      - name: NodeResourcesBalancedAllocation
+        weight: 2
+`, false,
+		},
+		{
+			`apiVersion: kubescheduler.config.k8s.io/v1beta3
+kind: KubeSchedulerConfiguration
+clientConnection:
+  kubeconfig: /etc/kubernetes/scheduler.conf
+`, false,
+		},
+		{
+			// wrong kind
+			`apiVersion: kubescheduler.config.k8s.io/v1
+kind: KubeProxyConfiguration
+`, true,
+		},
+		{
+			// unsupported apiVersion
+			`apiVersion: kubescheduler.config.k8s.io/v2
+kind: KubeSchedulerConfiguration
+`, true,
+		},
+		{
+			// no kubeconfig in the clientConnection
+			`apiVersion: kubescheduler.config.k8s.io/v1
+kind: KubeSchedulerConfiguration
+clientConnection:
+  qps: 100
+`, true,
+		},
+	}
+
+	for i, testCase := range testCases {
+		err := CheckSchedulerConfig(testCase.config)
+		if testCase.expectedErr && err == nil {
+			t.Fatalf("Error: test case %d: error expected but not found", i)
+		}
+		if !testCase.expectedErr && err != nil {
+			t.Fatalf("Error: test case %d: unexpected error: %s", i, err)
+		}
+	}
+}
+
+func TestSchedulerConfigWithKubeconfig(t *testing.T) {
+	config := "apiVersion: kubescheduler.config.k8s.io/v1\nkind: KubeSchedulerConfiguration"
+	res := SchedulerConfigWithKubeconfig(config)
+	if err := CheckSchedulerConfig(res); err != nil {
+		t.Fatalf("Error: unexpected error: %s", err)
+	}
+	if !schedulerKubeconfigRegexp.MatchString(res) {
+		t.Fatalf("Error: no kubeconfig added in %q", res)
+	}
+	if SchedulerConfigWithKubeconfig(res) != res {
+		t.Fatalf("Error: the kubeconfig was added twice")
+	}
+}
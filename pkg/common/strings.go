@@ -26,3 +26,39 @@ func StringSliceUnique(slice []string) []string {
 	}
 	return list
 }
+
+// InterfacesToStrings converts a list of interfaces (ie, a `TypeList` of strings
+// in the schema) to a list of (non-empty and unique) strings
+func InterfacesToStrings(slice []interface{}) []string {
+	list := []string{}
+	for _, entry := range slice {
+		if s, ok := entry.(string); ok && s != "" {
+			list = append(list, s)
+		}
+	}
+	return StringSliceUnique(list)
+}
+
+// InterfacesMapToStrings converts a map of interfaces (ie, a `TypeMap` of strings
+// in the schema) to a map of strings
+func InterfacesMapToStrings(m interface{}) map[string]string {
+	res := map[string]string{}
+	if mm, ok := m.(map[string]interface{}); ok {
+		for k, v := range mm {
+			if s, ok := v.(string); ok {
+				res[k] = s
+			}
+		}
+	}
+	return res
+}
+
+// StringSliceContains returns true if some string is in a string slice
+func StringSliceContains(slice []string, s string) bool {
+	for _, entry := range slice {
+		if entry == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// SwapEnabled is the swap mode when the kubelet can use the swap in the nodes
+	SwapEnabled = "enabled"
+
+	// SwapDisabled is the swap mode when the kubelet fails to start if swap is on
+	SwapDisabled = "disabled"
+
+	// DefSwapBehavior is the default swap behavior when swap is enabled
+	DefSwapBehavior = "LimitedSwap"
+)
+
+// SwapBehaviors are the valid `memorySwap.swapBehavior` values
+var SwapBehaviors = []string{"NoSwap", "LimitedSwap", "UnlimitedSwap"}
+
+var (
+	// kubeletFailSwapOnRegexp matches the `failSwapOn` in a KubeletConfiguration
+	kubeletFailSwapOnRegexp = regexp.MustCompile(`(?m)^failSwapOn:`)
+
+	// kubeletMemorySwapRegexp matches the `memorySwap` in a KubeletConfiguration
+	kubeletMemorySwapRegexp = regexp.MustCompile(`(?m)^memorySwap:`)
+
+	// kubeletFeatureGatesRegexp matches the `featureGates` in a KubeletConfiguration
+	kubeletFeatureGatesRegexp = regexp.MustCompile(`(?m)^featureGates:`)
+
+	// kubeletNodeSwapGateRegexp matches the `NodeSwap` feature gate enabled in a KubeletConfiguration
+	kubeletNodeSwapGateRegexp = regexp.MustCompile(`(?m)^[ \t]+NodeSwap:[ \t]*true[ \t]*$`)
+)
+
+// CheckSwapVersion checks the swap settings are supported in a Kubernetes version
+func CheckSwapVersion(enabled bool, behavior string, kubeVersion string) error {
+	if !enabled {
+		return nil
+	}
+	if !KubeVersionAtLeast(kubeVersion, 1, 28) {
+		return fmt.Errorf("swap can only be enabled in Kubernetes 1.28 or higher (version: %s)", kubeVersion)
+	}
+	if !StringSliceContains(SwapBehaviors, behavior) {
+		return fmt.Errorf("unknown swap behavior %q (valid: %v)", behavior, SwapBehaviors)
+	}
+	if behavior == "UnlimitedSwap" && KubeVersionAtLeast(kubeVersion, 1, 30) {
+		return fmt.Errorf("the UnlimitedSwap behavior has been removed in Kubernetes 1.30 (version: %s)", kubeVersion)
+	}
+	return nil
+}
+
+// SwapKubeletConfig returns the KubeletConfiguration with the swap settings
+// (`failSwapOn`, `memorySwap` and the `NodeSwap` feature gate when necessary),
+// creating a new KubeletConfiguration when `kubeletConfig` is empty.
+// The swap settings cannot be present in the `kubeletConfig`.
+func SwapKubeletConfig(kubeletConfig string, enabled bool, behavior string, kubeVersion string) (string, error) {
+	if err := CheckSwapVersion(enabled, behavior, kubeVersion); err != nil {
+		return "", err
+	}
+
+	if kubeletFailSwapOnRegexp.MatchString(kubeletConfig) || kubeletMemorySwapRegexp.MatchString(kubeletConfig) {
+		return "", fmt.Errorf("'failSwapOn' and 'memorySwap' cannot be set in the KubeletConfiguration when the swap is managed")
+	}
+
+	// the NodeSwap feature gate is disabled by default before 1.30
+	nodeSwapGate := enabled && !KubeVersionAtLeast(kubeVersion, 1, 30)
+	if nodeSwapGate && kubeletFeatureGatesRegexp.MatchString(kubeletConfig) {
+		if !kubeletNodeSwapGateRegexp.MatchString(kubeletConfig) {
+			return "", fmt.Errorf("the 'NodeSwap: true' feature gate must be added to the 'featureGates' in the KubeletConfiguration")
+		}
+		nodeSwapGate = false
+	}
+
+	config := strings.TrimRight(kubeletConfig, "\n")
+	if strings.TrimSpace(config) == "" {
+		config = fmt.Sprintf("apiVersion: %s/v1beta1\nkind: %s", KubeletConfigGroup, KubeletConfigKind)
+	}
+
+	config += fmt.Sprintf("\nfailSwapOn: %t\n", !enabled)
+	if enabled {
+		config += fmt.Sprintf("memorySwap:\n  swapBehavior: %s\n", behavior)
+	}
+	if nodeSwapGate {
+		config += "featureGates:\n  NodeSwap: true\n"
+	}
+	return config, nil
+}
@@ -0,0 +1,81 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"testing"
+)
+
+func TestSwapKubeletConfig(t *testing.T) {
+	testCases := []struct {
+		kubeletConfig string
+		enabled       bool
+		behavior      string
+		version       string
+		expected      string
+		expectedErr   bool
+	}{
+		{
+			"", false, "", "v1.27.0",
+			"apiVersion: kubelet.config.k8s.io/v1beta1\nkind: KubeletConfiguration\nfailSwapOn: true\n",
+			false,
+		},
+		{
+			"", true, "LimitedSwap", "v1.28.2",
+			"apiVersion: kubelet.config.k8s.io/v1beta1\nkind: KubeletConfiguration\nfailSwapOn: false\nmemorySwap:\n  swapBehavior: LimitedSwap\nfeatureGates:\n  NodeSwap: true\n",
+			false,
+		},
+		{
+			"apiVersion: kubelet.config.k8s.io/v1beta1\nkind: KubeletConfiguration\nmaxPods: 200\n", true, "NoSwap", "v1.30.0",
+			"apiVersion: kubelet.config.k8s.io/v1beta1\nkind: KubeletConfiguration\nmaxPods: 200\nfailSwapOn: false\nmemorySwap:\n  swapBehavior: NoSwap\n",
+			false,
+		},
+		{
+			// NodeSwap already enabled by the user
+			"apiVersion: kubelet.config.k8s.io/v1beta1\nkind: KubeletConfiguration\nfeatureGates:\n  NodeSwap: true\n", true, "LimitedSwap", "v1.29.0",
+			"apiVersion: kubelet.config.k8s.io/v1beta1\nkind: KubeletConfiguration\nfeatureGates:\n  NodeSwap: true\nfailSwapOn: false\nmemorySwap:\n  swapBehavior: LimitedSwap\n",
+			false,
+		},
+		{
+			// other feature gates, but not NodeSwap
+			"apiVersion: kubelet.config.k8s.io/v1beta1\nkind: KubeletConfiguration\nfeatureGates:\n  GracefulNodeShutdown: true\n", true, "LimitedSwap", "v1.29.0",
+			"", true,
+		},
+		{
+			// swap settings already present
+			"apiVersion: kubelet.config.k8s.io/v1beta1\nkind: KubeletConfiguration\nfailSwapOn: false\n", false, "", "v1.29.0",
+			"", true,
+		},
+		{"", true, "LimitedSwap", "v1.27.5", "", true},
+		{"", true, "UnlimitedSwap", "v1.30.0", "", true},
+		{"", true, "SomeSwap", "v1.29.0", "", true},
+	}
+
+	for i, testCase := range testCases {
+		res, err := SwapKubeletConfig(testCase.kubeletConfig, testCase.enabled, testCase.behavior, testCase.version)
+		if testCase.expectedErr {
+			if err == nil {
+				t.Fatalf("Error: test case %d: error expected but not found", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Error: test case %d: unexpected error: %s", i, err)
+		}
+		if res != testCase.expected {
+			t.Fatalf("Error: test case %d: expected %q, got %q", i, testCase.expected, res)
+		}
+	}
+}
@@ -15,11 +15,15 @@
 package common
 
 import (
+	"encoding/pem"
 	"fmt"
 	"net"
 	"net/url"
 	"path/filepath"
 	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/hashicorp/terraform/helper/validation"
 )
@@ -32,6 +36,120 @@ var DnsRegexMatcher = regexp.MustCompile(dnsRegex)
 var ValidateDNSName = validation.StringMatch(DnsRegexMatcher,
 	"the DNS name does not follow  RFC 952 and RFC 1123 requirements")
 
+// dns1123LabelRegexp matches a RFC 1123 label (ie, a valid namespace name)
+var dns1123LabelRegexp = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+
+// ValidateNamespace validates a namespace name (a RFC 1123 label, ie, "kube-system")
+func ValidateNamespace(v interface{}, k string) (ws []string, errors []error) {
+	namespace := v.(string)
+	if len(namespace) > 63 || !dns1123LabelRegexp.MatchString(namespace) {
+		errors = append(errors, fmt.Errorf("%q: %q is not a valid namespace name: it must be a RFC 1123 label (up to 63 lowercase alphanumeric characters or '-')", k, namespace))
+	}
+	return
+}
+
+// ValidateClusterName validates a cluster name (a RFC 1123 label, ie, "prod-eu1")
+func ValidateClusterName(v interface{}, k string) (ws []string, errors []error) {
+	name := v.(string)
+	if len(name) > 63 || !dns1123LabelRegexp.MatchString(name) {
+		errors = append(errors, fmt.Errorf("%q: %q is not a valid cluster name: it must be a RFC 1123 label (up to 63 lowercase alphanumeric characters or '-')", k, name))
+	}
+	return
+}
+
+// ValidateVolumeName validates a volume name (a RFC 1123 label, ie, "audit-logs")
+func ValidateVolumeName(v interface{}, k string) (ws []string, errors []error) {
+	name := v.(string)
+	if len(name) > 63 || !dns1123LabelRegexp.MatchString(name) {
+		errors = append(errors, fmt.Errorf("%q: %q is not a valid volume name: it must be a RFC 1123 label (up to 63 lowercase alphanumeric characters or '-')", k, name))
+	}
+	return
+}
+
+// ValidateKubeletExtraArgs validates a map of kubelet arguments, with bare names (ie, "max-pods")
+func ValidateKubeletExtraArgs(v interface{}, k string) (ws []string, errors []error) {
+	for arg := range v.(map[string]interface{}) {
+		if arg == "" || strings.HasPrefix(arg, "-") || strings.ContainsAny(arg, "= ") {
+			errors = append(errors, fmt.Errorf("%q: %q is not a valid argument name: use the bare name (ie, \"max-pods\" instead of \"--max-pods\")", k, arg))
+		}
+	}
+	return
+}
+
+var (
+	// labelKeyRegexp matches a label key, with an optional DNS prefix (ie, "example.com/role")
+	labelKeyRegexp = regexp.MustCompile(`^([a-z0-9]([-a-z0-9.]*[a-z0-9])?/)?[A-Za-z0-9]([-A-Za-z0-9_.]{0,61}[A-Za-z0-9])?$`)
+
+	// labelValueRegexp matches a (possibly empty) label value
+	labelValueRegexp = regexp.MustCompile(`^([A-Za-z0-9]([-A-Za-z0-9_.]{0,61}[A-Za-z0-9])?)?$`)
+
+	// labelSetRequirementRegexp matches a set-based requirement (ie, "env in (prod, staging)")
+	labelSetRequirementRegexp = regexp.MustCompile(`^(\S+)\s+(in|notin)\s+\(([^()]*)\)$`)
+
+	// labelRequirementRegexp matches an equality-based or existence requirement (ie, "env!=prod" or "!env")
+	labelRequirementRegexp = regexp.MustCompile(`^(!?)\s*([^=!\s]+)\s*(?:(==|=|!=)\s*(\S*))?$`)
+)
+
+// splitLabelSelector splits a label selector in its requirements (the commas
+// inside the values of a set-based requirement are not separators)
+func splitLabelSelector(selector string) []string {
+	requirements := []string{}
+	depth, start := 0, 0
+	for i, c := range selector {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				requirements = append(requirements, strings.TrimSpace(selector[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(requirements, strings.TrimSpace(selector[start:]))
+}
+
+// ValidateLabelSelector validates a label selector (ie, "app=web,tier notin (cache)")
+func ValidateLabelSelector(v interface{}, k string) (ws []string, errors []error) {
+	selector := v.(string)
+	if strings.TrimSpace(selector) == "" {
+		return
+	}
+	for _, requirement := range splitLabelSelector(selector) {
+		valid := false
+		if m := labelSetRequirementRegexp.FindStringSubmatch(requirement); m != nil {
+			valid = labelKeyRegexp.MatchString(m[1])
+			for _, value := range strings.Split(m[3], ",") {
+				valid = valid && labelValueRegexp.MatchString(strings.TrimSpace(value))
+			}
+		} else if m := labelRequirementRegexp.FindStringSubmatch(requirement); m != nil {
+			valid = labelKeyRegexp.MatchString(m[2]) && labelValueRegexp.MatchString(m[4]) && !(m[1] == "!" && m[3] != "")
+		}
+		if !valid {
+			errors = append(errors, fmt.Errorf("%q: invalid requirement %q in label selector %q", k, requirement, selector))
+		}
+	}
+	return
+}
+
+// ValidateNodeLabel validates a label as "key[=value]" (ie, "example.com/role=control-plane")
+func ValidateNodeLabel(v interface{}, k string) (ws []string, errors []error) {
+	label := v.(string)
+	key, value := label, ""
+	if i := strings.Index(label, "="); i >= 0 {
+		key, value = label[:i], label[i+1:]
+	}
+	if !labelKeyRegexp.MatchString(key) {
+		errors = append(errors, fmt.Errorf("%q: %q is not a valid label key", k, key))
+	}
+	if !labelValueRegexp.MatchString(value) {
+		errors = append(errors, fmt.Errorf("%q: %q is not a valid label value", k, value))
+	}
+	return
+}
+
 // ValidateDNSNameOrIP is a regular expression for validating a DNS name or an IP
 var ValidateDNSNameOrIP = validation.Any(validation.SingleIP(), ValidateDNSName)
 
@@ -55,3 +173,333 @@ func ValidateURL(v interface{}, k string) (ws []string, errors []error) {
 	}
 	return
 }
+
+// ValidateCIDRs validates a comma-separated list of CIDRs: it can be a
+// single CIDR, or one IPv4 and one IPv6 CIDRs (for dual-stack)
+func ValidateCIDRs(v interface{}, k string) (ws []string, errors []error) {
+	cidrs, err := ParseCIDRs(v.(string))
+	if err != nil {
+		errors = append(errors, fmt.Errorf("%q does not contain valid CIDRs: %s", k, err))
+		return
+	}
+	switch len(cidrs) {
+	case 1:
+	case 2:
+		if (cidrs[0].IP.To4() != nil) == (cidrs[1].IP.To4() != nil) {
+			errors = append(errors, fmt.Errorf("%q must contain one IPv4 and one IPv6 CIDRs for dual-stack", k))
+		}
+	default:
+		errors = append(errors, fmt.Errorf("%q must contain one CIDR (or two for dual-stack)", k))
+	}
+	return
+}
+
+// ValidateIPs validates a comma-separated list of IP addresses: it can be a
+// single IP, or one IPv4 and one IPv6 addresses (for dual-stack)
+func ValidateIPs(v interface{}, k string) (ws []string, errors []error) {
+	ips := []net.IP{}
+	for _, s := range strings.Split(v.(string), ",") {
+		ip := net.ParseIP(strings.TrimSpace(s))
+		if ip == nil {
+			errors = append(errors, fmt.Errorf("%q: %q is not a valid IP address", k, s))
+			return
+		}
+		ips = append(ips, ip)
+	}
+	switch len(ips) {
+	case 1:
+	case 2:
+		if (ips[0].To4() != nil) == (ips[1].To4() != nil) {
+			errors = append(errors, fmt.Errorf("%q must contain one IPv4 and one IPv6 addresses for dual-stack", k))
+		}
+	default:
+		errors = append(errors, fmt.Errorf("%q must contain one IP address (or two for dual-stack)", k))
+	}
+	return
+}
+
+var (
+	// known names of kubeadm preflight checks
+	knownPreflightChecks = []string{
+		"all",
+		"CRI",
+		"ExternalEtcdVersion",
+		"Firewalld",
+		"Hostname",
+		"HTTPProxy",
+		"HTTPProxyCIDR",
+		"ImagePull",
+		"IsDockerSystemdCheck",
+		"IsPrivilegedUser",
+		"KubeletVersion",
+		"KubernetesVersion",
+		"Mem",
+		"NumCPU",
+		"Swap",
+		"SystemVerification",
+	}
+
+	// known prefixes of kubeadm preflight checks (ie, "Port-6443")
+	knownPreflightChecksPrefixes = []string{
+		"DirAvailable--",
+		"FileAvailable--",
+		"FileContent--",
+		"FileExisting-",
+		"HTTPProxy-",
+		"HTTPProxyCIDR-",
+		"Port-",
+		"Service-",
+	}
+)
+
+var (
+	// known phases (and sub-phases) of `kubeadm init phase`
+	knownInitPhases = []string{
+		"preflight",
+		"certs", "certs/all", "certs/ca", "certs/apiserver", "certs/apiserver-kubelet-client",
+		"certs/front-proxy-ca", "certs/front-proxy-client", "certs/etcd-ca", "certs/etcd-server",
+		"certs/etcd-peer", "certs/etcd-healthcheck-client", "certs/apiserver-etcd-client", "certs/sa",
+		"kubeconfig", "kubeconfig/all", "kubeconfig/admin", "kubeconfig/super-admin", "kubeconfig/kubelet",
+		"kubeconfig/controller-manager", "kubeconfig/scheduler",
+		"etcd", "etcd/local",
+		"control-plane", "control-plane/all", "control-plane/apiserver",
+		"control-plane/controller-manager", "control-plane/scheduler",
+		"kubelet-start",
+		"wait-control-plane",
+		"upload-config", "upload-config/all", "upload-config/kubeadm", "upload-config/kubelet",
+		"upload-certs",
+		"mark-control-plane",
+		"bootstrap-token",
+		"kubelet-finalize", "kubelet-finalize/all", "kubelet-finalize/enable-client-cert-rotation",
+		"kubelet-finalize/experimental-cert-rotation",
+		"addon", "addon/all", "addon/coredns", "addon/kube-proxy",
+		"show-join-command",
+	}
+)
+
+// ParseInitPhase parses a `kubeadm init` phase with some optional flags
+// (ie, "addon/coredns" or "upload-certs --upload-certs"), returning the phase and the flags
+func ParseInitPhase(s string) (string, []string, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return "", nil, fmt.Errorf("empty phase")
+	}
+	phase, args := fields[0], fields[1:]
+	known := false
+	for _, p := range knownInitPhases {
+		if phase == p {
+			known = true
+			break
+		}
+	}
+	if !known {
+		return "", nil, fmt.Errorf("%q is not a valid 'kubeadm init' phase", phase)
+	}
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "--") {
+			return "", nil, fmt.Errorf("invalid argument %q for phase %q: only flags (ie, '--upload-certs') are allowed", arg, phase)
+		}
+	}
+	return phase, args, nil
+}
+
+// ValidateInitPhase validates a `kubeadm init` phase with some optional flags
+func ValidateInitPhase(v interface{}, k string) (ws []string, errors []error) {
+	if _, _, err := ParseInitPhase(v.(string)); err != nil {
+		errors = append(errors, fmt.Errorf("%q: %s", k, err))
+	}
+	return
+}
+
+// ValidateEtcdVersion validates an etcd version (ie, "3.4.13" or "3.5.9-0")
+func ValidateEtcdVersion(v interface{}, k string) (ws []string, errors []error) {
+	if _, err := ParseEtcdVersion(v.(string)); err != nil {
+		errors = append(errors, fmt.Errorf("%q: %s", k, err))
+	}
+	return
+}
+
+// ValidateDuration validates a (positive) duration (ie, "1m30s")
+func ValidateDuration(v interface{}, k string) (ws []string, errors []error) {
+	duration, err := time.ParseDuration(v.(string))
+	if err != nil {
+		errors = append(errors, fmt.Errorf("%q: %q is not a valid duration (ie, 1m30s): %s", k, v.(string), err))
+		return
+	}
+	if duration <= 0 {
+		errors = append(errors, fmt.Errorf("%q: the duration must be positive", k))
+	}
+	return
+}
+
+// imageReferenceRegexp matches a container image reference, with an optional
+// registry (with an optional port), a tag and/or a digest (ie, "registry.local:5000/pause:3.9")
+var imageReferenceRegexp = regexp.MustCompile(`^([a-zA-Z0-9.-]+(:[0-9]+)?/)?[a-z0-9]+([._-][a-z0-9]+)*(/[a-z0-9]+([._-][a-z0-9]+)*)*(:[a-zA-Z0-9_][a-zA-Z0-9_.-]{0,127})?(@sha256:[a-f0-9]{64})?$`)
+
+// ValidateImageReference validates a container image reference (ie, "registry.local/pause:3.9").
+// References without a tag or digest produce a warning, as they would use the "latest" tag.
+func ValidateImageReference(v interface{}, k string) (ws []string, errors []error) {
+	image := v.(string)
+	if !imageReferenceRegexp.MatchString(image) {
+		errors = append(errors, fmt.Errorf("%q: %q is not a valid image reference (ie, registry.local:5000/pause:3.9)", k, image))
+		return
+	}
+	name := image[strings.LastIndex(image, "/")+1:]
+	if !strings.Contains(name, ":") && !strings.Contains(name, "@") {
+		ws = append(ws, fmt.Sprintf("%q: %q has no tag or digest: the 'latest' tag will be used", k, image))
+	}
+	return
+}
+
+// logSizeRegexp matches a size for the container logs (ie, "10Mi")
+var logSizeRegexp = regexp.MustCompile(`^[1-9][0-9]*(Ki|Mi|Gi|K|M|G)?$`)
+
+// ValidateLogSize validates a size for the container logs, as a quantity (ie, "10Mi")
+func ValidateLogSize(v interface{}, k string) (ws []string, errors []error) {
+	if !logSizeRegexp.MatchString(v.(string)) {
+		errors = append(errors, fmt.Errorf("%q: %q is not a valid size (ie, 10Mi, 1Gi)", k, v.(string)))
+	}
+	return
+}
+
+// quantityRegexp matches a resource quantity (ie, "100m", "0.5" or "256Mi")
+var quantityRegexp = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?(m|k|M|G|T|P|Ki|Mi|Gi|Ti|Pi)?$`)
+
+// ValidateQuantity validates a resource quantity, like a CPU (ie, "100m") or memory (ie, "256Mi") amount
+func ValidateQuantity(v interface{}, k string) (ws []string, errors []error) {
+	if !quantityRegexp.MatchString(v.(string)) {
+		errors = append(errors, fmt.Errorf("%q: %q is not a valid quantity (ie, 100m, 0.5, 256Mi)", k, v.(string)))
+	}
+	return
+}
+
+// resourceNameRegexp matches a resource name, with an optional DNS prefix
+// (ie, "requests.cpu", "count/deployments.apps" or "example.com/gpu")
+var resourceNameRegexp = regexp.MustCompile(`^([a-z0-9]([-a-z0-9.]*[a-z0-9])?/)?[a-z0-9]([-a-z0-9.]*[a-z0-9])?$`)
+
+// ValidateResourceQuantities validates a map of resource names to quantities (ie, {"requests.cpu": "4"}),
+// like the hard limits in a ResourceQuota or the limits in a LimitRange
+func ValidateResourceQuantities(v interface{}, k string) (ws []string, errors []error) {
+	for resource, quantity := range v.(map[string]interface{}) {
+		if !resourceNameRegexp.MatchString(resource) {
+			errors = append(errors, fmt.Errorf("%q: %q is not a valid resource name (ie, requests.cpu, pods)", k, resource))
+		}
+		if !quantityRegexp.MatchString(quantity.(string)) {
+			errors = append(errors, fmt.Errorf("%q: %q is not a valid quantity for %q (ie, 100m, 0.5, 256Mi)", k, quantity.(string), resource))
+		}
+	}
+	return
+}
+
+// ValidatePublicKey validates a PEM-encoded public key
+func ValidatePublicKey(v interface{}, k string) (ws []string, errors []error) {
+	block, _ := pem.Decode([]byte(v.(string)))
+	if block == nil || !strings.HasSuffix(block.Type, "PUBLIC KEY") {
+		errors = append(errors, fmt.Errorf("%q: not a PEM-encoded public key", k))
+	}
+	return
+}
+
+// ValidateRBACManifest validates a RBAC manifest. Only inline manifests are
+// checked: URLs and local files are loaded as they are.
+func ValidateRBACManifest(v interface{}, k string) (ws []string, errors []error) {
+	manifest := v.(string)
+	if !strings.Contains(strings.TrimSpace(manifest), "\n") {
+		return
+	}
+	if err := CheckRBACManifest(manifest); err != nil {
+		errors = append(errors, fmt.Errorf("%q: invalid RBAC manifest: %s", k, err))
+	}
+	return
+}
+
+// ValidateEgressSelectorConfig validates an API server egress selector configuration
+func ValidateEgressSelectorConfig(v interface{}, k string) (ws []string, errors []error) {
+	if err := CheckEgressSelectorConfig(v.(string)); err != nil {
+		errors = append(errors, fmt.Errorf("%q: invalid egress selector configuration: %s", k, err))
+	}
+	return
+}
+
+// ValidateSchedulerConfig validates a scheduler configuration
+func ValidateSchedulerConfig(v interface{}, k string) (ws []string, errors []error) {
+	if err := CheckSchedulerConfig(v.(string)); err != nil {
+		errors = append(errors, fmt.Errorf("%q: invalid scheduler configuration: %s", k, err))
+	}
+	return
+}
+
+// wellKnownPorts are the ports used by the control plane components, which
+// cannot be part of the NodePorts range
+var wellKnownPorts = map[int]string{
+	DefAPIServerPort: "kube-apiserver",
+	2379:             "etcd",
+	2380:             "etcd peers",
+	10250:            "kubelet",
+	10251:            "kube-scheduler",
+	10252:            "kube-controller-manager",
+	10256:            "kube-proxy",
+	10257:            "kube-controller-manager",
+	10259:            "kube-scheduler",
+}
+
+// ParsePortRange parses a range of ports like "30000-32767"
+func ParsePortRange(s string) (int, int, error) {
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("%q is not a range of ports (ie, 30000-32767)", s)
+	}
+	first, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return 0, 0, fmt.Errorf("%q: invalid first port: %s", s, err)
+	}
+	last, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return 0, 0, fmt.Errorf("%q: invalid last port: %s", s, err)
+	}
+	if first < 1 || last > 65535 || first >= last {
+		return 0, 0, fmt.Errorf("%q is not a valid range of ports: ports must be in 1-65535, and the first one lower than the last one", s)
+	}
+	return first, last, nil
+}
+
+// ValidatePortRange validates a range of ports for NodePort services, checking
+// it does not include any of the ports used by the control plane.
+func ValidatePortRange(v interface{}, k string) (ws []string, errors []error) {
+	first, last, err := ParsePortRange(v.(string))
+	if err != nil {
+		errors = append(errors, fmt.Errorf("%q: %s", k, err))
+		return
+	}
+	for port, component := range wellKnownPorts {
+		if port >= first && port <= last {
+			errors = append(errors, fmt.Errorf("%q: range %d-%d includes port %d, used by %s", k, first, last, port, component))
+		}
+	}
+	if first < 1024 {
+		ws = append(ws, fmt.Sprintf("%q: range %d-%d includes privileged ports (<1024)", k, first, last))
+	}
+	return
+}
+
+// ValidatePreflightCheck validates the name of a kubeadm preflight check.
+// Unknown names just produce a warning, as new checks can be added in kubeadm.
+func ValidatePreflightCheck(v interface{}, k string) (ws []string, errors []error) {
+	check := strings.TrimSpace(v.(string))
+	if check == "" {
+		errors = append(errors, fmt.Errorf("%q: empty preflight check name", k))
+		return
+	}
+	for _, known := range knownPreflightChecks {
+		if strings.EqualFold(check, known) {
+			return
+		}
+	}
+	for _, prefix := range knownPreflightChecksPrefixes {
+		if len(check) > len(prefix) && strings.EqualFold(check[:len(prefix)], prefix) {
+			return
+		}
+	}
+	ws = append(ws, fmt.Sprintf("%q: %q is not a known kubeadm preflight check", k, check))
+	return
+}
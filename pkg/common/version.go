@@ -0,0 +1,103 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ParseKubeVersion parses a kubernetes version (ie, "v1.15.0"), returning the major and minor numbers
+func ParseKubeVersion(version string) (int, int, error) {
+	components := strings.Split(strings.TrimPrefix(strings.TrimSpace(version), "v"), ".")
+	if len(components) < 2 {
+		return 0, 0, fmt.Errorf("could not parse version %q", version)
+	}
+	major, err := strconv.Atoi(components[0])
+	if err != nil {
+		return 0, 0, fmt.Errorf("could not parse major in version %q: %s", version, err)
+	}
+	minor, err := strconv.Atoi(components[1])
+	if err != nil {
+		return 0, 0, fmt.Errorf("could not parse minor in version %q: %s", version, err)
+	}
+	return major, minor, nil
+}
+
+// KubeVersionAtLeast returns true if a kubernetes version is greater or equal than major.minor
+// (unparseable versions, like "stable", are considered as the latest version)
+func KubeVersionAtLeast(version string, major, minor int) bool {
+	vMajor, vMinor, err := ParseKubeVersion(version)
+	if err != nil {
+		return true
+	}
+	return vMajor > major || (vMajor == major && vMinor >= minor)
+}
+
+// etcdMinorVersions are the etcd minor versions used by kubeadm, starting
+// at some kubernetes minor version (ie, etcd 3.4 since v1.17)
+var etcdMinorVersions = []struct {
+	kubeMinor int
+	etcd      string
+}{
+	{13, "3.2"},
+	{14, "3.3"},
+	{17, "3.4"},
+	{22, "3.5"},
+}
+
+// etcdVersionRegexp matches an etcd image tag (ie, "3.3.10", "v3.4.3" or "3.5.9-0")
+var etcdVersionRegexp = regexp.MustCompile(`^v?([0-9]+)\.([0-9]+)\.([0-9]+)(-[0-9]+)?$`)
+
+// ParseEtcdVersion parses an etcd version (ie, "3.5.9-0"), returning the "major.minor" version
+func ParseEtcdVersion(version string) (string, error) {
+	m := etcdVersionRegexp.FindStringSubmatch(strings.TrimSpace(version))
+	if m == nil {
+		return "", fmt.Errorf("%q is not a valid etcd version (ie, 3.4.13 or 3.5.9-0)", version)
+	}
+	return fmt.Sprintf("%s.%s", m[1], m[2]), nil
+}
+
+// EtcdMinorVersionFor returns the etcd "major.minor" version used by kubeadm for
+// some kubernetes version (or an empty string when unknown)
+func EtcdMinorVersionFor(kubeVersion string) string {
+	major, minor, err := ParseKubeVersion(kubeVersion)
+	if err != nil || major != 1 {
+		return ""
+	}
+	res := ""
+	for _, v := range etcdMinorVersions {
+		if minor >= v.kubeMinor {
+			res = v.etcd
+		}
+	}
+	return res
+}
+
+// CheckEtcdVersion checks that an etcd version is the one kubeadm uses for some
+// kubernetes version (comparing the "major.minor" versions)
+func CheckEtcdVersion(etcdVersion string, kubeVersion string) error {
+	etcdMinor, err := ParseEtcdVersion(etcdVersion)
+	if err != nil {
+		return err
+	}
+	expected := EtcdMinorVersionFor(kubeVersion)
+	if expected == "" || expected == etcdMinor {
+		return nil
+	}
+	return fmt.Errorf("etcd %s is not the version kubeadm uses for Kubernetes %s (etcd %s.x)", etcdVersion, kubeVersion, expected)
+}
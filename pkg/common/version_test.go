@@ -0,0 +1,44 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package common
+
+import (
+	"testing"
+)
+
+func TestCheckEtcdVersion(t *testing.T) {
+	testsCases := []struct {
+		etcdVersion string
+		kubeVersion string
+		valid       bool
+	}{
+		{"3.3.10", "v1.15.0", true},
+		{"v3.3.15", "v1.16.2", true},
+		{"3.4.13-0", "v1.20.0", true},
+		{"3.5.9-0", "v1.28.1", true},
+		{"3.5.9-0", "v1.15.0", false},
+		{"3.3.10", "v1.22.0", false},
+		{"3.5.9-0", "stable", true},
+		{"latest", "v1.15.0", false},
+		{"3.5", "v1.22.0", false},
+	}
+
+	for _, testCase := range testsCases {
+		err := CheckEtcdVersion(testCase.etcdVersion, testCase.kubeVersion)
+		if (err == nil) != testCase.valid {
+			t.Fatalf("Error: unexpected result for etcd %q and Kubernetes %q: %v", testCase.etcdVersion, testCase.kubeVersion, err)
+		}
+	}
+}
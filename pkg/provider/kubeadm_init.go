@@ -17,8 +17,11 @@ package provider
 import (
 	"fmt"
 	"net"
+	"path/filepath"
 	"regexp"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/hashicorp/terraform/helper/schema"
 	kubeadmapi "k8s.io/kubernetes/cmd/kubeadm/app/apis/kubeadm"
@@ -45,6 +48,10 @@ func dataSourceToInitConfig(d *schema.ResourceData, token string) (*kubeadmapi.I
 		},
 	}
 
+	if clusterName, ok := d.GetOk("cluster_name"); ok {
+		initConfig.ClusterName = clusterName.(string)
+	}
+
 	if _, ok := d.GetOk("api.0"); ok {
 		if external, ok := d.GetOk("api.0.external"); ok {
 			initConfig.ControlPlaneEndpoint = common.AddressWithPort(external.(string), common.DefAPIServerPort)
@@ -81,6 +88,29 @@ func dataSourceToInitConfig(d *schema.ResourceData, token string) (*kubeadmapi.I
 			initConfig.Networking.ServiceSubnet = servicesCIDROpt.(string)
 		}
 
+		// the pods and the services subnets must use the same families (both of them for dual-stack)
+		podSubnet, serviceSubnet := initConfig.Networking.PodSubnet, initConfig.Networking.ServiceSubnet
+		if podSubnet == "" {
+			podSubnet = common.DefPodCIDR
+		}
+		if serviceSubnet == "" {
+			serviceSubnet = common.DefServiceCIDR
+		}
+		if !common.SameCIDRsFamilies(podSubnet, serviceSubnet) {
+			return nil, fmt.Errorf("the pods (%q) and services (%q) subnets must use the same IP families (IPv4 and IPv6 CIDRs in both of them for dual-stack)",
+				podSubnet, serviceSubnet)
+		}
+		if common.IsDualStackCIDRs(initConfig.Networking.PodSubnet) {
+			// dual-stack is GA (and the feature gate has been removed) in v1.21
+			version := d.Get("version").(string)
+			if !common.KubeVersionAtLeast(version, 1, 21) {
+				if initConfig.FeatureGates == nil {
+					initConfig.FeatureGates = map[string]bool{}
+				}
+				initConfig.FeatureGates["IPv6DualStack"] = true
+			}
+		}
+
 		if _, ok := d.GetOk("network.0.dns.0"); ok {
 			if dnsDomainOpt, ok := d.GetOk("network.0.dns.0.domain"); ok {
 				dnsDomain := dnsDomainOpt.(string)
@@ -147,6 +177,23 @@ func dataSourceToInitConfig(d *schema.ResourceData, token string) (*kubeadmapi.I
 				initConfig.NodeRegistration.KubeletExtraArgs = args.(map[string]string)
 			}
 		}
+
+		kubeletArgs, err := addSandboxImageArgs(d, initConfig.NodeRegistration.KubeletExtraArgs)
+		if err != nil {
+			return nil, err
+		}
+		initConfig.NodeRegistration.KubeletExtraArgs = kubeletArgs
+	}
+
+	if hardeningOpt, ok := d.GetOk("hardening"); ok {
+		preset, ok := common.HardeningPresets[hardeningOpt.(string)]
+		if !ok {
+			return nil, fmt.Errorf("unknown hardening preset %q", hardeningOpt.(string))
+		}
+		initConfig.ClusterConfiguration.APIServer.ExtraArgs = common.MergeArgs(preset.APIServerArgs, initConfig.ClusterConfiguration.APIServer.ExtraArgs)
+		initConfig.ClusterConfiguration.ControllerManager.ExtraArgs = common.MergeArgs(preset.ControllerManagerArgs, initConfig.ClusterConfiguration.ControllerManager.ExtraArgs)
+		initConfig.ClusterConfiguration.Scheduler.ExtraArgs = common.MergeArgs(preset.SchedulerArgs, initConfig.ClusterConfiguration.Scheduler.ExtraArgs)
+		initConfig.NodeRegistration.KubeletExtraArgs = common.MergeArgs(preset.KubeletArgs, initConfig.NodeRegistration.KubeletExtraArgs)
 	}
 
 	// check if we have some cloud-provider
@@ -169,6 +216,168 @@ func dataSourceToInitConfig(d *schema.ResourceData, token string) (*kubeadmapi.I
 		initConfig.ClusterConfiguration.ControllerManager.ExtraArgs["cloud-provider"] = "external"
 	}
 
+	if requestTimeoutOpt, ok := d.GetOk("api.0.request_timeout"); ok {
+		if initConfig.ClusterConfiguration.APIServer.ExtraArgs == nil {
+			initConfig.ClusterConfiguration.APIServer.ExtraArgs = map[string]string{}
+		}
+		initConfig.ClusterConfiguration.APIServer.ExtraArgs["request-timeout"] = requestTimeoutOpt.(string)
+	}
+
+	if minRequestTimeoutOpt, ok := d.GetOk("api.0.min_request_timeout"); ok {
+		if initConfig.ClusterConfiguration.APIServer.ExtraArgs == nil {
+			initConfig.ClusterConfiguration.APIServer.ExtraArgs = map[string]string{}
+		}
+		initConfig.ClusterConfiguration.APIServer.ExtraArgs["min-request-timeout"] = strconv.Itoa(minRequestTimeoutOpt.(int))
+	}
+
+	enabledPlugins, disabledPlugins := []string{}, []string{}
+	if pluginsOpt, ok := d.GetOk("api.0.admission_plugins"); ok {
+		enabledPlugins = common.InterfacesToStrings(pluginsOpt.([]interface{}))
+	}
+	if pluginsOpt, ok := d.GetOk("api.0.disable_admission_plugins"); ok {
+		disabledPlugins = common.InterfacesToStrings(pluginsOpt.([]interface{}))
+	}
+	apiServerArgs, err := addAdmissionPluginsArgs(initConfig.ClusterConfiguration.APIServer.ExtraArgs, enabledPlugins, disabledPlugins)
+	if err != nil {
+		return nil, err
+	}
+	initConfig.ClusterConfiguration.APIServer.ExtraArgs = apiServerArgs
+
+	if disableOpt, ok := d.GetOk("api.0.disable_anonymous_auth"); ok && disableOpt.(bool) {
+		if initConfig.ClusterConfiguration.APIServer.ExtraArgs == nil {
+			initConfig.ClusterConfiguration.APIServer.ExtraArgs = map[string]string{}
+		}
+		args := initConfig.ClusterConfiguration.APIServer.ExtraArgs
+		if anonymous, ok := args["anonymous-auth"]; ok && anonymous != "false" {
+			return nil, fmt.Errorf("'disable_anonymous_auth' cannot be used with a 'anonymous-auth=%s' API server argument", anonymous)
+		}
+		args["anonymous-auth"] = "false"
+	}
+
+	// joining nodes get the "cluster-info" ConfigMap anonymously with the token-based discovery
+	if initConfig.ClusterConfiguration.APIServer.ExtraArgs["anonymous-auth"] == "false" {
+		if !d.Get("discovery_file").(bool) && !d.Get("tls_bootstrap").(bool) {
+			return nil, fmt.Errorf("anonymous requests to the API server can only be disabled with a file-based discovery: use 'discovery_file' or 'tls_bootstrap'")
+		}
+	}
+
+	if signingDurationOpt, ok := d.GetOk("certs.0.cluster_signing_duration"); ok {
+		if initConfig.ClusterConfiguration.ControllerManager.ExtraArgs == nil {
+			initConfig.ClusterConfiguration.ControllerManager.ExtraArgs = map[string]string{}
+		}
+		// the flag was "experimental" before v1.19
+		flag := "cluster-signing-duration"
+		if !common.KubeVersionAtLeast(d.Get("version").(string), 1, 19) {
+			flag = "experimental-cluster-signing-duration"
+		}
+		initConfig.ClusterConfiguration.ControllerManager.ExtraArgs[flag] = signingDurationOpt.(string)
+	}
+
+	if portRangeOpt, ok := d.GetOk("network.0.node_port_range"); ok {
+		// the API server could be listening in a port inside the range
+		first, last, err := common.ParsePortRange(portRangeOpt.(string))
+		if err != nil {
+			return nil, err
+		}
+		if port := int(initConfig.LocalAPIEndpoint.BindPort); port >= first && port <= last {
+			return nil, fmt.Errorf("the NodePorts range %q includes the API server port %d", portRangeOpt.(string), port)
+		}
+
+		if initConfig.ClusterConfiguration.APIServer.ExtraArgs == nil {
+			initConfig.ClusterConfiguration.APIServer.ExtraArgs = map[string]string{}
+		}
+		initConfig.ClusterConfiguration.APIServer.ExtraArgs["service-node-port-range"] = portRangeOpt.(string)
+	}
+
+	if _, ok := d.GetOk("audit.0"); ok {
+		if initConfig.ClusterConfiguration.APIServer.ExtraArgs == nil {
+			initConfig.ClusterConfiguration.APIServer.ExtraArgs = map[string]string{}
+		}
+		args := initConfig.ClusterConfiguration.APIServer.ExtraArgs
+		args["audit-policy-file"] = common.DefAuditPolicyPath
+		initConfig.APIServer.ExtraVolumes = append(initConfig.APIServer.ExtraVolumes, kubeadmapi.HostPathMount{
+			Name:      "audit",
+			HostPath:  common.DefAuditDir,
+			MountPath: common.DefAuditDir,
+			ReadOnly:  true,
+		})
+
+		if logPathOpt, ok := d.GetOk("audit.0.log_path"); ok {
+			logPath := logPathOpt.(string)
+			args["audit-log-path"] = logPath
+			initConfig.APIServer.ExtraVolumes = append(initConfig.APIServer.ExtraVolumes, kubeadmapi.HostPathMount{
+				Name:      "audit-log",
+				HostPath:  filepath.Dir(logPath),
+				MountPath: filepath.Dir(logPath),
+			})
+		}
+
+		if _, ok := d.GetOk("audit.0.webhook.0"); ok {
+			args["audit-webhook-config-file"] = common.DefAuditWebhookConfigPath
+			args["audit-webhook-mode"] = d.Get("audit.0.webhook.0.mode").(string)
+			if maxSizeOpt, ok := d.GetOk("audit.0.webhook.0.batch_max_size"); ok {
+				args["audit-webhook-batch-max-size"] = strconv.Itoa(maxSizeOpt.(int))
+			}
+			if maxWaitOpt, ok := d.GetOk("audit.0.webhook.0.batch_max_wait"); ok {
+				if _, err := time.ParseDuration(maxWaitOpt.(string)); err != nil {
+					return nil, fmt.Errorf("invalid audit webhook 'batch_max_wait' %q: %s", maxWaitOpt.(string), err)
+				}
+				args["audit-webhook-batch-max-wait"] = maxWaitOpt.(string)
+			}
+		}
+	}
+
+	if _, ok := d.GetOk("egress_selector.0"); ok {
+		if initConfig.ClusterConfiguration.APIServer.ExtraArgs == nil {
+			initConfig.ClusterConfiguration.APIServer.ExtraArgs = map[string]string{}
+		}
+		initConfig.ClusterConfiguration.APIServer.ExtraArgs["egress-selector-config-file"] = common.DefEgressSelectorConfigPath
+		initConfig.APIServer.ExtraVolumes = append(initConfig.APIServer.ExtraVolumes, kubeadmapi.HostPathMount{
+			Name:      "egress-selector",
+			HostPath:  common.DefEgressSelectorDir,
+			MountPath: common.DefEgressSelectorDir,
+			ReadOnly:  true,
+		})
+		if d.Get("egress_selector.0.konnectivity").(bool) {
+			initConfig.APIServer.ExtraVolumes = append(initConfig.APIServer.ExtraVolumes, kubeadmapi.HostPathMount{
+				Name:      "konnectivity-uds",
+				HostPath:  common.DefKonnectivityDir,
+				MountPath: common.DefKonnectivityDir,
+			})
+		}
+	}
+
+	if _, ok := d.GetOk("scheduler.0.config"); ok {
+		if initConfig.ClusterConfiguration.Scheduler.ExtraArgs == nil {
+			initConfig.ClusterConfiguration.Scheduler.ExtraArgs = map[string]string{}
+		}
+		initConfig.ClusterConfiguration.Scheduler.ExtraArgs["config"] = common.DefSchedulerConfigPath
+		initConfig.Scheduler.ExtraVolumes = append(initConfig.Scheduler.ExtraVolumes, kubeadmapi.HostPathMount{
+			Name:      "scheduler-config",
+			HostPath:  common.DefSchedulerConfigDir,
+			MountPath: common.DefSchedulerConfigDir,
+			ReadOnly:  true,
+		})
+	}
+
+	if _, ok := d.GetOk("extra_volumes"); ok {
+		components := map[string]*kubeadmapi.ControlPlaneComponent{
+			"api_server":         &initConfig.APIServer.ControlPlaneComponent,
+			"controller_manager": &initConfig.ControllerManager,
+			"scheduler":          &initConfig.Scheduler,
+		}
+		for name, component := range components {
+			volumes, err := getExtraVolumes(d, name)
+			if err != nil {
+				return nil, err
+			}
+			component.ExtraVolumes = append(component.ExtraVolumes, volumes...)
+			if err := checkExtraVolumes(component.ExtraVolumes); err != nil {
+				return nil, fmt.Errorf("invalid extra volumes for the %s: %s", name, err)
+			}
+		}
+	}
+
 	if _, ok := d.GetOk("cni.0"); ok {
 		if arg, ok := d.GetOk("cni.0.bin_dir"); ok {
 			initConfig.NodeRegistration.KubeletExtraArgs["cni-bin-dir"] = arg.(string)
@@ -189,6 +398,36 @@ func dataSourceToInitConfig(d *schema.ResourceData, token string) (*kubeadmapi.I
 			}
 			initConfig.Etcd.External.Endpoints = etcdServersLst.([]string)
 		}
+
+		// tuning for the local (stacked) etcd
+		etcdExtraArgs := map[string]string{}
+		if quota, ok := d.GetOk("etcd.0.quota_backend_bytes"); ok {
+			etcdExtraArgs["quota-backend-bytes"] = fmt.Sprintf("%d", quota.(int))
+		}
+		if mode, ok := d.GetOk("etcd.0.auto_compaction_mode"); ok {
+			etcdExtraArgs["auto-compaction-mode"] = mode.(string)
+		}
+		if retention, ok := d.GetOk("etcd.0.auto_compaction_retention"); ok {
+			etcdExtraArgs["auto-compaction-retention"] = retention.(string)
+		}
+		dataDir := d.Get("etcd.0.data_dir").(string)
+
+		if len(etcdExtraArgs) > 0 || dataDir != "" {
+			if initConfig.Etcd.External != nil {
+				return nil, fmt.Errorf("etcd 'data_dir' and tuning cannot be used with external etcd 'endpoints'")
+			}
+			if initConfig.Etcd.Local == nil {
+				initConfig.Etcd.Local = &kubeadmapi.LocalEtcd{}
+			}
+			// note: kubeadm mounts the data dir in the etcd static pod
+			initConfig.Etcd.Local.DataDir = dataDir
+			if initConfig.Etcd.Local.ExtraArgs == nil {
+				initConfig.Etcd.Local.ExtraArgs = map[string]string{}
+			}
+			for k, v := range etcdExtraArgs {
+				initConfig.Etcd.Local.ExtraArgs[k] = v
+			}
+		}
 	}
 
 	if len(token) > 0 {
@@ -202,3 +441,211 @@ func dataSourceToInitConfig(d *schema.ResourceData, token string) (*kubeadmapi.I
 
 	return initConfig, nil
 }
+
+// getExtraVolumes returns the `extra_volumes` for a control plane component
+func getExtraVolumes(d *schema.ResourceData, component string) ([]kubeadmapi.HostPathMount, error) {
+	res := []kubeadmapi.HostPathMount{}
+	for _, volumeOpt := range d.Get("extra_volumes").([]interface{}) {
+		volume := volumeOpt.(map[string]interface{})
+		if volume["component"].(string) != component {
+			continue
+		}
+		mount := kubeadmapi.HostPathMount{
+			Name:      volume["name"].(string),
+			HostPath:  volume["host_path"].(string),
+			MountPath: volume["mount_path"].(string),
+			ReadOnly:  volume["read_only"].(bool),
+		}
+		if err := setHostPathType(&mount, volume["path_type"].(string)); err != nil {
+			return nil, err
+		}
+		res = append(res, mount)
+	}
+	return res, nil
+}
+
+// getEtcdVolumesPatch returns the kubeadm patch that mounts the `extra_volumes`
+// in the local etcd (or nil when there are no volumes for etcd)
+func getEtcdVolumesPatch(d *schema.ResourceData) ([]byte, error) {
+	volumes, err := getExtraVolumes(d, "etcd")
+	if err != nil || len(volumes) == 0 {
+		return nil, err
+	}
+	if _, ok := d.GetOk("etcd.0.endpoints"); ok {
+		return nil, fmt.Errorf("extra volumes for etcd cannot be used with an external etcd")
+	}
+	if !common.KubeVersionAtLeast(d.Get("version").(string), 1, 22) {
+		return nil, fmt.Errorf("extra volumes for etcd require Kubernetes 1.22 or higher (kubeadm patches are not supported)")
+	}
+	if err := checkExtraVolumes(volumes); err != nil {
+		return nil, fmt.Errorf("invalid extra volumes for the etcd: %s", err)
+	}
+	return common.EtcdVolumesPatch(volumes)
+}
+
+// setHostPathType sets the type of a host path mount (ie, "DirectoryOrCreate"),
+// leaving it empty (no checks in the host path) when no `pathType` is provided
+func setHostPathType(mount *kubeadmapi.HostPathMount, pathType string) error {
+	switch pathType {
+	case "":
+	case "DirectoryOrCreate":
+		mount.PathType = "DirectoryOrCreate"
+	case "Directory":
+		mount.PathType = "Directory"
+	case "FileOrCreate":
+		mount.PathType = "FileOrCreate"
+	case "File":
+		mount.PathType = "File"
+	case "Socket":
+		mount.PathType = "Socket"
+	default:
+		return fmt.Errorf("unknown path type %q for the volume %q", pathType, mount.Name)
+	}
+	return nil
+}
+
+// checkExtraVolumes checks that the volumes of a control plane component
+// (including the volumes added by this provider) have unique names and mount paths
+func checkExtraVolumes(volumes []kubeadmapi.HostPathMount) error {
+	names, mountPaths := map[string]bool{}, map[string]bool{}
+	for _, volume := range volumes {
+		if names[volume.Name] {
+			return fmt.Errorf("duplicate volume name %q", volume.Name)
+		}
+		if mountPaths[volume.MountPath] {
+			return fmt.Errorf("duplicate mount path %q (in volume %q)", volume.MountPath, volume.Name)
+		}
+		names[volume.Name] = true
+		mountPaths[volume.MountPath] = true
+	}
+	return nil
+}
+
+// splitAdmissionPlugins splits a comma-separated list of admission plugins
+func splitAdmissionPlugins(plugins string) []string {
+	res := []string{}
+	for _, plugin := range strings.Split(plugins, ",") {
+		if plugin = strings.TrimSpace(plugin); plugin != "" {
+			res = append(res, plugin)
+		}
+	}
+	return res
+}
+
+// addAdmissionPluginsArgs adds the "enable-admission-plugins" and "disable-admission-plugins"
+// API server arguments, merging the plugins in the extra args with the `enabled`/`disabled`
+// ones. The default admission plugins are enabled unless they are explicitly disabled.
+func addAdmissionPluginsArgs(args map[string]string, enabled []string, disabled []string) (map[string]string, error) {
+	if args == nil {
+		args = map[string]string{}
+	}
+
+	enabled = append(splitAdmissionPlugins(args["enable-admission-plugins"]), enabled...)
+	disabled = common.StringSliceUnique(append(splitAdmissionPlugins(args["disable-admission-plugins"]), disabled...))
+	for _, plugin := range enabled {
+		if common.StringSliceContains(disabled, plugin) {
+			return nil, fmt.Errorf("admission plugin %q cannot be enabled and disabled at the same time", plugin)
+		}
+	}
+	for _, plugin := range common.DefAdmissionPlugins {
+		if !common.StringSliceContains(disabled, plugin) {
+			enabled = append(enabled, plugin)
+		}
+	}
+
+	enabled = common.StringSliceUnique(enabled)
+	if len(enabled) > 0 {
+		args["enable-admission-plugins"] = strings.Join(enabled, ",")
+	}
+	if len(disabled) > 0 {
+		args["disable-admission-plugins"] = strings.Join(disabled, ",")
+	}
+	return args, nil
+}
+
+// addSandboxImageArgs adds the "pod-infra-container-image" kubelet argument for
+// the "sandbox_image", so the kubelet and the runtime engine use the same image
+func addSandboxImageArgs(d *schema.ResourceData, args map[string]string) (map[string]string, error) {
+	sandboxImageOpt, ok := d.GetOk("runtime.0.sandbox_image")
+	if !ok {
+		return args, nil
+	}
+
+	if args == nil {
+		args = map[string]string{}
+	}
+	sandboxImage := sandboxImageOpt.(string)
+	if image, ok := args["pod-infra-container-image"]; ok && image != sandboxImage {
+		return nil, fmt.Errorf("'sandbox_image' cannot be used with a different 'pod-infra-container-image=%s' kubelet argument", image)
+	}
+	args["pod-infra-container-image"] = sandboxImage
+	return args, nil
+}
+
+// addContainerLogsConfig adds the settings for the rotation of the containers logs
+// (`containerLogMaxSize` and `containerLogMaxFiles`) to the KubeletConfiguration, using
+// the defaults for the values not provided (unless they are already in the KubeletConfiguration).
+// These settings are ignored by the kubelet when using the dockershim, so nothing is added
+// with docker (and we return an error when they are provided).
+func addContainerLogsConfig(d *schema.ResourceData, kubeletConfig string) (string, error) {
+	maxSizeOpt, hasMaxSize := d.GetOk("runtime.0.container_log_max_size")
+	maxFilesOpt, hasMaxFiles := d.GetOk("runtime.0.container_log_max_files")
+
+	engine := common.DefRuntimeEngine
+	if engineOpt, ok := d.GetOk("runtime.0.engine"); ok {
+		engine = engineOpt.(string)
+	}
+	if engine == "docker" {
+		if hasMaxSize || hasMaxFiles {
+			return "", fmt.Errorf("the containers logs rotation cannot be configured in the kubelet with docker: use the 'log-opts' in the docker daemon configuration")
+		}
+		return kubeletConfig, nil
+	}
+
+	settings := []struct {
+		field    string
+		value    string
+		provided bool
+	}{
+		{"containerLogMaxSize", common.DefContainerLogMaxSize, hasMaxSize},
+		{"containerLogMaxFiles", strconv.Itoa(common.DefContainerLogMaxFiles), hasMaxFiles},
+	}
+	if hasMaxSize {
+		settings[0].value = maxSizeOpt.(string)
+	}
+	if hasMaxFiles {
+		settings[1].value = strconv.Itoa(maxFilesOpt.(int))
+	}
+
+	for _, setting := range settings {
+		if !setting.provided && common.KubeletConfigField(kubeletConfig, setting.field) != "" {
+			continue
+		}
+		var err error
+		kubeletConfig, err = common.SetKubeletConfigField(kubeletConfig, setting.field, setting.value)
+		if err != nil {
+			return "", fmt.Errorf("invalid containers logs rotation: %s", err)
+		}
+	}
+	return kubeletConfig, nil
+}
+
+// addServingCertsConfig enables the `serverTLSBootstrap` in the KubeletConfiguration, so the
+// kubelets request serving certificates signed by the cluster CA (instead of using
+// self-signed certificates)
+func addServingCertsConfig(d *schema.ResourceData, kubeletConfig string) (string, error) {
+	if !d.Get("runtime.0.kubelet_serving_certs").(bool) {
+		return kubeletConfig, nil
+	}
+
+	if args, ok := d.GetOk("runtime.0.extra_args.0.kubelet"); ok {
+		if rotate, ok := args.(map[string]string)["rotate-server-certificates"]; ok && rotate != "true" {
+			return "", fmt.Errorf("'kubelet_serving_certs' cannot be used with a 'rotate-server-certificates=%s' kubelet argument", rotate)
+		}
+	}
+	kubeletConfig, err := common.SetKubeletConfigField(kubeletConfig, "serverTLSBootstrap", "true")
+	if err != nil {
+		return "", fmt.Errorf("'kubelet_serving_certs' cannot be used: %s", err)
+	}
+	return kubeletConfig, nil
+}
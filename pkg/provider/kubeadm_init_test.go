@@ -19,6 +19,7 @@ import (
 	"testing"
 
 	"github.com/hashicorp/terraform/helper/schema"
+	kubeadmapi "k8s.io/kubernetes/cmd/kubeadm/app/apis/kubeadm"
 
 	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
 )
@@ -47,3 +48,106 @@ func TestKubeadmInitConfigSerialization(t *testing.T) {
 	fmt.Printf("----------------- init configuration ---------------- \n%s", initConfigBytes)
 
 }
+
+func TestAddAdmissionPluginsArgs(t *testing.T) {
+	testsCases := []struct {
+		args     map[string]string
+		enabled  []string
+		disabled []string
+		expected map[string]string
+		err      bool
+	}{
+		{
+			nil, nil, nil,
+			map[string]string{"enable-admission-plugins": "NodeRestriction"},
+			false,
+		},
+		{
+			map[string]string{"enable-admission-plugins": "PodSecurity"},
+			[]string{"EventRateLimit"}, nil,
+			map[string]string{"enable-admission-plugins": "PodSecurity,EventRateLimit,NodeRestriction"},
+			false,
+		},
+		{
+			nil, nil, []string{"NodeRestriction"},
+			map[string]string{"disable-admission-plugins": "NodeRestriction"},
+			false,
+		},
+		{
+			map[string]string{"disable-admission-plugins": "NodeRestriction"},
+			[]string{"PodSecurity"}, nil,
+			map[string]string{"enable-admission-plugins": "PodSecurity", "disable-admission-plugins": "NodeRestriction"},
+			false,
+		},
+		{
+			nil, []string{"PodSecurity"}, []string{"PodSecurity"},
+			nil,
+			true,
+		},
+	}
+
+	for _, testCase := range testsCases {
+		args, err := addAdmissionPluginsArgs(testCase.args, testCase.enabled, testCase.disabled)
+		if testCase.err {
+			if err == nil {
+				t.Fatalf("Error: no error for enabled=%v disabled=%v", testCase.enabled, testCase.disabled)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Error: %s", err)
+		}
+		if len(args) != len(testCase.expected) {
+			t.Fatalf("Error: unexpected args: %v (expected %v)", args, testCase.expected)
+		}
+		for k, v := range testCase.expected {
+			if args[k] != v {
+				t.Fatalf("Error: unexpected %q: %q (expected %q)", k, args[k], v)
+			}
+		}
+	}
+}
+
+func TestCheckExtraVolumes(t *testing.T) {
+	testsCases := []struct {
+		volumes []kubeadmapi.HostPathMount
+		err     bool
+	}{
+		{
+			[]kubeadmapi.HostPathMount{
+				{Name: "audit", HostPath: "/etc/kubernetes/audit", MountPath: "/etc/kubernetes/audit"},
+				{Name: "webhooks", HostPath: "/etc/webhooks", MountPath: "/etc/webhooks"},
+			},
+			false,
+		},
+		{
+			[]kubeadmapi.HostPathMount{
+				{Name: "audit", HostPath: "/etc/kubernetes/audit", MountPath: "/etc/kubernetes/audit"},
+				{Name: "audit", HostPath: "/var/log/audit", MountPath: "/var/log/audit"},
+			},
+			true,
+		},
+		{
+			[]kubeadmapi.HostPathMount{
+				{Name: "audit", HostPath: "/etc/kubernetes/audit", MountPath: "/etc/kubernetes/audit"},
+				{Name: "other", HostPath: "/etc/other", MountPath: "/etc/kubernetes/audit"},
+			},
+			true,
+		},
+	}
+
+	for i, testCase := range testsCases {
+		err := checkExtraVolumes(testCase.volumes)
+		if (err != nil) != testCase.err {
+			t.Fatalf("Error: unexpected result in test case %d: %v", i, err)
+		}
+	}
+
+	mount := kubeadmapi.HostPathMount{Name: "webhooks"}
+	if err := setHostPathType(&mount, "DirectoryOrCreate"); err != nil || mount.PathType != "DirectoryOrCreate" {
+		t.Fatalf("Error: could not set the path type: %v", err)
+	}
+	if err := setHostPathType(&mount, "Something"); err == nil {
+		t.Fatalf("Error: no error with an unknown path type")
+	}
+}
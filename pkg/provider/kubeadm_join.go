@@ -16,6 +16,7 @@ package provider
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/hashicorp/terraform/helper/schema"
 	kubeadmapi "k8s.io/kubernetes/cmd/kubeadm/app/apis/kubeadm"
@@ -38,6 +39,20 @@ func dataSourceToJoinConfig(d *schema.ResourceData, token string) (*kubeadmapi.J
 		},
 	}
 
+	// when using a discovery file, the cluster CA is verified with the CA in this file
+	// and the token is only used for the TLS bootstrap
+	// (with a bootstrap kubeconfig the token is in the file, so it is not needed here)
+	if d.Get("discovery_file").(bool) || d.Get("tls_bootstrap").(bool) {
+		joinConfig.Discovery = kubeadmapi.Discovery{
+			File: &kubeadmapi.FileDiscovery{
+				KubeConfigPath: common.DefDiscoveryKubeconfigPath,
+			},
+		}
+		if !d.Get("tls_bootstrap").(bool) {
+			joinConfig.Discovery.TLSBootstrapToken = token
+		}
+	}
+
 	if _, ok := d.GetOk("runtime.0"); ok {
 		if runtimeEngineOpt, ok := d.GetOk("runtime.0.engine"); ok {
 			if socket, ok := common.DefCriSocket[runtimeEngineOpt.(string)]; ok {
@@ -54,6 +69,20 @@ func dataSourceToJoinConfig(d *schema.ResourceData, token string) (*kubeadmapi.J
 				joinConfig.NodeRegistration.KubeletExtraArgs = args.(map[string]string)
 			}
 		}
+
+		kubeletArgs, err := addSandboxImageArgs(d, joinConfig.NodeRegistration.KubeletExtraArgs)
+		if err != nil {
+			return nil, err
+		}
+		joinConfig.NodeRegistration.KubeletExtraArgs = kubeletArgs
+	}
+
+	if hardeningOpt, ok := d.GetOk("hardening"); ok {
+		preset, ok := common.HardeningPresets[hardeningOpt.(string)]
+		if !ok {
+			return nil, fmt.Errorf("unknown hardening preset %q", hardeningOpt.(string))
+		}
+		joinConfig.NodeRegistration.KubeletExtraArgs = common.MergeArgs(preset.KubeletArgs, joinConfig.NodeRegistration.KubeletExtraArgs)
 	}
 
 	if _, ok := d.GetOk("network.0"); ok {
@@ -74,3 +103,31 @@ func dataSourceToJoinConfig(d *schema.ResourceData, token string) (*kubeadmapi.J
 
 	return joinConfig, nil
 }
+
+// getJoinCommand returns a ready-to-run "kubeadm join" command for the `joinConfig`, with the
+// token and the hash of the CA certificate (or a reference to the discovery file)
+func getJoinCommand(endpoint string, joinConfig *kubeadmapi.JoinConfiguration, caCert []byte) (string, error) {
+	args := []string{"kubeadm", "join"}
+	switch {
+	case joinConfig.Discovery.File != nil:
+		// the discovery (or bootstrap) kubeconfig must be copied to the node before joining
+		args = append(args, "--discovery-file", joinConfig.Discovery.File.KubeConfigPath)
+		if joinConfig.Discovery.TLSBootstrapToken != "" {
+			args = append(args, "--tls-bootstrap-token", joinConfig.Discovery.TLSBootstrapToken)
+		}
+	case joinConfig.Discovery.BootstrapToken != nil:
+		caCertHash, err := common.CACertHash(caCert)
+		if err != nil {
+			return "", err
+		}
+		args = append(args, endpoint,
+			"--token", joinConfig.Discovery.BootstrapToken.Token,
+			"--discovery-token-ca-cert-hash", caCertHash)
+	default:
+		return "", fmt.Errorf("no discovery method in the join configuration")
+	}
+	if joinConfig.NodeRegistration.CRISocket != "" {
+		args = append(args, "--cri-socket", joinConfig.NodeRegistration.CRISocket)
+	}
+	return strings.Join(args, " "), nil
+}
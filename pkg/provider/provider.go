@@ -19,6 +19,8 @@ import (
 	"encoding/hex"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/davecgh/go-spew/spew"
 	"github.com/hashicorp/terraform/helper/schema"
@@ -147,15 +149,29 @@ func createConfigForProvisioner(d *schema.ResourceData) error {
 	// Terraform just skips fields...
 	// NOTE: these fields must be in ProvisionerConfigElements
 	provConfig := map[string]interface{}{
-		"token":               token,
-		"init":                common.ToTerraformSafeString(initConfigBytes[:]),
-		"join":                common.ToTerraformSafeString(joinConfigBytes[:]),
-		"config_path":         kubeconfig,
-		"cni_plugin":          d.Get("cni.0.plugin").(string),
-		"cni_plugin_manifest": d.Get("cni.0.plugin_manifest").(string),
-		"helm_enabled":        fmt.Sprintf("%t", d.Get("helm.0.install").(bool)),
-		"dashboard_enabled":   fmt.Sprintf("%t", d.Get("dashboard.0.install").(bool)),
-		"certs_dir":           initConfig.CertificatesDir,
+		"token":                    token,
+		"init":                     common.ToTerraformSafeString(initConfigBytes[:]),
+		"join":                     common.ToTerraformSafeString(joinConfigBytes[:]),
+		"config_path":              kubeconfig,
+		"cni_plugin":               d.Get("cni.0.plugin").(string),
+		"cni_plugin_manifest":      d.Get("cni.0.plugin_manifest").(string),
+		"helm_enabled":             fmt.Sprintf("%t", d.Get("helm.0.install").(bool)),
+		"dashboard_enabled":        fmt.Sprintf("%t", d.Get("dashboard.0.install").(bool)),
+		"certs_dir":                initConfig.CertificatesDir,
+		"skip_token_print":         fmt.Sprintf("%t", d.Get("skip_token_print").(bool)),
+		"discovery_file":           fmt.Sprintf("%t", d.Get("discovery_file").(bool)),
+		"tls_bootstrap":            fmt.Sprintf("%t", d.Get("tls_bootstrap").(bool)),
+		"critical_addons_priority": fmt.Sprintf("%t", d.Get("critical_addons_priority").(bool)),
+	}
+
+	// the exported bootstrap kubeconfig uses its own (short-lived) token
+	bootstrapKubeconfigToken := ""
+	if d.Get("tls_bootstrap").(bool) && initConfig.ControlPlaneEndpoint != "" {
+		bootstrapKubeconfigToken, err = common.GetRandomToken()
+		if err != nil {
+			return err
+		}
+		provConfig["bootstrap_kubeconfig_token"] = bootstrapKubeconfigToken
 	}
 
 	if cniConfigDir, ok := d.GetOk("cni.0.conf_dir"); ok {
@@ -176,6 +192,17 @@ func createConfigForProvisioner(d *schema.ResourceData) error {
 		provConfig["cni_pod_cidr"] = common.DefPodCIDR
 	}
 
+	// the built-in flannel and weave manifests only support IPv4
+	podCIDRV4, podCIDRV6 := common.SplitCIDRsByFamily(provConfig["cni_pod_cidr"].(string))
+	if podCIDRV6 != "" && len(d.Get("cni.0.plugin_manifest").(string)) == 0 {
+		switch plugin := d.Get("cni.0.plugin").(string); plugin {
+		case "flannel", "weave":
+			return fmt.Errorf("the built-in %q CNI plugin does not support IPv6: use a 'plugin_manifest' for a CNI with IPv6 support", plugin)
+		}
+	}
+	provConfig["cni_pod_cidr_v4"] = podCIDRV4
+	provConfig["cni_pod_cidr_v6"] = podCIDRV6
+
 	if fb, ok := d.GetOk("cni.0.flannel.0.backend"); ok {
 		provConfig["flannel_backend"] = fb.(string)
 	} else {
@@ -188,6 +215,116 @@ func createConfigForProvisioner(d *schema.ResourceData) error {
 		provConfig["flannel_image_version"] = common.DefFlannelImageVersion
 	}
 
+	denyIngress := common.InterfacesToStrings(d.Get("network.0.default_deny_ingress").([]interface{}))
+	denyEgress := common.InterfacesToStrings(d.Get("network.0.default_deny_egress").([]interface{}))
+	if len(denyIngress) > 0 || len(denyEgress) > 0 {
+		// the NetworkPolicies would be silently ignored by a CNI plugin that does not enforce them
+		plugin, manifest := d.Get("cni.0.plugin").(string), d.Get("cni.0.plugin_manifest").(string)
+		if plugin == "" && manifest == "" {
+			return fmt.Errorf("default NetworkPolicies need a CNI plugin that supports them: use a 'plugin' or a 'plugin_manifest'")
+		}
+		if supported, known := common.CNIPluginsNetworkPolicies[plugin]; manifest == "" && known && !supported {
+			return fmt.Errorf("the %q CNI plugin does not support NetworkPolicies: default NetworkPolicies cannot be used", plugin)
+		}
+		provConfig["network_policy_deny_ingress"] = strings.Join(denyIngress, ",")
+		provConfig["network_policy_deny_egress"] = strings.Join(denyEgress, ",")
+	}
+
+	if d.Get("cni.0.multus.0.install").(bool) {
+		// Multus is a meta-plugin: it delegates the default network to some other CNI plugin
+		if len(d.Get("cni.0.plugin").(string)) == 0 && len(d.Get("cni.0.plugin_manifest").(string)) == 0 {
+			return fmt.Errorf("the Multus meta-CNI plugin needs a primary CNI plugin: use a 'plugin' or a 'plugin_manifest'")
+		}
+		provConfig["multus_enabled"] = "true"
+		provConfig["multus_manifest"] = d.Get("cni.0.multus.0.manifest").(string)
+		provConfig["multus_image_version"] = d.Get("cni.0.multus.0.version").(string)
+		provConfig["multus_default_network"] = d.Get("cni.0.multus.0.default_network").(string)
+	}
+
+	if driver, manifest := d.Get("storage.0.driver").(string), d.Get("storage.0.manifest").(string); driver != "" || manifest != "" {
+		storage := common.StorageDrivers[driver]
+		if manifest != "" {
+			storage.Manifest = manifest
+		}
+		if class := d.Get("storage.0.class").(string); class != "" {
+			storage.Class = class
+		}
+		if namespace := d.Get("storage.0.namespace").(string); namespace != "" {
+			storage.Namespace = namespace
+		}
+		provConfig["storage_manifest"] = storage.Manifest
+		provConfig["storage_class"] = storage.Class
+		provConfig["storage_class_default"] = fmt.Sprintf("%t", d.Get("storage.0.default").(bool))
+		provConfig["storage_namespace"] = storage.Namespace
+	}
+
+	if namespacesOpt, ok := d.GetOk("namespace"); ok {
+		namespaces := []common.Namespace{}
+		names := map[string]bool{}
+		for _, namespaceOpt := range namespacesOpt.([]interface{}) {
+			n := namespaceOpt.(map[string]interface{})
+			namespace := common.Namespace{
+				Name:        n["name"].(string),
+				Labels:      map[string]string{},
+				Annotations: map[string]string{},
+			}
+			if names[namespace.Name] {
+				return fmt.Errorf("duplicate namespace %q", namespace.Name)
+			}
+			names[namespace.Name] = true
+			for k, v := range n["labels"].(map[string]interface{}) {
+				namespace.Labels[k] = v.(string)
+			}
+			for k, v := range n["annotations"].(map[string]interface{}) {
+				namespace.Annotations[k] = v.(string)
+			}
+			namespace.Quota = common.InterfacesMapToStrings(n["quota"])
+			if limitRanges, ok := n["limit_range"].([]interface{}); ok && len(limitRanges) > 0 && limitRanges[0] != nil {
+				limitRange := limitRanges[0].(map[string]interface{})
+				namespace.LimitRange = common.LimitRange{
+					Default:        common.InterfacesMapToStrings(limitRange["default"]),
+					DefaultRequest: common.InterfacesMapToStrings(limitRange["default_request"]),
+					Max:            common.InterfacesMapToStrings(limitRange["max"]),
+					Min:            common.InterfacesMapToStrings(limitRange["min"]),
+				}
+				if err := namespace.LimitRange.Check(); err != nil {
+					return fmt.Errorf("invalid limit_range in namespace %q: %s", namespace.Name, err)
+				}
+			}
+			namespaces = append(namespaces, namespace)
+		}
+		provConfig["namespaces_manifest"] = common.ToTerraformSafeString([]byte(common.NamespacesManifest(namespaces)))
+	}
+
+	if _, ok := d.GetOk("rbac.0"); ok {
+		manifests := []string{}
+		for _, manifest := range common.InterfacesToStrings(d.Get("rbac.0.manifests").([]interface{})) {
+			manifests = append(manifests, common.ToTerraformSafeString([]byte(manifest)))
+		}
+
+		names := map[string]bool{}
+		for _, bindingOpt := range d.Get("rbac.0.binding").([]interface{}) {
+			b := bindingOpt.(map[string]interface{})
+			binding := common.RBACBinding{
+				Name:      b["name"].(string),
+				Role:      b["role"].(string),
+				Namespace: b["namespace"].(string),
+				Groups:    common.InterfacesToStrings(b["groups"].([]interface{})),
+				Users:     common.InterfacesToStrings(b["users"].([]interface{})),
+			}
+			if len(binding.Groups) == 0 && len(binding.Users) == 0 {
+				return fmt.Errorf("the RBAC binding for %q has no groups or users", binding.Role)
+			}
+			key := binding.Namespace + "/" + binding.GetName()
+			if names[key] {
+				return fmt.Errorf("duplicate RBAC binding %q: use a different 'name'", binding.GetName())
+			}
+			names[key] = true
+			manifests = append(manifests, common.ToTerraformSafeString([]byte(binding.Manifest())))
+		}
+		provConfig["rbac_manifests"] = strings.Join(manifests, ",")
+	}
+
 	if v, ok := d.GetOk("network.0.dns.0.upstream"); ok {
 		dnsUp := v.([]interface{})
 		if len(dnsUp) > 0 {
@@ -199,6 +336,168 @@ func createConfigForProvisioner(d *schema.ResourceData) error {
 		}
 	}
 
+	if replicas, ok := d.GetOk("network.0.dns.0.replicas"); ok {
+		provConfig["dns_replicas"] = strconv.Itoa(replicas.(int))
+		provConfig["dns_anti_affinity"] = strconv.FormatBool(d.Get("network.0.dns.0.anti_affinity").(bool))
+	}
+
+	provConfig["runtime_engine"] = common.DefRuntimeEngine
+	provConfig["runtime_manage_config"] = "true"
+	if _, ok := d.GetOk("runtime.0"); ok {
+		if engine, ok := d.GetOk("runtime.0.engine"); ok {
+			provConfig["runtime_engine"] = engine.(string)
+		}
+		provConfig["runtime_manage_config"] = fmt.Sprintf("%t", d.Get("runtime.0.manage_config").(bool))
+
+		provConfig["kubelet_serving_certs"] = fmt.Sprintf("%t", d.Get("runtime.0.kubelet_serving_certs").(bool))
+
+		if sandboxImage, ok := d.GetOk("runtime.0.sandbox_image"); ok {
+			provConfig["sandbox_image"] = sandboxImage.(string)
+		}
+
+		if mirrorsOpt, ok := d.GetOk("runtime.0.registry_mirrors"); ok {
+			mirrors := []common.RegistryMirror{}
+			for _, mirrorOpt := range mirrorsOpt.([]interface{}) {
+				mirror := mirrorOpt.(map[string]interface{})
+				endpoints := []string{}
+				for _, endpoint := range mirror["endpoints"].([]interface{}) {
+					endpoints = append(endpoints, endpoint.(string))
+				}
+				mirrors = append(mirrors, common.RegistryMirror{
+					Registry:  mirror["registry"].(string),
+					Endpoints: endpoints,
+					CACert:    mirror["ca_crt"].(string),
+				})
+			}
+			mirrorsStr, err := common.RegistryMirrorsToString(mirrors)
+			if err != nil {
+				return err
+			}
+			provConfig["registry_mirrors"] = mirrorsStr
+		}
+
+		kubeletConfig, err := getKubeletConfig(d)
+		if err != nil {
+			return err
+		}
+		if kubeletConfig != "" {
+			provConfig["kubelet_config"] = common.ToTerraformSafeString([]byte(kubeletConfig))
+		}
+
+		if _, ok := d.GetOk("runtime.0.swap.0"); ok {
+			swap := common.SwapDisabled
+			if d.Get("runtime.0.swap.0.enabled").(bool) {
+				swap = common.SwapEnabled
+			}
+			provConfig["swap"] = swap
+			provConfig["manage_swap"] = fmt.Sprintf("%t", d.Get("runtime.0.swap.0.manage").(bool))
+		}
+
+		if _, ok := d.GetOk("runtime.0.kubelet_reserved.0"); ok {
+			provConfig["kubelet_reserved_auto"] = fmt.Sprintf("%t", d.Get("runtime.0.kubelet_reserved.0.auto").(bool))
+			provConfig["kubelet_system_reserved"] = common.ReservedToString(map[string]string{
+				"cpu":               d.Get("runtime.0.kubelet_reserved.0.system_cpu").(string),
+				"memory":            d.Get("runtime.0.kubelet_reserved.0.system_memory").(string),
+				"ephemeral-storage": d.Get("runtime.0.kubelet_reserved.0.system_ephemeral_storage").(string),
+			})
+			provConfig["kubelet_kube_reserved"] = common.ReservedToString(map[string]string{
+				"cpu":               d.Get("runtime.0.kubelet_reserved.0.kube_cpu").(string),
+				"memory":            d.Get("runtime.0.kubelet_reserved.0.kube_memory").(string),
+				"ephemeral-storage": d.Get("runtime.0.kubelet_reserved.0.kube_ephemeral_storage").(string),
+			})
+		}
+	}
+
+	if key, ok := d.GetOk("images.0.verify_public_key"); ok {
+		provConfig["images_verify_key"] = common.ToTerraformSafeString([]byte(key.(string)))
+	}
+
+	if hardening, ok := d.GetOk("hardening"); ok {
+		provConfig["hardening"] = hardening.(string)
+	}
+
+	if label, ok := d.GetOk("control_plane_label"); ok {
+		provConfig["control_plane_label"] = label.(string)
+	}
+
+	if disableOpt, ok := d.GetOk("api.0.disable_anonymous_auth"); ok && disableOpt.(bool) {
+		provConfig["anonymous_auth_disabled"] = "true"
+	}
+
+	if _, ok := d.GetOk("audit.0"); ok {
+		provConfig["audit_policy"] = common.ToTerraformSafeString([]byte(d.Get("audit.0.policy").(string)))
+		if webhookConfig, ok := d.GetOk("audit.0.webhook.0.config"); ok {
+			provConfig["audit_webhook_config"] = common.ToTerraformSafeString([]byte(webhookConfig.(string)))
+		}
+	}
+
+	if _, ok := d.GetOk("egress_selector.0"); ok {
+		konnectivity := d.Get("egress_selector.0.konnectivity").(bool)
+		config := d.Get("egress_selector.0.config").(string)
+		if config == "" {
+			if !konnectivity {
+				return fmt.Errorf("the egress selector needs a 'config' when the konnectivity server is not deployed")
+			}
+			config = common.DefKonnectivityEgressSelectorConfig
+		}
+		provConfig["egress_selector_config"] = common.ToTerraformSafeString([]byte(config))
+
+		if konnectivity {
+			// the agents connect to the konnectivity servers through the control plane endpoint
+			if initConfig.ControlPlaneEndpoint == "" {
+				return fmt.Errorf("the konnectivity agents need a stable control plane endpoint: use an 'external' address in the 'api'")
+			}
+			host, _, err := common.SplitHostPort(initConfig.ControlPlaneEndpoint, common.DefAPIServerPort)
+			if err != nil {
+				return err
+			}
+			provConfig["konnectivity_enabled"] = "true"
+			provConfig["konnectivity_version"] = d.Get("egress_selector.0.konnectivity_version").(string)
+			provConfig["konnectivity_agent_port"] = strconv.Itoa(d.Get("egress_selector.0.konnectivity_port").(int))
+			provConfig["konnectivity_server_host"] = host
+		}
+	}
+
+	if mode, ok := d.GetOk("kube_proxy.0.mode"); ok {
+		provConfig["kube_proxy_mode"] = mode.(string)
+	}
+
+	if configOpt, ok := d.GetOk("scheduler.0.config"); ok {
+		config := common.SchedulerConfigWithKubeconfig(configOpt.(string))
+		provConfig["scheduler_config"] = common.ToTerraformSafeString([]byte(config))
+	}
+
+	etcdPatch, err := getEtcdVolumesPatch(d)
+	if err != nil {
+		return err
+	}
+	if len(etcdPatch) > 0 {
+		provConfig["etcd_patch"] = common.ToTerraformSafeString(etcdPatch)
+	}
+
+	if _, ok := d.GetOk("kubeconfig.0"); ok {
+		for _, name := range []string{"cluster", "context", "user"} {
+			if v, ok := d.GetOk("kubeconfig.0." + name); ok {
+				provConfig["kubeconfig_"+name] = v.(string)
+			}
+		}
+	}
+
+	// use the cluster name in the kubeconfig (unless some other names have been provided),
+	// so kubeconfigs of different clusters can be merged
+	if clusterName, ok := d.GetOk("cluster_name"); ok {
+		defaults := map[string]string{
+			"cluster": clusterName.(string),
+			"context": clusterName.(string),
+			"user":    clusterName.(string) + "-admin",
+		}
+		for name, value := range defaults {
+			if _, ok := provConfig["kubeconfig_"+name]; !ok {
+				provConfig["kubeconfig_"+name] = value
+			}
+		}
+	}
+
 	if version, ok := d.GetOk("version"); ok {
 		provConfig["kube_version"] = version.(string)
 	} else {
@@ -236,6 +535,66 @@ func createConfigForProvisioner(d *schema.ResourceData) error {
 		return err
 	}
 
+	// expose the full configuration generated, for debugging and auditing
+	kubeletConfig, err := getKubeletConfig(d)
+	if err != nil {
+		return err
+	}
+	renderedInitConfig := common.AppendKubeletConfig(initConfigBytes, []byte(kubeletConfig))
+	if mode, ok := d.GetOk("kube_proxy.0.mode"); ok {
+		renderedInitConfig = common.AppendKubeProxyConfig(renderedInitConfig, mode.(string))
+	}
+	if err = d.Set("rendered_init_config", string(renderedInitConfig)); err != nil {
+		return err
+	}
+	if err = d.Set("rendered_join_config", string(joinConfigBytes)); err != nil {
+		return err
+	}
+
+	// expose the discovery kubeconfig, so it can be used for joining nodes
+	// without Terraform (only possible when we have a stable endpoint)
+	if d.Get("discovery_file").(bool) && initConfig.ControlPlaneEndpoint != "" {
+		discoveryKubeconfig, err := common.DiscoveryKubeconfig(initConfig.ControlPlaneEndpoint, []byte(certConfig["ca_crt"]))
+		if err != nil {
+			return err
+		}
+		if err = d.Set("discovery_kubeconfig", string(discoveryKubeconfig)); err != nil {
+			return err
+		}
+	}
+
+	// expose the bootstrap kubeconfig, for joining nodes without Terraform
+	if bootstrapKubeconfigToken != "" {
+		bootstrapKubeconfig, err := common.BootstrapKubeconfig(initConfig.ControlPlaneEndpoint, []byte(certConfig["ca_crt"]), bootstrapKubeconfigToken)
+		if err != nil {
+			return err
+		}
+		if err = d.Set("bootstrap_kubeconfig", string(bootstrapKubeconfig)); err != nil {
+			return err
+		}
+	}
+
+	// expose the "kubeadm join" command, for joining nodes without Terraform
+	if v, ok := d.GetOk("export_join_command"); ok && v.(bool) {
+		if initConfig.ControlPlaneEndpoint == "" {
+			return fmt.Errorf("'export_join_command' needs a stable control plane endpoint: set the 'api.external'")
+		}
+		joinCommand, err := getJoinCommand(initConfig.ControlPlaneEndpoint, joinConfig, []byte(certConfig["ca_crt"]))
+		if err != nil {
+			return err
+		}
+		if err = d.Set("join_command", joinCommand); err != nil {
+			return err
+		}
+	}
+
+	// only expose the token when we are not hiding it
+	if !d.Get("skip_token_print").(bool) {
+		if err = d.Set("token", token); err != nil {
+			return err
+		}
+	}
+
 	ssh.Debug("-------------------------------------------------------------------------")
 	ssh.Debug("'data.config' after configuration:")
 	ssh.Debug("%s", spew.Sdump(provConfig))
@@ -251,6 +610,45 @@ func createConfigForProvisioner(d *schema.ResourceData) error {
 	return nil
 }
 
+// getKubeletConfig returns the KubeletConfiguration for the cluster (if any), with
+// the containers logs rotation, the serving certificates and the swap settings (when
+// a `swap` block is present in the `runtime`)
+func getKubeletConfig(d *schema.ResourceData) (string, error) {
+	kubeletConfig := ""
+	if kubeletConfigOpt, ok := d.GetOk("runtime.0.kubelet_config"); ok {
+		kubeletConfig = kubeletConfigOpt.(string)
+	}
+	kubeletConfig, err := addContainerLogsConfig(d, kubeletConfig)
+	if err != nil {
+		return "", err
+	}
+	kubeletConfig, err = addServingCertsConfig(d, kubeletConfig)
+	if err != nil {
+		return "", err
+	}
+	if _, ok := d.GetOk("runtime.0.swap.0"); !ok {
+		return kubeletConfig, nil
+	}
+
+	if args, ok := d.GetOk("runtime.0.extra_args.0.kubelet"); ok {
+		if _, ok := args.(map[string]string)["fail-swap-on"]; ok {
+			return "", fmt.Errorf("the kubelet 'fail-swap-on' flag cannot be used with the 'swap' in the 'runtime'")
+		}
+	}
+
+	version := common.DefKubernetesVersion
+	if versionOpt, ok := d.GetOk("version"); ok {
+		version = versionOpt.(string)
+	}
+	enabled := d.Get("runtime.0.swap.0.enabled").(bool)
+	behavior := d.Get("runtime.0.swap.0.behavior").(string)
+	kubeletConfig, err = common.SwapKubeletConfig(kubeletConfig, enabled, behavior, version)
+	if err != nil {
+		return "", fmt.Errorf("invalid swap configuration: %s", err)
+	}
+	return kubeletConfig, nil
+}
+
 // dataSourceVerify verifies the config
 func dataSourceVerify(d *schema.ResourceData) error {
 	ssh.Debug("verifying configuration...")
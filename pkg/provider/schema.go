@@ -40,6 +40,38 @@ func dataSourceKubeadm() *schema.Resource {
 				ForceNew:    true,
 				Description: "A local copy of the kubeconfig",
 			},
+			"cluster_name": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				Description:  "name of the cluster (instead of 'kubernetes'), used in the kubeadm config and in the kubeconfig",
+				ValidateFunc: common.ValidateClusterName,
+			},
+			"kubeconfig": {
+				Type:     schema.TypeList,
+				Optional: true,
+				ForceNew: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"cluster": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "name for the cluster in the kubeconfig (instead of 'kubernetes')",
+						},
+						"context": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "name for the context in the kubeconfig (instead of 'kubernetes-admin@kubernetes')",
+						},
+						"user": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "name for the user in the kubeconfig (instead of 'kubernetes-admin')",
+						},
+					},
+				},
+			},
 			"api": {
 				Type:     schema.TypeList,
 				Optional: true,
@@ -65,6 +97,36 @@ func dataSourceKubeadm() *schema.Resource {
 							Optional:    true,
 							Description: "List of SANs to use in api-server certificate. Example: 'IP=127.0.0.1,IP=127.0.0.2,DNS=localhost', If empty, SANs will be obtained from the external and internal names/IPs",
 						},
+						"request_timeout": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Description:  "duration the API server waits for a request before timing it out (ie, 1m30s)",
+							ValidateFunc: common.ValidateDuration,
+						},
+						"min_request_timeout": {
+							Type:         schema.TypeInt,
+							Optional:     true,
+							Description:  "minimum number of seconds a watch request handler is kept open by the API server",
+							ValidateFunc: validation.IntAtLeast(1),
+						},
+						"admission_plugins": {
+							Type:        schema.TypeList,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+							Optional:    true,
+							Description: "admission plugins enabled in the API server, in addition to the default ones (ie, NodeRestriction)",
+						},
+						"disable_admission_plugins": {
+							Type:        schema.TypeList,
+							Elem:        &schema.Schema{Type: schema.TypeString},
+							Optional:    true,
+							Description: "admission plugins disabled in the API server (including the ones enabled by default)",
+						},
+						"disable_anonymous_auth": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     false,
+							Description: "disable the anonymous requests to the API server (ie, '--anonymous-auth=false')",
+						},
 					},
 				},
 			},
@@ -100,6 +162,175 @@ func dataSourceKubeadm() *schema.Resource {
 					},
 				},
 			},
+			"storage": {
+				Type:     schema.TypeList,
+				Optional: true,
+				ForceNew: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"driver": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Default:      "",
+							Description:  "storage driver to install. Currently supported: local-path",
+							ValidateFunc: validation.StringInSlice(common.StorageDriversList, false),
+						},
+						"manifest": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Default:     "",
+							Description: "manifest (URL, local file or inline) for a CSI driver, instead of the pre-defined drivers",
+						},
+						"class": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Default:     "",
+							Description: "name of the StorageClass created by the driver",
+						},
+						"default": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     true,
+							Description: "mark the StorageClass as the default one",
+						},
+						"namespace": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Default:     "",
+							Description: "namespace where the driver pods run (for waiting until they are ready)",
+						},
+					},
+				},
+			},
+			"namespace": {
+				Type:        schema.TypeList,
+				Optional:    true,
+				ForceNew:    true,
+				Description: "namespaces created after the cluster is initialized, before loading any addon",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": {
+							Type:         schema.TypeString,
+							Required:     true,
+							Description:  "name of the namespace",
+							ValidateFunc: common.ValidateNamespace,
+						},
+						"labels": {
+							Type:        schema.TypeMap,
+							Optional:    true,
+							Description: "labels for the namespace",
+							Elem:        &schema.Schema{Type: schema.TypeString},
+						},
+						"annotations": {
+							Type:        schema.TypeMap,
+							Optional:    true,
+							Description: "annotations for the namespace",
+							Elem:        &schema.Schema{Type: schema.TypeString},
+						},
+						"quota": {
+							Type:         schema.TypeMap,
+							Optional:     true,
+							Description:  "hard limits of a ResourceQuota created in the namespace (ie, pods = \"20\")",
+							Elem:         &schema.Schema{Type: schema.TypeString},
+							ValidateFunc: common.ValidateResourceQuantities,
+						},
+						"limit_range": {
+							Type:        schema.TypeList,
+							Optional:    true,
+							MaxItems:    1,
+							Description: "limits for the containers, in a LimitRange created in the namespace",
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"default": {
+										Type:         schema.TypeMap,
+										Optional:     true,
+										Description:  "default limits for the containers (ie, cpu = \"500m\")",
+										Elem:         &schema.Schema{Type: schema.TypeString},
+										ValidateFunc: common.ValidateResourceQuantities,
+									},
+									"default_request": {
+										Type:         schema.TypeMap,
+										Optional:     true,
+										Description:  "default requests for the containers (ie, cpu = \"500m\")",
+										Elem:         &schema.Schema{Type: schema.TypeString},
+										ValidateFunc: common.ValidateResourceQuantities,
+									},
+									"max": {
+										Type:         schema.TypeMap,
+										Optional:     true,
+										Description:  "maximum limits for the containers (ie, cpu = \"500m\")",
+										Elem:         &schema.Schema{Type: schema.TypeString},
+										ValidateFunc: common.ValidateResourceQuantities,
+									},
+									"min": {
+										Type:         schema.TypeMap,
+										Optional:     true,
+										Description:  "minimum requests for the containers (ie, cpu = \"500m\")",
+										Elem:         &schema.Schema{Type: schema.TypeString},
+										ValidateFunc: common.ValidateResourceQuantities,
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			"rbac": {
+				Type:     schema.TypeList,
+				Optional: true,
+				ForceNew: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"manifests": {
+							Type:        schema.TypeList,
+							Optional:    true,
+							Description: "list of RBAC manifests (URL, local file or inline) applied after the cluster is initialized",
+							Elem:        &schema.Schema{Type: schema.TypeString, ValidateFunc: common.ValidateRBACManifest},
+						},
+						"binding": {
+							Type:        schema.TypeList,
+							Optional:    true,
+							Description: "bindings of cluster roles to groups (ie, OIDC groups) and/or users",
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"name": {
+										Type:        schema.TypeString,
+										Optional:    true,
+										Default:     "",
+										Description: "name of the binding (generated from the role when empty)",
+									},
+									"role": {
+										Type:        schema.TypeString,
+										Required:    true,
+										Description: "the ClusterRole bound",
+									},
+									"namespace": {
+										Type:         schema.TypeString,
+										Optional:     true,
+										Default:      "",
+										Description:  "namespace for the binding (a cluster-wide binding is created when empty)",
+										ValidateFunc: common.ValidateNamespace,
+									},
+									"groups": {
+										Type:        schema.TypeList,
+										Optional:    true,
+										Description: "groups bound to the role",
+										Elem:        &schema.Schema{Type: schema.TypeString},
+									},
+									"users": {
+										Type:        schema.TypeList,
+										Optional:    true,
+										Description: "users bound to the role",
+										Elem:        &schema.Schema{Type: schema.TypeString},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
 			"cni": {
 				Type:     schema.TypeList,
 				Optional: true,
@@ -171,6 +402,40 @@ func dataSourceKubeadm() *schema.Resource {
 								},
 							},
 						},
+						"multus": {
+							Type:     schema.TypeList,
+							Optional: true,
+							ForceNew: true,
+							MaxItems: 1,
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"install": {
+										Type:        schema.TypeBool,
+										Optional:    true,
+										Default:     true,
+										Description: "install the Multus meta-CNI plugin on top of the primary CNI plugin",
+									},
+									"version": {
+										Type:        schema.TypeString,
+										Optional:    true,
+										Default:     common.DefMultusImageVersion,
+										Description: "the Multus image version",
+									},
+									"manifest": {
+										Type:        schema.TypeString,
+										Optional:    true,
+										Default:     "",
+										Description: "use a specific manifest for Multus instead of the pre-defined one",
+									},
+									"default_network": {
+										Type:        schema.TypeString,
+										Optional:    true,
+										Default:     "",
+										Description: "name of the CNI configuration file (in the 'conf_dir') used as the default network (defaults to the first file found)",
+									},
+								},
+							},
+						},
 					},
 				},
 			},
@@ -185,15 +450,33 @@ func dataSourceKubeadm() *schema.Resource {
 							Type:         schema.TypeString,
 							Optional:     true,
 							Default:      common.DefServiceCIDR,
-							Description:  "subnet used by k8s services. Defaults to 10.96.0.0/12.",
-							ValidateFunc: validation.CIDRNetwork(0, 32),
+							Description:  "subnet used by k8s services (use a comma-separated IPv4 and IPv6 subnets for dual-stack). Defaults to 10.96.0.0/12.",
+							ValidateFunc: common.ValidateCIDRs,
 						},
 						"pods": {
 							Type:         schema.TypeString,
 							Optional:     true,
 							Default:      common.DefPodCIDR,
-							Description:  "subnet used by pods",
-							ValidateFunc: validation.CIDRNetwork(0, 32),
+							Description:  "subnet used by pods (use a comma-separated IPv4 and IPv6 subnets for dual-stack)",
+							ValidateFunc: common.ValidateCIDRs,
+						},
+						"node_port_range": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Description:  "range of ports reserved for NodePort services (ie, 30000-32767)",
+							ValidateFunc: common.ValidatePortRange,
+						},
+						"default_deny_ingress": {
+							Type:        schema.TypeList,
+							Optional:    true,
+							Description: "namespaces where all the ingress traffic is denied by default with a NetworkPolicy",
+							Elem:        &schema.Schema{Type: schema.TypeString, ValidateFunc: common.ValidateNamespace},
+						},
+						"default_deny_egress": {
+							Type:        schema.TypeList,
+							Optional:    true,
+							Description: "namespaces where all the egress traffic is denied by default with a NetworkPolicy",
+							Elem:        &schema.Schema{Type: schema.TypeString, ValidateFunc: common.ValidateNamespace},
 						},
 						"dns": {
 							Type:     schema.TypeList,
@@ -215,6 +498,18 @@ func dataSourceKubeadm() *schema.Resource {
 										Description: "upstream DNS servers",
 										Elem:        &schema.Schema{Type: schema.TypeString},
 									},
+									"replicas": {
+										Type:         schema.TypeInt,
+										Optional:     true,
+										Description:  "number of CoreDNS replicas (instead of the kubeadm default)",
+										ValidateFunc: validation.IntAtLeast(1),
+									},
+									"anti_affinity": {
+										Type:        schema.TypeBool,
+										Optional:    true,
+										Default:     true,
+										Description: "spread the CoreDNS replicas across nodes (with a pod anti-affinity)",
+									},
 								},
 							},
 						},
@@ -239,9 +534,16 @@ func dataSourceKubeadm() *schema.Resource {
 							Description: "the etcd image repository",
 						},
 						"etcd_version": {
-							Type:        schema.TypeString,
-							Optional:    true,
-							Description: "the etcd version",
+							Type:         schema.TypeString,
+							Optional:     true,
+							Description:  "the etcd version (image tag) for the stacked etcd (ie, 3.4.13-0)",
+							ValidateFunc: common.ValidateEtcdVersion,
+						},
+						"verify_public_key": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Description:  "PEM-encoded public key for verifying (with cosign) the signatures of the control plane images before 'kubeadm init'",
+							ValidateFunc: common.ValidatePublicKey,
 						},
 					},
 				},
@@ -259,6 +561,29 @@ func dataSourceKubeadm() *schema.Resource {
 							Optional:    true,
 							Description: "list of etcd servers URLs including host:port",
 						},
+						"data_dir": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Description:  "data directory for the (stacked) etcd (ie, in some dedicated disk)",
+							ValidateFunc: common.ValidateAbsPath,
+						},
+						"quota_backend_bytes": {
+							Type:         schema.TypeInt,
+							Optional:     true,
+							Description:  "raise alarms when the backend size exceeds the given quota",
+							ValidateFunc: validation.IntAtLeast(0),
+						},
+						"auto_compaction_mode": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Description:  "interpret the auto-compaction retention as 'periodic' or 'revision'",
+							ValidateFunc: validation.StringInSlice([]string{"periodic", "revision"}, false),
+						},
+						"auto_compaction_retention": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Description: "auto-compaction retention for the mvcc key value store (ie, '1h' or '1000')",
+						},
 					},
 				},
 			},
@@ -269,6 +594,260 @@ func dataSourceKubeadm() *schema.Resource {
 				ForceNew:    true,
 				Description: "Kubernetes version to use (Example: v1.15.0).",
 			},
+			"audit": {
+				Type:     schema.TypeList,
+				Optional: true,
+				ForceNew: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"policy": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Default:     common.DefAuditPolicy,
+							Description: "the audit policy (defaults to logging the metadata of all the requests)",
+						},
+						"log_path": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Description:  "path (in the control plane nodes) for the audit log file",
+							ValidateFunc: common.ValidateAbsPath,
+						},
+						"webhook": {
+							Type:     schema.TypeList,
+							Optional: true,
+							MaxItems: 1,
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"config": {
+										Type:         schema.TypeString,
+										Required:     true,
+										Sensitive:    true,
+										Description:  "kubeconfig-format file with the remote audit webhook service",
+										ValidateFunc: common.ValidateKubeconfig,
+									},
+									"mode": {
+										Type:         schema.TypeString,
+										Optional:     true,
+										Default:      common.DefAuditWebhookMode,
+										Description:  "strategy for sending audit events: batch, blocking or blocking-strict",
+										ValidateFunc: validation.StringInSlice([]string{"batch", "blocking", "blocking-strict"}, false),
+									},
+									"batch_max_size": {
+										Type:         schema.TypeInt,
+										Optional:     true,
+										Description:  "maximum size of a batch (only in batch mode)",
+										ValidateFunc: validation.IntAtLeast(0),
+									},
+									"batch_max_wait": {
+										Type:         schema.TypeString,
+										Optional:     true,
+										Description:  "amount of time to wait before force writing a batch that hadn't reached the max size (ie, 30s)",
+										ValidateFunc: common.ValidateDuration,
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			"egress_selector": {
+				Type:     schema.TypeList,
+				Optional: true,
+				ForceNew: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"config": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Description:  "the API server egress selector configuration (defaults to using the konnectivity server for the 'cluster' traffic)",
+							ValidateFunc: common.ValidateEgressSelectorConfig,
+						},
+						"konnectivity": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     true,
+							Description: "deploy the konnectivity server (in the control plane nodes) and agent (in all the nodes)",
+						},
+						"konnectivity_version": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Default:     common.DefKonnectivityImageVersion,
+							Description: "version of the konnectivity images",
+						},
+						"konnectivity_port": {
+							Type:         schema.TypeInt,
+							Optional:     true,
+							Default:      common.DefKonnectivityAgentPort,
+							Description:  "port where the konnectivity server listens for agents",
+							ValidateFunc: validation.IntBetween(1, 65535),
+						},
+					},
+				},
+			},
+			"scheduler": {
+				Type:     schema.TypeList,
+				Optional: true,
+				ForceNew: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"config": {
+							Type:         schema.TypeString,
+							Required:     true,
+							Description:  "the scheduler configuration (a KubeSchedulerConfiguration), with profiles, plugins, etc",
+							ValidateFunc: common.ValidateSchedulerConfig,
+						},
+					},
+				},
+			},
+			"extra_volumes": {
+				Type:     schema.TypeList,
+				Optional: true,
+				ForceNew: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"component": {
+							Type:         schema.TypeString,
+							Required:     true,
+							Description:  "control plane component where the volume is mounted: api_server, controller_manager, scheduler or etcd",
+							ValidateFunc: validation.StringInSlice([]string{"api_server", "controller_manager", "scheduler", "etcd"}, false),
+						},
+						"name": {
+							Type:         schema.TypeString,
+							Required:     true,
+							Description:  "name of the volume",
+							ValidateFunc: common.ValidateVolumeName,
+						},
+						"host_path": {
+							Type:         schema.TypeString,
+							Required:     true,
+							Description:  "path in the host",
+							ValidateFunc: common.ValidateAbsPath,
+						},
+						"mount_path": {
+							Type:         schema.TypeString,
+							Required:     true,
+							Description:  "path where the volume is mounted in the static pod",
+							ValidateFunc: common.ValidateAbsPath,
+						},
+						"read_only": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     false,
+							Description: "mount the volume as read-only",
+						},
+						"path_type": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Description:  "type of the host path: DirectoryOrCreate, Directory, FileOrCreate, File or Socket",
+							ValidateFunc: validation.StringInSlice([]string{"DirectoryOrCreate", "Directory", "FileOrCreate", "File", "Socket"}, false),
+						},
+					},
+				},
+			},
+			"kube_proxy": {
+				Type:     schema.TypeList,
+				Optional: true,
+				ForceNew: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"mode": {
+							Type:         schema.TypeString,
+							Required:     true,
+							Description:  "mode of the kube-proxy (iptables or ipvs), or 'none' when it is replaced by the CNI plugin (ie, cilium)",
+							ValidateFunc: validation.StringInSlice(common.KubeProxyModes, false),
+						},
+					},
+				},
+			},
+			"hardening": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				Description:  "hardening preset applied to the cluster components (ie, 'cis')",
+				ValidateFunc: validation.StringInSlice(common.HardeningPresetsList(), false),
+			},
+			"control_plane_label": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				Description:  "custom label (key[=value]) set in the control plane nodes (ie, 'example.com/role=control-plane')",
+				ValidateFunc: common.ValidateNodeLabel,
+			},
+			"critical_addons_priority": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     true,
+				ForceNew:    true,
+				Description: "set the system-node-critical/system-cluster-critical priority classes in the CNI and DNS addons",
+			},
+			"skip_token_print": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     true,
+				ForceNew:    true,
+				Description: "skip printing the bootstrap token in 'kubeadm init' (and do not export it in the 'token' attribute)",
+			},
+			"token": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Sensitive:   true,
+				Description: "the bootstrap token (only when 'skip_token_print' is false)",
+			},
+			"discovery_file": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				ForceNew:    true,
+				Description: "join nodes with a discovery kubeconfig file (verifying the cluster CA) instead of a token-based discovery",
+			},
+			"discovery_kubeconfig": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Sensitive:   true,
+				Description: "the discovery kubeconfig for joining nodes (only when 'discovery_file' is true and 'api.external' is set)",
+			},
+			"tls_bootstrap": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				ForceNew:    true,
+				Description: "join nodes with a bootstrap kubeconfig file (with the credentials for the kubelet TLS bootstrap) instead of a bootstrap token",
+			},
+			"bootstrap_kubeconfig": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Sensitive:   true,
+				Description: "the bootstrap kubeconfig for joining nodes (only when 'tls_bootstrap' is true and 'api.external' is set)",
+			},
+			"export_join_command": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				ForceNew:    true,
+				Description: "export a ready-to-run 'kubeadm join' command in the 'join_command' attribute",
+			},
+			"join_command": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Sensitive:   true,
+				Description: "the 'kubeadm join' command for joining nodes without Terraform (only when 'export_join_command' is true and 'api.external' is set)",
+			},
+			"rendered_init_config": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Sensitive:   true,
+				Description: "the kubeadm init configuration generated (InitConfiguration, ClusterConfiguration and KubeletConfiguration), in YAML, before the per-node changes done by the provisioner",
+			},
+			"rendered_join_config": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Sensitive:   true,
+				Description: "the kubeadm join configuration generated (JoinConfiguration), in YAML, before the per-node changes done by the provisioner",
+			},
 			"cloud": {
 				Type:     schema.TypeList,
 				Optional: true,
@@ -309,6 +888,145 @@ func dataSourceKubeadm() *schema.Resource {
 							Description:  "runtime engine: docker, containerd or crio",
 							ValidateFunc: validation.StringInSlice([]string{"crio", "containerd", "docker"}, true),
 						},
+						"manage_config": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     true,
+							Description: "generate/patch the runtime engine configuration in the nodes (only for containerd)",
+						},
+						"sandbox_image": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Description:  "sandbox (pause) image used by the runtime engine, independent of the 'images.kube_repo' (defaults to the image expected by kubeadm)",
+							ValidateFunc: common.ValidateImageReference,
+						},
+						"kubelet_serving_certs": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     false,
+							Description: "use kubelet serving certificates signed by the cluster CA (approving the kubelet CSRs when provisioning the nodes)",
+						},
+						"container_log_max_size": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Description:  "maximum size of a container log file before it is rotated (ie, 10Mi)",
+							ValidateFunc: common.ValidateLogSize,
+						},
+						"container_log_max_files": {
+							Type:         schema.TypeInt,
+							Optional:     true,
+							Description:  "maximum number of log files kept for a container",
+							ValidateFunc: validation.IntAtLeast(2),
+						},
+						"registry_mirrors": {
+							Type:     schema.TypeList,
+							Optional: true,
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"registry": {
+										Type:        schema.TypeString,
+										Required:    true,
+										Description: "registry to mirror (ie, docker.io)",
+									},
+									"endpoints": {
+										Type:        schema.TypeList,
+										Elem:        &schema.Schema{Type: schema.TypeString},
+										Required:    true,
+										Description: "list of mirror endpoints for the registry",
+									},
+									"ca_crt": {
+										Type:        schema.TypeString,
+										Optional:    true,
+										Description: "CA certificate used for verifying the mirrors",
+									},
+								},
+							},
+						},
+						"kubelet_config": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Description:  "full KubeletConfiguration YAML document (flags in extra_args.kubelet and other settings take precedence)",
+							ValidateFunc: common.ValidateKubeletConfig,
+						},
+						"swap": {
+							Type:     schema.TypeList,
+							Optional: true,
+							MaxItems: 1,
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"enabled": {
+										Type:        schema.TypeBool,
+										Optional:    true,
+										Default:     false,
+										Description: "let the kubelet run with swap on in the nodes (Kubernetes 1.28 or higher)",
+									},
+									"behavior": {
+										Type:         schema.TypeString,
+										Optional:     true,
+										Default:      common.DefSwapBehavior,
+										Description:  "how the pods can use the swap when it is enabled (NoSwap, LimitedSwap or UnlimitedSwap)",
+										ValidateFunc: validation.StringInSlice(common.SwapBehaviors, false),
+									},
+									"manage": {
+										Type:        schema.TypeBool,
+										Optional:    true,
+										Default:     true,
+										Description: "turn off the swap in the nodes when it is not enabled",
+									},
+								},
+							},
+						},
+						"kubelet_reserved": {
+							Type:     schema.TypeList,
+							Optional: true,
+							MaxItems: 1,
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"auto": {
+										Type:        schema.TypeBool,
+										Optional:    true,
+										Default:     true,
+										Description: "reserve resources for the system and kubernetes daemons scaled to the size of each node (for the values not provided)",
+									},
+									"system_cpu": {
+										Type:         schema.TypeString,
+										Optional:     true,
+										Description:  "CPU reserved for the system daemons (ie, 100m)",
+										ValidateFunc: common.ValidateQuantity,
+									},
+									"system_memory": {
+										Type:         schema.TypeString,
+										Optional:     true,
+										Description:  "memory reserved for the system daemons (ie, 256Mi)",
+										ValidateFunc: common.ValidateQuantity,
+									},
+									"system_ephemeral_storage": {
+										Type:         schema.TypeString,
+										Optional:     true,
+										Description:  "ephemeral storage reserved for the system daemons (ie, 1Gi)",
+										ValidateFunc: common.ValidateQuantity,
+									},
+									"kube_cpu": {
+										Type:         schema.TypeString,
+										Optional:     true,
+										Description:  "CPU reserved for the kubernetes daemons (ie, 100m)",
+										ValidateFunc: common.ValidateQuantity,
+									},
+									"kube_memory": {
+										Type:         schema.TypeString,
+										Optional:     true,
+										Description:  "memory reserved for the kubernetes daemons (ie, 256Mi)",
+										ValidateFunc: common.ValidateQuantity,
+									},
+									"kube_ephemeral_storage": {
+										Type:         schema.TypeString,
+										Optional:     true,
+										Description:  "ephemeral storage reserved for the kubernetes daemons (ie, 1Gi)",
+										ValidateFunc: common.ValidateQuantity,
+									},
+								},
+							},
+						},
 						"extra_args": {
 							Type:     schema.TypeList,
 							Optional: true,
@@ -401,6 +1119,13 @@ func dataSourceKubeadm() *schema.Resource {
 							ForceNew:  true,
 							Sensitive: true,
 						},
+						"cluster_signing_duration": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							ForceNew:     true,
+							Description:  "duration of the certificates signed by the controller manager, like the kubelet certificates (ie, 8760h)",
+							ValidateFunc: common.ValidateDuration,
+						},
 					},
 				},
 			},
@@ -35,6 +35,7 @@ var expectedBinaries = []struct {
 	why         string
 	defaultPath func(*schema.ResourceData) string
 	property    string
+	optional    bool
 }{
 	{
 		name:        "kubeadm",
@@ -48,6 +49,13 @@ var expectedBinaries = []struct {
 		defaultPath: getKubectlFromResourceData,
 		property:    "install.kubectl_path",
 	},
+	{
+		name:        "crictl",
+		why:         "crictl is used for pre-pulling images and inspecting containers",
+		defaultPath: getCrictlFromResourceData,
+		property:    "install.crictl_path",
+		optional:    true,
+	},
 	//{
 	//	name:        "hostname",
 	//	defaultPath: nil,
@@ -55,15 +63,36 @@ var expectedBinaries = []struct {
 	//},
 }
 
-// getKubeadmIgnoredChecksArg returns the kubeadm arguments for the ignored checks
-func getKubeadmIgnoredChecksArg(d *schema.ResourceData) string {
-	ignoredChecks := common.DefIgnorePreflightChecks[:]
-	if checksOptRaw, ok := d.GetOk("ignore_checks"); ok {
-		checksOpts := checksOptRaw.([]interface{})
-		for _, check := range checksOpts {
-			ignoredChecks = append(ignoredChecks, check.(string))
+// getUserIgnoredChecks returns the list of preflight checks ignored by the user
+func getUserIgnoredChecks(d *schema.ResourceData) []string {
+	ignoredChecks := []string{}
+	for _, key := range []string{"ignore_checks", "ignore_preflight_errors"} {
+		if checksOptRaw, ok := d.GetOk(key); ok {
+			checksOpts := checksOptRaw.([]interface{})
+			for _, check := range checksOpts {
+				ignoredChecks = append(ignoredChecks, strings.TrimSpace(check.(string)))
+			}
 		}
 	}
+	return common.StringSliceUnique(ignoredChecks)
+}
+
+// doWarnIgnoredChecks warns about the preflight checks ignored by the user
+func doWarnIgnoredChecks(d *schema.ResourceData) ssh.Action {
+	ignoredChecks := getUserIgnoredChecks(d)
+	if len(ignoredChecks) == 0 {
+		return nil
+	}
+	return ssh.ActionList{
+		ssh.DoMessageWarn("WARNING: ignoring errors in kubeadm preflight checks: %s", strings.Join(ignoredChecks, ", ")),
+		ssh.DoMessageWarn("WARNING: ignoring preflight checks is not supported for production clusters"),
+	}
+}
+
+// getKubeadmIgnoredChecksArg returns the kubeadm arguments for the ignored checks
+func getKubeadmIgnoredChecksArg(d *schema.ResourceData) string {
+	ignoredChecks := append([]string{}, common.DefIgnorePreflightChecks...)
+	ignoredChecks = append(ignoredChecks, getUserIgnoredChecks(d)...)
 	ignoredChecks = common.StringSliceUnique(ignoredChecks) // remove all the duplicates
 
 	if len(ignoredChecks) > 0 {
@@ -96,28 +125,96 @@ func doExecKubeadmWithConfig(d *schema.ResourceData, command string, cfg string,
 
 // doKubeadm is the common kubeadm call, both for the `init` as well as well as for the `join`.
 func doKubeadm(d *schema.ResourceData, kubeadmConfigFilename string, command string, args ...string) ssh.Action {
-	// run kubeadm... if something goes wrong, delete the "kubeadm-*.conf" file created
-	// otherwise, back up the config file
+	var output bytes.Buffer
+
+	// run kubeadm... and, despite the result, remove the "kubeadm-*.conf" file created,
+	// as it contains the bootstrap token (or back it up when keeping sensitive files)
 	actions := ssh.ActionList{
 		ssh.DoMessageInfo("Starting kubeadm..."),
-		ssh.DoWithException(
+		ssh.DoWithCleanup(
+			ssh.DoWithException(
+				ssh.ActionList{
+					doUploadKubeadmConfig(d, command, kubeadmConfigFilename),
+					doBackupKubeadmConfig(d, kubeadmConfigFilename),
+					ssh.DoInPhase(
+						fmt.Sprintf("kubeadm %s", command),
+						ssh.DoWithHeartbeat(
+							fmt.Sprintf("kubeadm %s", command),
+							getHeartbeatIntervalFromResourceData(d),
+							ssh.DoCopyingExecOutputToWriter(
+								doExecKubeadmWithConfig(d, command, kubeadmConfigFilename, args...),
+								&output))),
+				},
+				ssh.ActionList{
+					doSaveKubeadmOutput(d, command, &output),
+					ssh.DoMessageWarn("kubeadm failed: dumping logs..."),
+					ssh.DoMessageWarn("- kubelet logs:"),
+					ssh.DoExec("systemctl --no-pager -l status kubelet"),
+					ssh.DoMessageWarn("- docker logs:"),
+					ssh.DoExec("systemctl --no-pager -l status docker"),
+					ssh.DoMessageWarn("- last lines in the journal:"),
+					ssh.DoExec("journalctl -e --no-pager | tail -n 20"),
+				}),
+			doCleanupKubeadmConfig(d, kubeadmConfigFilename)),
+	}
+	return actions
+}
+
+// doValidateKubeadmConfig validates the kubeadm configuration for `command` with a
+// `kubeadm config validate` in the remote machine, before touching anything in the system.
+// The validation is skipped when disabled or when kubeadm does not support it (< v1.26,
+// where 'kubeadm config validate' is missing or does not accept the '--config' flag)
+func doValidateKubeadmConfig(d *schema.ResourceData, command string) ssh.Action {
+	if !getValidateConfigFromResourceData(d) {
+		return nil
+	}
+
+	kubeadm := getKubeadmFromResourceData(d)
+	return ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+		kubeadmVersion, err := getRemoteKubeVersion(ctx, fmt.Sprintf("%s version -o short", kubeadm))
+		if err != nil {
+			return ssh.DoMessageWarn("could not get the kubeadm version: skipping configuration validation: %s", err)
+		}
+		if !common.KubeVersionAtLeast(kubeadmVersion, 1, 26) {
+			return ssh.DoMessageWarn("'kubeadm config validate' is not supported by kubeadm %s: skipping configuration validation", kubeadmVersion)
+		}
+
+		kubeadmConfigFilename, err := ssh.GetTempFilenameFromContext(ctx)
+		if err != nil {
+			return ssh.ActionError(fmt.Sprintf("Could not get a temporary filename: %s", err))
+		}
+
+		var buf bytes.Buffer
+		res := ssh.DoWithCleanup(
 			ssh.ActionList{
+				ssh.DoMessageInfo("Validating the kubeadm configuration..."),
 				doUploadKubeadmConfig(d, command, kubeadmConfigFilename),
-				doExecKubeadmWithConfig(d, command, kubeadmConfigFilename, args...),
+				ssh.DoSendingExecOutputToWriter(
+					ssh.DoExec(fmt.Sprintf("%s config validate --config=%s", kubeadm, kubeadmConfigFilename)),
+					&buf),
 			},
-			ssh.ActionList{
-				ssh.DoMessageWarn("kubeadm failed: dumping logs..."),
-				ssh.DoMessageWarn("- kubelet logs:"),
-				ssh.DoExec("systemctl --no-pager -l status kubelet"),
-				ssh.DoMessageWarn("- docker logs:"),
-				ssh.DoExec("systemctl --no-pager -l status docker"),
-				ssh.DoMessageWarn("- last lines in the journal:"),
-				ssh.DoExec("journalctl -e --no-pager | tail -n 20"),
-				ssh.DoTry(ssh.DoDeleteFile(kubeadmConfigFilename)),
-			}),
-		ssh.DoTry(ssh.DoMoveFile(kubeadmConfigFilename, kubeadmConfigFilename+".bak")),
+			ssh.DoTry(ssh.DoDeleteFile(kubeadmConfigFilename))).Apply(ctx)
+		if ssh.IsError(res) {
+			return ssh.DoAbort("invalid kubeadm configuration: %s\n%s", res.Error(), strings.TrimSpace(buf.String()))
+		}
+		return ssh.DoMessageInfo("kubeadm configuration is valid")
+	})
+}
+
+// doCleanupKubeadmConfig removes the kubeadm config file uploaded (as well as any backup
+// left by previous runs), unless we want to keep sensitive files for debugging
+func doCleanupKubeadmConfig(d *schema.ResourceData, kubeadmConfigFilename string) ssh.Action {
+	if getKeepSensitiveFilesFromResourceData(d) {
+		return ssh.ActionList{
+			ssh.DoMessageWarn("keeping the kubeadm config file at %q: remember to remove it", kubeadmConfigFilename+".bak"),
+			ssh.DoTry(ssh.DoMoveFile(kubeadmConfigFilename, kubeadmConfigFilename+".bak")),
+		}
+	}
+	return ssh.ActionList{
+		ssh.DoMessageDebug("removing the kubeadm config file %q", kubeadmConfigFilename),
+		ssh.DoTry(ssh.DoDeleteFile(kubeadmConfigFilename)),
+		ssh.DoTry(ssh.DoDeleteFile(kubeadmConfigFilename + ".bak")),
 	}
-	return actions
 }
 
 // doMaybeResetWorker maybe "reset"s with kubeadm if /etc/kubernetes/kubeadm-* exists
@@ -133,7 +230,7 @@ func doMaybeResetWorker(d *schema.ResourceData, kubeadmConfigFilename string) ss
 }
 
 func doUploadKubeadmConfig(d *schema.ResourceData, command string, kubeadmConfigFilename string) ssh.Action {
-	return ssh.ActionFunc(func(context.Context) ssh.Action {
+	return ssh.ActionFunc(func(ctx context.Context) ssh.Action {
 		// we must delay the {init|join}Config retrieval as some other functions
 		// modify it until the very last moment...
 		configBytes := []byte{}
@@ -145,16 +242,148 @@ func doUploadKubeadmConfig(d *schema.ResourceData, command string, kubeadmConfig
 				return ssh.ActionError(fmt.Sprintf("could not get a valid 'config' for init'ing: %s", err))
 			}
 
+			// the KubeletConfiguration is stored by kubeadm in the cluster (in the "kubelet-config"
+			// ConfigMap) and it is used when joining, so we only need to add it for the "init"
+			kubeletConfig, err := getKubeletConfigFromResourceData(d)
+			if err != nil {
+				return ssh.ActionError(err.Error())
+			}
+			configBytes = common.AppendKubeletConfig(configBytes, kubeletConfig)
+			configBytes = common.AppendKubeProxyConfig(configBytes, getKubeProxyModeFromResourceData(d))
+
 		case "join":
-			_, configBytes, err = common.JoinConfigFromResourceData(d)
+			joinConfig, joinConfigBytes, err := common.JoinConfigFromResourceData(d)
 			if err != nil {
 				return ssh.ActionError(fmt.Sprintf("could not get a valid 'config' for join'ing: %s", err))
 			}
+			configBytes = joinConfigBytes
+
+			// with the TLS bootstrap, kubeadm must use the credentials in the bootstrap kubeconfig
+			if getTLSBootstrapFromResourceData(d) {
+				joinConfig.Discovery.TLSBootstrapToken = ""
+				configBytes, err = common.JoinConfigToYAML(joinConfig)
+				if err != nil {
+					return ssh.ActionError(fmt.Sprintf("could not get a valid 'config' for join'ing: %s", err))
+				}
+			}
+		}
+
+		// convert the configuration to the API version supported by the kubeadm in the machine
+		version, err := getKubeadmAPIVersion(ctx, d)
+		if err != nil {
+			return ssh.DoAbort("%s", err)
+		}
+		configBytes, err = common.ConvertKubeadmConfig(configBytes, version)
+		if err != nil {
+			return ssh.ActionError(fmt.Sprintf("could not convert the kubeadm configuration to %s: %s", version, err))
 		}
+
 		return ssh.DoUploadBytesToFile(configBytes, kubeadmConfigFilename)
 	})
 }
 
+// getKubeadmAPIVersion returns the kubeadm config API version we must use in the configuration
+// files: the one requested in "kubeadm_api_version" (checking it is supported by the kubeadm
+// in the machine), or the newest version supported by that kubeadm.
+func getKubeadmAPIVersion(ctx context.Context, d *schema.ResourceData) (string, error) {
+	requested := getKubeadmAPIVersionFromResourceData(d)
+
+	kubeadmVersion, err := getRemoteKubeVersion(ctx, fmt.Sprintf("%s version -o short", getKubeadmFromResourceData(d)))
+	if err != nil {
+		if requested != "" {
+			return requested, nil
+		}
+		ssh.Debug("could not detect the kubeadm version (using %s): %s", common.DefKubeadmAPIVersion(), err)
+		return common.DefKubeadmAPIVersion(), nil
+	}
+
+	if requested != "" {
+		if err := common.CheckKubeadmAPIVersion(requested, kubeadmVersion); err != nil {
+			return "", fmt.Errorf("invalid 'kubeadm_api_version': %s", err)
+		}
+		return requested, nil
+	}
+
+	version, err := common.KubeadmAPIVersionFor(kubeadmVersion)
+	if err != nil {
+		return "", err
+	}
+	ssh.Debug("using kubeadm config API version %s for kubeadm %s", version, kubeadmVersion)
+	return version, nil
+}
+
+// doCleanupSensitiveFiles removes the leftovers and all the temporary files uploaded to the
+// node (kubeconfigs, manifests...), but keeps the temporary files when we want to keep
+// sensitive files for debugging
+func doCleanupSensitiveFiles(d *schema.ResourceData) ssh.Action {
+	if getKeepSensitiveFilesFromResourceData(d) {
+		return ssh.ActionList{
+			ssh.DoCleanupLeftovers(),
+			ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+				return ssh.DoMessageWarn("keeping temporary files at %q: remember to remove them", ssh.GetTmpDirFromContext(ctx))
+			}),
+		}
+	}
+	return ssh.ActionList{
+		ssh.DoCleanupLeftovers(),
+		ssh.DoCleanupTmpDir(),
+	}
+}
+
+// updateKubeletExtraArgs updates the kubelet extra args in the `config.init` or `config.join`
+// (depending on the `command`) with some function
+func updateKubeletExtraArgs(d *schema.ResourceData, command string, update func(args map[string]string) error) error {
+	switch command {
+	case "init":
+		initConfig, _, err := common.InitConfigFromResourceData(d)
+		if err != nil {
+			return fmt.Errorf("could not get a valid 'config' for init'ing: %s", err)
+		}
+		if initConfig.NodeRegistration.KubeletExtraArgs == nil {
+			initConfig.NodeRegistration.KubeletExtraArgs = map[string]string{}
+		}
+		if err := update(initConfig.NodeRegistration.KubeletExtraArgs); err != nil {
+			return err
+		}
+		return common.InitConfigToResourceData(d, initConfig)
+
+	case "join":
+		joinConfig, _, err := common.JoinConfigFromResourceData(d)
+		if err != nil {
+			return fmt.Errorf("could not get a valid 'config' for join'ing: %s", err)
+		}
+		if joinConfig.NodeRegistration.KubeletExtraArgs == nil {
+			joinConfig.NodeRegistration.KubeletExtraArgs = map[string]string{}
+		}
+		if err := update(joinConfig.NodeRegistration.KubeletExtraArgs); err != nil {
+			return err
+		}
+		return common.JoinConfigToResourceData(d, joinConfig)
+	}
+	return fmt.Errorf("unknown kubeadm command %q", command)
+}
+
+// getKubeletExtraArgs returns the kubelet extra args in the `config.init` or `config.join`
+// (depending on the `command`)
+func getKubeletExtraArgs(d *schema.ResourceData, command string) (map[string]string, error) {
+	switch command {
+	case "init":
+		initConfig, _, err := common.InitConfigFromResourceData(d)
+		if err != nil {
+			return nil, fmt.Errorf("could not get a valid 'config' for init'ing: %s", err)
+		}
+		return initConfig.NodeRegistration.KubeletExtraArgs, nil
+
+	case "join":
+		joinConfig, _, err := common.JoinConfigFromResourceData(d)
+		if err != nil {
+			return nil, fmt.Errorf("could not get a valid 'config' for join'ing: %s", err)
+		}
+		return joinConfig.NodeRegistration.KubeletExtraArgs, nil
+	}
+	return nil, fmt.Errorf("unknown kubeadm command %q", command)
+}
+
 // doUploadCerts upload the certificates from the serialized `d.config` to the remote machine
 // we only do this on the control plane machines
 func doUploadCerts(d *schema.ResourceData) ssh.Action {
@@ -163,6 +392,11 @@ func doUploadCerts(d *schema.ResourceData) ssh.Action {
 		return ssh.ActionError("no certificates data in config")
 	}
 
+	// upload the whole PKI tree when it has been pre-generated
+	if getPKIDirFromResourceData(d) != "" {
+		return doUploadPKIDir(d, certsConfig)
+	}
+
 	certsDir := common.DefPKIDir
 	certsDirRaw, ok := d.GetOk("config.certs_dir")
 	if ok {
@@ -182,6 +416,107 @@ func doUploadCerts(d *schema.ResourceData) ssh.Action {
 	return actions
 }
 
+// doUploadAuditConfig uploads the API server audit policy and webhook config (when configured)
+// we only do this on the control plane machines
+func doUploadAuditConfig(d *schema.ResourceData) ssh.Action {
+	policyOpt, ok := d.GetOk("config.audit_policy")
+	if !ok || policyOpt.(string) == "" {
+		return nil
+	}
+
+	policy, err := common.FromTerraformSafeString(policyOpt.(string))
+	if err != nil {
+		return ssh.ActionError(fmt.Sprintf("could not decode the audit policy: %s", err))
+	}
+
+	actions := ssh.ActionList{
+		ssh.DoMessageInfo("Uploading audit configuration..."),
+		ssh.DoUploadBytesToFile(policy, common.DefAuditPolicyPath),
+	}
+
+	if webhookOpt, ok := d.GetOk("config.audit_webhook_config"); ok && webhookOpt.(string) != "" {
+		webhookConfig, err := common.FromTerraformSafeString(webhookOpt.(string))
+		if err != nil {
+			return ssh.ActionError(fmt.Sprintf("could not decode the audit webhook config: %s", err))
+		}
+		// the webhook kubeconfig can contain credentials
+		actions = append(actions,
+			ssh.DoUploadBytesToFileWithMode(webhookConfig, common.DefAuditWebhookConfigPath, 0600))
+	}
+
+	return actions
+}
+
+// doUploadEgressSelectorConfig uploads the API server egress selector configuration (when configured)
+// we only do this on the control plane machines
+func doUploadEgressSelectorConfig(d *schema.ResourceData) ssh.Action {
+	configOpt, ok := d.GetOk("config.egress_selector_config")
+	if !ok || configOpt.(string) == "" {
+		return nil
+	}
+
+	config, err := common.FromTerraformSafeString(configOpt.(string))
+	if err != nil {
+		return ssh.ActionError(fmt.Sprintf("could not decode the egress selector configuration: %s", err))
+	}
+
+	actions := ssh.ActionList{
+		ssh.DoMessageInfo("Uploading egress selector configuration..."),
+		ssh.DoUploadBytesToFile(config, common.DefEgressSelectorConfigPath),
+	}
+	if isKonnectivityEnabled(d) {
+		// the API server mounts the directory where the konnectivity server creates its socket
+		actions = append(actions, ssh.DoExec(fmt.Sprintf("mkdir -p %s", common.DefKonnectivityDir)))
+	}
+	return actions
+}
+
+// doUploadSchedulerConfig uploads the scheduler configuration (when configured)
+// we only do this on the control plane machines
+func doUploadSchedulerConfig(d *schema.ResourceData) ssh.Action {
+	configOpt, ok := d.GetOk("config.scheduler_config")
+	if !ok || configOpt.(string) == "" {
+		return nil
+	}
+
+	config, err := common.FromTerraformSafeString(configOpt.(string))
+	if err != nil {
+		return ssh.ActionError(fmt.Sprintf("could not decode the scheduler configuration: %s", err))
+	}
+
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Uploading scheduler configuration..."),
+		ssh.DoUploadBytesToFile(config, common.DefSchedulerConfigPath),
+	}
+}
+
+// doUploadEtcdPatch uploads the kubeadm patch for the local etcd (when configured)
+func doUploadEtcdPatch(d *schema.ResourceData) ssh.Action {
+	patchOpt, ok := d.GetOk("config.etcd_patch")
+	if !ok || patchOpt.(string) == "" {
+		return nil
+	}
+
+	patch, err := common.FromTerraformSafeString(patchOpt.(string))
+	if err != nil {
+		return ssh.ActionError(fmt.Sprintf("could not decode the etcd patch: %s", err))
+	}
+
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Uploading etcd patch..."),
+		ssh.DoUploadBytesToFile(patch, common.DefEtcdPatchPath),
+	}
+}
+
+// getKubeadmPatchesArgs returns the kubeadm arguments for using the patches
+// uploaded by doUploadEtcdPatch
+func getKubeadmPatchesArgs(d *schema.ResourceData) []string {
+	if patch, ok := d.GetOk("config.etcd_patch"); !ok || patch.(string) == "" {
+		return []string{}
+	}
+	return []string{"--patches=" + common.DefKubeadmPatchesDir}
+}
+
 // doLoadCloudProviderManager uploads the cloud-config to /etc/kubernetes/cloud.conf if necessary
 func doLoadCloudProviderManager(d *schema.ResourceData) ssh.Action {
 	cloudProviderRaw, ok := d.GetOk("config.cloud_provider")
@@ -226,8 +561,11 @@ func doCheckCommonBinaries(d *schema.ResourceData) ssh.Action {
 		if expected.property != "" {
 			notFoundActions = append(notFoundActions, ssh.DoMessageWarn("You can specify a custom path in the '%s' property in the 'provisioner kubeadm' block.", expected.property))
 		}
-		// .. and the last action will be an abort()
-		notFoundActions = append(notFoundActions, ssh.DoAbort("base system requirements not satisfied"))
+		// .. and the last action will be an abort(), unless the binary is optional
+		// and the user did not provide a custom path for it
+		if !expected.optional || path != expected.name {
+			notFoundActions = append(notFoundActions, ssh.DoAbort("base system requirements not satisfied"))
+		}
 
 		checks = append(checks,
 			ssh.DoIfElse(
@@ -236,6 +574,9 @@ func doCheckCommonBinaries(d *schema.ResourceData) ssh.Action {
 				notFoundActions))
 	}
 
+	// ... and then check they have matching versions
+	checks = append(checks, doCheckBinariesVersions(d))
+
 	return checks
 }
 
@@ -266,3 +607,14 @@ func doUploadResolvConf(d *schema.ResourceData) ssh.Action {
 		ssh.DoUploadBytesToFile(buf.Bytes(), common.DefResolvUpstreamConf),
 	}
 }
+
+// doRebootIfNeeded reboots the machine (waiting until it is back) when some
+// previous action has signaled it, but only if "reboot_if_needed" is enabled
+func doRebootIfNeeded(d *schema.ResourceData) ssh.Action {
+	if !d.Get("reboot_if_needed").(bool) {
+		return ssh.DoIf(
+			ssh.CheckRebootNeeded(),
+			ssh.DoMessageWarn("a reboot is needed for applying some changes: enable 'reboot_if_needed' for doing it automatically"))
+	}
+	return ssh.DoRebootIfNeeded(ssh.DefRebootTimeout)
+}
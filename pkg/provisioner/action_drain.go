@@ -22,29 +22,91 @@ import (
 	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
 )
 
+// doRemoveNode removes the node from the cluster: it is drained, deleted
+// and removed from the etcd cluster (if it was a member). Control-plane
+// nodes are also reset, so the machine can be destroyed safely.
 func doRemoveNode(d *schema.ResourceData) ssh.Action {
+	if getResetOnlyFromResourceData(d) {
+		return doResetNode(d)
+	}
 	return ssh.ActionList{
 		ssh.DoMessageInfo("Preparing to remove node from cluster..."),
+		doCheckEtcdQuorum(d),
 		ssh.DoTry(doDrainKubernetesNode(d)),
 		ssh.DoTry(doRemoveIfMember(d)),
+		ssh.DoTry(doResetControlPlane(d)),
+	}
+}
+
+// doResetNode removes the node from the cluster but keeps the machine, so it
+// can be reused: the node is drained, removed from the etcd cluster, "kubeadm reset"
+// and, finally, deleted from the cluster
+func doResetNode(d *schema.ResourceData) ssh.Action {
+	localKubeNode := ssh.KubeNode{}
+	notFound := false
+
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Preparing to remove node from cluster (keeping the machine)..."),
+		doCheckEtcdQuorum(d),
+		ssh.DoTry(ssh.ActionList{
+			DoGetNodename(d, &localKubeNode),
+			doCordonAndDrainKubernetesNode(d, &localKubeNode, &notFound),
+		}),
+		ssh.DoTry(doRemoveIfMember(d)),
+		ssh.DoMessageInfo("Resetting the node with 'kubeadm reset'..."),
+		doExecKubeadmWithConfig(d, "reset", "", "--force"),
+		ssh.DoFlushCache(),
+		ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+			if localKubeNode.IsEmpty() || notFound {
+				return nil
+			}
+			return ssh.DoTry(ssh.ActionList{
+				doKubectlDeleteNode(d, localKubeNode.Nodename),
+				ssh.DoMessageInfo("Kubernetes node %q has been deleted", localKubeNode.Nodename),
+			})
+		}),
+		ssh.DoMessageInfo("Node has been reset: the machine can be reused"),
 	}
 }
 
-// doDrainKubernetesNode drains a Kubernetes node
+// doCordonAndDrainKubernetesNode cordons and drains a Kubernetes node, setting
+// `notFound` when the node is not in the cluster
+func doCordonAndDrainKubernetesNode(d *schema.ResourceData, node *ssh.KubeNode, notFound *bool) ssh.Action {
+	return ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+		if node.IsEmpty() {
+			return ssh.DoMessageWarn("could not find Kubernetes nodename for this node")
+		}
+
+		// cordon the node with "nodename", so no new pods are scheduled while draining
+		res := doKubectlCordonNode(d, node.Nodename, notFound).Apply(ctx)
+		if ssh.IsError(res) {
+			return res
+		}
+		if *notFound {
+			return ssh.DoMessageWarn("nothing to drain: node %q is not in the cluster", node.Nodename)
+		}
+
+		return ssh.ActionList{
+			doKubectlDrainNode(d, node.Nodename),
+			ssh.DoMessageInfo("Kubernetes node %q has been drained", node.Nodename),
+		}
+	})
+}
+
+// doDrainKubernetesNode drains a Kubernetes node (and deletes it from the cluster)
 func doDrainKubernetesNode(d *schema.ResourceData) ssh.Action {
 	localKubeNode := ssh.KubeNode{}
+	notFound := false
 
 	actions := ssh.ActionList{
 		ssh.DoMessageInfo("Checking if we must drain the node from the Kubernetes cluster..."),
 		DoGetNodename(d, &localKubeNode),
+		doCordonAndDrainKubernetesNode(d, &localKubeNode, &notFound),
 		ssh.ActionFunc(func(ctx context.Context) ssh.Action {
-			if localKubeNode.IsEmpty() {
-				return ssh.DoMessageWarn("could not find Kubernetes nodename for this node")
+			if localKubeNode.IsEmpty() || notFound {
+				return nil
 			}
-			// drain the node with "nodename"
 			return ssh.ActionList{
-				doKubectlDrainNode(d, localKubeNode.Nodename),
-				ssh.DoMessageInfo("Kubernetes node %q has been drained", localKubeNode.Nodename),
 				doKubectlDeleteNode(d, localKubeNode.Nodename),
 				ssh.DoMessageInfo("Kubernetes node %q has been deleted", localKubeNode.Nodename),
 			}
@@ -15,7 +15,12 @@
 package provisioner
 
 import (
+	"bytes"
+	"context"
+	"crypto/md5"
 	"fmt"
+	"path"
+	"strings"
 	"time"
 
 	"github.com/hashicorp/terraform/helper/schema"
@@ -24,9 +29,123 @@ import (
 	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
 )
 
+const (
+	// interval between "kubeadm init" attempts (multiplied by initRetryBackoff after each attempt)
+	initRetryInterval = 15 * time.Second
+
+	// factor for increasing the interval between "kubeadm init" attempts
+	initRetryBackoff = 2
+
+	// exit code used by kubeadm when some preflight check fails
+	kubeadmPreflightExitCode = 2
+
+	// exit code used by kubeadm when the configuration is not valid
+	kubeadmValidationExitCode = 3
+)
+
+var (
+	// some things in the kubeadm output that indicate a configuration error,
+	// where retrying (after a reset) would be pointless
+	kubeadmConfigErrors = []string{
+		"unknown flag",
+		"unknown field",
+		"error unmarshaling",
+		"error converting YAML",
+		"invalid configuration",
+		"this version of kubeadm only supports",
+	}
+)
+
+// isRetryableKubeadmOutput returns false when the output of a failed kubeadm
+// shows some configuration error that should not be retried. Other failures
+// (ie, timeouts or image pull errors) can be retried.
+func isRetryableKubeadmOutput(output string) bool {
+	for _, e := range kubeadmConfigErrors {
+		if strings.Contains(output, e) {
+			return false
+		}
+	}
+	return true
+}
+
+// isRetryableKubeadmResult returns false when kubeadm failed with some
+// exit code (or output) that shows that retrying would be pointless
+func isRetryableKubeadmResult(res ssh.Action, output string) bool {
+	if e, ok := ssh.GetExecError(res); ok {
+		switch e.ExitCode {
+		case kubeadmPreflightExitCode, kubeadmValidationExitCode:
+			return false
+		}
+	}
+	return isRetryableKubeadmOutput(output)
+}
+
+// doKubeadmInitWithRetries runs the `kubeadm init` up to "init_retries"+1 times,
+// resetting the node between attempts
+func doKubeadmInitWithRetries(d *schema.ResourceData, extraArgs ...string) ssh.Action {
+	retries := getInitRetriesFromResourceData(d)
+	attempt := 0
+	var output bytes.Buffer
+
+	return ssh.DoRetry(
+		ssh.Retry{
+			Times:    retries + 1,
+			Interval: initRetryInterval,
+			Backoff:  initRetryBackoff,
+			Retryable: func(res ssh.Action) bool {
+				return isRetryableKubeadmResult(res, output.String())
+			},
+		},
+		ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+			attempt++
+			output.Reset()
+
+			// (a partial setup left by a previous run has already been reset before the first attempt)
+			actions := ssh.ActionList{}
+			if attempt > 1 {
+				actions = append(actions,
+					ssh.DoMessageWarn("Resetting the node before retrying 'kubeadm init' (attempt %d of %d)...", attempt, retries+1),
+					doExecKubeadmWithConfig(d, "reset", "", "--force"),
+					ssh.DoFlushCache())
+			}
+
+			actions = append(actions,
+				doUploadCerts(d), // (we must upload certs because a "kubeadm reset" wipes them...)
+				doUploadAuditConfig(d),
+				doUploadEgressSelectorConfig(d),
+				doUploadSchedulerConfig(d),
+				doUploadEtcdPatch(d),
+				ssh.DoMessageInfo("Initializing the cluster with 'kubadm init'..."),
+				ssh.DoCopyingExecOutputToWriter(doKubeadm(d, common.DefKubeadmInitConfPath, "init", extraArgs...), &output))
+
+			res := actions.Apply(ctx)
+			if ssh.IsError(res) && !isRetryableKubeadmResult(res, output.String()) {
+				_ = ssh.DoMessageWarn("kubeadm failed with a configuration error: it will not be retried").Apply(ctx)
+			}
+			return res
+		}),
+	)
+}
+
 // doKubeadmInit runs the `kubeadm init`
 func doKubeadmInit(d *schema.ResourceData) ssh.Action {
-	extraArgs := []string{"--skip-token-print"}
+	extraArgs := []string{}
+	if getSkipTokenPrintFromResourceData(d) {
+		extraArgs = append(extraArgs, "--skip-token-print")
+	}
+	skipPhases := []string{}
+	if getPKIDirFromResourceData(d) != "" {
+		// all the certificates are in the pre-generated PKI tree
+		skipPhases = append(skipPhases, "certs")
+	}
+	if getKubeProxyModeFromResourceData(d) == common.KubeProxyModeNone {
+		// the kube-proxy is replaced by the CNI plugin
+		skipPhases = append(skipPhases, "addon/kube-proxy")
+	}
+	if len(skipPhases) > 0 {
+		extraArgs = append(extraArgs, "--skip-phases="+strings.Join(skipPhases, ","))
+	}
+	extraArgs = append(extraArgs, getKubeadmPatchesArgs(d)...)
 
 	// get the join configuration
 	initConfig, _, err := common.InitConfigFromResourceData(d)
@@ -46,8 +165,12 @@ func doKubeadmInit(d *schema.ResourceData) ssh.Action {
 		// * if a "admin.conf" is there and the cluster is alive, do nothing
 		//   (just try to reload CNI, Helm and so)
 		// * if a partial setup is detected (ie, cluster is not alive but some manifests are there...)
-		//   try to reset the node
+		//   reset the node before the preflight checks
 		// * in any other case, do a regular "kubeadm init"
+		// but first, make sure this node has not been initialized for a different cluster
+		ssh.DoIf(
+			checkInitializedForOtherCluster(d),
+			ssh.DoAbort("this node has already been initialized for a different cluster: reset it first (ie, with 'kubeadm reset')")),
 		doDeleteLocalKubeconfig(d),
 		ssh.DoIfElse(
 			checkAdminConfAlive(d),
@@ -55,43 +178,98 @@ func doKubeadmInit(d *schema.ResourceData) ssh.Action {
 				ssh.DoMessageInfo("There is a 'admin.conf' in this master pointing to a live cluster: skipping any setup"),
 			},
 			ssh.ActionList{
-				ssh.DoRetry(
-					ssh.Retry{Times: 3, Interval: 15 * time.Second},
-					ssh.ActionList{
-						doMaybeResetMaster(d, common.DefKubeadmInitConfPath),
-						doUploadCerts(d), // (we must upload certs because a "kubeadm reset" wipes them...)
-						ssh.DoMessageInfo("Initializing the cluster with 'kubadm init'..."),
-						doKubeadm(d, common.DefKubeadmInitConfPath, "init", extraArgs...),
-					},
-				),
+				doMaybeResetMaster(d, common.DefKubeadmInitConfPath),
+				doRunHook(d, "pre_init"),
+				doKubeadmPreflight(d, "init"),
+				doKubeadmInitWithRetries(d, extraArgs...),
+				doPatchAPIServerProbes(d),
+				doDeployKonnectivityServer(d),
+				doRestrictPermissions(d),
+				doRunHook(d, "post_init"),
 			},
 		),
 		// we always download the kubeconfig and try to do a "kubeactl apply -f" of manifests
 		doDownloadKubeconfig(d),
-		doLoadCNI(d),
+		doWaitControlPlaneHealthy(d),
+		doCheckAPIServerCertSANs(d, "init"),
+		doSetControlPlaneLabel(d),
+		doWaitDefaultServiceAccounts(d, defaultServiceAccountsNamespaces...),
+		doCreateNamespaces(d),
+		doApproveServingCSRs(d),
+		doCreateBootstrapKubeconfigToken(d),
+		doLoadCNIIfNotHealthy(d),
+		doLoadMultus(d),
+		doLoadKonnectivityAgent(d),
+		doWaitCoreAddons(d),
+		doVerifyNoKubeProxy(d),
+		doLoadNetworkPolicies(d),
 		doLoadDashboard(d),
 		doLoadHelm(d),
 		doLoadCloudProviderManager(d),
+		doLoadStorage(d),
+		doLoadRBAC(d),
 		doLoadExtraManifests(d),
+		doLoadKustomizations(d),
+		doRunKubectlCommands(d),
+		doWaitForWorkers(d),
+		doScaleCoreDNS(d),
+		doSetCriticalAddonsPriority(d),
+		doCheckDNS(d),
 	}
 	return actions
 }
 
+// checkPreviousKubeadmState checks if there is some previous kubeadm state in the node
+// (ie, some static pods manifests, a kubelet.conf or a running etcd)
+func checkPreviousKubeadmState() ssh.CheckerFunc {
+	return ssh.CheckOr(
+		ssh.CheckFileExists("/etc/kubernetes/manifests/kube-apiserver.yaml"),
+		ssh.CheckFileExists("/etc/kubernetes/manifests/etcd.yaml"),
+		ssh.CheckFileExists("/etc/kubernetes/kubelet.conf"),
+		ssh.CheckExec("pgrep -x etcd >/dev/null"),
+	)
+}
+
+// checkInitializedForOtherCluster checks if the node has some previous kubeadm
+// state and a CA certificate that does not match the CA of our cluster
+func checkInitializedForOtherCluster(d *schema.ResourceData) ssh.CheckerFunc {
+	certsConfig := &common.CertsConfig{}
+	if err := certsConfig.FromResourceDataConfig(d); err != nil || certsConfig.CaCrt == "" {
+		// we cannot know which is our CA: we cannot tell if this is a different cluster
+		return ssh.CheckExpr(false)
+	}
+
+	certsDir := common.DefPKIDir
+	if certsDirRaw, ok := d.GetOk("config.certs_dir"); ok {
+		certsDir = certsDirRaw.(string)
+	}
+	caPath := path.Join(certsDir, "ca.crt")
+
+	sameCA := fmt.Sprintf(`[ "$(md5sum < %s | cut -d' ' -f1)" = "%x" ]`, caPath, md5.Sum([]byte(certsConfig.CaCrt)))
+	return ssh.CheckAnd(
+		checkPreviousKubeadmState(),
+		ssh.CheckFileExists(caPath),
+		ssh.CheckNot(ssh.CheckExec(sameCA)))
+}
+
 // doMaybeResetMaster maybe "reset"s the master with kubeadm if
-// it is detected as "partially" setup:
-// ie, /etc/kubernetes/kubeadm-*.conf exist AND /etc/kubernetes/manifests/* exist
+// it is detected as "partially" setup (ie, by an interrupted `kubeadm init`):
+// ie, /etc/kubernetes/kubeadm-*.conf exist OR /etc/kubernetes/manifests/* exist.
+// Callers must make sure the node is not part of a live cluster (ie, with `checkAdminConfAlive`).
 func doMaybeResetMaster(d *schema.ResourceData, kubeadmConfigFilename string) ssh.Action {
-	return ssh.DoIf(
+	return ssh.DoIfElse(
 		ssh.CheckOr(
 			ssh.CheckFileExists(kubeadmConfigFilename),
 			ssh.CheckFileExists("/etc/kubernetes/manifests/kube-apiserver.yaml"),
 			ssh.CheckFileExists("/etc/kubernetes/manifests/kube-controller-manager.yaml"),
 			ssh.CheckFileExists("/etc/kubernetes/manifests/kube-scheduler.yaml"),
+			ssh.CheckFileExists("/etc/kubernetes/manifests/etcd.yaml"),
 		),
 		ssh.ActionList{
-			ssh.DoMessageWarn("previous kubeadm config file found: resetting node"),
+			ssh.DoMessageWarn("a previous kubeadm run left this node partially set up: resetting node"),
 			doExecKubeadmWithConfig(d, "reset", "", "--force"),
 			ssh.DoDeleteFile(kubeadmConfigFilename),
 			ssh.DoFlushCache(),
-		})
+		},
+		ssh.DoMessageDebug("no previous (interrupted) kubeadm run found in this node"))
 }
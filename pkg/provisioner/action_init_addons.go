@@ -15,8 +15,13 @@
 package provisioner
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"io"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/hashicorp/terraform/helper/schema"
 
@@ -46,6 +51,113 @@ func doLoadDashboard(d *schema.ResourceData) ssh.Action {
 	}
 }
 
+const (
+	// command for getting the "Ready" condition of the pods in a namespace
+	kubectlGetNamespaceReadyCmd = `-n %s get pods -o=jsonpath='{.items[*].status.conditions[?(@.type=="Ready")].status}'`
+
+	// command for marking a StorageClass as the default one
+	kubectlSetDefaultStorageClassCmd = `patch storageclass %s -p '{"metadata": {"annotations": {"storageclass.kubernetes.io/is-default-class": "true"}}}'`
+
+	// interval between checks of the storage driver pods
+	storageReadyInterval = 10 * time.Second
+
+	// max time we wait for the storage driver pods to be ready
+	storageReadyTimeout = 5 * time.Minute
+)
+
+// doLoadStorage loads the storage driver (if enabled), waits for its pods to be
+// ready and (optionally) marks its StorageClass as the default one
+func doLoadStorage(d *schema.ResourceData) ssh.Action {
+	manifestOpt, ok := d.GetOk("config.storage_manifest")
+	if !ok || manifestOpt.(string) == "" {
+		return nil
+	}
+	manifest := ssh.NewManifest(manifestOpt.(string))
+
+	actions := ssh.ActionList{
+		ssh.DoMessageInfo("Loading the storage driver..."),
+		doRemoteKubectlApply(d, []ssh.Manifest{manifest}),
+	}
+
+	// note: the driver pods could be waiting for some workers (ie, when the control plane
+	//       is tainted), so we do not fail when they are not ready
+	if namespaceOpt, ok := d.GetOk("config.storage_namespace"); ok && namespaceOpt.(string) != "" {
+		namespace := namespaceOpt.(string)
+		checkReady := ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+			var buf bytes.Buffer
+			res := doKubectlWithOutput(d, &buf, fmt.Sprintf(kubectlGetNamespaceReadyCmd, namespace)).Apply(ctx)
+			if ssh.IsError(res) {
+				return res
+			}
+			if !isReadyConditionsOutput(buf.String()) {
+				return ssh.ActionError(fmt.Sprintf("storage driver pods in %q are not ready", namespace))
+			}
+			return nil
+		})
+
+		actions = append(actions,
+			ssh.DoMessageInfo("Waiting for the storage driver pods in %q to be ready...", namespace),
+			ssh.DoIfElse(
+				ssh.CheckAction(doPoll(storageReadyTimeout, storageReadyInterval, checkReady)),
+				ssh.DoMessageInfo("The storage driver is ready."),
+				ssh.DoMessageWarn("the storage driver pods are not ready after %s: maybe they are waiting for some workers", storageReadyTimeout)))
+	}
+
+	classOpt, _ := d.GetOk("config.storage_class")
+	defaultOpt, _ := d.GetOk("config.storage_class_default")
+	class, _ := classOpt.(string)
+	defaultStr, _ := defaultOpt.(string)
+	if isDefault, _ := strconv.ParseBool(defaultStr); class != "" && isDefault {
+		actions = append(actions,
+			ssh.DoMessageInfo("Setting %q as the default StorageClass", class),
+			doKubectl(d, fmt.Sprintf(kubectlSetDefaultStorageClassCmd, class)))
+	}
+
+	return actions
+}
+
+// doCreateNamespaces creates the namespaces, with their ResourceQuotas and LimitRanges (updating them
+// when they already exist), so they are ready before loading any addon or manifest
+func doCreateNamespaces(d *schema.ResourceData) ssh.Action {
+	manifestOpt, ok := d.GetOk("config.namespaces_manifest")
+	if !ok || manifestOpt.(string) == "" {
+		return nil
+	}
+
+	manifest, err := common.FromTerraformSafeString(manifestOpt.(string))
+	if err != nil {
+		return ssh.ActionError(fmt.Sprintf("could not decode the namespaces manifest: %s", err))
+	}
+
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Creating namespaces (and their quotas and limits)..."),
+		doRemoteKubectlApply(d, []ssh.Manifest{{Inline: string(manifest)}}),
+	}
+}
+
+// doLoadRBAC applies the RBAC manifests and bindings. As they are applied with
+// a `kubectl apply`, this can be safely done every time the master is provisioned.
+func doLoadRBAC(d *schema.ResourceData) ssh.Action {
+	manifestsOpt, ok := d.GetOk("config.rbac_manifests")
+	if !ok || manifestsOpt.(string) == "" {
+		return nil
+	}
+
+	manifests := []ssh.Manifest{}
+	for _, encoded := range strings.Split(manifestsOpt.(string), ",") {
+		manifest, err := common.FromTerraformSafeString(encoded)
+		if err != nil {
+			return ssh.ActionError(fmt.Sprintf("could not decode the RBAC manifests: %s", err))
+		}
+		manifests = append(manifests, ssh.NewManifest(string(manifest)))
+	}
+
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Loading %d RBAC manifests...", len(manifests)),
+		doRemoteKubectlApply(d, manifests),
+	}
+}
+
 // doLoadExtraManifests loads some extra manifests
 func doLoadExtraManifests(d *schema.ResourceData) ssh.Action {
 	manifestsOpt, ok := d.GetOk("manifests")
@@ -64,3 +176,113 @@ func doLoadExtraManifests(d *schema.ResourceData) ssh.Action {
 		doRemoteKubectlApply(d, manifests),
 	}
 }
+
+// doLoadKustomizations uploads and applies some kustomize directories
+func doLoadKustomizations(d *schema.ResourceData) ssh.Action {
+	dirsOpt, ok := d.GetOk("kustomizations")
+	if !ok {
+		return nil
+	}
+
+	kubeconfig := getKubeconfigFromResourceData(d)
+	kubectl := getKubectlFromResourceData(d)
+	opts := getKubectlApplyOptionsFromResourceData(d)
+
+	actions := ssh.ActionList{}
+	for _, v := range dirsOpt.([]interface{}) {
+		dir := v.(string)
+		actions = append(actions,
+			ssh.DoMessageInfo("Loading kustomization from %q", dir),
+			doWithKubectlRetries(d, nil, func(w io.Writer) ssh.Action {
+				return ssh.DoCopyingExecOutputToWriter(ssh.DoRemoteKubectlApplyKustomize(kubectl, kubeconfig, dir, opts), w)
+			}))
+	}
+	return actions
+}
+
+// doRunKubectlCommands runs the user-provided kubectl commands
+func doRunKubectlCommands(d *schema.ResourceData) ssh.Action {
+	commandsOpt, ok := d.GetOk("kubectl_commands")
+	if !ok {
+		return nil
+	}
+	actions := ssh.ActionList{}
+	for _, v := range commandsOpt.([]interface{}) {
+		command := strings.TrimSpace(v.(string))
+		if command == "" {
+			continue
+		}
+		actions = append(actions,
+			ssh.DoMessageInfo("Running 'kubectl %s'", command),
+			doKubectl(d, command))
+	}
+	return actions
+}
+
+const (
+	// max time we wait for each core addon to be rolled out
+	coreAddonsReadyTimeout = 5 * time.Minute
+)
+
+// coreAddon is an addon deployed by kubeadm in the kube-system namespace
+type coreAddon struct {
+	Kind string
+	Name string
+
+	// NeedsCNI is true when the addon pods cannot be ready until some CNI is loaded
+	NeedsCNI bool
+}
+
+// coreAddons are the addons deployed by kubeadm we wait for
+var coreAddons = []coreAddon{
+	{Kind: "daemonset", Name: "kube-proxy"},
+	{Kind: "deployment", Name: "coredns", NeedsCNI: true},
+}
+
+// doWaitCoreAddons waits until the core addons deployed by kubeadm (kube-proxy
+// and CoreDNS) are fully rolled out. Addons that have not been deployed
+// (ie, when the kube-proxy has been replaced by the CNI plugin) are skipped.
+func doWaitCoreAddons(d *schema.ResourceData) ssh.Action {
+	if !d.Get("wait_addons").(bool) {
+		return nil
+	}
+
+	actions := ssh.ActionList{}
+	for _, addon := range coreAddons {
+		addon := addon
+		if addon.NeedsCNI && !hasCNIPlugin(d) {
+			actions = append(actions, ssh.DoMessageInfo("No CNI plugin loaded: not waiting for %s", addon.Name))
+			continue
+		}
+
+		exists := ssh.CheckerFunc(func(ctx context.Context) (bool, error) {
+			var buf bytes.Buffer
+			res := doKubectlWithOutput(d, &buf, "-n", "kube-system", "get", addon.Kind, addon.Name).Apply(ctx)
+			if ssh.IsError(res) {
+				if isKubectlNotFoundOutput(buf.String()) {
+					return false, nil
+				}
+				return false, fmt.Errorf("%s", res.Error())
+			}
+			return true, nil
+		})
+		timeout := fmt.Sprintf("--timeout=%ds", int(coreAddonsReadyTimeout.Seconds()))
+		actions = append(actions, ssh.DoIfElse(
+			exists,
+			ssh.ActionList{
+				ssh.DoMessageInfo("Waiting for %s to be rolled out...", addon.Name),
+				ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+					var out bytes.Buffer
+					res := doKubectlWithOutput(d, &out, "-n", "kube-system", "rollout", "status", addon.Kind+"/"+addon.Name, timeout).Apply(ctx)
+					if ssh.IsError(res) {
+						return ssh.ActionError(fmt.Sprintf("%s %q has not been rolled out after %s: check the pods in the kube-system namespace",
+							addon.Kind, addon.Name, coreAddonsReadyTimeout))
+					}
+					return ssh.DoMessageInfo("%s is ready.", addon.Name)
+				}),
+			},
+			ssh.DoMessageInfo("No %s %q found in kube-system: not waiting for it", addon.Kind, addon.Name),
+		))
+	}
+	return actions
+}
@@ -15,15 +15,105 @@
 package provisioner
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/terraform/helper/schema"
 
+	"github.com/inercia/terraform-provider-kubeadm/internal/assets"
 	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
 	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
 )
 
+const (
+	// command for getting the number of desired and ready pods of the DaemonSets matching a selector
+	kubectlGetDaemonSetsReadyCmd = `get daemonsets --all-namespaces -l '%s' -o=jsonpath='{range .items[*]}{.status.desiredNumberScheduled}{" "}{.status.numberReady}{"\n"}{end}'`
+
+	// labels selector for the Multus DaemonSet
+	multusDaemonSetSelector = "app=multus"
+
+	// interval between checks of the Multus DaemonSet
+	multusReadyInterval = 10 * time.Second
+
+	// max time we wait for the Multus DaemonSet to be ready
+	multusReadyTimeout = 5 * time.Minute
+)
+
+// cniDaemonSetsSelectors are the labels selectors for the DaemonSets of the CNI plugins
+var cniDaemonSetsSelectors = map[string]string{
+	"flannel": "app=flannel",
+	"weave":   "name=weave-net",
+	"calico":  "k8s-app=calico-node",
+	"cilium":  "k8s-app=cilium",
+}
+
+// isDaemonSetsReadyOutput returns true if the output of `kubectlGetDaemonSetsReadyCmd`
+// contains at least one DaemonSet and all the DaemonSets have all their pods ready
+func isDaemonSetsReadyOutput(output string) bool {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) == 0 || lines[0] == "" {
+		return false
+	}
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return false
+		}
+		desired, err := strconv.Atoi(fields[0])
+		if err != nil || desired == 0 {
+			return false
+		}
+		ready, err := strconv.Atoi(fields[1])
+		if err != nil || ready != desired {
+			return false
+		}
+	}
+	return true
+}
+
+// checkCNIHealthy checks if the CNI plugin is already running in the cluster,
+// with all the pods of its DaemonSet ready
+func checkCNIHealthy(d *schema.ResourceData) ssh.CheckerFunc {
+	return ssh.CheckerFunc(func(ctx context.Context) (bool, error) {
+		selector, ok := cniDaemonSetsSelectors[getCNIPluginFromResourceData(d)]
+		if !ok {
+			// we do not know how to detect this plugin
+			return false, nil
+		}
+
+		var buf bytes.Buffer
+		res := doKubectlWithOutput(d, &buf, fmt.Sprintf(kubectlGetDaemonSetsReadyCmd, selector)).Apply(ctx)
+		if ssh.IsError(res) {
+			return false, nil
+		}
+		return isDaemonSetsReadyOutput(buf.String()), nil
+	})
+}
+
+// doLoadCNIIfNotHealthy loads the CNI driver, skipping it when it is already
+// present and healthy (so we do not disrupt the network in every apply)
+func doLoadCNIIfNotHealthy(d *schema.ResourceData) ssh.Action {
+	return ssh.DoIfElse(
+		checkCNIHealthy(d),
+		ssh.DoMessageInfo("The CNI plugin is already running and healthy: skipping it."),
+		doLoadCNI(d))
+}
+
+// hasCNIPlugin returns true if some CNI plugin is loaded in the cluster (either
+// a pre-defined plugin or a user-provided manifest)
+func hasCNIPlugin(d *schema.ResourceData) bool {
+	for _, key := range []string{"config.cni_plugin", "config.cni_plugin_manifest"} {
+		if opt, ok := d.GetOk(key); ok && strings.TrimSpace(opt.(string)) != "" {
+			return true
+		}
+	}
+	return false
+}
+
 // doLoadCNI loads the CNI driver
 func doLoadCNI(d *schema.ResourceData) ssh.Action {
 	manifest := ssh.Manifest{}
@@ -68,3 +158,54 @@ func doLoadCNI(d *schema.ResourceData) ssh.Action {
 		doRemoteKubectlApply(d, []ssh.Manifest{manifest}),
 	}
 }
+
+// doLoadMultus loads the Multus meta-CNI plugin (when enabled) on top of the
+// primary CNI plugin, waiting until it is ready
+func doLoadMultus(d *schema.ResourceData) ssh.Action {
+	enabledOpt, ok := d.GetOk("config.multus_enabled")
+	if !ok {
+		return nil
+	}
+	if enabled, _ := strconv.ParseBool(enabledOpt.(string)); !enabled {
+		return nil
+	}
+
+	manifest := ssh.Manifest{Inline: assets.MultusManifestCode}
+	if manifestOpt, ok := d.GetOk("config.multus_manifest"); ok && len(strings.TrimSpace(manifestOpt.(string))) > 0 {
+		manifest = ssh.NewManifest(strings.TrimSpace(manifestOpt.(string)))
+		if manifest.Inline != "" {
+			return ssh.ActionError(fmt.Sprintf("%q not recognized as URL or local filename", manifestOpt.(string)))
+		}
+	}
+
+	err := manifest.ReplaceConfig(common.GetProvisionerConfig(d))
+	if err != nil {
+		return ssh.ActionError(fmt.Sprintf("could not replace variables in Multus manifest: %s", err))
+	}
+
+	checkReady := ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+		var buf bytes.Buffer
+		res := doKubectlWithOutput(d, &buf, fmt.Sprintf(kubectlGetDaemonSetsReadyCmd, multusDaemonSetSelector)).Apply(ctx)
+		if ssh.IsError(res) {
+			return res
+		}
+		if !isDaemonSetsReadyOutput(buf.String()) {
+			return ssh.ActionError("the Multus pods are not ready")
+		}
+		return nil
+	})
+
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Loading the Multus meta-CNI plugin..."),
+		doRemoteKubectlApply(d, []ssh.Manifest{manifest}),
+		ssh.DoMessageInfo("Waiting for Multus to be ready..."),
+		ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+			res := doPoll(multusReadyTimeout, multusReadyInterval, checkReady).Apply(ctx)
+			if ssh.IsError(res) {
+				return ssh.ActionError(fmt.Sprintf("timeout after %s waiting for Multus: %s", multusReadyTimeout, res.Error()))
+			}
+			return res
+		}),
+		ssh.DoMessageInfo("Multus is ready."),
+	}
+}
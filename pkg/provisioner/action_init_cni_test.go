@@ -0,0 +1,69 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/assets"
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+)
+
+func TestIsDaemonSetsReadyOutput(t *testing.T) {
+	testsCases := []struct {
+		output   string
+		expected bool
+	}{
+		{"3 3\n", true},
+		{"3 3\n1 1\n", true},
+		{"3 2\n", false},
+		{"3 3\n1 0\n", false},
+		{"0 0\n", false},
+		{"3\n", false},
+		{"", false},
+	}
+
+	for _, testCase := range testsCases {
+		if res := isDaemonSetsReadyOutput(testCase.output); res != testCase.expected {
+			t.Fatalf("Error: unexpected result for %q: got %t, expected %t", testCase.output, res, testCase.expected)
+		}
+	}
+}
+
+func TestMultusManifest(t *testing.T) {
+	config := map[string]interface{}{
+		"cni_conf_dir":         "/etc/cni/net.d",
+		"cni_bin_dir":          "/opt/cni/bin",
+		"multus_image_version": "v3.9.3",
+	}
+
+	manifest := ssh.Manifest{Inline: assets.MultusManifestCode}
+	if err := manifest.ReplaceConfig(config); err != nil {
+		t.Fatalf("Error: could not replace variables in Multus manifest: %s", err)
+	}
+	if strings.Contains(manifest.Inline, "--multus-master-cni-file-name") {
+		t.Fatalf("Error: default network found in Multus manifest when not provided")
+	}
+
+	config["multus_default_network"] = "10-flannel.conflist"
+	manifest = ssh.Manifest{Inline: assets.MultusManifestCode}
+	if err := manifest.ReplaceConfig(config); err != nil {
+		t.Fatalf("Error: could not replace variables in Multus manifest: %s", err)
+	}
+	if !strings.Contains(manifest.Inline, `"--multus-master-cni-file-name=10-flannel.conflist"`) {
+		t.Fatalf("Error: default network not found in Multus manifest:\n%s", manifest.Inline)
+	}
+}
@@ -15,14 +15,156 @@
 package provisioner
 
 import (
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
 	"github.com/inercia/terraform-provider-kubeadm/internal/assets"
 	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
 	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
 )
 
+const (
+	// script for generating/patching the containerd configuration. It
+	// * generates a default configuration if there is no config (or the CRI plugin is disabled)
+	// * sets the systemd cgroups driver
+	// * looks for the registries configuration in the "certs.d" directory
+	// containerd is restarted only if the configuration has been changed
+	containerdConfigScript = `#!/bin/sh
+CONFIG="%[1]s"
+CERTS_DIR="%[2]s"
+
+mkdir -p "$(dirname $CONFIG)" "$CERTS_DIR"
+BEFORE="$(md5sum $CONFIG 2>/dev/null)"
+
+if [ ! -s "$CONFIG" ] || grep -q 'disabled_plugins.*"cri"' "$CONFIG" ; then
+    echo "Generating a default containerd configuration"
+    containerd config default > "$CONFIG"
+fi
+
+sed -i 's/SystemdCgroup = false/SystemdCgroup = true/' "$CONFIG"
+sed -i "/\[plugins.\"io.containerd.grpc.v1.cri\".registry\]/,/^ *\[/ s|config_path = \"\"|config_path = \"$CERTS_DIR\"|" "$CONFIG"
+
+AFTER="$(md5sum $CONFIG 2>/dev/null)"
+if [ "$BEFORE" != "$AFTER" ] ; then
+    echo "containerd configuration has changed: restarting containerd"
+    systemctl --no-pager restart containerd
+fi
+`
+
+	// script for setting the sandbox image in the containerd configuration.
+	// containerd is restarted only if the configuration has been changed
+	containerdSandboxImageScript = `#!/bin/sh
+CONFIG="%[1]s"
+SANDBOX_IMAGE="%[2]s"
+
+grep -q "sandbox_image = \"$SANDBOX_IMAGE\"" "$CONFIG" && exit 0
+
+sed -i "s|sandbox_image = .*|sandbox_image = \"$SANDBOX_IMAGE\"|" "$CONFIG"
+if ! grep -q "sandbox_image = \"$SANDBOX_IMAGE\"" "$CONFIG" ; then
+    echo "could not set the sandbox image in $CONFIG"
+    exit 1
+fi
+
+echo "containerd sandbox image changed to $SANDBOX_IMAGE: restarting containerd"
+systemctl --no-pager restart containerd
+`
+
+	// CRI-O drop-in configuration for the sandbox image
+	crioSandboxImageConf = `[crio.image]
+pause_image = "%s"
+`
+
+	// command for getting the list of images used by kubeadm
+	kubeadmImagesListCmd = "%s config images list"
+
+	// the socket of the docker engine (the dockershim socket is created by the kubelet)
+	dockerEngineSocket = "/var/run/docker.sock"
+
+	// script for checking the runtime engine is running: it prints the status of the
+	// service, if the socket exists and if the runtime responds in the socket
+	runtimeCheckScript = `
+SERVICE="%[1]s"
+SOCKET="%[2]s"
+echo "service=$(systemctl is-active $SERVICE 2>/dev/null || echo unknown)"
+if [ ! -S "$SOCKET" ] ; then
+	echo "socket=missing"
+	exit 0
+fi
+echo "socket=present"
+if [ "$SERVICE" = "docker" ] ; then
+	docker info >/dev/null 2>&1 && echo "info=ok" || echo "info=failed"
+elif command -v %[3]s >/dev/null 2>&1 ; then
+	%[3]s --runtime-endpoint unix://$SOCKET --timeout 10s info >/dev/null 2>&1 && echo "info=ok" || echo "info=failed"
+else
+	echo "info=unknown"
+fi
+`
+)
+
+// getRuntimeSocket returns the socket where the runtime `engine` must respond
+func getRuntimeSocket(engine string) string {
+	if engine == "docker" {
+		return dockerEngineSocket
+	}
+	return common.DefCriSocket[engine]
+}
+
+// checkRuntimeOutput checks the output of the `runtimeCheckScript`, returning an
+// error when the runtime `engine` is not running or does not respond in the `socket`
+func checkRuntimeOutput(engine string, socket string, out string) error {
+	values := map[string]string{}
+	for _, line := range strings.Split(out, "\n") {
+		if kv := strings.SplitN(strings.TrimSpace(line), "=", 2); len(kv) == 2 {
+			values[kv[0]] = kv[1]
+		}
+	}
+
+	switch {
+	case values["socket"] == "missing":
+		return fmt.Errorf("the %q container runtime is not running: no socket found at %s (service status: %s)",
+			engine, socket, values["service"])
+	case values["info"] == "failed":
+		return fmt.Errorf("the %q container runtime is not responding at %s (service status: %s)",
+			engine, socket, values["service"])
+	case values["socket"] != "present":
+		return fmt.Errorf("could not check the %q container runtime at %s", engine, socket)
+	}
+	return nil
+}
+
+// doCheckContainerRuntime checks the container runtime engine is installed and
+// responding in its socket, failing with a clear message otherwise
+func doCheckContainerRuntime(d *schema.ResourceData) ssh.Action {
+	engine := getRuntimeEngineFromResourceData(d)
+	socket := getRuntimeSocket(engine)
+	if socket == "" {
+		return ssh.ActionError(fmt.Sprintf("unknown runtime engine %q", engine))
+	}
+	script := fmt.Sprintf(runtimeCheckScript, engine, socket, getCrictlFromResourceData(d))
+
+	var buf bytes.Buffer
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Checking the %q container runtime is running at %s...", engine, socket),
+		ssh.DoSendingExecOutputToWriter(ssh.DoExecScript([]byte(script)), &buf),
+		ssh.ActionFunc(func(context.Context) ssh.Action {
+			if err := checkRuntimeOutput(engine, socket, buf.String()); err != nil {
+				return ssh.ActionError(err.Error())
+			}
+			return nil
+		}),
+	}
+}
+
 // doPrepareCRI preparse the CRI in the target node
-func doPrepareCRI() ssh.Action {
+func doPrepareCRI(d *schema.ResourceData) ssh.Action {
 	return ssh.ActionList{
+		doConfigureContainerd(d),
+		doConfigureCrio(d),
 		ssh.DoUploadBytesToFile([]byte(assets.CNIDefConfCode), common.DefCniLookbackConfPath),
 		// we must reload the containers runtime engine after changing the CNI configuration
 		ssh.DoIf(
@@ -33,3 +175,160 @@ func doPrepareCRI() ssh.Action {
 			ssh.DoRestartService("docker.service")),
 	}
 }
+
+// doConfigureContainerd generates (or patches) the containerd configuration
+// when containerd is the runtime engine (and the "manage_config" is enabled)
+func doConfigureContainerd(d *schema.ResourceData) ssh.Action {
+	if getRuntimeEngineFromResourceData(d) != "containerd" {
+		return nil
+	}
+	if !getRuntimeManageConfigFromResourceData(d) {
+		return ssh.DoMessageWarn("Not managing the containerd configuration")
+	}
+
+	script := fmt.Sprintf(containerdConfigScript,
+		common.DefContainerdConfigPath,
+		common.DefContainerdCertsDir)
+
+	return ssh.DoIf(
+		ssh.CheckServiceExists("containerd.service"),
+		ssh.ActionList{
+			ssh.DoMessageInfo("Configuring containerd..."),
+			doUploadRegistryMirrors(d),
+			ssh.DoExecScript([]byte(script)),
+			doSetContainerdSandboxImage(d),
+		})
+}
+
+// doSetContainerdSandboxImage sets the sandbox (pause) image in the containerd configuration.
+// A mismatch between this image and the image expected by kubeadm can leave
+// pods stuck at "ContainerCreating".
+// The image is the one specified in the "sandbox_image" (when provided), or the
+// pause image kubeadm expects for the kubernetes version.
+func doSetContainerdSandboxImage(d *schema.ResourceData) ssh.Action {
+	if sandboxImageOpt, ok := d.GetOk("config.sandbox_image"); ok && sandboxImageOpt.(string) != "" {
+		return doUploadContainerdSandboxImage(sandboxImageOpt.(string))
+	}
+
+	cmd, err := getKubeadmImagesListCmd(d)
+	if err != nil {
+		return ssh.ActionError(fmt.Sprintf("could not get a valid 'config' for getting the sandbox image: %s", err))
+	}
+
+	return ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+		var buf bytes.Buffer
+		if res := ssh.DoSendingExecOutputToWriter(ssh.DoExec(cmd), &buf).Apply(ctx); ssh.IsError(res) {
+			return ssh.ActionError(fmt.Sprintf("could not get the list of kubeadm images: %s", res.Error()))
+		}
+
+		sandboxImage := getPauseImageFromImagesList(buf.String())
+		if sandboxImage == "" {
+			return ssh.ActionError("could not find the pause image in the list of kubeadm images")
+		}
+		return doUploadContainerdSandboxImage(sandboxImage)
+	})
+}
+
+// doConfigureCrio sets the sandbox image in a CRI-O drop-in configuration when
+// CRI-O is the runtime engine (and a "sandbox_image" has been provided).
+// Note: CRI-O is restarted after the CNI configuration is uploaded.
+func doConfigureCrio(d *schema.ResourceData) ssh.Action {
+	if getRuntimeEngineFromResourceData(d) != "crio" || !getRuntimeManageConfigFromResourceData(d) {
+		return nil
+	}
+	sandboxImageOpt, ok := d.GetOk("config.sandbox_image")
+	if !ok || sandboxImageOpt.(string) == "" {
+		return nil
+	}
+
+	conf := fmt.Sprintf(crioSandboxImageConf, sandboxImageOpt.(string))
+	return ssh.DoIf(
+		ssh.CheckServiceExists("crio.service"),
+		ssh.ActionList{
+			ssh.DoMessageInfo("Using sandbox image %q in CRI-O", sandboxImageOpt.(string)),
+			ssh.DoMkdirOnce(path.Dir(common.DefCrioSandboxImageConfPath)),
+			ssh.DoUploadBytesToFile([]byte(conf), common.DefCrioSandboxImageConfPath),
+		})
+}
+
+// getKubeadmImagesListCmd returns the `kubeadm config images list` command for
+// the kubernetes version and images repository in the configuration
+func getKubeadmImagesListCmd(d *schema.ResourceData) (string, error) {
+	initConfig, _, err := common.InitConfigFromResourceData(d)
+	if err != nil {
+		return "", err
+	}
+
+	cmd := fmt.Sprintf(kubeadmImagesListCmd, getKubeadmFromResourceData(d))
+	if initConfig.KubernetesVersion != "" {
+		cmd += fmt.Sprintf(" --kubernetes-version=%s", initConfig.KubernetesVersion)
+	}
+	if initConfig.ImageRepository != "" {
+		cmd += fmt.Sprintf(" --image-repository=%s", initConfig.ImageRepository)
+	}
+	return cmd, nil
+}
+
+// doUploadContainerdSandboxImage sets (and verifies) the sandbox image in the containerd config
+func doUploadContainerdSandboxImage(sandboxImage string) ssh.Action {
+	script := fmt.Sprintf(containerdSandboxImageScript, common.DefContainerdConfigPath, sandboxImage)
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Using sandbox image %q", sandboxImage),
+		ssh.DoExecScript([]byte(script)),
+	}
+}
+
+// getPauseImageFromImagesList gets the pause image from the output of `kubeadm config images list`
+func getPauseImageFromImagesList(output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		image := strings.TrimSpace(line)
+		if strings.Contains(image, "/pause:") {
+			return image
+		}
+	}
+	return ""
+}
+
+// registryAuth are the credentials for the mirrors of a registry
+type registryAuth struct {
+	Username string
+	Password string
+}
+
+// doUploadRegistryMirrors uploads the registries configuration (the "hosts.toml"
+// and CA certificates) to the containerd "certs.d" directory.
+// Note: containerd reads this directory on every pull, so no restart is needed.
+func doUploadRegistryMirrors(d *schema.ResourceData) ssh.Action {
+	mirrors, err := common.RegistryMirrorsFromResourceData(d)
+	if err != nil {
+		return ssh.ActionError(err.Error())
+	}
+
+	auths := getRegistryAuthFromResourceData(d)
+	actions := ssh.ActionList{}
+	for _, mirror := range mirrors {
+		hostsTOML := path.Join(mirror.Dir(), "hosts.toml")
+		actions = append(actions,
+			ssh.DoMessageInfo("Using mirrors %v for registry %q", mirror.Endpoints, mirror.Registry),
+			ssh.DoMkdirOnce(mirror.Dir()))
+		if auth, ok := auths[mirror.Registry]; ok {
+			// (the "hosts.toml" contains the credentials)
+			mirror.Username, mirror.Password = auth.Username, auth.Password
+			delete(auths, mirror.Registry)
+			actions = append(actions,
+				ssh.DoUploadBytesToFileWithMode([]byte(mirror.HostsTOML()), hostsTOML, 0600))
+		} else {
+			actions = append(actions,
+				ssh.DoUploadBytesToFile([]byte(mirror.HostsTOML()), hostsTOML))
+		}
+		if mirror.CACert != "" {
+			actions = append(actions,
+				ssh.DoUploadBytesToFile([]byte(mirror.CACert), mirror.CACertPath()))
+		}
+	}
+	for registry := range auths {
+		actions = append(actions,
+			ssh.DoMessageWarn("there are credentials for registry %q, but it has no 'registry_mirrors': ignored", registry))
+	}
+	return actions
+}
@@ -0,0 +1,56 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"testing"
+)
+
+func TestGetPauseImageFromImagesList(t *testing.T) {
+	output := `k8s.gcr.io/kube-apiserver:v1.15.0
+k8s.gcr.io/kube-controller-manager:v1.15.0
+k8s.gcr.io/kube-scheduler:v1.15.0
+k8s.gcr.io/kube-proxy:v1.15.0
+k8s.gcr.io/pause:3.1
+k8s.gcr.io/etcd:3.3.10
+k8s.gcr.io/coredns:1.3.1
+`
+	if image := getPauseImageFromImagesList(output); image != "k8s.gcr.io/pause:3.1" {
+		t.Fatalf("Error: wrong pause image: %q", image)
+	}
+	if image := getPauseImageFromImagesList("k8s.gcr.io/kube-proxy:v1.15.0"); image != "" {
+		t.Fatalf("Error: unexpected pause image: %q", image)
+	}
+}
+
+func TestCheckRuntimeOutput(t *testing.T) {
+	testsCases := []struct {
+		out string
+		err bool
+	}{
+		{"service=active\nsocket=present\ninfo=ok\n", false},
+		{"service=active\r\nsocket=present\r\ninfo=unknown\r\n", false},
+		{"service=inactive\nsocket=missing\n", true},
+		{"service=active\nsocket=present\ninfo=failed\n", true},
+		{"", true},
+	}
+
+	for _, testCase := range testsCases {
+		err := checkRuntimeOutput("containerd", "/var/run/containerd/containerd.sock", testCase.out)
+		if (err != nil) != testCase.err {
+			t.Fatalf("Error: unexpected result for %q: %v", testCase.out, err)
+		}
+	}
+}
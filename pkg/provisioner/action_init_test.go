@@ -0,0 +1,58 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"testing"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+)
+
+func TestIsRetryableKubeadmOutput(t *testing.T) {
+	tests := []struct {
+		output   string
+		expected bool
+	}{
+		{"", true},
+		{"[ERROR ImagePull]: failed to pull image k8s.gcr.io/kube-apiserver:v1.20.0: output: timeout", true},
+		{"error execution phase wait-control-plane: timed out waiting for the condition", true},
+		{`W0101 error unmarshaling JSON: while decoding JSON: json: unknown field "foo"`, false},
+		{"Error: unknown flag: --foo", false},
+	}
+	for _, test := range tests {
+		if res := isRetryableKubeadmOutput(test.output); res != test.expected {
+			t.Fatalf("Error: unexpected result for %q: %t", test.output, res)
+		}
+	}
+}
+
+func TestIsRetryableKubeadmResult(t *testing.T) {
+	tests := []struct {
+		res      ssh.Action
+		output   string
+		expected bool
+	}{
+		{ssh.ActionError("some error"), "", true},
+		{&ssh.ExecError{Action: "kubeadm init", ExitCode: 1}, "", true},
+		{&ssh.ExecError{Action: "kubeadm init", ExitCode: 1}, "Error: unknown flag: --foo", false},
+		{&ssh.ExecError{Action: "kubeadm init", ExitCode: kubeadmPreflightExitCode}, "", false},
+		{&ssh.ExecError{Action: "kubeadm init", ExitCode: kubeadmValidationExitCode}, "", false},
+	}
+	for _, test := range tests {
+		if res := isRetryableKubeadmResult(test.res, test.output); res != test.expected {
+			t.Fatalf("Error: unexpected result for %q: %t", test.res.Error(), res)
+		}
+	}
+}
@@ -32,6 +32,15 @@ const (
 
 	// ... waiting 30 seconds between each try
 	joinRetryInterval = 30 * time.Second
+
+	// interval between checks of the node registration
+	nodeRegisteredInterval = 10 * time.Second
+
+	// max time we wait for the node to be registered in the API server
+	nodeRegisteredTimeout = 3 * time.Minute
+
+	// command for getting the last lines of the kubelet logs
+	kubeletLogsCmd = "journalctl -u kubelet --no-pager -n 30"
 )
 
 // doKubeadmJoinWorker runs the `kubeadm join`
@@ -45,11 +54,15 @@ func doKubeadmJoinWorker(d *schema.ResourceData) ssh.Action {
 	// ... update the nodename
 	joinConfig.NodeRegistration.Name = getNodenameFromResourceData(d)
 
+	// ... and register the node with the quarantine taint (when enabled)
+	addQuarantineTaint(d, &joinConfig.NodeRegistration)
+
 	// ... and update the `config.join` section
 	if err := common.JoinConfigToResourceData(d, joinConfig); err != nil {
 		return ssh.ActionError(err.Error())
 	}
 
+	getToken, deleteToken := doJoinToken(d)
 	actions := ssh.ActionList{
 		ssh.DoRetry(
 			ssh.Retry{Times: joinRetryTimes, Interval: joinRetryInterval},
@@ -59,17 +72,28 @@ func doKubeadmJoinWorker(d *schema.ResourceData) ssh.Action {
 		ssh.DoRetry(
 			ssh.Retry{Times: joinRetryTimes, Interval: joinRetryInterval},
 			ssh.ActionList{
-				doRefreshToken(d),
+				getToken,
 			}),
+		doRunHook(d, "pre_join"),
+		doKubeadmPreflight(d, "join"),
 		ssh.DoRetry(
 			ssh.Retry{Times: joinRetryTimes, Interval: joinRetryInterval},
 			ssh.ActionList{
-				doMaybeResetWorker(d, common.DefKubeadmJoinConfPath),
-				ssh.DoMessageInfo("Trying to join the cluster as a worker with 'kubadm join'..."),
-				doKubeadm(d, common.DefKubeadmJoinConfPath, "join"),
+				doWithJoinEndpoints(d, ssh.ActionList{
+					doMaybeResetWorker(d, common.DefKubeadmJoinConfPath),
+					ssh.DoMessageInfo("Trying to join the cluster as a worker with 'kubadm join'..."),
+					doWithDiscoveryFile(d, doKubeadm(d, common.DefKubeadmJoinConfPath, "join")),
+				}),
 			}),
+		// (the node is registered with the quarantine taint, so it must be released after any failure)
+		doWithQuarantine(d, ssh.ActionList{
+			doVerifyJoin(d),
+			doRestrictPermissions(d),
+			doApproveServingCSRs(d),
+			doRunHook(d, "post_join"),
+		}),
 	}
-	return actions
+	return ssh.DoWithCleanup(actions, deleteToken)
 }
 
 // doKubeadmJoinControlPlane runs the `kubeadm join` for another control-plane machine
@@ -109,6 +133,7 @@ func doKubeadmJoinControlPlane(d *schema.ResourceData) ssh.Action {
 		return ssh.ActionError(err.Error())
 	}
 
+	getToken, deleteToken := doJoinToken(d)
 	actions := ssh.ActionList{
 		ssh.DoRetry(
 			ssh.Retry{Times: joinRetryTimes, Interval: joinRetryInterval},
@@ -118,18 +143,82 @@ func doKubeadmJoinControlPlane(d *schema.ResourceData) ssh.Action {
 		ssh.DoRetry(
 			ssh.Retry{Times: joinRetryTimes, Interval: joinRetryInterval},
 			ssh.ActionList{
-				doRefreshToken(d),
+				getToken,
 			}),
+		doRunHook(d, "pre_join"),
+		doKubeadmPreflight(d, "join"),
 		ssh.DoRetry(
 			ssh.Retry{Times: joinRetryTimes, Interval: joinRetryInterval},
 			ssh.ActionList{
 				ssh.DoMessageInfo("Trying to join the cluster control-plane with 'kubadm join'..."),
 				doMaybeResetMaster(d, common.DefKubeadmJoinConfPath),
 				doUploadCerts(d), // (we must upload certs because a "kubeadm reset" wipes them...)
-				doKubeadm(d, common.DefKubeadmJoinConfPath, "join"),
+				doUploadAuditConfig(d),
+				doUploadEgressSelectorConfig(d),
+				doUploadSchedulerConfig(d),
+				doUploadEtcdPatch(d),
+				doWithDiscoveryFile(d, doKubeadm(d, common.DefKubeadmJoinConfPath, "join", getKubeadmPatchesArgs(d)...)),
+			}),
+		doVerifyJoin(d),
+		doCheckAPIServerCertSANs(d, "join"),
+		doPatchAPIServerProbes(d),
+		doDeployKonnectivityServer(d),
+		doRestrictPermissions(d),
+		doApproveServingCSRs(d),
+		doSetControlPlaneLabel(d),
+		doRunHook(d, "post_join"),
+	}
+	return ssh.DoWithCleanup(actions, deleteToken)
+}
+
+// doVerifyJoin verifies that, after a `kubeadm join`, the node has been registered
+// in the API server and it becomes "Ready", dumping the kubelet logs otherwise.
+// The readiness is not checked when we do not load any CNI plugin, as the node
+// will not be "Ready" until some CNI is installed.
+func doVerifyJoin(d *schema.ResourceData) ssh.Action {
+	if !d.Get("verify_join").(bool) {
+		return nil
+	}
+
+	// note: the nodename can come from the resource data, so we must check the node object exists
+	localKubeNode := ssh.KubeNode{}
+	checkRegistered := ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+		res := DoGetNodename(d, &localKubeNode).Apply(ctx)
+		if ssh.IsError(res) {
+			return res
+		}
+		if localKubeNode.IsEmpty() {
+			return ssh.ActionError("node not found in the cluster")
+		}
+		return doKubectl(d, "get", "node", localKubeNode.Nodename).Apply(ctx)
+	})
+
+	verify := ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+		res := doPoll(nodeRegisteredTimeout, nodeRegisteredInterval, checkRegistered).Apply(ctx)
+		if ssh.IsError(res) {
+			return ssh.ActionError(fmt.Sprintf("'kubeadm join' succeeded but the node has not been registered in the cluster after %s: %s",
+				nodeRegisteredTimeout, res.Error()))
+		}
+		nodename := localKubeNode.Nodename
+		if !hasCNIPlugin(d) {
+			return ssh.DoMessageInfo("Node %q has been registered in the cluster (no CNI plugin loaded: not waiting for it to be ready)", nodename)
+		}
+		return ssh.ActionList{
+			ssh.DoMessageInfo("Node %q has been registered in the cluster: waiting for it to be ready...", nodename),
+			doWaitNodeReady(d, nodename),
+			ssh.DoMessageInfo("Node %q is ready.", nodename),
+		}
+	})
+
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Verifying the node has joined the cluster..."),
+		ssh.DoWithException(
+			verify,
+			ssh.ActionList{
+				ssh.DoMessageWarn("the node has not joined the cluster: last lines in the kubelet logs:"),
+				ssh.DoExec(kubeletLogsCmd),
 			}),
 	}
-	return actions
 }
 
 // doCheckLocalKubeconfigExists checks that there is a local kubeconfig
@@ -142,3 +231,76 @@ func doCheckLocalKubeconfigExists(d *schema.ResourceData) ssh.Action {
 			ssh.DoMessageWarn("no local kubeconfig found at %q", kubeconfig))
 	})
 }
+
+// doWithDiscoveryFile runs some action with the discovery kubeconfig uploaded to the node
+// (when joining with a discovery file), removing it afterwards.
+// When using the TLS bootstrap, the kubeconfig also contains the bootstrap token.
+func doWithDiscoveryFile(d *schema.ResourceData, action ssh.Action) ssh.Action {
+	tlsBootstrap := getTLSBootstrapFromResourceData(d)
+	if !getDiscoveryFileFromResourceData(d) && !tlsBootstrap {
+		return action
+	}
+
+	certsConfig := &common.CertsConfig{}
+	if err := certsConfig.FromResourceDataConfig(d); err != nil {
+		return ssh.ActionError("no certificates data in config")
+	}
+
+	// note: the seeder (and the token) must be obtained at the last moment, as
+	// they can be changed when trying different endpoints (or when the token expires)
+	return ssh.ActionFunc(func(context.Context) ssh.Action {
+		seeder := common.AddressWithPort(getJoinFromResourceData(d), common.DefAPIServerPort)
+
+		var discoveryKubeconfig []byte
+		var err error
+		if tlsBootstrap {
+			discoveryKubeconfig, err = common.BootstrapKubeconfig(seeder, []byte(certsConfig.CaCrt), getTokenFromResourceData(d))
+			if err != nil {
+				return ssh.ActionError(fmt.Sprintf("could not create the bootstrap kubeconfig: %s", err))
+			}
+		} else {
+			discoveryKubeconfig, err = common.DiscoveryKubeconfig(seeder, []byte(certsConfig.CaCrt))
+			if err != nil {
+				return ssh.ActionError(fmt.Sprintf("could not create the discovery kubeconfig: %s", err))
+			}
+		}
+
+		return ssh.DoWithCleanup(
+			ssh.ActionList{
+				ssh.DoMessageInfo("Joining with a discovery kubeconfig file (for %s)", seeder),
+				ssh.DoUploadBytesToFile(discoveryKubeconfig, common.DefDiscoveryKubeconfigPath),
+				action,
+			},
+			ssh.DoTry(ssh.DoDeleteFile(common.DefDiscoveryKubeconfigPath)))
+	})
+}
+
+// doWithJoinEndpoints runs the join `action` using each one of the API server endpoints
+// (see getJoinEndpointsFromResourceData) for the discovery, until one of them succeeds
+func doWithJoinEndpoints(d *schema.ResourceData, action ssh.Action) ssh.Action {
+	endpoints := getJoinEndpointsFromResourceData(d)
+	if len(endpoints) <= 1 {
+		return action
+	}
+
+	seeder := getJoinFromResourceData(d)
+	return ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+		// restore the original seeder when we are done
+		defer func() { _ = d.Set("join", seeder) }()
+
+		var res ssh.Action
+		for _, endpoint := range endpoints {
+			if err := d.Set("join", endpoint); err != nil {
+				return ssh.ActionError(fmt.Sprintf("could not set the API server endpoint for joining: %s", err))
+			}
+
+			_ = ssh.DoMessageInfo("Using API server endpoint %s for joining", endpoint).Apply(ctx)
+			res = action.Apply(ctx)
+			if !ssh.IsError(res) {
+				return ssh.DoMessageInfo("Joined the cluster through %s", endpoint)
+			}
+			_ = ssh.DoMessageWarn("could not join through %s: %s", endpoint, res.Error()).Apply(ctx)
+		}
+		return res
+	})
+}
@@ -24,6 +24,13 @@ import (
 	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
 )
 
+const (
+	// selinuxRebootNeededCmd succeeds when SELinux has been configured in permissive
+	// mode (ie, by the installation script) but it is still enforcing, so the node
+	// must be rebooted for applying the new mode
+	selinuxRebootNeededCmd = `[ "$(getenforce 2>/dev/null)" = "Enforcing" ] && grep -q '^SELINUX=permissive' /etc/selinux/config`
+)
+
 // doKubeadmSetup tries to install kubeadm in the remote machine
 // the auto-installation can be
 // 1) our built-in auto-installation script
@@ -59,6 +66,9 @@ func doKubeadmSetup(d *schema.ResourceData) ssh.Action {
 		return ssh.ActionList{
 			ssh.DoMessage(descr),
 			ssh.DoExecScript([]byte(code)),
+			ssh.DoIf(
+				ssh.CheckExec(selinuxRebootNeededCmd),
+				ssh.DoSignalRebootNeeded()),
 		}
 	}
 	return ssh.ActionList{
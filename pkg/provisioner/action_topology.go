@@ -0,0 +1,149 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+)
+
+const (
+	// well-known labels for the topology of a node
+	topologyZoneLabel   = "topology.kubernetes.io/zone"
+	topologyRegionLabel = "topology.kubernetes.io/region"
+)
+
+// topologyMetadataSources is the list of cloud metadata services we can get the topology from
+var topologyMetadataSources = []string{"", "aws", "gce", "azure"}
+
+// topologyMetadataScripts are scripts that print the zone and the region
+// (in two lines, "zone=..." and "region=...") from the metadata service of a cloud
+var topologyMetadataScripts = map[string]string{
+	"aws": `
+MD=http://169.254.169.254/latest
+TOKEN=$(curl -sf -X PUT -H "X-aws-ec2-metadata-token-ttl-seconds: 60" $MD/api/token)
+echo "zone=$(curl -sf -H "X-aws-ec2-metadata-token: $TOKEN" $MD/meta-data/placement/availability-zone)"
+echo "region=$(curl -sf -H "X-aws-ec2-metadata-token: $TOKEN" $MD/meta-data/placement/region)"
+`,
+	"gce": `
+ZONE=$(curl -sf -H "Metadata-Flavor: Google" http://metadata.google.internal/computeMetadata/v1/instance/zone)
+ZONE=${ZONE##*/}
+echo "zone=$ZONE"
+echo "region=${ZONE%-*}"
+`,
+	"azure": `
+MD="http://169.254.169.254/metadata/instance/compute"
+REGION=$(curl -sf -H "Metadata: true" "$MD/location?api-version=2021-02-01&format=text")
+ZONE=$(curl -sf -H "Metadata: true" "$MD/zone?api-version=2021-02-01&format=text")
+[ -n "$ZONE" ] && echo "zone=$REGION-$ZONE"
+echo "region=$REGION"
+`,
+}
+
+// parseTopologyOutput parses the output of a topology metadata script,
+// returning the zone and the region
+func parseTopologyOutput(out string) (string, string) {
+	zone, region := "", ""
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "zone="):
+			zone = strings.TrimPrefix(line, "zone=")
+		case strings.HasPrefix(line, "region="):
+			region = strings.TrimPrefix(line, "region=")
+		}
+	}
+	return zone, region
+}
+
+// mergeNodeLabels adds some labels to a kubelet `--node-labels` argument
+func mergeNodeLabels(current string, labels map[string]string) string {
+	all := map[string]string{}
+	for _, label := range strings.Split(current, ",") {
+		if kv := strings.SplitN(strings.TrimSpace(label), "=", 2); len(kv) == 2 {
+			all[kv[0]] = kv[1]
+		}
+	}
+	for k, v := range labels {
+		all[k] = v
+	}
+
+	res := []string{}
+	for k, v := range all {
+		res = append(res, fmt.Sprintf("%s=%s", k, v))
+	}
+	sort.Strings(res)
+	return strings.Join(res, ",")
+}
+
+// doSetTopologyLabels sets the zone and region labels for this node in the
+// kubelet's `--node-labels`, using the explicit values provided or detecting
+// them from the cloud metadata service. The `command` can be "init" or "join".
+func doSetTopologyLabels(d *schema.ResourceData, command string) ssh.Action {
+	zone, region, metadata := getTopologyFromResourceData(d)
+	if zone == "" && region == "" && metadata == "" {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	actions := ssh.ActionList{}
+	if metadata != "" {
+		actions = append(actions,
+			ssh.DoMessageInfo("Detecting the zone and region from the %q metadata service...", metadata),
+			ssh.DoTry(ssh.DoSendingExecOutputToWriter(ssh.DoExecScript([]byte(topologyMetadataScripts[metadata])), &buf)))
+	}
+
+	actions = append(actions, ssh.ActionFunc(func(context.Context) ssh.Action {
+		// explicit values take precedence over the detected ones
+		zone, region := zone, region
+		detectedZone, detectedRegion := parseTopologyOutput(buf.String())
+		if zone == "" {
+			zone = detectedZone
+		}
+		if region == "" {
+			region = detectedRegion
+		}
+
+		labels := map[string]string{}
+		if zone != "" {
+			labels[topologyZoneLabel] = zone
+		}
+		if region != "" {
+			labels[topologyRegionLabel] = region
+		}
+		if len(labels) == 0 {
+			return ssh.DoMessageWarn("could not detect the zone and region of this node")
+		}
+
+		err := updateKubeletExtraArgs(d, command, func(args map[string]string) error {
+			args["node-labels"] = mergeNodeLabels(args["node-labels"], labels)
+			return nil
+		})
+		if err != nil {
+			return ssh.ActionError(err.Error())
+		}
+
+		return ssh.DoMessageInfo("Node topology: zone=%q region=%q", zone, region)
+	}))
+
+	return actions
+}
@@ -0,0 +1,55 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"testing"
+)
+
+func TestParseTopologyOutput(t *testing.T) {
+	zone, region := parseTopologyOutput("\nzone=us-east-1a\r\nregion=us-east-1\n")
+	if zone != "us-east-1a" {
+		t.Fatalf("Error: unexpected zone: %q", zone)
+	}
+	if region != "us-east-1" {
+		t.Fatalf("Error: unexpected region: %q", region)
+	}
+}
+
+func TestMergeNodeLabels(t *testing.T) {
+	testsCases := []struct {
+		current  string
+		labels   map[string]string
+		expected string
+	}{
+		{
+			"",
+			map[string]string{topologyZoneLabel: "zone-a"},
+			"topology.kubernetes.io/zone=zone-a",
+		},
+		{
+			"role=db,topology.kubernetes.io/zone=old",
+			map[string]string{topologyZoneLabel: "zone-a", topologyRegionLabel: "region-1"},
+			"role=db,topology.kubernetes.io/region=region-1,topology.kubernetes.io/zone=zone-a",
+		},
+	}
+
+	for _, testCase := range testsCases {
+		res := mergeNodeLabels(testCase.current, testCase.labels)
+		if res != testCase.expected {
+			t.Fatalf("Error: unexpected labels: %q != %q", res, testCase.expected)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+const (
+	// the API server static pod manifest
+	apiServerManifest = "/etc/kubernetes/manifests/kube-apiserver.yaml"
+
+	// name of the ServiceAccount (and its ClusterRole, ClusterRoleBinding and token Secret)
+	// used for authenticating the API server probes
+	apiServerProberName = "kube-apiserver-prober"
+
+	// manifest with the ServiceAccount used by the API server probes, a (long-lived) token
+	// for it and the RBAC rules for getting the health endpoints
+	apiServerProberManifest = `apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: %[1]s
+  namespace: kube-system
+---
+apiVersion: v1
+kind: Secret
+type: kubernetes.io/service-account-token
+metadata:
+  name: %[1]s-token
+  namespace: kube-system
+  annotations:
+    kubernetes.io/service-account.name: %[1]s
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: %[1]s
+rules:
+- nonResourceURLs: ["/livez", "/livez/*", "/readyz", "/readyz/*", "/healthz"]
+  verbs: ["get"]
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: %[1]s
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: %[1]s
+subjects:
+- kind: ServiceAccount
+  name: %[1]s
+  namespace: kube-system
+`
+
+	// kubectl command for getting the token of the API server probes ServiceAccount
+	kubectlGetProberTokenCmd = `-n kube-system get secret %s-token -o=jsonpath='{.data.token}'`
+
+	// number of times we try to get the token (until it is populated by the token controller)
+	apiServerProberTokenRetries = 10
+
+	// time between attempts for getting the token
+	apiServerProberTokenInterval = 3 * time.Second
+
+	// script for adding an "Authorization" header (with the probes ServiceAccount token)
+	// to the HTTP probes in the API server manifest, as the kubelet probes are anonymous
+	// requests otherwise. Nothing is done when the probes already have this header.
+	apiServerProbesScript = `#!/bin/sh
+MANIFEST="%s"
+TOKEN="%s"
+[ -f "$MANIFEST" ] || exit 0
+grep -q "httpGet:" "$MANIFEST" || exit 0
+grep -q "name: Authorization" "$MANIFEST" && exit 0
+
+TMP="$(mktemp)"
+awk -v token="$TOKEN" '
+{ print }
+/^ *httpGet:/ {
+    pad = substr($0, 1, match($0, /[^ ]/) - 1) "  "
+    print pad "httpHeaders:"
+    print pad "- name: Authorization"
+    print pad "  value: Bearer " token
+}
+' "$MANIFEST" > "$TMP"
+
+if ! grep -q "name: Authorization" "$TMP" ; then
+    echo "could not add the authorization header to the probes in $MANIFEST"
+    rm -f "$TMP"
+    exit 1
+fi
+cat "$TMP" > "$MANIFEST"
+rm -f "$TMP"
+echo "API server probes authenticated in $MANIFEST"
+`
+)
+
+// isAnonymousAuthDisabled returns true if anonymous requests to the API server are
+// disabled, with the "disable_anonymous_auth" or with an "anonymous-auth=false" argument
+func isAnonymousAuthDisabled(d *schema.ResourceData) bool {
+	if disabledOpt, ok := d.GetOk("config.anonymous_auth_disabled"); ok {
+		if disabled, _ := strconv.ParseBool(disabledOpt.(string)); disabled {
+			return true
+		}
+	}
+
+	initConfig, _, err := common.InitConfigFromResourceData(d)
+	if err != nil {
+		return false
+	}
+	return initConfig.APIServer.ExtraArgs["anonymous-auth"] == "false"
+}
+
+// parseProberToken parses the (base64-encoded) token of the API server probes ServiceAccount
+func parseProberToken(out string) (string, error) {
+	encoded := strings.Trim(strings.TrimSpace(out), "'\"")
+	if encoded == "" {
+		return "", fmt.Errorf("the token has not been populated yet")
+	}
+	token, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("could not decode the token: %s", err)
+	}
+	return strings.TrimSpace(string(token)), nil
+}
+
+// doPatchAPIServerProbes authenticates the liveness/readiness/startup probes in the API server
+// manifest when anonymous requests are disabled: the kubelet does not authenticate its probes,
+// so the HTTP probes would fail (with a 401) and the kubelet would keep restarting the API server.
+// The probes use the token of a ServiceAccount that can only get the health endpoints.
+// Note: the manifest is regenerated by kubeadm in upgrades, so this must be done again.
+func doPatchAPIServerProbes(d *schema.ResourceData) ssh.Action {
+	if !isAnonymousAuthDisabled(d) {
+		return nil
+	}
+
+	manifest := fmt.Sprintf(apiServerProberManifest, apiServerProberName)
+
+	token := ""
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Anonymous requests to the API server are disabled: authenticating the API server probes"),
+		doKubectlWithStdin(d, []byte(manifest), "apply", "-f", "-"),
+		ssh.DoRetry(
+			ssh.Retry{Times: apiServerProberTokenRetries, Interval: apiServerProberTokenInterval},
+			ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+				var buf bytes.Buffer
+				cmd := fmt.Sprintf(kubectlGetProberTokenCmd, apiServerProberName)
+				if res := doKubectlWithOutput(d, &buf, cmd).Apply(ctx); ssh.IsError(res) {
+					return res
+				}
+				t, err := parseProberToken(buf.String())
+				if err != nil {
+					return ssh.ActionError(err.Error())
+				}
+				token = t
+				return nil
+			})),
+		ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+			return ssh.DoExecScript([]byte(fmt.Sprintf(apiServerProbesScript, apiServerManifest, token)))
+		}),
+	}
+}
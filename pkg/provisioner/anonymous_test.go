@@ -0,0 +1,48 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"testing"
+)
+
+func TestParseProberToken(t *testing.T) {
+	testCases := []struct {
+		out         string
+		expected    string
+		expectedErr bool
+	}{
+		{"c2VjcmV0LXRva2Vu", "secret-token", false},
+		{"'c2VjcmV0LXRva2Vu'\n", "secret-token", false},
+		{"", "", true},
+		{"not base64!", "", true},
+	}
+
+	for _, testCase := range testCases {
+		res, err := parseProberToken(testCase.out)
+		if testCase.expectedErr {
+			if err == nil {
+				t.Fatalf("Error: expected an error for %q", testCase.out)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Error: unexpected error for %q: %s", testCase.out, err)
+		}
+		if res != testCase.expected {
+			t.Fatalf("Error: expected %q for %q, got %q", testCase.expected, testCase.out, res)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+// kubeadmConfigBackupScript archives the kubeadm config (with the tokens and certificate
+// keys redacted), the static pods manifests and the kubelet config in a dated directory,
+// removing the oldest backups (keeping only the latest N)
+const kubeadmConfigBackupScript = `#!/bin/sh
+CONF=%s
+BACKUPS_DIR=%s
+KEEP=%d
+
+DIR=$BACKUPS_DIR/$(date +%%Y%%m%%d-%%H%%M%%S)
+mkdir -p $DIR || exit 1
+chmod 700 $BACKUPS_DIR
+
+[ -f $CONF ] && sed -E 's/^([[:space:]]*-?[[:space:]]*(token|tlsBootstrapToken|certificateKey):).*/\1 REDACTED/' $CONF > $DIR/$(basename $CONF)
+[ -d /etc/kubernetes/manifests ] && cp -a /etc/kubernetes/manifests $DIR/
+[ -f /var/lib/kubelet/config.yaml ] && cp -a /var/lib/kubelet/config.yaml $DIR/kubelet-config.yaml
+
+ls -1d $BACKUPS_DIR/*/ | sort -r | tail -n +$((KEEP + 1)) | xargs -r rm -rf
+echo "$DIR"
+`
+
+// doBackupKubeadmConfig archives the kubeadm config that is going to be applied, as
+// well as the current static pods manifests, in a dated directory in the node.
+// Failures are not fatal: they just produce a warning.
+func doBackupKubeadmConfig(d *schema.ResourceData, kubeadmConfigFilename string) ssh.Action {
+	keep := getConfigBackupsFromResourceData(d)
+	if keep <= 0 {
+		return nil
+	}
+
+	script := fmt.Sprintf(kubeadmConfigBackupScript, kubeadmConfigFilename, common.DefKubeadmBackupsDir, keep)
+	return ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+		res := ssh.ActionList{
+			ssh.DoMessageInfo("Backing up the kubeadm config in %q (keeping the last %d backups)", common.DefKubeadmBackupsDir, keep),
+			ssh.DoExecScript([]byte(script)),
+		}.Apply(ctx)
+		if ssh.IsError(res) {
+			return ssh.DoMessageWarn("could not backup the kubeadm config: %s", res.Error())
+		}
+		return nil
+	})
+}
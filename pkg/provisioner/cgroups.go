@@ -0,0 +1,225 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+const (
+	// the cgroup drivers
+	cgroupDriverSystemd  = "systemd"
+	cgroupDriverCgroupfs = "cgroupfs"
+
+	// cgroupVersionLabel is the node label with the cgroup version detected in the node ("v1" or "v2")
+	cgroupVersionLabel = "kubeadm.terraform.io/cgroup-version"
+
+	// kubectlGetClusterKubeletConfigCmd gets the KubeletConfiguration stored by kubeadm in the cluster
+	kubectlGetClusterKubeletConfigCmd = `-n kube-system get configmap kubelet-config -o=jsonpath='{.data.kubelet}'`
+
+	// cgroupInfoScript prints the cgroup hierarchy version ("cgroup2fs" for v2, "tmpfs" for v1),
+	// the effective cgroup driver of the runtime engine (from `crictl info`, falling back to the
+	// runtime configuration) and the kubelet flags in the kubelet environment files
+	cgroupInfoScript = `
+echo "cgroupfs=$(stat -fc %%T /sys/fs/cgroup/)"
+case "%[1]s" in
+containerd)
+	INFO=$(%[2]s --runtime-endpoint unix://%[3]s info 2>/dev/null || containerd config dump 2>/dev/null || cat %[4]s 2>/dev/null)
+	if echo "$INFO" | grep -q -i '"*SystemdCgroup"* *[:=] *true' ; then
+		echo "driver=systemd"
+	else
+		echo "driver=cgroupfs"
+	fi
+	;;
+crio)
+	echo "driver=$(crio config 2>/dev/null | sed -n 's/^ *cgroup_manager *= *"\(.*\)".*/\1/p' | head -1)"
+	;;
+docker)
+	echo "driver=$(docker info --format '{{.CgroupDriver}}' 2>/dev/null)"
+	;;
+esac
+for f in /etc/default/kubelet /etc/sysconfig/kubelet ; do
+	[ -f $f ] && echo "kubelet=$(sed -n 's/.*--cgroup-driver[= ]\([a-z]*\).*/\1/p' $f | head -1)"
+done
+true
+`
+)
+
+// cgroupInfo is the cgroups information detected in a node
+type cgroupInfo struct {
+	// Version is the cgroup hierarchy version (1 or 2, or 0 if unknown)
+	Version int
+
+	// Driver is the cgroup driver used by the runtime engine (if it can be detected)
+	Driver string
+
+	// KubeletEnvDriver is the cgroup driver in the kubelet environment files (if any)
+	KubeletEnvDriver string
+}
+
+// parseCgroupInfo parses the output of the `cgroupInfoScript`
+func parseCgroupInfo(out string) cgroupInfo {
+	info := cgroupInfo{}
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "cgroupfs="):
+			switch strings.TrimPrefix(line, "cgroupfs=") {
+			case "cgroup2fs":
+				info.Version = 2
+			case "tmpfs":
+				info.Version = 1
+			}
+		case strings.HasPrefix(line, "driver="):
+			info.Driver = strings.TrimPrefix(line, "driver=")
+		case strings.HasPrefix(line, "kubelet=") && info.KubeletEnvDriver == "":
+			info.KubeletEnvDriver = strings.TrimPrefix(line, "kubelet=")
+		}
+	}
+	return info
+}
+
+// doDetectCgroups detects the cgroup hierarchy version and the cgroup drivers in the node,
+// leaving the result in `info`
+func doDetectCgroups(d *schema.ResourceData, info *cgroupInfo) ssh.Action {
+	engine := getRuntimeEngineFromResourceData(d)
+	crictl := getCrictlFromResourceData(d)
+	script := fmt.Sprintf(cgroupInfoScript, engine, crictl, common.DefCriSocket[engine], common.DefContainerdConfigPath)
+
+	var buf bytes.Buffer
+	return ssh.ActionList{
+		ssh.DoSendingExecOutputToWriter(ssh.DoExecScript([]byte(script)), &buf),
+		ssh.ActionFunc(func(context.Context) ssh.Action {
+			*info = parseCgroupInfo(buf.String())
+			return nil
+		}),
+	}
+}
+
+// doAlignCgroupDriver detects the cgroup hierarchy version and the cgroup driver used
+// by the runtime engine, and makes the kubelet use the same driver: when "init"ing, the
+// driver is set in the `cgroupDriver` of the KubeletConfiguration (that kubeadm stores
+// in the cluster), and when "join"ing, the driver is checked against the `cgroupDriver`
+// in the cluster's KubeletConfiguration. It fails when the kubelet has been configured
+// with a different driver, or when the `cgroupfs` driver is used in a cgroup v2 host.
+// The detected version is exported in the `cgroupVersionLabel` node label.
+// The `command` can be "init" or "join".
+func doAlignCgroupDriver(d *schema.ResourceData, command string) ssh.Action {
+	engine := getRuntimeEngineFromResourceData(d)
+
+	info := cgroupInfo{}
+	return ssh.ActionList{
+		doDetectCgroups(d, &info),
+		ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+			messages := ssh.ActionList{}
+			if info.Version == 0 {
+				messages = append(messages, ssh.DoMessageWarn("could not detect the cgroup version"))
+			} else {
+				messages = append(messages, ssh.DoMessageInfo("Detected cgroup v%d (runtime %q cgroup driver: %q)", info.Version, engine, info.Driver))
+				err := updateKubeletExtraArgs(d, command, func(args map[string]string) error {
+					args["node-labels"] = mergeNodeLabels(args["node-labels"],
+						map[string]string{cgroupVersionLabel: fmt.Sprintf("v%d", info.Version)})
+					return nil
+				})
+				if err != nil {
+					return ssh.ActionError(err.Error())
+				}
+			}
+			if info.Driver == "" {
+				return append(messages, ssh.DoMessageWarn("could not detect the %q runtime cgroup driver: the kubelet cgroup driver will not be checked", engine))
+			}
+			if info.Version == 2 && info.Driver == cgroupDriverCgroupfs {
+				return ssh.ActionError(fmt.Sprintf("the %q runtime uses the %q cgroup driver in a cgroup v2 host: use the %q driver",
+					engine, cgroupDriverCgroupfs, cgroupDriverSystemd))
+			}
+
+			kubeletConfig, err := getKubeletConfigFromResourceData(d)
+			if err != nil {
+				return ssh.ActionError(err.Error())
+			}
+
+			switch command {
+			case "init":
+				config, err := common.CgroupDriverKubeletConfig(string(kubeletConfig), info.Driver)
+				if err != nil {
+					return ssh.ActionError(fmt.Sprintf("%s (used by the %q runtime)", err, engine))
+				}
+				kubeletConfig = []byte(config)
+				if err := setKubeletConfigInResourceData(d, kubeletConfig); err != nil {
+					return ssh.ActionError(err.Error())
+				}
+			case "join":
+				// the kubelet will use the KubeletConfiguration stored in the cluster
+				var buf bytes.Buffer
+				if res := doKubectlWithOutput(d, &buf, kubectlGetClusterKubeletConfigCmd).Apply(ctx); ssh.IsError(res) {
+					messages = append(messages, ssh.DoMessageWarn("could not get the KubeletConfiguration from the cluster: %s", res.Error()))
+					kubeletConfig = nil
+				} else {
+					kubeletConfig = buf.Bytes()
+				}
+			}
+			return append(messages, doVerifyCgroupDriver(d, command, info, kubeletConfig))
+		}),
+	}
+}
+
+// checkCgroupDrivers checks that all the kubelet cgroup drivers (indexed by where they
+// have been configured) match the `runtime` cgroup driver
+func checkCgroupDrivers(engine string, runtime string, kubelet map[string]string) error {
+	sources := []string{}
+	for source := range kubelet {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	for _, source := range sources {
+		if driver := kubelet[source]; driver != "" && driver != runtime {
+			return fmt.Errorf("cgroup driver mismatch: the kubelet uses %q (in %s) but the %q runtime uses %q",
+				driver, source, engine, runtime)
+		}
+	}
+	return nil
+}
+
+// doVerifyCgroupDriver verifies that the effective cgroup driver of the runtime engine (as
+// detected in `info`) matches the cgroup driver configured for the kubelet, in the kubelet args,
+// the `kubeletConfig` or the kubelet environment files. The `command` can be "init" or "join".
+func doVerifyCgroupDriver(d *schema.ResourceData, command string, info cgroupInfo, kubeletConfig []byte) ssh.Action {
+	engine := getRuntimeEngineFromResourceData(d)
+
+	kubelet := map[string]string{
+		"the kubelet environment file": info.KubeletEnvDriver,
+		"the KubeletConfiguration":     common.KubeletConfigCgroupDriver(string(kubeletConfig)),
+	}
+	args, err := getKubeletExtraArgs(d, command)
+	if err != nil {
+		return ssh.ActionError(err.Error())
+	}
+	kubelet["the kubelet args"] = args["cgroup-driver"]
+
+	if err := checkCgroupDrivers(engine, info.Driver, kubelet); err != nil {
+		return ssh.ActionError(err.Error())
+	}
+	return ssh.DoMessageInfo("Verified the cgroup driver: kubelet and %q runtime use %q", engine, info.Driver)
+}
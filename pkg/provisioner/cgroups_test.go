@@ -0,0 +1,75 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"testing"
+
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+func TestParseCgroupInfo(t *testing.T) {
+	testsCases := []struct {
+		out             string
+		expectedVersion int
+		expectedDriver  string
+		expectedEnv     string
+	}{
+		{"cgroupfs=cgroup2fs\ndriver=systemd\n", 2, "systemd", ""},
+		{"cgroupfs=tmpfs\r\ndriver=cgroupfs\r\nkubelet=cgroupfs\r\n", 1, "cgroupfs", "cgroupfs"},
+		{"cgroupfs=\nkubelet=\nkubelet=systemd\n", 0, "", "systemd"},
+	}
+
+	for _, testCase := range testsCases {
+		info := parseCgroupInfo(testCase.out)
+		if info.Version != testCase.expectedVersion {
+			t.Fatalf("Error: unexpected version for %q: %d", testCase.out, info.Version)
+		}
+		if info.Driver != testCase.expectedDriver {
+			t.Fatalf("Error: unexpected driver for %q: %q", testCase.out, info.Driver)
+		}
+		if info.KubeletEnvDriver != testCase.expectedEnv {
+			t.Fatalf("Error: unexpected kubelet env driver for %q: %q", testCase.out, info.KubeletEnvDriver)
+		}
+	}
+}
+
+func TestCheckCgroupDrivers(t *testing.T) {
+	testsCases := []struct {
+		out           string
+		kubeletConfig string
+		args          string
+		err           bool
+	}{
+		{"driver=systemd\n", "", "systemd", false},
+		{"driver=systemd\nkubelet=\n", "cgroupDriver: systemd", "", false},
+		{"driver=systemd\n", "cgroupDriver: cgroupfs", "systemd", true},
+		{"driver=cgroupfs\nkubelet=systemd\n", "", "cgroupfs", true},
+		{"driver=cgroupfs\n", "", "systemd", true},
+	}
+
+	for _, testCase := range testsCases {
+		info := parseCgroupInfo(testCase.out)
+		kubelet := map[string]string{
+			"env":    info.KubeletEnvDriver,
+			"args":   testCase.args,
+			"config": common.KubeletConfigCgroupDriver(testCase.kubeletConfig),
+		}
+		err := checkCgroupDrivers("containerd", info.Driver, kubelet)
+		if (err != nil) != testCase.err {
+			t.Fatalf("Error: unexpected result for %q (config=%q, args=%q): %v", testCase.out, testCase.kubeletConfig, testCase.args, err)
+		}
+	}
+}
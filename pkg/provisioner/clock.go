@@ -0,0 +1,119 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+)
+
+const (
+	// default max clock skew between the joining node and the control plane
+	defMaxClockSkew = "30s"
+
+	// script for getting the "Date" header from the API server, as well as the
+	// local time (as seconds since the epoch) right after getting the response
+	clockSkewScript = `#!/bin/sh
+URL="https://%s/healthz"
+if command -v curl >/dev/null 2>&1 ; then
+    HEADERS="$(curl -skI --max-time 10 "$URL" 2>&1)"
+elif command -v wget >/dev/null 2>&1 ; then
+    HEADERS="$(wget -S --spider --no-check-certificate -T 10 "$URL" 2>&1)"
+else
+    echo "no curl or wget available"
+    exit 1
+fi
+echo "local: $(date -u +%%s)"
+echo "$HEADERS" | grep -i "date:"
+`
+)
+
+// parseClockSkew parses the output of the clockSkewScript, returning the
+// difference between the local clock and the API server clock
+func parseClockSkew(output string) (time.Duration, error) {
+	var local, remote time.Time
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		lower := strings.ToLower(line)
+		switch {
+		case strings.HasPrefix(lower, "local:"):
+			secs, err := strconv.ParseInt(strings.TrimSpace(line[len("local:"):]), 10, 64)
+			if err != nil {
+				return 0, fmt.Errorf("could not parse the local time in %q: %s", line, err)
+			}
+			local = time.Unix(secs, 0)
+		case strings.HasPrefix(lower, "date:"):
+			t, err := http.ParseTime(strings.TrimSpace(line[len("date:"):]))
+			if err != nil {
+				return 0, fmt.Errorf("could not parse the API server date in %q: %s", line, err)
+			}
+			remote = t
+		}
+	}
+	if local.IsZero() || remote.IsZero() {
+		return 0, fmt.Errorf("could not get the local and API server times")
+	}
+	return local.Sub(remote), nil
+}
+
+// checkClockSkew returns an error when the skew (in any direction) exceeds the max
+func checkClockSkew(skew time.Duration, max time.Duration) error {
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > max {
+		return fmt.Errorf("the clock in this node differs from the control plane's clock by %s (max %s): "+
+			"certificates could be considered not yet valid (or expired). Check the NTP configuration in the nodes", skew, max)
+	}
+	return nil
+}
+
+// doCheckClockSkew checks the clock skew between this node and the control plane,
+// comparing the local time with the "Date" header in a response from the API server.
+// The skew cannot be detected with an accuracy better than a second, and the
+// check is skipped (with a warning) when the API server time cannot be obtained.
+func doCheckClockSkew(d *schema.ResourceData) ssh.Action {
+	endpoints := getJoinEndpointsFromResourceData(d)
+	if len(endpoints) == 0 {
+		return nil
+	}
+	max := getMaxClockSkewFromResourceData(d)
+
+	script := fmt.Sprintf(clockSkewScript, endpoints[0])
+	return ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+		var buf bytes.Buffer
+		if res := ssh.DoSendingExecOutputToWriter(ssh.DoExecScript([]byte(script)), &buf).Apply(ctx); ssh.IsError(res) {
+			return ssh.DoMessageWarn("could not get the time in the API server at %s: skipping the clock skew check", endpoints[0])
+		}
+		skew, err := parseClockSkew(buf.String())
+		if err != nil {
+			return ssh.DoMessageWarn("%s: skipping the clock skew check", err)
+		}
+		if err := checkClockSkew(skew, max); err != nil {
+			return ssh.DoAbort("%s", err)
+		}
+		ssh.Debug("clock skew with the control plane: %s", skew)
+		return nil
+	})
+}
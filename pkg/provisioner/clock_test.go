@@ -0,0 +1,69 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseClockSkew(t *testing.T) {
+	testsCases := []struct {
+		output string
+		skew   time.Duration
+		err    bool
+	}{
+		{
+			"local: 1700000045\ndate: Tue, 14 Nov 2023 22:13:20 GMT\n",
+			45 * time.Second,
+			false,
+		},
+		{
+			// wget output, with the local clock behind the API server
+			"local: 1699999880\n  Date: Tue, 14 Nov 2023 22:13:20 GMT\n",
+			-2 * time.Minute,
+			false,
+		},
+		{
+			"local: 1700000000\n",
+			0,
+			true,
+		},
+		{
+			"local: 1700000000\ndate: yesterday\n",
+			0,
+			true,
+		},
+	}
+
+	for _, testCase := range testsCases {
+		skew, err := parseClockSkew(testCase.output)
+		if testCase.err {
+			if err == nil {
+				t.Fatalf("Error: no error when parsing %q", testCase.output)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Error: could not parse %q: %s", testCase.output, err)
+		}
+		if skew != testCase.skew {
+			t.Fatalf("Error: unexpected skew for %q: %s (expected %s)", testCase.output, skew, testCase.skew)
+		}
+		if checkClockSkew(skew, time.Minute) == nil && (skew > time.Minute || skew < -time.Minute) {
+			t.Fatalf("Error: no error for a %s skew", skew)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+)
+
+const (
+	// command for getting the CSRs (name, requestor, signer, request and conditions)
+	kubectlGetCSRsCmd = `get csr -o=jsonpath='{range .items[*]}{.metadata.name}{"\t"}{.spec.username}{"\t"}{.spec.signerName}{"\t"}{.spec.request}{"\t"}{.status.conditions[*].type}{"\n"}{end}'`
+
+	// command for getting the addresses (IPs and hostnames) of a node
+	kubectlGetNodeAddressesCmd = `get node %s -o=jsonpath='{range .status.addresses[*]}{.address}{"\n"}{end}'`
+
+	// signer for the kubelet serving certificates
+	kubeletServingSigner = "kubernetes.io/kubelet-serving"
+
+	// interval between checks of the kubelet serving CSRs
+	servingCSRInterval = 5 * time.Second
+
+	// max time we wait for the kubelet serving CSRs
+	servingCSRTimeout = 2 * time.Minute
+
+	// the kubelet serving certificate (once the CSR has been approved)
+	kubeletServingCertPath = "/var/lib/kubelet/pki/kubelet-server-current.pem"
+)
+
+// servingCSR is a pending CSR for a kubelet serving certificate
+type servingCSR struct {
+	// Name is the name of the CSR
+	Name string
+
+	// Request is the (base64 encoded) PEM certificate request
+	Request string
+}
+
+// getPendingServingCSRs parses the output of `kubectlGetCSRsCmd`, returning the pending
+// CSRs for the kubelet serving certificate of a node. Old clusters do not set a signer
+// for CSRs, but the only CSRs requested by the node itself (and not approved automatically)
+// are the ones for the serving certificates.
+func getPendingServingCSRs(output string, nodename string) []servingCSR {
+	csrs := []servingCSR{}
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) < 4 {
+			continue
+		}
+		name, username, signer, request := fields[0], fields[1], fields[2], fields[3]
+		if username != "system:node:"+nodename {
+			continue
+		}
+		if signer != "" && signer != kubeletServingSigner {
+			continue
+		}
+		if len(fields) > 4 && strings.TrimSpace(fields[4]) != "" {
+			continue // already approved or denied
+		}
+		csrs = append(csrs, servingCSR{Name: name, Request: request})
+	}
+	return csrs
+}
+
+// checkServingCSR checks that a (base64 encoded) serving certificate request is for the
+// node `nodename` and that it only contains DNS names and IPs in the node `addresses`
+func checkServingCSR(request string, nodename string, addresses []string) error {
+	pemBytes, err := base64.StdEncoding.DecodeString(strings.TrimSpace(request))
+	if err != nil {
+		return fmt.Errorf("could not decode the certificate request: %s", err)
+	}
+	block, _ := pem.Decode(pemBytes)
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		return fmt.Errorf("no PEM certificate request found")
+	}
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("could not parse the certificate request: %s", err)
+	}
+
+	if csr.Subject.CommonName != "system:node:"+nodename {
+		return fmt.Errorf("unexpected common name %q", csr.Subject.CommonName)
+	}
+	if len(csr.EmailAddresses) > 0 || len(csr.URIs) > 0 {
+		return fmt.Errorf("unexpected email or URI SANs")
+	}
+	if len(csr.DNSNames) == 0 && len(csr.IPAddresses) == 0 {
+		return fmt.Errorf("no DNS names or IPs requested")
+	}
+
+	known := map[string]bool{}
+	for _, address := range addresses {
+		address = strings.TrimSpace(address)
+		if ip := net.ParseIP(address); ip != nil {
+			address = ip.String()
+		}
+		known[strings.ToLower(address)] = true
+	}
+	for _, name := range csr.DNSNames {
+		if !known[strings.ToLower(name)] {
+			return fmt.Errorf("DNS name %q is not an address of node %q", name, nodename)
+		}
+	}
+	for _, ip := range csr.IPAddresses {
+		if !known[ip.String()] {
+			return fmt.Errorf("IP %s is not an address of node %q", ip, nodename)
+		}
+	}
+	return nil
+}
+
+// doApproveServingCSRs approves the CSRs for the kubelet serving certificate of this node,
+// waiting until the kubelet has requested it. CSRs with names or IPs that are not addresses
+// of this node are not approved. Nothing is done when the kubelet already has a serving
+// certificate.
+func doApproveServingCSRs(d *schema.ResourceData) ssh.Action {
+	if !getKubeletServingCertsFromResourceData(d) {
+		return nil
+	}
+
+	localKubeNode := ssh.KubeNode{}
+	approve := ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+		var buf bytes.Buffer
+		res := doKubectlWithOutput(d, &buf, kubectlGetCSRsCmd).Apply(ctx)
+		if ssh.IsError(res) {
+			return res
+		}
+		csrs := getPendingServingCSRs(buf.String(), localKubeNode.Nodename)
+		if len(csrs) == 0 {
+			return ssh.ActionError(fmt.Sprintf("no pending serving CSRs for node %q", localKubeNode.Nodename))
+		}
+
+		var addressesBuf bytes.Buffer
+		res = doKubectlWithOutput(d, &addressesBuf, fmt.Sprintf(kubectlGetNodeAddressesCmd, localKubeNode.Nodename)).Apply(ctx)
+		if ssh.IsError(res) {
+			return res
+		}
+		addresses := strings.Split(addressesBuf.String(), "\n")
+
+		// only approve the CSRs for the names and IPs of this node
+		messages := ssh.ActionList{}
+		approved := []string{}
+		for _, csr := range csrs {
+			if err := checkServingCSR(csr.Request, localKubeNode.Nodename, addresses); err != nil {
+				messages = append(messages, ssh.DoMessageWarn("not approving serving CSR %q: %s", csr.Name, err))
+				continue
+			}
+			approved = append(approved, csr.Name)
+		}
+		if len(approved) == 0 {
+			return messages
+		}
+		return append(messages,
+			ssh.DoMessageInfo("Approving serving CSRs for node %q: %s", localKubeNode.Nodename, strings.Join(approved, ", ")),
+			doKubectl(d, append([]string{"certificate", "approve"}, approved...)...))
+	})
+
+	return ssh.DoIf(
+		ssh.CheckNot(ssh.CheckFileExists(kubeletServingCertPath)),
+		ssh.ActionList{
+			DoGetNodename(d, &localKubeNode),
+			ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+				if localKubeNode.IsEmpty() {
+					return ssh.DoMessageWarn("could not find Kubernetes nodename for this node: the serving CSRs cannot be approved")
+				}
+				res := doPoll(servingCSRTimeout, servingCSRInterval, approve).Apply(ctx)
+				if ssh.IsError(res) {
+					return ssh.DoMessageWarn("could not approve the kubelet serving CSRs after %s: %s", servingCSRTimeout, res.Error())
+				}
+				return res
+			}),
+		})
+}
@@ -0,0 +1,95 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestGetPendingServingCSRs(t *testing.T) {
+	output := "csr-1\tsystem:node:node-0\tkubernetes.io/kubelet-serving\treq-1\t\n" +
+		"csr-2\tsystem:node:node-0\tkubernetes.io/kubelet-serving\treq-2\tApproved\n" +
+		"csr-3\tsystem:node:node-0\tkubernetes.io/kube-apiserver-client-kubelet\treq-3\t\n" +
+		"csr-4\tsystem:node:node-1\tkubernetes.io/kubelet-serving\treq-4\t\n" +
+		"csr-5\tsystem:bootstrap:abcdef\tkubernetes.io/kube-apiserver-client-kubelet\treq-5\tApproved\n" +
+		"csr-6\tsystem:node:node-0\t\treq-6\t\n"
+
+	testsCases := []struct {
+		nodename string
+		expected []servingCSR
+	}{
+		{"node-0", []servingCSR{{"csr-1", "req-1"}, {"csr-6", "req-6"}}},
+		{"node-1", []servingCSR{{"csr-4", "req-4"}}},
+		{"node-2", []servingCSR{}},
+	}
+
+	for _, testCase := range testsCases {
+		csrs := getPendingServingCSRs(output, testCase.nodename)
+		if !reflect.DeepEqual(csrs, testCase.expected) {
+			t.Fatalf("Error: unexpected CSRs for %q: got %v, expected %v", testCase.nodename, csrs, testCase.expected)
+		}
+	}
+}
+
+func TestCheckServingCSR(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Error: could not generate key: %s", err)
+	}
+	newRequest := func(cn string, dnsNames []string, ips []string) string {
+		template := &x509.CertificateRequest{
+			Subject:  pkix.Name{CommonName: cn, Organization: []string{"system:nodes"}},
+			DNSNames: dnsNames,
+		}
+		for _, ip := range ips {
+			template.IPAddresses = append(template.IPAddresses, net.ParseIP(ip))
+		}
+		der, err := x509.CreateCertificateRequest(rand.Reader, template, key)
+		if err != nil {
+			t.Fatalf("Error: could not create certificate request: %s", err)
+		}
+		return base64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der}))
+	}
+
+	addresses := []string{"10.0.0.5", "node-0", "node-0.example.com", ""}
+	testsCases := []struct {
+		request string
+		err     bool
+	}{
+		{newRequest("system:node:node-0", []string{"node-0"}, []string{"10.0.0.5"}), false},
+		{newRequest("system:node:node-0", []string{"Node-0.example.com"}, nil), false},
+		{newRequest("system:node:node-0", []string{"node-0"}, []string{"10.0.0.6"}), true},
+		{newRequest("system:node:node-0", []string{"kubernetes.default"}, nil), true},
+		{newRequest("system:node:node-1", []string{"node-0"}, nil), true},
+		{newRequest("system:node:node-0", nil, nil), true},
+		{"not-base64!", true},
+	}
+
+	for i, testCase := range testsCases {
+		err := checkServingCSR(testCase.request, "node-0", addresses)
+		if (err != nil) != testCase.err {
+			t.Fatalf("Error: test case %d: unexpected result: %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+const (
+	// diagnosticsJournalLines is the number of lines of the journal collected for each service
+	diagnosticsJournalLines = 2000
+
+	// kubeadmOutputLogFilename is the file (in the remote temporary directory) where
+	// the output of a failed kubeadm is saved for the diagnostics bundle
+	kubeadmOutputLogFilename = "kubeadm-%s.log"
+)
+
+// diagnosticsBundleScript collects the kubelet and runtime logs, the running containers,
+// the (non-sensitive) files in /etc/kubernetes and the kubeadm output in a tarball, leaving
+// a base64-encoded copy of it in the temporary directory.
+// Private keys, kubeconfigs and the PKI directory are not included, and the bootstrap
+// tokens and certificate keys are redacted from the kubeadm output.
+const diagnosticsBundleScript = `#!/bin/sh
+TMP_DIR=%s
+CRICTL="%s --runtime-endpoint unix://%s"
+LINES=%d
+
+NAME=kubeadm-diagnostics
+DIR=$TMP_DIR/$NAME
+rm -rf $DIR $DIR.tar.gz $DIR.tar.gz.b64
+mkdir -p $DIR/etc-kubernetes || exit 1
+
+for svc in kubelet containerd crio docker ; do
+	journalctl -u $svc --no-pager -n $LINES > $DIR/$svc.log 2>&1
+done
+systemctl --no-pager -l status kubelet > $DIR/kubelet-status.txt 2>&1
+$CRICTL ps -a > $DIR/crictl-ps.txt 2>&1 || docker ps -a > $DIR/docker-ps.txt 2>&1
+$CRICTL pods > $DIR/crictl-pods.txt 2>&1
+
+if [ -d /etc/kubernetes ] ; then
+	ls -lR /etc/kubernetes > $DIR/etc-kubernetes.txt 2>&1
+	tar -C /etc/kubernetes --exclude='*.key' --exclude='*.conf' --exclude='*.kubeconfig' \
+		--exclude='pki' --exclude='backups' -cf - . | tar -C $DIR/etc-kubernetes -xf -
+fi
+for f in $TMP_DIR/kubeadm-*.log ; do
+	[ -f "$f" ] || continue
+	sed -E -e 's/[a-z0-9]{6}\.[a-z0-9]{16}/<redacted>/g' \
+		-e 's/(--certificate-key[ =]+)[a-f0-9]+/\1<redacted>/g' \
+		-e 's/^[a-f0-9]{64}$/<redacted>/' "$f" > $DIR/$(basename "$f")
+done
+
+tar -C $TMP_DIR -czf $DIR.tar.gz $NAME || exit 1
+base64 $DIR.tar.gz > $DIR.tar.gz.b64 || exit 1
+rm -rf $DIR $DIR.tar.gz
+`
+
+// nopWriteCloser is a io.WriteCloser where Close() does nothing
+type nopWriteCloser struct {
+	*bytes.Buffer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// doSaveKubeadmOutput saves the output of kubeadm in the remote temporary directory,
+// so it can be included in the diagnostics bundle
+func doSaveKubeadmOutput(d *schema.ResourceData, command string, output *bytes.Buffer) ssh.Action {
+	if getDiagnosticsDirFromResourceData(d) == "" {
+		return nil
+	}
+
+	return ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+		remote := path.Join(ssh.GetTmpDirFromContext(ctx), fmt.Sprintf(kubeadmOutputLogFilename, command))
+		return ssh.DoTry(ssh.DoUploadBytesToFile(output.Bytes(), remote))
+	})
+}
+
+// doCollectDiagnostics collects a diagnostics bundle in the remote machine and
+// downloads it to the local diagnostics directory.
+// Failures are not fatal: they just produce a warning.
+func doCollectDiagnostics(d *schema.ResourceData) ssh.Action {
+	dir := getDiagnosticsDirFromResourceData(d)
+	if dir == "" {
+		return nil
+	}
+
+	engine := getRuntimeEngineFromResourceData(d)
+	crictl := getCrictlFromResourceData(d)
+	return ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+		tmpDir := ssh.GetTmpDirFromContext(ctx)
+		script := fmt.Sprintf(diagnosticsBundleScript, tmpDir, crictl, common.DefCriSocket[engine], diagnosticsJournalLines)
+		remote := path.Join(tmpDir, "kubeadm-diagnostics.tar.gz.b64")
+
+		var buf bytes.Buffer
+		res := ssh.ActionList{
+			ssh.DoMessageInfo("Provisioning failed: collecting diagnostics..."),
+			ssh.DoSendingExecOutputToDevNull(ssh.DoExecScript([]byte(script))),
+			ssh.DoDownloadFileToWriter(remote, nopWriteCloser{&buf}),
+			ssh.DoTry(ssh.DoDeleteFile(remote)),
+		}.Apply(ctx)
+		if ssh.IsError(res) {
+			return ssh.DoMessageWarn("could not collect the diagnostics bundle: %s", res.Error())
+		}
+
+		contents, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(buf.String()), ""))
+		if err != nil {
+			return ssh.DoMessageWarn("could not decode the diagnostics bundle: %s", err)
+		}
+
+		local, err := writeDiagnosticsBundle(dir, getNodenameFromResourceData(d), contents)
+		if err != nil {
+			return ssh.DoMessageWarn("could not save the diagnostics bundle: %s", err)
+		}
+		return ssh.DoMessageWarn("Diagnostics bundle saved at %q", local)
+	})
+}
+
+// writeDiagnosticsBundle writes the bundle in a new file in the local directory `dir`,
+// returning the full path of the file created
+func writeDiagnosticsBundle(dir string, nodename string, contents []byte) (string, error) {
+	prefix := "kubeadm-diagnostics-"
+	if nodename != "" {
+		prefix += nodename + "-"
+	}
+
+	f, err := ioutil.TempFile(dir, prefix+"*.tar.gz")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(contents); err != nil {
+		return "", err
+	}
+	return f.Name(), nil
+}
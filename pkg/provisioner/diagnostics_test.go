@@ -0,0 +1,54 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteDiagnosticsBundle(t *testing.T) {
+	dir, err := ioutil.TempDir("", "diagnostics")
+	if err != nil {
+		t.Fatalf("Error: could not create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	testCases := []struct {
+		nodename       string
+		expectedPrefix string
+	}{
+		{"node-1", "kubeadm-diagnostics-node-1-"},
+		{"", "kubeadm-diagnostics-"},
+	}
+
+	for _, testCase := range testCases {
+		local, err := writeDiagnosticsBundle(dir, testCase.nodename, []byte("contents"))
+		if err != nil {
+			t.Fatalf("Error: could not write the bundle: %s", err)
+		}
+		base := filepath.Base(local)
+		if !strings.HasPrefix(base, testCase.expectedPrefix) || !strings.HasSuffix(base, ".tar.gz") {
+			t.Fatalf("Error: unexpected bundle name %q", base)
+		}
+		contents, err := ioutil.ReadFile(local)
+		if err != nil || string(contents) != "contents" {
+			t.Fatalf("Error: unexpected bundle contents %q (%v)", contents, err)
+		}
+	}
+}
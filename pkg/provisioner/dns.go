@@ -0,0 +1,181 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+const (
+	// the internal name we always try to resolve
+	dnsCheckInternalName = "kubernetes.default"
+
+	// the script run in the DNS check pod: try to resolve all the names for a while
+	dnsCheckScript = `for i in $(seq 1 24); do %s && exit 0; sleep 5; done; exit 1`
+
+	// overrides for the DNS check pod, so it can run in (tainted) control plane nodes
+	dnsCheckOverrides = `{"spec":{"tolerations":[{"operator":"Exists"}]}}`
+
+	// interval between checks of the DNS check pod
+	dnsCheckInterval = 10 * time.Second
+
+	// max time we wait for the DNS check pod to finish
+	dnsCheckTimeout = 5 * time.Minute
+
+	// label of the CoreDNS pods
+	coreDNSAppLabel = "kube-dns"
+
+	// command for getting the names of the nodes
+	kubectlGetNodesNamesCmd = `get nodes -o=jsonpath='{range .items[*]}{.metadata.name}{"\n"}{end}'`
+)
+
+// dnsCheckPodScript returns the script for resolving some names in the DNS check pod
+func dnsCheckPodScript(names []string) string {
+	lookups := []string{}
+	for _, name := range names {
+		lookups = append(lookups, fmt.Sprintf("nslookup %s", name))
+	}
+	return fmt.Sprintf(dnsCheckScript, strings.Join(lookups, " && "))
+}
+
+// doCheckDNS runs a (throwaway) pod that verifies that the cluster DNS can
+// resolve some internal and external names, removing the pod afterwards.
+func doCheckDNS(d *schema.ResourceData) ssh.Action {
+	if !d.Get("check_dns").(bool) {
+		return nil
+	}
+
+	names := []string{dnsCheckInternalName}
+	if external := strings.TrimSpace(d.Get("check_dns_external").(string)); external != "" {
+		names = append(names, external)
+	}
+
+	pod := fmt.Sprintf("kubeadm-dns-check-%d", time.Now().Unix())
+	script := dnsCheckPodScript(names)
+
+	// waitPod waits until the pod has finished, returning its phase
+	phase := ""
+	waitPod := ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+		var buf bytes.Buffer
+		res := doKubectlWithOutput(d, &buf, "get", "pod", pod, "-o=jsonpath='{.status.phase}'").Apply(ctx)
+		if ssh.IsError(res) {
+			return res
+		}
+		phase = strings.TrimSpace(buf.String())
+		switch phase {
+		case "Succeeded", "Failed":
+			return nil
+		}
+		return ssh.ActionError(fmt.Sprintf("DNS check pod is %q", phase))
+	})
+
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Checking the cluster DNS can resolve %s...", strings.Join(names, ", ")),
+		ssh.DoWithCleanup(
+			ssh.ActionList{
+				doKubectl(d, "run", pod, "--restart=Never", "--namespace=default",
+					fmt.Sprintf("--image=%s", common.DefDNSCheckImage),
+					fmt.Sprintf("--overrides='%s'", dnsCheckOverrides),
+					"--command", "--", "sh", "-c", fmt.Sprintf("'%s'", script)),
+				ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+					res := doPoll(dnsCheckTimeout, dnsCheckInterval, waitPod).Apply(ctx)
+					if ssh.IsError(res) {
+						return ssh.ActionError(fmt.Sprintf("timeout after %s waiting for the DNS check: %s",
+							dnsCheckTimeout, res.Error()))
+					}
+					if phase != "Succeeded" {
+						return ssh.ActionList{
+							ssh.DoMessageWarn("DNS check failed: logs from the %q pod:", pod),
+							ssh.DoTry(doKubectl(d, "logs", pod, "--namespace=default")),
+							ssh.ActionError(fmt.Sprintf("the cluster DNS could not resolve %s", strings.Join(names, ", "))),
+						}
+					}
+					return ssh.DoMessageInfo("The cluster DNS is working.")
+				}),
+			},
+			ssh.DoTry(doKubectl(d, "delete", "pod", pod, "--namespace=default", "--ignore-not-found", "--wait=false"))),
+	}
+}
+
+// coreDNSPatch returns the (strategic merge) patch for the CoreDNS Deployment, setting
+// the number of replicas and (optionally) a preferred anti-affinity between replicas
+func coreDNSPatch(replicas int, antiAffinity bool) string {
+	spec := map[string]interface{}{"replicas": replicas}
+	if antiAffinity {
+		term := map[string]interface{}{
+			"weight": 100,
+			"podAffinityTerm": map[string]interface{}{
+				"labelSelector": map[string]interface{}{
+					"matchExpressions": []interface{}{
+						map[string]interface{}{"key": "k8s-app", "operator": "In", "values": []string{coreDNSAppLabel}},
+					},
+				},
+				"topologyKey": "kubernetes.io/hostname",
+			},
+		}
+		spec["template"] = map[string]interface{}{
+			"spec": map[string]interface{}{
+				"affinity": map[string]interface{}{
+					"podAntiAffinity": map[string]interface{}{
+						"preferredDuringSchedulingIgnoredDuringExecution": []interface{}{term},
+					},
+				},
+			},
+		}
+	}
+	patch, _ := json.Marshal(map[string]interface{}{"spec": spec})
+	return string(patch)
+}
+
+// doScaleCoreDNS sets the number of CoreDNS replicas (spreading them across
+// nodes when enabled), warning when there are more replicas than nodes
+func doScaleCoreDNS(d *schema.ResourceData) ssh.Action {
+	replicasOpt, ok := d.GetOk("config.dns_replicas")
+	if !ok {
+		return nil
+	}
+	replicas, err := strconv.Atoi(replicasOpt.(string))
+	if err != nil || replicas < 1 {
+		return ssh.ActionError(fmt.Sprintf("invalid number of CoreDNS replicas %q", replicasOpt.(string)))
+	}
+	antiAffinity := true
+	if antiAffinityOpt, ok := d.GetOk("config.dns_anti_affinity"); ok {
+		antiAffinity, _ = strconv.ParseBool(antiAffinityOpt.(string))
+	}
+
+	var nodesBuf bytes.Buffer
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Setting %d CoreDNS replicas...", replicas),
+		doKubectlWithOutput(d, &nodesBuf, kubectlGetNodesNamesCmd),
+		ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+			if nodes := len(strings.Fields(nodesBuf.String())); replicas > nodes {
+				return ssh.DoMessageWarn("%d CoreDNS replicas but only %d nodes in the cluster: some replicas will share nodes", replicas, nodes)
+			}
+			return nil
+		}),
+		doKubectl(d, fmt.Sprintf("-n kube-system patch deployment coredns -p '%s'", coreDNSPatch(replicas, antiAffinity))),
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"testing"
+)
+
+func TestDNSCheckPodScript(t *testing.T) {
+	expected := `for i in $(seq 1 24); do nslookup kubernetes.default && nslookup kubernetes.io && exit 0; sleep 5; done; exit 1`
+	if res := dnsCheckPodScript([]string{"kubernetes.default", "kubernetes.io"}); res != expected {
+		t.Fatalf("Error: unexpected script: %q", res)
+	}
+}
+
+func TestCoreDNSPatch(t *testing.T) {
+	expected := `{"spec":{"replicas":3}}`
+	if res := coreDNSPatch(3, false); res != expected {
+		t.Fatalf("Error: unexpected patch: %q", res)
+	}
+
+	expected = `{"spec":{"replicas":2,"template":{"spec":{"affinity":{"podAntiAffinity":{"preferredDuringSchedulingIgnoredDuringExecution":` +
+		`[{"podAffinityTerm":{"labelSelector":{"matchExpressions":[{"key":"k8s-app","operator":"In","values":["kube-dns"]}]},` +
+		`"topologyKey":"kubernetes.io/hostname"},"weight":100}]}}}}}}`
+	if res := coreDNSPatch(2, true); res != expected {
+		t.Fatalf("Error: unexpected patch: %q", res)
+	}
+}
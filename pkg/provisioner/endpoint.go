@@ -0,0 +1,102 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+const (
+	// script for getting the `/healthz` of the API server through some endpoint:
+	// it prints the response body and, in the last line, the HTTP status code
+	// (or "000" when the connection fails)
+	endpointHealthzScript = `#!/bin/sh
+command -v curl >/dev/null 2>&1 || { echo "no curl available" ; exit 2 ; }
+curl -sk --max-time 10 -w "\n%%{http_code}" "https://%s/healthz"
+exit 0
+`
+)
+
+// parseHealthzOutput parses the output of the endpointHealthzScript,
+// returning the HTTP status code and the response body
+func parseHealthzOutput(output string) (int, string, error) {
+	output = strings.TrimRight(output, "\n")
+	i := strings.LastIndex(output, "\n")
+	body, codeStr := "", output
+	if i >= 0 {
+		body, codeStr = output[:i], output[i+1:]
+	}
+	code, err := strconv.Atoi(strings.TrimSpace(codeStr))
+	if err != nil {
+		return 0, "", fmt.Errorf("unexpected output when getting the /healthz: %q", output)
+	}
+	return code, strings.TrimSpace(body), nil
+}
+
+// checkHealthzResponse checks the response for a `/healthz` through the control plane
+// endpoint. Authentication errors (when anonymous requests are disabled) are accepted,
+// as they can only come from an API server.
+func checkHealthzResponse(code int, body string) error {
+	switch {
+	case code == 0:
+		return fmt.Errorf("could not connect (or no response)")
+	case code == 401 || code == 403:
+		return nil
+	case code != 200:
+		return fmt.Errorf("unexpected HTTP status %d (%q)", code, body)
+	case body != "ok":
+		return fmt.Errorf("unexpected response %q: it does not seem to be an API server", body)
+	}
+	return nil
+}
+
+// doCheckControlPlaneEndpoint checks that the control plane endpoint (ie, a load
+// balancer) forwards requests to a healthy API server, getting the `/healthz`
+// through it. This is more than checking that the port is open, as load balancers
+// accept connections even when all the backends are down.
+func doCheckControlPlaneEndpoint(d *schema.ResourceData) ssh.Action {
+	endpoint := getControlPlaneEndpointFromResourceData(d)
+	if endpoint == "" {
+		return nil
+	}
+	endpoint = common.AddressWithPort(endpoint, common.DefAPIServerPort)
+
+	script := fmt.Sprintf(endpointHealthzScript, endpoint)
+	return ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+		var buf bytes.Buffer
+		if res := ssh.DoSendingExecOutputToWriter(ssh.DoExecScript([]byte(script)), &buf).Apply(ctx); ssh.IsError(res) {
+			return ssh.DoMessageWarn("could not check the control plane endpoint %s: %s", endpoint, strings.TrimSpace(buf.String()))
+		}
+		code, body, err := parseHealthzOutput(buf.String())
+		if err == nil {
+			err = checkHealthzResponse(code, body)
+		}
+		if err != nil {
+			return ssh.DoAbort("the control plane endpoint %s is not forwarding requests to a healthy API server: %s. "+
+				"Check that the load balancer points to the control plane nodes (port %d) and its health checks", endpoint, err, common.DefAPIServerPort)
+		}
+		ssh.Debug("control plane endpoint %s is healthy", endpoint)
+		return nil
+	})
+}
@@ -0,0 +1,45 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"testing"
+)
+
+func TestCheckHealthzOutput(t *testing.T) {
+	testsCases := []struct {
+		output string
+		err    bool
+	}{
+		{"ok\n200", false},
+		{"ok\n200\n", false},
+		{`{"kind":"Status","code":401}` + "\n401", false},
+		{"\n000", true},
+		{"<html>502 Bad Gateway</html>\n502", true},
+		{"[-]etcd failed\nhealthz check failed\n500", true},
+		{"hello\n200", true},
+		{"something", true},
+	}
+
+	for _, testCase := range testsCases {
+		code, body, err := parseHealthzOutput(testCase.output)
+		if err == nil {
+			err = checkHealthzResponse(code, body)
+		}
+		if (err != nil) != testCase.err {
+			t.Fatalf("Error: unexpected result for %q: %v", testCase.output, err)
+		}
+	}
+}
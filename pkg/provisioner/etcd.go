@@ -26,6 +26,7 @@ import (
 	"github.com/hashicorp/terraform/helper/schema"
 
 	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
 )
 
 const (
@@ -51,6 +52,21 @@ const (
 
 	// command for removing a member
 	subcmdMemberRemove = "member remove"
+
+	// command for getting the health of all the members in the cluster
+	subcmdClusterHealth = "endpoint health --cluster"
+
+	// command for getting the health of the local member
+	subcmdLocalHealth = "endpoint health"
+
+	// label for the etcd static pods
+	etcdPodsLabel = "component=etcd"
+
+	// the etcd manifest in control-plane nodes running a local etcd
+	etcdManifest = "/etc/kubernetes/manifests/etcd.yaml"
+
+	// check that is successful when a (maybe not existing) directory is in the root filesystem
+	checkDirInRootFsCmd = `d=%q; while [ ! -e "$d" ] ; do d=$(dirname "$d") ; done ; [ "$(df -P "$d" | tail -n1 | awk '{print $6}')" = "/" ]`
 )
 
 var (
@@ -151,6 +167,101 @@ func (endpoints EtcdEndpointsSet) GetLocalEndpoint() EtcdEndpoint {
 	return localEndpoint
 }
 
+// EtcdMember is a member of the etcd cluster
+type EtcdMember struct {
+	ID   string
+	Name string
+}
+
+// EtcdMembersList is the list of members in the etcd cluster
+type EtcdMembersList []EtcdMember
+
+// FromString parses the output of `etcdctl member list`, like
+//
+// 8e9e05c52164694d, started, master-0, https://10.0.0.1:2380, https://10.0.0.1:2379, false
+func (members *EtcdMembersList) FromString(s string) error {
+	*members = EtcdMembersList{}
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		res := strings.Split(line, ",")
+		if len(res) < 5 {
+			ssh.Debug("cannot parse as member info: %q", line)
+			return ErrParsingEtcdOutput
+		}
+		*members = append(*members, EtcdMember{
+			ID:   strings.TrimSpace(res[0]),
+			Name: strings.TrimSpace(res[2]),
+		})
+	}
+	return nil
+}
+
+// Get returns the member with some ID (if present)
+func (members EtcdMembersList) Get(id string) (EtcdMember, bool) {
+	for _, member := range members {
+		if member.ID == id {
+			return member, true
+		}
+	}
+	return EtcdMember{}, false
+}
+
+// EtcdHealth is the number of members (and healthy members) in the etcd cluster
+type EtcdHealth struct {
+	Members int
+	Healthy int
+}
+
+// FromString parses the output of `etcdctl endpoint health`, like
+//
+// https://10.0.0.1:2379 is healthy: successfully committed proposal: took = 2.1ms
+// https://10.0.0.2:2379 is unhealthy: failed to commit proposal: context deadline exceeded
+func (h *EtcdHealth) FromString(s string) error {
+	h.Members, h.Healthy = 0, 0
+	for _, line := range strings.Split(s, "\n") {
+		switch {
+		case strings.Contains(line, " is healthy"):
+			h.Members++
+			h.Healthy++
+		case strings.Contains(line, " is unhealthy"):
+			h.Members++
+		}
+	}
+	if h.Members == 0 {
+		ssh.Debug("cannot parse as endpoints health: %q", s)
+		return ErrParsingEtcdOutput
+	}
+	return nil
+}
+
+// etcdQuorum returns the number of healthy members needed for a cluster with `members` members
+func etcdQuorum(members int) int {
+	return members/2 + 1
+}
+
+// CheckRemoval returns an error if removing one member (healthy or not)
+// would leave the etcd cluster without quorum
+func (h EtcdHealth) CheckRemoval(removedIsHealthy bool) error {
+	// removing the last member destroys the cluster: that is what users want
+	if h.Members <= 1 {
+		return nil
+	}
+
+	remaining := h.Members - 1
+	remainingHealthy := h.Healthy
+	if removedIsHealthy {
+		remainingHealthy--
+	}
+	if quorum := etcdQuorum(remaining); remainingHealthy < quorum {
+		return fmt.Errorf("removing this member would leave %d healthy etcd members (out of %d) but %d are needed for quorum",
+			remainingHealthy, remaining, quorum)
+	}
+	return nil
+}
+
 /////////////////////////////////////////////////////////////////////////////////////////
 
 // DoGetEndpointsList gets the list of endpoints in the etcd cluster
@@ -168,9 +279,81 @@ func DoGetEndpointsList(eps *EtcdEndpointsSet) ssh.Action {
 	}
 }
 
-// doRemoveIfMember removes this node from the etcd cluster iff it was a member
+// DoGetMembersList gets the list of members in the etcd cluster
+func DoGetMembersList(members *EtcdMembersList) ssh.Action {
+	var buf bytes.Buffer
+	return ssh.ActionList{
+		ssh.DoSendingExecOutputToWriter(DoRunEtcdctlSubcommand(subcmdMembersList), &buf),
+		ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+			if err := members.FromString(buf.String()); err != nil {
+				return ssh.ActionError(err.Error())
+			}
+			return nil
+		}),
+	}
+}
+
+// doGetMembersListFromPeer gets the list of members in the etcd cluster from the
+// etcd running in some other control-plane node (the local etcd stops once removed)
+func doGetMembersListFromPeer(d *schema.ResourceData, localNodename string, members *EtcdMembersList) ssh.Action {
+	var podsBuf, membersBuf bytes.Buffer
+	return ssh.ActionList{
+		doKubectlWithOutput(d, &podsBuf, "-n", "kube-system", "get", "pods", "-l", etcdPodsLabel,
+			"--field-selector=status.phase=Running",
+			`-o=jsonpath='{range .items[*]}{.metadata.name}{" "}{.spec.nodeName}{"\n"}{end}'`),
+		ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+			pod := ""
+			for _, line := range strings.Split(podsBuf.String(), "\n") {
+				fields := strings.Fields(line)
+				if len(fields) == 2 && fields[1] != localNodename {
+					pod = fields[0]
+					break
+				}
+			}
+			if pod == "" {
+				return ssh.ActionError("could not find any etcd running in other control-plane node")
+			}
+
+			ssh.Debug("getting the etcd members list from %q", pod)
+			args := []string{"-n", "kube-system", "exec", pod, "--", "etcdctl"}
+			args = append(args, strings.Fields(argsCommon)...)
+			args = append(args, fmt.Sprintf("--endpoints=https://%s:%d", localEtcdEndpointIP, localEtcdEndpointPort))
+			args = append(args, strings.Fields(subcmdMembersList)...)
+			return doKubectlWithOutput(d, &membersBuf, args...)
+		}),
+		ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+			if err := members.FromString(membersBuf.String()); err != nil {
+				return ssh.ActionError(err.Error())
+			}
+			return nil
+		}),
+	}
+}
+
+// doVerifyMemberRemoved checks that some member is not in the etcd cluster anymore,
+// and that the cluster has the `expected` number of members
+func doVerifyMemberRemoved(d *schema.ResourceData, removed EtcdMember, expected int) ssh.Action {
+	members := EtcdMembersList{}
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Verifying %q is not in the etcd cluster anymore...", removed.ID),
+		doGetMembersListFromPeer(d, removed.Name, &members),
+		ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+			if _, found := members.Get(removed.ID); found {
+				return ssh.ActionError(fmt.Sprintf("%q is still a member of the etcd cluster", removed.ID))
+			}
+			if len(members) != expected {
+				return ssh.ActionError(fmt.Sprintf("the etcd cluster has %d members (%d expected)", len(members), expected))
+			}
+			return ssh.DoMessageInfo("The etcd cluster has %d members", len(members))
+		}),
+	}
+}
+
+// doRemoveIfMember removes this node from the etcd cluster iff it was a member,
+// verifying it is not in the cluster afterwards
 func doRemoveIfMember(d *schema.ResourceData) ssh.Action {
 	eps := EtcdEndpointsSet{}
+	members := EtcdMembersList{}
 	return ssh.ActionList{
 		ssh.DoMessageInfo("Checking if we must delete the node from the etcd cluster..."),
 		ssh.DoIfElse(
@@ -189,13 +372,25 @@ func doRemoveIfMember(d *schema.ResourceData) ssh.Action {
 						return ssh.DoMessageWarn("could not find the local etcd endpoint details")
 					}
 
+					if res := DoGetMembersList(&members).Apply(ctx); ssh.IsError(res) {
+						return res
+					}
+					localMember, found := members.Get(localEndpoint.ID)
+					if !found {
+						return ssh.DoMessageWarn("%q is not a member of the etcd cluster", localEndpoint.ID)
+					}
+
 					// now we have the etcd ID for the etcd instance running in this machine
 					// we can run the "member remove <ID>"
-					return ssh.ActionList{
+					actions := ssh.ActionList{
 						ssh.DoMessageInfo("Removing %q from the etcd cluster", localEndpoint.ID),
 						DoRunEtcdctlSubcommand(subcmdMemberRemove, localEndpoint.ID),
 						ssh.DoMessageInfo("%q has been removed from the etcd cluster", localEndpoint.ID),
 					}
+					if len(members) > 1 {
+						actions = append(actions, doVerifyMemberRemoved(d, localMember, len(members)-1))
+					}
+					return actions
 				}),
 			},
 			ssh.ActionList{
@@ -205,6 +400,62 @@ func doRemoveIfMember(d *schema.ResourceData) ssh.Action {
 	}
 }
 
+// doResetControlPlane runs a "kubeadm reset" in control-plane nodes running etcd,
+// cleaning up the etcd data directory and the static pods
+func doResetControlPlane(d *schema.ResourceData) ssh.Action {
+	return ssh.DoIf(
+		ssh.CheckFileExists(etcdManifest),
+		ssh.ActionList{
+			ssh.DoMessageInfo("Resetting the control-plane node with 'kubeadm reset'..."),
+			doExecKubeadmWithConfig(d, "reset", "", "--force"),
+			ssh.DoFlushCache(),
+		})
+}
+
+// doGetEtcdHealth gets the health of the etcd cluster and of the local member
+func doGetEtcdHealth(cluster *EtcdHealth, local *EtcdHealth) ssh.Action {
+	var clusterBuf, localBuf bytes.Buffer
+	return ssh.ActionList{
+		// note: `etcdctl endpoint health` fails when some endpoint is unhealthy
+		ssh.DoTry(ssh.DoSendingExecOutputToWriter(DoRunEtcdctlSubcommand(subcmdClusterHealth), &clusterBuf)),
+		ssh.DoTry(ssh.DoSendingExecOutputToWriter(DoRunEtcdctlSubcommand(subcmdLocalHealth), &localBuf)),
+		ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+			if err := cluster.FromString(clusterBuf.String()); err != nil {
+				return ssh.ActionError(fmt.Sprintf("could not get the health of the etcd cluster: %s", err))
+			}
+			if err := local.FromString(localBuf.String()); err != nil {
+				return ssh.ActionError(fmt.Sprintf("could not get the health of the local etcd member: %s", err))
+			}
+			return nil
+		}),
+	}
+}
+
+// doCheckEtcdQuorum refuses to continue when removing the etcd member
+// running in this node would leave the etcd cluster without quorum
+func doCheckEtcdQuorum(d *schema.ResourceData) ssh.Action {
+	if getIgnoreEtcdQuorumFromResourceData(d) {
+		return ssh.DoMessageWarn("not checking the etcd quorum: the etcd cluster could be lost")
+	}
+
+	cluster, local := EtcdHealth{}, EtcdHealth{}
+	return ssh.DoIf(
+		ssh.CheckContainerRunning(etcContainerPattern),
+		ssh.ActionList{
+			ssh.DoMessageInfo("Checking the etcd cluster keeps its quorum without this node..."),
+			ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+				if res := doGetEtcdHealth(&cluster, &local).Apply(ctx); ssh.IsError(res) {
+					return ssh.DoAbort("%s: use 'ignore_etcd_quorum' for removing the node anyway", res.Error())
+				}
+				ssh.Debug("etcd cluster health: %+v, local member health: %+v", cluster, local)
+				if err := cluster.CheckRemoval(local.Healthy > 0); err != nil {
+					return ssh.DoAbort("%s: use 'ignore_etcd_quorum' for removing the node anyway", err)
+				}
+				return ssh.DoMessageInfo("%d/%d etcd members are healthy: the node can be removed", cluster.Healthy, cluster.Members)
+			}),
+		})
+}
+
 // doPrintEtcdStatus prints the status of etcd, if running
 func doPrintEtcdStatus(d *schema.ResourceData) ssh.Action {
 	eps := EtcdEndpointsSet{}
@@ -228,3 +479,37 @@ func doPrintEtcdStatus(d *schema.ResourceData) ssh.Action {
 		},
 	)
 }
+
+// doCheckEtcdDataDir prints a warning when a custom etcd data directory
+// has been provided but it is in the root filesystem
+func doCheckEtcdDataDir(d *schema.ResourceData) ssh.Action {
+	initConfig, _, err := common.InitConfigFromResourceData(d)
+	if err != nil {
+		return ssh.ActionError(fmt.Sprintf("could not get a valid 'config': %s", err))
+	}
+	if initConfig.Etcd.Local == nil || initConfig.Etcd.Local.DataDir == "" {
+		return nil
+	}
+
+	dataDir := initConfig.Etcd.Local.DataDir
+	return ssh.DoIf(
+		ssh.CheckExec(fmt.Sprintf(checkDirInRootFsCmd, dataDir)),
+		ssh.DoMessageWarn("etcd data directory %q is in the root filesystem: this can lead to IO contention", dataDir))
+}
+
+// doCheckEtcdVersion prints a warning when a custom etcd version has been provided
+// but it is not the version kubeadm uses for the Kubernetes version installed
+func doCheckEtcdVersion(d *schema.ResourceData) ssh.Action {
+	initConfig, _, err := common.InitConfigFromResourceData(d)
+	if err != nil {
+		return ssh.ActionError(fmt.Sprintf("could not get a valid 'config': %s", err))
+	}
+	if initConfig.Etcd.Local == nil || initConfig.Etcd.Local.ImageTag == "" {
+		return nil
+	}
+
+	if err := common.CheckEtcdVersion(initConfig.Etcd.Local.ImageTag, initConfig.KubernetesVersion); err != nil {
+		return ssh.DoMessageWarn("%s: this version could not be supported", err)
+	}
+	return nil
+}
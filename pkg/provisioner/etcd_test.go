@@ -14,7 +14,10 @@
 
 package provisioner
 
-import "testing"
+import (
+	"fmt"
+	"testing"
+)
 
 func TestParseEndpointsListOutput(t *testing.T) {
 	s := "https://127.0.0.1:2379, e942f75ad6f00855, 3.3.10, 1.8 MB, true, 2, 24139"
@@ -55,3 +58,58 @@ https://127.0.8.1:2379, f0085f42f7f00855, 3.3.10, 1.8 MB, false, 2, 24139\r
 	}
 
 }
+
+func TestEtcdHealthCheckRemoval(t *testing.T) {
+	healthy := "https://10.0.0.%d:2379 is healthy: successfully committed proposal: took = 2.1ms\r\n"
+	unhealthy := "https://10.0.0.%d:2379 is unhealthy: failed to commit proposal: context deadline exceeded\r\n"
+
+	tests := []struct {
+		output         string
+		removedHealthy bool
+		expectedErr    bool
+	}{
+		{fmt.Sprintf(healthy, 1), true, false},
+		{fmt.Sprintf(healthy, 1) + fmt.Sprintf(healthy, 2), true, false},
+		{fmt.Sprintf(healthy, 1) + fmt.Sprintf(healthy, 2) + fmt.Sprintf(healthy, 3), true, false},
+		{fmt.Sprintf(healthy, 1) + fmt.Sprintf(healthy, 2) + fmt.Sprintf(unhealthy, 3), true, true},
+		{fmt.Sprintf(healthy, 1) + fmt.Sprintf(healthy, 2) + fmt.Sprintf(unhealthy, 3), false, false},
+		{fmt.Sprintf(healthy, 1) + fmt.Sprintf(unhealthy, 2) + fmt.Sprintf(unhealthy, 3), true, true},
+	}
+	for _, test := range tests {
+		health := EtcdHealth{}
+		if err := health.FromString(test.output); err != nil {
+			t.Fatalf("Error: could not parse %q: %s", test.output, err)
+		}
+		if err := health.CheckRemoval(test.removedHealthy); (err != nil) != test.expectedErr {
+			t.Fatalf("Error: unexpected result for %q (removed healthy: %t): %v", test.output, test.removedHealthy, err)
+		}
+	}
+
+	if err := (&EtcdHealth{}).FromString("Error: context deadline exceeded"); err == nil {
+		t.Fatalf("Error: no error when parsing an invalid output")
+	}
+}
+
+func TestParseMembersListOutput(t *testing.T) {
+	s := `
+8e9e05c52164694d, started, master-0, https://10.0.0.1:2380, https://10.0.0.1:2379, false\r
+2f75f75431008954, started, master-1, https://10.0.0.2:2380, https://10.0.0.2:2379, false\r
+`
+	members := EtcdMembersList{}
+	if err := members.FromString(s); err != nil {
+		t.Fatalf("Error: %v", err)
+	}
+	if len(members) != 2 {
+		t.Fatalf("Error: unexpected number of members: %d", len(members))
+	}
+	if member, found := members.Get("2f75f75431008954"); !found || member.Name != "master-1" {
+		t.Fatalf("Error: member not found or unexpected name: %+v", member)
+	}
+	if _, found := members.Get("e942f75ad6f00855"); found {
+		t.Fatalf("Error: unexpected member found")
+	}
+
+	if err := members.FromString("Error: context deadline exceeded"); err == nil {
+		t.Fatalf("Error: no error when parsing an invalid output")
+	}
+}
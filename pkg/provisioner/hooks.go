@@ -0,0 +1,77 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+)
+
+// getHookCommandsFromResourceData returns the list of commands for a hook
+func getHookCommandsFromResourceData(d *schema.ResourceData, hook string) []string {
+	commands := []string{}
+	if commandsOpt, ok := d.GetOk(hook); ok {
+		for _, command := range commandsOpt.([]interface{}) {
+			if s := strings.TrimSpace(command.(string)); s != "" {
+				commands = append(commands, s)
+			}
+		}
+	}
+	return commands
+}
+
+// doRunHook runs the list of commands for some hook (ie, "pre_init") in the remote machine.
+// The provisioning fails when some command fails, unless "ignore_hook_failures" is set.
+func doRunHook(d *schema.ResourceData, hook string) ssh.Action {
+	commands := getHookCommandsFromResourceData(d, hook)
+	if len(commands) == 0 {
+		return nil
+	}
+
+	ignoreFailures := d.Get("ignore_hook_failures").(bool)
+
+	actions := ssh.ActionList{
+		ssh.DoMessageInfo("Running %q hook (%d commands)...", hook, len(commands)),
+	}
+	for _, command := range commands {
+		command := command
+		actions = append(actions, ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+			var buf bytes.Buffer
+			res := ssh.ActionList{
+				ssh.DoMessageInfo("- %s", command),
+				ssh.DoCopyingExecOutputToWriter(ssh.DoExec(command), &buf),
+			}.Apply(ctx)
+			if !ssh.IsError(res) {
+				return nil
+			}
+
+			msg := fmt.Sprintf("%q hook command %q failed: %s", hook, command, res.Error())
+			if output := strings.TrimSpace(buf.String()); output != "" {
+				msg = fmt.Sprintf("%s\n%s", msg, output)
+			}
+			if ignoreFailures {
+				return ssh.DoMessageWarn("%s (ignored)", msg)
+			}
+			return ssh.ActionError(msg)
+		}))
+	}
+	return actions
+}
@@ -0,0 +1,158 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+const (
+	// command for getting the machine architecture
+	unameArchCmd = "uname -m"
+
+	// the flannel image (for some version and architecture)
+	flannelImage = "quay.io/coreos/flannel:%s-%s"
+)
+
+var (
+	// map between `uname -m` and the architectures used in images
+	unameArchs = map[string]string{
+		"x86_64":  "amd64",
+		"aarch64": "arm64",
+		"armv7l":  "arm",
+		"ppc64le": "ppc64le",
+		"s390x":   "s390x",
+	}
+
+	// images used in the built-in weave manifest
+	weaveImages = []string{
+		"docker.io/weaveworks/weave-kube:2.5.2",
+		"docker.io/weaveworks/weave-npc:2.5.2",
+	}
+
+	// the kubeadm images that are used in worker nodes
+	workerImagesNames = []string{"/kube-proxy:", "/pause:"}
+)
+
+// getWorkerImagesFromImagesList gets the images used in worker nodes from the
+// output of `kubeadm config images list`. The `sandboxImage` (when not empty)
+// replaces the pause image.
+func getWorkerImagesFromImagesList(output string, sandboxImage string) []string {
+	images := []string{}
+	for _, line := range strings.Split(output, "\n") {
+		image := strings.TrimSpace(line)
+		for _, name := range workerImagesNames {
+			if strings.Contains(image, name) {
+				if name == "/pause:" && sandboxImage != "" {
+					image = sandboxImage
+				}
+				images = append(images, image)
+			}
+		}
+	}
+	return images
+}
+
+// getCNIImages returns the images used by the built-in CNI plugin (for some
+// architecture). Images in user-provided manifests are unknown.
+func getCNIImages(d *schema.ResourceData, arch string) []string {
+	if manifest, ok := d.GetOk("config.cni_plugin_manifest"); ok && manifest.(string) != "" {
+		return []string{}
+	}
+
+	switch d.Get("config.cni_plugin").(string) {
+	case "flannel":
+		version := common.DefFlannelImageVersion
+		if v, ok := d.GetOk("config.flannel_image_version"); ok && v.(string) != "" {
+			version = v.(string)
+		}
+		return []string{fmt.Sprintf(flannelImage, version, arch)}
+	case "weave":
+		return weaveImages
+	}
+	return []string{}
+}
+
+// getImagePullCmd returns the command for pulling an image with some runtime engine
+func getImagePullCmd(engine string, crictl string, image string) string {
+	switch engine {
+	case "docker":
+		return fmt.Sprintf("docker pull %s", image)
+	case "containerd":
+		socket := common.DefCriSocket[engine]
+		return fmt.Sprintf("%s --runtime-endpoint unix://%s pull %s || ctr -n k8s.io images pull %s", crictl, socket, image, image)
+	default:
+		socket := common.DefCriSocket[engine]
+		return fmt.Sprintf("%s --runtime-endpoint unix://%s pull %s", crictl, socket, image)
+	}
+}
+
+// doPrePullImages pulls the images used in worker nodes (the kube-proxy, the pause
+// and the CNI images), so the node does not stall at "ContainerCreating" after joining
+// the cluster while pulling these images in slow networks.
+// Failures are not fatal, as any missing image will be pulled later on.
+func doPrePullImages(d *schema.ResourceData) ssh.Action {
+	if !d.Get("prepull_images").(bool) {
+		return nil
+	}
+
+	listCmd, err := getKubeadmImagesListCmd(d)
+	if err != nil {
+		return ssh.ActionError(fmt.Sprintf("could not get a valid 'config' for getting the list of images: %s", err))
+	}
+
+	sandboxImage := ""
+	if sandboxImageOpt, ok := d.GetOk("config.sandbox_image"); ok {
+		sandboxImage = sandboxImageOpt.(string)
+	}
+	engine := getRuntimeEngineFromResourceData(d)
+	crictl := getCrictlFromResourceData(d)
+
+	return ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+		var buf bytes.Buffer
+		if res := ssh.DoSendingExecOutputToWriter(ssh.DoExec(listCmd), &buf).Apply(ctx); ssh.IsError(res) {
+			return ssh.DoMessageWarn("could not get the list of kubeadm images: skipping images pre-pull")
+		}
+		images := getWorkerImagesFromImagesList(buf.String(), sandboxImage)
+
+		var archBuf bytes.Buffer
+		if res := ssh.DoSendingExecOutputToWriter(ssh.DoExec(unameArchCmd), &archBuf).Apply(ctx); !ssh.IsError(res) {
+			if arch, ok := unameArchs[strings.TrimSpace(archBuf.String())]; ok {
+				images = append(images, getCNIImages(d, arch)...)
+			}
+		}
+
+		actions := ssh.ActionList{
+			ssh.DoMessageInfo("Pre-pulling %d images...", len(images)),
+		}
+		for _, image := range images {
+			image := image
+			actions = append(actions,
+				ssh.DoMessageInfo("- %s", image),
+				ssh.DoIf(
+					ssh.CheckNot(ssh.CheckAction(ssh.DoSendingExecOutputToDevNull(ssh.DoExec(getImagePullCmd(engine, crictl, image))))),
+					ssh.DoMessageWarn("could not pull %q: it will be pulled later on", image)))
+		}
+		return actions
+	})
+}
@@ -0,0 +1,51 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetWorkerImagesFromImagesList(t *testing.T) {
+	output := `k8s.gcr.io/kube-apiserver:v1.20.0
+k8s.gcr.io/kube-controller-manager:v1.20.0
+k8s.gcr.io/kube-scheduler:v1.20.0
+k8s.gcr.io/kube-proxy:v1.20.0
+k8s.gcr.io/pause:3.2
+k8s.gcr.io/etcd:3.4.13-0
+k8s.gcr.io/coredns:1.7.0
+`
+	testsCases := []struct {
+		sandboxImage string
+		expected     []string
+	}{
+		{
+			"",
+			[]string{"k8s.gcr.io/kube-proxy:v1.20.0", "k8s.gcr.io/pause:3.2"},
+		},
+		{
+			"registry.local/pause:3.2",
+			[]string{"k8s.gcr.io/kube-proxy:v1.20.0", "registry.local/pause:3.2"},
+		},
+	}
+
+	for _, testCase := range testsCases {
+		images := getWorkerImagesFromImagesList(output, testCase.sandboxImage)
+		if !reflect.DeepEqual(images, testCase.expected) {
+			t.Fatalf("Error: unexpected images: %v, expected: %v", images, testCase.expected)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+)
+
+// kernelRequirements are the kernel requirements for a CNI plugin
+type kernelRequirements struct {
+	// minVersion is the minimum kernel version (ie, "4.19.57")
+	minVersion string
+
+	// configs are the kernel config options that must be enabled (built-in or as modules)
+	configs []string
+
+	// files are some files that must exist (ie, "/sys/fs/cgroup/cgroup.controllers")
+	files []string
+}
+
+// cniKernelRequirements are the kernel requirements for the CNI plugins
+var cniKernelRequirements = map[string]kernelRequirements{
+	"flannel": {
+		minVersion: "3.10",
+		configs:    []string{"CONFIG_VXLAN"},
+	},
+	"weave": {
+		minVersion: "3.8",
+	},
+	"calico": {
+		minVersion: "3.10",
+	},
+	"cilium": {
+		minVersion: "4.19.57",
+		configs: []string{
+			"CONFIG_BPF",
+			"CONFIG_BPF_SYSCALL",
+			"CONFIG_BPF_JIT",
+			"CONFIG_NET_CLS_BPF",
+			"CONFIG_NET_SCH_INGRESS",
+			"CONFIG_CGROUP_BPF",
+			"CONFIG_CRYPTO_SHA1",
+			"CONFIG_CRYPTO_USER_API_HASH",
+		},
+	},
+}
+
+// kernelInfoScript prints the kernel version, the kernel config options
+// enabled and the files found (from the list of files in the arguments)
+const kernelInfoScript = `
+echo "version=$(uname -r)"
+CFG=/boot/config-$(uname -r)
+if [ -f $CFG ] ; then
+	grep -E '^CONFIG_[A-Z0-9_]+=(y|m)' $CFG
+elif [ -f /proc/config.gz ] ; then
+	zcat /proc/config.gz | grep -E '^CONFIG_[A-Z0-9_]+=(y|m)'
+fi
+for f in %s ; do
+	[ -e $f ] && echo "file=$f"
+done
+`
+
+// getCNIPluginFromResourceData returns the name of the CNI plugin used, guessing
+// it from the manifest when a `plugin_manifest` is used
+func getCNIPluginFromResourceData(d *schema.ResourceData) string {
+	if manifestOpt, ok := d.GetOk("config.cni_plugin_manifest"); ok && len(manifestOpt.(string)) > 0 {
+		manifest := strings.ToLower(manifestOpt.(string))
+		for name := range cniKernelRequirements {
+			if strings.Contains(manifest, name) {
+				return name
+			}
+		}
+		return ""
+	}
+	if pluginOpt, ok := d.GetOk("config.cni_plugin"); ok {
+		return strings.TrimSpace(strings.ToLower(pluginOpt.(string)))
+	}
+	return ""
+}
+
+// parseKernelVersion parses a kernel version (ie, "5.4.0-88-generic")
+// returning the numeric components (ie, [5, 4, 0])
+func parseKernelVersion(version string) []int {
+	res := []int{}
+	for _, c := range strings.Split(version, ".") {
+		// stop at the first non-numeric character (ie, "0-88-generic" -> "0")
+		end := strings.IndexFunc(c, func(r rune) bool { return r < '0' || r > '9' })
+		if end == 0 {
+			break
+		}
+		if end > 0 {
+			c = c[:end]
+		}
+		n, err := strconv.Atoi(c)
+		if err != nil {
+			break
+		}
+		res = append(res, n)
+		if end > 0 {
+			break
+		}
+	}
+	return res
+}
+
+// kernelVersionAtLeast returns true if the kernel version is greater or equal than `min`
+func kernelVersionAtLeast(version string, min string) bool {
+	v, m := parseKernelVersion(version), parseKernelVersion(min)
+	for i := range m {
+		if i >= len(v) {
+			return false
+		}
+		if v[i] != m[i] {
+			return v[i] > m[i]
+		}
+	}
+	return true
+}
+
+// checkKernelRequirements checks the output of the `kernelInfoScript` against some requirements,
+// returning the list of unmet requirements (and if the kernel config could be checked)
+func checkKernelRequirements(out string, reqs kernelRequirements) ([]string, bool) {
+	version := ""
+	configs := map[string]bool{}
+	files := map[string]bool{}
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "version="):
+			version = strings.TrimPrefix(line, "version=")
+		case strings.HasPrefix(line, "file="):
+			files[strings.TrimPrefix(line, "file=")] = true
+		case strings.HasPrefix(line, "CONFIG_"):
+			configs[strings.SplitN(line, "=", 2)[0]] = true
+		}
+	}
+
+	unmet := []string{}
+	if reqs.minVersion != "" && !kernelVersionAtLeast(version, reqs.minVersion) {
+		unmet = append(unmet, fmt.Sprintf("kernel version %q is older than %q", version, reqs.minVersion))
+	}
+	configChecked := len(configs) > 0
+	if configChecked {
+		for _, config := range reqs.configs {
+			if !configs[config] {
+				unmet = append(unmet, fmt.Sprintf("%s is not enabled", config))
+			}
+		}
+	}
+	for _, file := range reqs.files {
+		if !files[file] {
+			unmet = append(unmet, fmt.Sprintf("%s does not exist", file))
+		}
+	}
+	return unmet, configChecked
+}
+
+// doCheckKernelRequirements checks that the kernel satisfies the requirements
+// of the CNI plugin, failing early with the unmet requirements
+func doCheckKernelRequirements(d *schema.ResourceData) ssh.Action {
+	cniPlugin := getCNIPluginFromResourceData(d)
+	reqs, ok := cniKernelRequirements[cniPlugin]
+	if !ok {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	script := fmt.Sprintf(kernelInfoScript, strings.Join(reqs.files, " "))
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Checking the kernel satisfies the %q requirements...", cniPlugin),
+		ssh.DoSendingExecOutputToWriter(ssh.DoExecScript([]byte(script)), &buf),
+		ssh.ActionFunc(func(context.Context) ssh.Action {
+			unmet, configChecked := checkKernelRequirements(buf.String(), reqs)
+			if len(unmet) > 0 {
+				return ssh.ActionError(fmt.Sprintf("the kernel does not satisfy the requirements of %q: %s",
+					cniPlugin, strings.Join(unmet, ", ")))
+			}
+			if !configChecked && len(reqs.configs) > 0 {
+				return ssh.DoMessageWarn("could not read the kernel config: kernel options required by %q have not been checked", cniPlugin)
+			}
+			return nil
+		}),
+	}
+}
@@ -0,0 +1,55 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"testing"
+)
+
+func TestKernelVersionAtLeast(t *testing.T) {
+	testsCases := []struct {
+		version  string
+		min      string
+		expected bool
+	}{
+		{"5.4.0-88-generic", "4.19.57", true},
+		{"4.19.57", "4.19.57", true},
+		{"4.19.12-1-default", "4.19.57", false},
+		{"3.10.0-1160.el7.x86_64", "4.19.57", false},
+		{"5.10", "5.10.1", false},
+		{"", "3.10", false},
+	}
+
+	for _, testCase := range testsCases {
+		res := kernelVersionAtLeast(testCase.version, testCase.min)
+		if res != testCase.expected {
+			t.Fatalf("Error: kernelVersionAtLeast(%q, %q) = %t", testCase.version, testCase.min, res)
+		}
+	}
+}
+
+func TestCheckKernelRequirements(t *testing.T) {
+	out := "version=4.19.100\nCONFIG_BPF=y\nCONFIG_VXLAN=m\n"
+
+	unmet, checked := checkKernelRequirements(out, cniKernelRequirements["flannel"])
+	if !checked || len(unmet) > 0 {
+		t.Fatalf("Error: unexpected unmet requirements for flannel: %v", unmet)
+	}
+
+	unmet, _ = checkKernelRequirements(out, cniKernelRequirements["cilium"])
+	if len(unmet) != len(cniKernelRequirements["cilium"].configs)-1 {
+		t.Fatalf("Error: unexpected unmet requirements for cilium: %v", unmet)
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/assets"
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+const (
+	// konnectivityKubeconfigScript creates the kubeconfig used by the konnectivity server,
+	// with a client certificate for "system:konnectivity-server" signed by the cluster CA
+	konnectivityKubeconfigScript = `
+set -e
+KUBECONFIG_FILE=%[1]s
+PKI_DIR=%[2]s
+TMP_DIR=$(mktemp -d)
+trap 'rm -rf $TMP_DIR' EXIT
+
+openssl req -subj "/CN=system:konnectivity-server" -new -newkey rsa:2048 -nodes \
+	-out $TMP_DIR/konnectivity.csr -keyout $TMP_DIR/konnectivity.key 2>/dev/null
+openssl x509 -req -in $TMP_DIR/konnectivity.csr -CA $PKI_DIR/ca.crt -CAkey $PKI_DIR/ca.key \
+	-CAcreateserial -CAserial $TMP_DIR/ca.srl -out $TMP_DIR/konnectivity.crt -days 375 -sha256 2>/dev/null
+
+SERVER=$(%[3]s --kubeconfig=/etc/kubernetes/admin.conf config view -o jsonpath='{.clusters..server}')
+%[3]s --kubeconfig=$KUBECONFIG_FILE config set-credentials system:konnectivity-server \
+	--client-certificate=$TMP_DIR/konnectivity.crt --client-key=$TMP_DIR/konnectivity.key --embed-certs=true
+%[3]s --kubeconfig=$KUBECONFIG_FILE config set-cluster kubernetes \
+	--server="$SERVER" --certificate-authority=$PKI_DIR/ca.crt --embed-certs=true
+%[3]s --kubeconfig=$KUBECONFIG_FILE config set-context system:konnectivity-server@kubernetes \
+	--cluster=kubernetes --user=system:konnectivity-server
+%[3]s --kubeconfig=$KUBECONFIG_FILE config use-context system:konnectivity-server@kubernetes
+chmod 600 $KUBECONFIG_FILE
+`
+)
+
+// isKonnectivityEnabled returns true if the konnectivity server and agent must be deployed
+func isKonnectivityEnabled(d *schema.ResourceData) bool {
+	enabledOpt, ok := d.GetOk("config.konnectivity_enabled")
+	if !ok {
+		return false
+	}
+	enabled, _ := strconv.ParseBool(enabledOpt.(string))
+	return enabled
+}
+
+// getKonnectivityPKIDir returns the directory with the cluster CA and the API server certificates
+func getKonnectivityPKIDir(d *schema.ResourceData) string {
+	if certsDirOpt, ok := d.GetOk("config.certs_dir"); ok && certsDirOpt.(string) != "" {
+		return certsDirOpt.(string)
+	}
+	return common.DefPKIDir
+}
+
+// getKonnectivityTemplateConfig returns the variables used in the konnectivity manifests
+func getKonnectivityTemplateConfig(d *schema.ResourceData) map[string]interface{} {
+	return konnectivityTemplateConfig(common.GetProvisionerConfig(d), getKonnectivityPKIDir(d))
+}
+
+// konnectivityTemplateConfig adds the konnectivity paths to (a copy of) the provisioner config
+func konnectivityTemplateConfig(provConfig map[string]interface{}, pkiDir string) map[string]interface{} {
+	config := map[string]interface{}{}
+	for k, v := range provConfig {
+		config[k] = v
+	}
+	config["konnectivity_pki_dir"] = pkiDir
+	config["konnectivity_uds_name"] = common.DefKonnectivitySocket
+	config["konnectivity_uds_dir"] = common.DefKonnectivityDir
+	config["konnectivity_kubeconfig"] = common.DefKonnectivityKubeconfigPath
+	return config
+}
+
+// doDeployKonnectivityServer deploys the konnectivity server (as a static pod) in
+// a control plane node, creating the kubeconfig it uses for talking to the API server
+func doDeployKonnectivityServer(d *schema.ResourceData) ssh.Action {
+	if !isKonnectivityEnabled(d) {
+		return nil
+	}
+
+	manifest, err := ssh.ReplaceInTemplate(assets.KonnectivityServerManifestCode, getKonnectivityTemplateConfig(d))
+	if err != nil {
+		return ssh.ActionError(fmt.Sprintf("could not replace variables in konnectivity server manifest: %s", err))
+	}
+
+	script := fmt.Sprintf(konnectivityKubeconfigScript,
+		common.DefKonnectivityKubeconfigPath, getKonnectivityPKIDir(d), getKubectlFromResourceData(d))
+
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Deploying the konnectivity server..."),
+		ssh.DoIf(
+			ssh.CheckNot(ssh.CheckBinaryExists("openssl")),
+			ssh.DoAbort("'openssl' is required for creating the konnectivity server certificate")),
+		ssh.DoExecScript([]byte(script)),
+		ssh.DoUploadBytesToFile([]byte(manifest), common.DefKonnectivityManifestPath),
+	}
+}
+
+// doLoadKonnectivityAgent loads the konnectivity agent (and the RBAC rules
+// needed by the konnectivity server)
+func doLoadKonnectivityAgent(d *schema.ResourceData) ssh.Action {
+	if !isKonnectivityEnabled(d) {
+		return nil
+	}
+
+	manifest := ssh.Manifest{Inline: assets.KonnectivityAgentManifestCode}
+	if err := manifest.ReplaceConfig(getKonnectivityTemplateConfig(d)); err != nil {
+		return ssh.ActionError(fmt.Sprintf("could not replace variables in konnectivity agent manifest: %s", err))
+	}
+
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Loading the konnectivity agent..."),
+		doRemoteKubectlApply(d, []ssh.Manifest{manifest}),
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/assets"
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+func TestKonnectivityManifests(t *testing.T) {
+	provConfig := map[string]interface{}{
+		"konnectivity_enabled":     "true",
+		"konnectivity_version":     common.DefKonnectivityImageVersion,
+		"konnectivity_agent_port":  "8132",
+		"konnectivity_server_host": "api.cluster.local",
+	}
+	config := konnectivityTemplateConfig(provConfig, common.DefPKIDir)
+
+	for _, manifest := range []string{assets.KonnectivityServerManifestCode, assets.KonnectivityAgentManifestCode} {
+		replaced, err := ssh.ReplaceInTemplate(manifest, config)
+		if err != nil {
+			t.Fatalf("Error: could not replace variables: %s", err)
+		}
+		if strings.Contains(replaced, "<no value>") {
+			t.Fatalf("Error: some variables were not replaced in:\n%s", replaced)
+		}
+		if !strings.Contains(replaced, ":"+common.DefKonnectivityImageVersion) {
+			t.Fatalf("Error: image version not found in:\n%s", replaced)
+		}
+	}
+
+	if _, ok := provConfig["konnectivity_uds_name"]; ok {
+		t.Fatalf("Error: the provisioner config has been modified")
+	}
+}
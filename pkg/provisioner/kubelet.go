@@ -0,0 +1,65 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+)
+
+// mergeKubeletExtraArgs adds the `extra` args to the kubelet `args`, returning the (sorted)
+// list of extra args ignored because they were already set with a different value
+func mergeKubeletExtraArgs(args map[string]string, extra map[string]string) []string {
+	ignored := []string{}
+	for arg, value := range extra {
+		if current, ok := args[arg]; ok && current != value {
+			ignored = append(ignored, arg)
+			continue
+		}
+		args[arg] = value
+	}
+	sort.Strings(ignored)
+	return ignored
+}
+
+// doSetKubeletExtraArgs adds the "kubelet_extra_args" of this node to the kubelet args in
+// the kubeadm configuration. The args set by the provider (including the `extra_args.kubelet`)
+// take precedence, so these args are ignored (with a warning) when they are already set.
+func doSetKubeletExtraArgs(d *schema.ResourceData, command string) ssh.Action {
+	extra := getKubeletExtraArgsFromResourceData(d)
+	if len(extra) == 0 {
+		return nil
+	}
+
+	return ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+		ignored := []string{}
+		err := updateKubeletExtraArgs(d, command, func(args map[string]string) error {
+			ignored = mergeKubeletExtraArgs(args, extra)
+			return nil
+		})
+		if err != nil {
+			return ssh.ActionError(err.Error())
+		}
+		if len(ignored) > 0 {
+			return ssh.DoMessageWarn("kubelet args already set by the provider (ignored in 'kubelet_extra_args'): %s", strings.Join(ignored, ", "))
+		}
+		return nil
+	})
+}
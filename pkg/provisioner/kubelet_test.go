@@ -0,0 +1,45 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeKubeletExtraArgs(t *testing.T) {
+	args := map[string]string{
+		"node-ip":       "10.0.0.1",
+		"cgroup-driver": "systemd",
+	}
+	extra := map[string]string{
+		"max-pods":      "200",
+		"node-ip":       "10.0.0.2",
+		"cgroup-driver": "systemd",
+	}
+
+	ignored := mergeKubeletExtraArgs(args, extra)
+	if !reflect.DeepEqual(ignored, []string{"node-ip"}) {
+		t.Fatalf("Error: unexpected ignored args: %v", ignored)
+	}
+	expected := map[string]string{
+		"node-ip":       "10.0.0.1",
+		"cgroup-driver": "systemd",
+		"max-pods":      "200",
+	}
+	if !reflect.DeepEqual(args, expected) {
+		t.Fatalf("Error: unexpected args: %v", args)
+	}
+}
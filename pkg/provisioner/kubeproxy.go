@@ -0,0 +1,116 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+const (
+	// kubectl command for getting the ClusterIP of the `kubernetes` Service
+	kubectlGetKubernetesClusterIPCmd = `-n default get service kubernetes -o=jsonpath='{.spec.clusterIP}'`
+
+	// number of times we check the service routing (the CNI plugin can still be starting)
+	serviceRoutingCheckRetries = 12
+
+	// time between service routing checks
+	serviceRoutingCheckInterval = 5 * time.Second
+)
+
+// parseClusterIP parses the ClusterIP of a Service
+func parseClusterIP(out string) (string, error) {
+	ip := strings.Trim(strings.TrimSpace(out), "'\"")
+	if net.ParseIP(ip) == nil {
+		return "", fmt.Errorf("%q is not a valid ClusterIP", ip)
+	}
+	return ip, nil
+}
+
+// doVerifyNoKubeProxy checks that, when the kube-proxy mode is "none", the kube-proxy
+// has not been deployed and the CNI plugin has taken over the Services routing: the API
+// server must be reachable from the node through the ClusterIP of the `kubernetes` Service.
+func doVerifyNoKubeProxy(d *schema.ResourceData) ssh.Action {
+	if getKubeProxyModeFromResourceData(d) != common.KubeProxyModeNone {
+		return nil
+	}
+
+	return ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+		var buf bytes.Buffer
+		res := doKubectlWithOutput(d, &buf, "-n", "kube-system", "get", "daemonset", "kube-proxy").Apply(ctx)
+		if !ssh.IsError(res) {
+			return ssh.DoAbort("the kube-proxy mode is 'none' but there is a kube-proxy DaemonSet in kube-system: " +
+				"delete it (ie, 'kubectl -n kube-system delete daemonset kube-proxy') and clean up its iptables rules")
+		}
+		if !isKubectlNotFoundOutput(buf.String()) {
+			return ssh.ActionError(fmt.Sprintf("could not check if the kube-proxy has been deployed: %s", res.Error()))
+		}
+
+		buf.Reset()
+		if res := doKubectlWithOutput(d, &buf, kubectlGetKubernetesClusterIPCmd).Apply(ctx); ssh.IsError(res) {
+			return ssh.ActionError(fmt.Sprintf("could not get the ClusterIP of the 'kubernetes' Service: %s", res.Error()))
+		}
+		clusterIP, err := parseClusterIP(buf.String())
+		if err != nil {
+			return ssh.ActionError(err.Error())
+		}
+
+		endpoint := common.AddressWithPort(clusterIP, 443)
+		script := fmt.Sprintf(endpointHealthzScript, endpoint)
+		var lastErr error
+		check := ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+			var out bytes.Buffer
+			if res := ssh.DoSendingExecOutputToWriter(ssh.DoExecScript([]byte(script)), &out).Apply(ctx); ssh.IsError(res) {
+				lastErr = fmt.Errorf("%s", strings.TrimSpace(out.String()))
+				return nil
+			}
+			code, body, err := parseHealthzOutput(out.String())
+			if err == nil {
+				err = checkHealthzResponse(code, body)
+			}
+			if err != nil {
+				lastErr = err
+				return ssh.ActionError(err.Error())
+			}
+			lastErr = nil
+			return nil
+		})
+
+		actions := ssh.ActionList{
+			ssh.DoMessageInfo("Checking the Services routing without kube-proxy (through %s)...", endpoint),
+			ssh.DoRetry(ssh.Retry{Times: serviceRoutingCheckRetries, Interval: serviceRoutingCheckInterval}, check),
+		}
+		res = actions.Apply(ctx)
+		switch {
+		case lastErr != nil && strings.Contains(lastErr.Error(), "no curl available"):
+			return ssh.DoMessageWarn("could not check the Services routing without kube-proxy: %s", lastErr)
+		case lastErr != nil:
+			return ssh.DoAbort("the kube-proxy mode is 'none' but the API server is not reachable through the 'kubernetes' Service (%s): %s. "+
+				"Check the CNI plugin replaces the kube-proxy (ie, 'kubeProxyReplacement' in cilium)", endpoint, lastErr)
+		case ssh.IsError(res):
+			return res
+		}
+		return ssh.DoMessageInfo("Services are routed without kube-proxy.")
+	})
+}
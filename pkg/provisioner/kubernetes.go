@@ -17,8 +17,13 @@ package provisioner
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/terraform/helper/schema"
 
@@ -30,15 +35,209 @@ const (
 	// command for getting the machine-id
 	machineIDCmd = `cat /etc/machine-id`
 
+	// command for getting the hostname (used by the kubelet as the default nodename)
+	hostnameCmd = `hostname`
+
+	// command for getting the machine-id of a node
+	kubectlGetNodeMachineIDCmd = `get node %s -o=jsonpath='{.status.nodeInfo.machineID}'`
+
+	// command for getting the PodDisruptionBudgets that do not allow disruptions (as "namespace/name<TAB>selector")
+	kubectlGetBlockingPDBsCmd = `get pdb --all-namespaces -o=jsonpath='{range .items[?(@.status.disruptionsAllowed==0)]}{.metadata.namespace}{"/"}{.metadata.name}{"\t"}{.spec.selector}{"\n"}{end}'`
+
+	// command for getting the pods in a node (as "namespace<TAB>labels")
+	kubectlGetNodePodsCmd = `get pods --all-namespaces --field-selector=spec.nodeName=%s -o=jsonpath='{range .items[*]}{.metadata.namespace}{"\t"}{.metadata.labels}{"\n"}{end}'`
+
+	// default max time for draining a node
+	defDrainTimeout = "10m"
+
 	// command for getting a map of "machine-id <-> nodename"
 	kubectlGetNodenameCmd = `get nodes -o yaml -o=jsonpath='{range .items[*]}{.status.nodeInfo.machineID}{"\t"}{.metadata.name}{"\n"}{end}'`
+
+	// command for getting the "Ready" condition of the pods of a control plane component
+	kubectlGetComponentReadyCmd = `-n kube-system get pods -l component=%s -o=jsonpath='{.items[*].status.conditions[?(@.type=="Ready")].status}'`
+
+	// interval between checks of the control plane health
+	controlPlaneHealthyInterval = 10 * time.Second
+
+	// max time we wait for the control plane to be healthy
+	controlPlaneHealthyTimeout = 5 * time.Minute
+
+	// command for getting the "Ready" condition of the workers
+	kubectlGetWorkersReadyCmd = `get nodes -l '!node-role.kubernetes.io/master,!node-role.kubernetes.io/control-plane' -o=jsonpath='{range .items[*]}{.status.conditions[?(@.type=="Ready")].status}{"\n"}{end}'`
+
+	// interval between checks of the number of workers ready
+	workersReadyInterval = 15 * time.Second
+
+	// max time we wait for the workers to be ready
+	workersReadyTimeout = 20 * time.Minute
+
+	// command for getting the "default" ServiceAccount in a namespace
+	kubectlGetDefaultServiceAccountCmd = `-n %s get serviceaccount default -o=name`
+
+	// interval between checks of the default ServiceAccounts
+	serviceAccountsInterval = 5 * time.Second
+
+	// max time we wait for the default ServiceAccounts to be created
+	serviceAccountsTimeout = 2 * time.Minute
+
+	// default number of attempts for kubectl commands failing with transient errors
+	defKubectlRetryAttempts = 5
+
+	// default interval between kubectl attempts
+	defKubectlRetryInterval = "2s"
+
+	// factor the interval between kubectl attempts is multiplied by after each attempt
+	kubectlRetryBackoff = 2
+)
+
+var (
+	// the control plane components (static pods) we wait for after a "kubeadm init"
+	controlPlaneComponents = []string{
+		"etcd",
+		"kube-apiserver",
+		"kube-controller-manager",
+		"kube-scheduler",
+	}
+
+	// kubectlTransientErrors are errors from kubectl that can be retried, as they are
+	// usually caused by the API server (or etcd) being restarted (ie, after the init)
+	kubectlTransientErrors = []string{
+		"connection refused",
+		"connection reset by peer",
+		"i/o timeout",
+		"TLS handshake timeout",
+		"unexpected EOF",
+		"http2: client connection lost",
+		"the server is currently unable to handle the request",
+		"the server was unable to return a response in the time allotted",
+		"Internal error occurred",
+		"etcdserver: leader changed",
+		"etcdserver: request timed out",
+		"Too many requests",
+	}
+
+	// defaultServiceAccountsNamespaces are the namespaces where addons are
+	// loaded, so their "default" ServiceAccount must exist before
+	defaultServiceAccountsNamespaces = []string{
+		"kube-system",
+		"default",
+	}
 )
 
-// doRemoteKubectl runs a remote kubectl with the kubeconfig specified in the schema
-func doRemoteKubectl(d *schema.ResourceData, args ...string) ssh.Action {
+// doKubectl runs kubectl in the remote machine. The remote "admin.conf" is
+// used when present (ie, in a control-plane node), otherwise the kubeconfig
+// specified in the schema is uploaded.
+func doKubectl(d *schema.ResourceData, args ...string) ssh.Action {
+	return doKubectlWithOutput(d, nil, args...)
+}
+
+// doKubectlWithOutput runs kubectl in the remote machine, sending the output to `output` (when not nil)
+func doKubectlWithOutput(d *schema.ResourceData, output io.Writer, args ...string) ssh.Action {
+	kubeconfig := getKubeconfigFromResourceData(d)
+	kubectl := getKubectlFromResourceData(d)
+
+	action := doWithKubectlRetries(d, output, func(w io.Writer) ssh.Action {
+		if output != nil {
+			return ssh.DoRemoteKubectlWithOutput(kubectl, kubeconfig, w, args...)
+		}
+		return ssh.DoCopyingExecOutputToWriter(ssh.DoRemoteKubectl(kubectl, kubeconfig, args...), w)
+	})
+	return doWithKubectlError(action, args...)
+}
+
+// doKubectlWithStdin runs kubectl in the remote machine, using `stdin` as the
+// standard input (ie, `doKubectlWithStdin(d, manifest, "apply", "-f", "-")`)
+func doKubectlWithStdin(d *schema.ResourceData, stdin []byte, args ...string) ssh.Action {
 	kubeconfig := getKubeconfigFromResourceData(d)
 	kubectl := getKubectlFromResourceData(d)
-	return ssh.DoRemoteKubectl(kubectl, kubeconfig, args...)
+
+	action := doWithKubectlRetries(d, nil, func(w io.Writer) ssh.Action {
+		return ssh.DoCopyingExecOutputToWriter(ssh.DoRemoteKubectlWithStdin(kubectl, kubeconfig, stdin, args...), w)
+	})
+	return doWithKubectlError(action, args...)
+}
+
+// isKubectlTransientOutput returns true if the output of kubectl shows some
+// transient error (ie, the API server is not reachable for a moment)
+func isKubectlTransientOutput(output string) bool {
+	for _, e := range kubectlTransientErrors {
+		if strings.Contains(output, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// doWithKubectlRetries runs a kubectl action (created with `run`, that must send the kubectl
+// output to the writer provided), retrying it when it fails with some transient error.
+// The output of the last attempt is sent to `output` (when not nil).
+func doWithKubectlRetries(d *schema.ResourceData, output io.Writer, run func(io.Writer) ssh.Action) ssh.Action {
+	retry := getKubectlRetryFromResourceData(d)
+	return ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+		if disabled, _ := ctx.Value(noKubectlRetriesKey{}).(bool); disabled {
+			retry.Times = 1
+		}
+
+		var attemptOutput bytes.Buffer
+		retry.Retryable = func(ssh.Action) bool {
+			return isKubectlTransientOutput(attemptOutput.String())
+		}
+
+		res := ssh.DoRetry(retry, ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+			attemptOutput.Reset()
+			return ssh.ActionList{run(&attemptOutput)}.Apply(ctx)
+		})).Apply(ctx)
+
+		if output != nil {
+			_, _ = output.Write(attemptOutput.Bytes())
+		}
+		return res
+	})
+}
+
+// noKubectlRetriesKey is the context key for disabling the kubectl transient retries
+type noKubectlRetriesKey struct{}
+
+// doPoll runs `check` every `interval` until it succeeds, for `timeout` at most (the
+// wall time, not a number of attempts). The transient kubectl retries are disabled in
+// `check`, as the polling retries anyway. On timeout, the error of the last complete
+// check is returned.
+func doPoll(timeout time.Duration, interval time.Duration, check ssh.Action) ssh.Action {
+	return ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+		var last ssh.Action
+		attempt := ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+			res := ssh.ActionList{check}.Apply(ctx)
+			if ctx.Err() == nil {
+				last = res
+			}
+			return res
+		})
+
+		times := int(timeout/interval) + 1
+		pollCtx := context.WithValue(ctx, noKubectlRetriesKey{}, true)
+		res := ssh.DoWithTimeout(timeout, ssh.DoRetry(ssh.Retry{Times: times, Interval: interval}, attempt)).Apply(pollCtx)
+		if ssh.IsError(res) && ctx.Err() == nil && ssh.IsError(last) {
+			return last
+		}
+		return res
+	})
+}
+
+// doWithKubectlError runs a kubectl action, returning an error with the kubectl arguments on failures
+// (an ExecError is kept, so the exit code and the output are not lost)
+func doWithKubectlError(action ssh.Action, args ...string) ssh.Action {
+	return ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+		res := ssh.ActionList{action}.Apply(ctx)
+		if e, ok := ssh.GetExecError(res); ok {
+			ee := *e
+			ee.Action = fmt.Sprintf("kubectl %s", strings.Join(args, " "))
+			return &ee
+		}
+		if ssh.IsError(res) {
+			return ssh.ActionError(fmt.Sprintf("'kubectl %s' failed: %s", strings.Join(args, " "), res.Error()))
+		}
+		return res
+	})
 }
 
 // DoRemoteKubectlApply applies some manifests with a remote kubectl, uploading the kubeconfig specified in the schema
@@ -47,20 +246,228 @@ func doRemoteKubectlApply(d *schema.ResourceData, manifests []ssh.Manifest) ssh.
 	if kubeconfig == "" {
 		return ssh.ActionError("no 'config_path' has been specified")
 	}
-	return ssh.DoRemoteKubectlApply(getKubectlFromResourceData(d), kubeconfig, manifests)
+	opts := getKubectlApplyOptionsFromResourceData(d)
+	return doWithKubectlRetries(d, nil, func(w io.Writer) ssh.Action {
+		return ssh.DoCopyingExecOutputToWriter(ssh.DoRemoteKubectlApplyWithOptions(getKubectlFromResourceData(d), kubeconfig, manifests, opts), w)
+	})
 }
 
-// doKubectlDrainNode runs a kubectl for draining a node
-func doKubectlDrainNode(d *schema.ResourceData, nodename string) ssh.Action {
+// isKubectlNotFoundOutput returns true if the output of kubectl says some object was not found
+func isKubectlNotFoundOutput(output string) bool {
+	return strings.Contains(output, "NotFound") || strings.Contains(output, "not found")
+}
+
+// doKubectlCordonNode runs a kubectl for cordoning a node (so no new pods are scheduled in it).
+// Cordoning an already cordoned node is a no-op. When the node does not exist in the
+// cluster, `notFound` is set and no error is returned.
+func doKubectlCordonNode(d *schema.ResourceData, nodename string, notFound *bool) ssh.Action {
+	args := []string{"cordon", nodename}
+
+	ssh.Debug("running 'kubectl cordon' command for %q", nodename)
+	return ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+		var buf bytes.Buffer
+		res := ssh.ActionList{
+			ssh.DoMessageInfo("Cordoning kubernetes node %q", nodename),
+			doKubectlWithOutput(d, &buf, args...),
+		}.Apply(ctx)
+		if ssh.IsError(res) {
+			if isKubectlNotFoundOutput(buf.String()) {
+				*notFound = true
+				return ssh.DoMessageWarn("Kubernetes node %q not found in the cluster", nodename)
+			}
+			return res
+		}
+		return nil
+	})
+}
+
+// drainOptions are the options for draining a node
+type drainOptions struct {
+	PodSelector     string
+	DisableEviction bool
+	Force           bool
+	Timeout         time.Duration
+	DeleteOnTimeout bool
+}
+
+// getDrainArgs returns the kubectl args for draining a node with some options
+func getDrainArgs(nodename string, opts drainOptions) []string {
 	args := []string{"drain",
-		"--delete-local-data=true", "--force=true", "--ignore-daemonsets=true",
-		nodename}
+		"--delete-local-data=true",
+		fmt.Sprintf("--force=%t", opts.Force),
+		"--ignore-daemonsets=true"}
+	if opts.PodSelector != "" {
+		// the args are joined in a shell command, and selectors can contain spaces and parentheses
+		selector := strings.Replace(opts.PodSelector, "'", `'\''`, -1)
+		args = append(args, fmt.Sprintf("--pod-selector='%s'", selector))
+	}
+	if opts.DisableEviction {
+		args = append(args, "--disable-eviction=true")
+	}
+	if opts.Timeout > 0 {
+		args = append(args, fmt.Sprintf("--timeout=%s", opts.Timeout))
+	}
+	return append(args, nodename)
+}
 
-	ssh.Debug("running 'kubectl drain' command for %q", nodename)
-	return ssh.ActionList{
-		ssh.DoMessageInfo("Draining kubernetes node %q", nodename),
-		doRemoteKubectl(d, args...),
+// labelSelector is a (JSON) label selector, as used in the PodDisruptionBudgets
+type labelSelector struct {
+	MatchLabels      map[string]string `json:"matchLabels"`
+	MatchExpressions []struct {
+		Key      string   `json:"key"`
+		Operator string   `json:"operator"`
+		Values   []string `json:"values"`
+	} `json:"matchExpressions"`
+}
+
+// matches returns true if the selector matches some labels
+func (s labelSelector) matches(labels map[string]string) bool {
+	for k, v := range s.MatchLabels {
+		if labels[k] != v {
+			return false
+		}
+	}
+	for _, e := range s.MatchExpressions {
+		value, exists := labels[e.Key]
+		found := false
+		for _, v := range e.Values {
+			found = found || (exists && v == value)
+		}
+		switch e.Operator {
+		case "In":
+			if !found {
+				return false
+			}
+		case "NotIn":
+			if found {
+				return false
+			}
+		case "Exists":
+			if !exists {
+				return false
+			}
+		case "DoesNotExist":
+			if exists {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// splitTabLines splits some output in lines of (at least) two tab-separated fields
+func splitTabLines(out string) [][]string {
+	res := [][]string{}
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.Trim(strings.TrimSpace(line), "'")
+		if line == "" {
+			continue
+		}
+		fields := strings.SplitN(line, "\t", 2)
+		if len(fields) < 2 {
+			fields = append(fields, "")
+		}
+		res = append(res, fields)
+	}
+	return res
+}
+
+// parseBlockingPDBs parses the output of `kubectlGetBlockingPDBsCmd` and `kubectlGetNodePodsCmd`,
+// returning the (sorted) list of PodDisruptionBudgets (as "namespace/name") that select some pod in the node
+func parseBlockingPDBs(pdbsOut string, podsOut string) ([]string, error) {
+	pods := map[string][]map[string]string{}
+	for _, fields := range splitTabLines(podsOut) {
+		labels := map[string]string{}
+		if strings.TrimSpace(fields[1]) != "" {
+			if err := json.Unmarshal([]byte(fields[1]), &labels); err != nil {
+				return nil, fmt.Errorf("could not parse the labels of a pod in %q: %s", fields[0], err)
+			}
+		}
+		pods[fields[0]] = append(pods[fields[0]], labels)
+	}
+
+	pdbs := []string{}
+	for _, fields := range splitTabLines(pdbsOut) {
+		// a PodDisruptionBudget without a selector does not select any pod
+		if strings.TrimSpace(fields[1]) == "" {
+			continue
+		}
+		selector := labelSelector{}
+		if err := json.Unmarshal([]byte(fields[1]), &selector); err != nil {
+			return nil, fmt.Errorf("could not parse the selector of %q: %s", fields[0], err)
+		}
+		namespace := strings.SplitN(fields[0], "/", 2)[0]
+		for _, labels := range pods[namespace] {
+			if selector.matches(labels) {
+				pdbs = append(pdbs, fields[0])
+				break
+			}
+		}
 	}
+	sort.Strings(pdbs)
+	return pdbs, nil
+}
+
+// doKubectlDrainNode runs a kubectl for draining a node. When the drain fails (ie, it times out),
+// the PodDisruptionBudgets not allowing disruptions are reported and, if enabled in the options,
+// the pods are deleted instead of evicted.
+func doKubectlDrainNode(d *schema.ResourceData, nodename string) ssh.Action {
+	opts := getDrainOptionsFromResourceData(d)
+	kubeconfig := getKubeconfigFromResourceData(d)
+	kubectl := getKubectlFromResourceData(d)
+
+	// the drain is run just once (it can take as long as the timeout), without retries
+	doDrain := func(opts drainOptions, output io.Writer) ssh.Action {
+		args := getDrainArgs(nodename, opts)
+		if output == nil {
+			return doWithKubectlError(ssh.DoRemoteKubectl(kubectl, kubeconfig, args...), args...)
+		}
+		return doWithKubectlError(ssh.DoRemoteKubectlWithOutput(kubectl, kubeconfig, output, args...), args...)
+	}
+
+	ssh.Debug("running 'kubectl drain' command for %q", nodename)
+	return ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+		var drainBuf bytes.Buffer
+		res := ssh.ActionList{
+			ssh.DoMessageInfo("Draining kubernetes node %q (timeout: %s)", nodename, opts.Timeout),
+			doDrain(opts, &drainBuf),
+		}.Apply(ctx)
+		if !ssh.IsError(res) {
+			return nil
+		}
+
+		var pdbsBuf, podsBuf bytes.Buffer
+		if pdbsRes := (ssh.ActionList{
+			doKubectlWithOutput(d, &pdbsBuf, kubectlGetBlockingPDBsCmd),
+			doKubectlWithOutput(d, &podsBuf, fmt.Sprintf(kubectlGetNodePodsCmd, nodename)),
+		}).Apply(ctx); ssh.IsError(pdbsRes) {
+			ssh.Debug("could not get the PodDisruptionBudgets: %s", pdbsRes.Error())
+			return res
+		}
+		pdbs, err := parseBlockingPDBs(pdbsBuf.String(), podsBuf.String())
+		if err != nil {
+			ssh.Debug("could not get the PodDisruptionBudgets: %s", err)
+			return res
+		}
+		if len(pdbs) == 0 {
+			return res
+		}
+
+		if opts.DeleteOnTimeout && !opts.DisableEviction {
+			deleteOpts := opts
+			deleteOpts.DisableEviction = true
+			return ssh.ActionList{
+				ssh.DoMessageWarn("could not evict the pods in node %q: PodDisruptionBudgets not allowing disruptions: %s",
+					nodename, strings.Join(pdbs, ", ")),
+				ssh.DoMessageWarn("deleting the pods in node %q (bypassing the PodDisruptionBudgets)", nodename),
+				doDrain(deleteOpts, nil),
+			}
+		}
+		return ssh.ActionError(fmt.Sprintf("could not drain node %q: PodDisruptionBudgets not allowing disruptions: %s (use 'delete_on_timeout' for deleting the pods): %s",
+			nodename, strings.Join(pdbs, ", "), res.Error()))
+	})
 }
 
 // doKubectlDeleteNode deletes the node from the cluster (so it will be forgotten forever)
@@ -70,7 +477,7 @@ func doKubectlDeleteNode(d *schema.ResourceData, nodename string) ssh.Action {
 	ssh.Debug("running 'kubectl delete node' command for %q", nodename)
 	return ssh.ActionList{
 		ssh.DoMessageInfo("Deleting kubernetes node %q", nodename),
-		doRemoteKubectl(d, args...),
+		doKubectl(d, args...),
 	}
 }
 
@@ -84,9 +491,6 @@ func DoGetNodename(d *schema.ResourceData, node *ssh.KubeNode) ssh.Action {
 		return nil
 	}
 
-	kubectl := getKubectlFromResourceData(d)
-	kubeconfig := getKubeconfigFromResourceData(d)
-
 	// otherwise, access the remote host
 	return ssh.ActionFunc(func(ctx context.Context) ssh.Action {
 		// first, get the machine ID
@@ -101,7 +505,7 @@ func DoGetNodename(d *schema.ResourceData, node *ssh.KubeNode) ssh.Action {
 		ssh.Debug("... machineID: %q", machineID)
 
 		res = ssh.DoSendingExecOutputToFunc(
-			ssh.DoRemoteKubectl(kubectl, kubeconfig, kubectlGetNodenameCmd),
+			doKubectl(d, kubectlGetNodenameCmd),
 			func(s string) {
 				if len(s) == 0 {
 					return
@@ -124,6 +528,56 @@ func DoGetNodename(d *schema.ResourceData, node *ssh.KubeNode) ssh.Action {
 	})
 }
 
+// checkNodenameConflict returns an error when a node with the same name is already
+// registered in the cluster by a different machine (with a different machine-id)
+func checkNodenameConflict(nodename string, localMachineID string, remoteMachineID string) error {
+	localMachineID = strings.TrimSpace(localMachineID)
+	remoteMachineID = strings.TrimSpace(remoteMachineID)
+	if remoteMachineID == "" || localMachineID == "" || remoteMachineID == localMachineID {
+		return nil
+	}
+	return fmt.Errorf("a node %q is already registered in the cluster by a different machine (machine-id %s, but this machine is %s): "+
+		"make sure all the machines have different hostnames (or use a different 'nodename')", nodename, remoteMachineID, localMachineID)
+}
+
+// doCheckDuplicateNodename checks that the nodename this machine will register
+// with is not already used by a different machine in the cluster (ie, machines
+// created from the same cloud image with the same hostname).
+// The check is skipped (with a warning) when the cluster cannot be queried.
+func doCheckDuplicateNodename(d *schema.ResourceData) ssh.Action {
+	return ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+		nodename := getNodenameFromResourceData(d)
+		if nodename == "" {
+			// the kubelet uses the (lowercase) hostname by default
+			var buf bytes.Buffer
+			if res := ssh.DoSendingExecOutputToWriter(ssh.DoExec(hostnameCmd), &buf).Apply(ctx); ssh.IsError(res) {
+				return res
+			}
+			nodename = strings.ToLower(strings.TrimSpace(buf.String()))
+		}
+
+		var localBuf bytes.Buffer
+		if res := ssh.DoSendingExecOutputToWriter(ssh.DoExec(machineIDCmd), &localBuf).Apply(ctx); ssh.IsError(res) {
+			return res
+		}
+
+		var remoteBuf bytes.Buffer
+		res := doKubectlWithOutput(d, &remoteBuf, fmt.Sprintf(kubectlGetNodeMachineIDCmd, nodename)).Apply(ctx)
+		if ssh.IsError(res) {
+			if isKubectlNotFoundOutput(remoteBuf.String()) {
+				ssh.Debug("node %q not found in the cluster: no conflicts", nodename)
+				return nil
+			}
+			return ssh.DoMessageWarn("could not check if node %q is already registered in the cluster: %s", nodename, res.Error())
+		}
+
+		if err := checkNodenameConflict(nodename, localBuf.String(), remoteBuf.String()); err != nil {
+			return ssh.DoAbort("%s", err)
+		}
+		return nil
+	})
+}
+
 //
 // kubeconfig
 //
@@ -142,6 +596,18 @@ func doDownloadKubeconfig(d *schema.ResourceData) ssh.Action {
 				return ssh.ActionError(err.Error())
 			}
 
+			// rename the cluster/context/user, so it can be merged with other kubeconfigs
+			if names := getKubeconfigNamesFromResourceData(d); !names.IsEmpty() {
+				ssh.Debug("renaming kubeconfig cluster/context/user: %+v", names)
+				cont, err = common.RenameKubeconfig(cont, names)
+				if err != nil {
+					return ssh.ActionError(fmt.Sprintf("could not rename the kubeconfig: %s", err))
+				}
+				if err := ioutil.WriteFile(kubeconfig, cont, 0600); err != nil {
+					return ssh.ActionError(err.Error())
+				}
+			}
+
 			_ = d.Set("kubeconfig", common.ToTerraformSafeString(cont))
 			return nil
 		}),
@@ -168,30 +634,187 @@ func doDeleteLocalKubeconfig(d *schema.ResourceData) ssh.Action {
 }
 
 // doCheckLocalKubeconfigIsAlive checks that the local "kubeconfig" can be
-// used for accessing the API server. In case we cannot, we just print
-// a warning, as maybe the API server is not accessible from the localhost
-// where Terraform is being run.
+// used for accessing the API server. When a control plane endpoint (ie, a VIP or
+// a load balancer) has been configured, the check is done through that endpoint,
+// so the certificate presented there is verified against the configured SANs.
+// In case we cannot, we just print a warning, as maybe the API server is
+// not accessible from the localhost where Terraform is being run.
 func doCheckLocalKubeconfigIsAlive(d *schema.ResourceData) ssh.Action {
+	endpoint := getControlPlaneEndpointFromResourceData(d)
+	descr := "the API server"
+	if endpoint != "" {
+		descr = fmt.Sprintf("the API server at %q", endpoint)
+	}
+
+	var buf bytes.Buffer
 	return ssh.ActionList{
-		ssh.DoMessageInfo("Checking API server health and reachability..."),
+		ssh.DoMessageInfo("Checking health and reachability of %s...", descr),
 		ssh.DoIfElse(
-			checkLocalKubeconfigAlive(d),
-			ssh.DoMessageInfo("The API server seems to be accessible from here."),
-			ssh.DoMessageWarn("the API server does NOT seem to be accessible from here."),
+			checkLocalKubeconfigAlive(d, endpoint, &buf),
+			ssh.DoMessageInfo("OK: %s seems to be accessible from here.", descr),
+			ssh.ActionFunc(func(context.Context) ssh.Action {
+				if isCertificateErrorOutput(buf.String()) {
+					return ssh.DoMessageWarn("the certificate presented by %s is not valid: check it is in the 'api.alt_names' (current SANs: %s).",
+						descr, strings.Join(getCertSANsFromResourceData(d), ", "))
+				}
+				return ssh.DoMessageWarn("%s does NOT seem to be accessible from here.", descr)
+			}),
 		),
 	}
 }
 
+// isCertificateErrorOutput returns true if the output of kubectl shows a certificate error
+// (ie, "x509: certificate is valid for 10.0.0.1, not 10.0.0.100")
+func isCertificateErrorOutput(out string) bool {
+	return strings.Contains(out, "x509:")
+}
+
+// doWaitControlPlaneHealthy waits until all the control plane components
+// (etcd, apiserver, controller-manager and scheduler) are "Ready". The API server
+// can answer requests before the other components are fully up, so this
+// should be used before loading anything in the cluster.
+func doWaitControlPlaneHealthy(d *schema.ResourceData) ssh.Action {
+	// checkHealth returns an error with the list of unhealthy components (if any)
+	checkHealth := ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+		unhealthy := []string{}
+		for _, component := range controlPlaneComponents {
+			var buf bytes.Buffer
+			cmd := fmt.Sprintf(kubectlGetComponentReadyCmd, component)
+			res := doKubectlWithOutput(d, &buf, cmd).Apply(ctx)
+			if ssh.IsError(res) || !isReadyConditionsOutput(buf.String()) {
+				ssh.Debug("control plane component %q is not healthy: %q", component, buf.String())
+				unhealthy = append(unhealthy, component)
+			}
+		}
+		if len(unhealthy) > 0 {
+			return ssh.ActionError(fmt.Sprintf("unhealthy components: %s", strings.Join(unhealthy, ", ")))
+		}
+		return nil
+	})
+
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Waiting for the control plane to be healthy..."),
+		ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+			res := doPoll(controlPlaneHealthyTimeout, controlPlaneHealthyInterval, checkHealth).Apply(ctx)
+			if ssh.IsError(res) {
+				return ssh.ActionError(fmt.Sprintf("timeout after %s waiting for the control plane to be healthy: %s",
+					controlPlaneHealthyTimeout, res.Error()))
+			}
+			return res
+		}),
+		ssh.DoMessageInfo("The control plane is healthy."),
+	}
+}
+
+// doWaitDefaultServiceAccounts waits until the "default" ServiceAccount exists in
+// some namespaces. The controller-manager creates them asynchronously after the
+// cluster is up, and pods created before that are rejected.
+func doWaitDefaultServiceAccounts(d *schema.ResourceData, namespaces ...string) ssh.Action {
+	// checkServiceAccounts returns an error with the list of namespaces without a "default" ServiceAccount
+	checkServiceAccounts := ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+		missing := []string{}
+		for _, namespace := range namespaces {
+			res := doKubectl(d, fmt.Sprintf(kubectlGetDefaultServiceAccountCmd, namespace)).Apply(ctx)
+			if ssh.IsError(res) {
+				missing = append(missing, namespace)
+			}
+		}
+		if len(missing) > 0 {
+			return ssh.ActionError(fmt.Sprintf("no default ServiceAccount in: %s", strings.Join(missing, ", ")))
+		}
+		return nil
+	})
+
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Waiting for the default ServiceAccounts..."),
+		ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+			res := doPoll(serviceAccountsTimeout, serviceAccountsInterval, checkServiceAccounts).Apply(ctx)
+			if ssh.IsError(res) {
+				return ssh.ActionError(fmt.Sprintf("timeout after %s waiting for the default ServiceAccounts: %s",
+					serviceAccountsTimeout, res.Error()))
+			}
+			return res
+		}),
+	}
+}
+
+// doWaitForWorkers waits until (at least) the number of workers specified
+// in "wait_for_workers" are "Ready" (or a timeout expires)
+func doWaitForWorkers(d *schema.ResourceData) ssh.Action {
+	expected := getWaitForWorkersFromResourceData(d)
+	if expected <= 0 {
+		return nil
+	}
+
+	// checkWorkers returns an error if there are not enough workers ready
+	checkWorkers := ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+		var buf bytes.Buffer
+		res := doKubectlWithOutput(d, &buf, kubectlGetWorkersReadyCmd).Apply(ctx)
+		if ssh.IsError(res) {
+			return res
+		}
+		ready := countReadyConditionsOutput(buf.String())
+		if ready < expected {
+			return ssh.ActionError(fmt.Sprintf("only %d of %d workers are ready", ready, expected))
+		}
+		return nil
+	})
+
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Waiting for %d workers to be ready...", expected),
+		ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+			res := doPoll(workersReadyTimeout, workersReadyInterval, checkWorkers).Apply(ctx)
+			if ssh.IsError(res) {
+				return ssh.ActionError(fmt.Sprintf("timeout after %s waiting for workers: %s",
+					workersReadyTimeout, res.Error()))
+			}
+			return res
+		}),
+		ssh.DoMessageInfo("%d workers are ready.", expected),
+	}
+}
+
+// countReadyConditionsOutput returns the number of "True" statuses in some "Ready" conditions output
+func countReadyConditionsOutput(output string) int {
+	count := 0
+	for _, status := range strings.Fields(output) {
+		if status == "True" {
+			count++
+		}
+	}
+	return count
+}
+
+// isReadyConditionsOutput returns true if the output of `kubectlGetComponentReadyCmd`
+// contains at least one pod and all the pods are "Ready"
+func isReadyConditionsOutput(output string) bool {
+	statuses := strings.Fields(output)
+	if len(statuses) == 0 {
+		return false
+	}
+	for _, status := range statuses {
+		if status != "True" {
+			return false
+		}
+	}
+	return true
+}
+
 ////////////////////////////////////////////////////////////////////////////////////////////////////
 // checks
 ////////////////////////////////////////////////////////////////////////////////////////////////////
 
 // checkLocalKubeconfigAlive checks if a local kubeconfig exists and is alive
-func checkLocalKubeconfigAlive(d *schema.ResourceData) ssh.CheckerFunc {
+// (through the `endpoint` when not empty), sending the kubectl output to `output`
+func checkLocalKubeconfigAlive(d *schema.ResourceData, endpoint string, output io.Writer) ssh.CheckerFunc {
 	kubeconfig := getKubeconfigFromResourceData(d)
+	args := []string{"cluster-info"}
+	if endpoint != "" {
+		args = append([]string{fmt.Sprintf("--server=https://%s", common.AddressWithPort(endpoint, common.DefAPIServerPort))}, args...)
+	}
 	return ssh.CheckAnd(
 		ssh.CheckLocalFileExists(getKubeconfigFromResourceData(d)),
-		ssh.CheckAction(ssh.DoRemoteKubectl(getKubectlFromResourceData(d), kubeconfig, "cluster-info")))
+		ssh.CheckAction(ssh.DoRemoteKubectlWithOutput(getKubectlFromResourceData(d), kubeconfig, output, args...)))
 }
 
 // checkAdminConfAlive checks if a remmote kubeconfig exists and is alive
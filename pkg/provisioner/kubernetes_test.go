@@ -15,7 +15,11 @@
 package provisioner
 
 import (
+	"context"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/terraform/helper/schema"
 
@@ -52,3 +56,194 @@ bf38f8ac633e4f64a4924b0ed7b25946        kubeadm-master-0
 		t.Fatalf("Error: wrong nodename %q", node.Nodename)
 	}
 }
+
+func TestDoWithKubectlError(t *testing.T) {
+	execErr := &ssh.ExecError{Action: "/usr/bin/kubectl --kubeconfig=/tmp/kubeconfig get nodes", ExitCode: 1, Output: "some error"}
+
+	res := doWithKubectlError(execErr, "get", "nodes").Apply(ssh.NewTestingContextWithResponses([]string{}))
+	e, ok := ssh.GetExecError(res)
+	if !ok {
+		t.Fatalf("Error: the ExecError has been lost: %v", res)
+	}
+	if e.ExitCode != 1 || e.Output != "some error" || e.Action != "kubectl get nodes" {
+		t.Fatalf("Error: unexpected ExecError: %+v", e)
+	}
+}
+
+func TestDoPoll(t *testing.T) {
+	attempts := 0
+	check := ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+		attempts++
+		if disabled, _ := ctx.Value(noKubectlRetriesKey{}).(bool); !disabled {
+			return ssh.ActionError("kubectl retries are not disabled")
+		}
+		// a slow check: the timeout must be the wall time, not a number of attempts
+		time.Sleep(40 * time.Millisecond)
+		return ssh.ActionError("not ready")
+	})
+
+	start := time.Now()
+	res := doPoll(100*time.Millisecond, 10*time.Millisecond, check).Apply(ssh.NewTestingContextWithResponses([]string{}))
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("Error: polling took %s", elapsed)
+	}
+	if !ssh.IsError(res) || res.Error() != "not ready" {
+		t.Fatalf("Error: unexpected result: %v", res)
+	}
+	if attempts < 2 {
+		t.Fatalf("Error: only %d attempts", attempts)
+	}
+}
+
+func TestIsReadyConditionsOutput(t *testing.T) {
+	tests := []struct {
+		output   string
+		expected bool
+	}{
+		{"", false},
+		{"  \n", false},
+		{"True", true},
+		{"True True\n", true},
+		{"True False", false},
+		{"Unknown", false},
+	}
+	for _, test := range tests {
+		if res := isReadyConditionsOutput(test.output); res != test.expected {
+			t.Fatalf("Error: unexpected result for %q: %t", test.output, res)
+		}
+	}
+}
+
+func TestCountReadyConditionsOutput(t *testing.T) {
+	if count := countReadyConditionsOutput("True\nFalse\nTrue\n"); count != 2 {
+		t.Fatalf("Error: unexpected count: %d", count)
+	}
+	if count := countReadyConditionsOutput(""); count != 0 {
+		t.Fatalf("Error: unexpected count: %d", count)
+	}
+}
+
+func TestIsKubectlNotFoundOutput(t *testing.T) {
+	tests := []struct {
+		output   string
+		expected bool
+	}{
+		{"", false},
+		{"node/worker-1 cordoned\n", false},
+		{"node/worker-1 already cordoned\n", false},
+		{`Error from server (NotFound): nodes "worker-1" not found`, true},
+	}
+	for _, test := range tests {
+		if res := isKubectlNotFoundOutput(test.output); res != test.expected {
+			t.Fatalf("Error: unexpected result for %q: %t", test.output, res)
+		}
+	}
+}
+
+func TestIsKubectlTransientOutput(t *testing.T) {
+	tests := []struct {
+		output   string
+		expected bool
+	}{
+		{"", false},
+		{"deployment.apps/coredns configured\n", false},
+		{`Error from server (NotFound): nodes "worker-1" not found`, false},
+		{"The connection to the server 10.0.0.1:6443 was refused - did you specify the right host or port?\n" +
+			"dial tcp 10.0.0.1:6443: connect: connection refused", true},
+		{"Error from server (ServiceUnavailable): the server is currently unable to handle the request", true},
+		{"Error from server: etcdserver: leader changed", true},
+	}
+	for _, test := range tests {
+		if res := isKubectlTransientOutput(test.output); res != test.expected {
+			t.Fatalf("Error: unexpected result for %q: %t", test.output, res)
+		}
+	}
+}
+
+func TestCheckNodenameConflict(t *testing.T) {
+	testsCases := []struct {
+		local    string
+		remote   string
+		conflict bool
+	}{
+		{"bf38f8ac633e4f64a4924b0ed7b25946", "", false},
+		{"bf38f8ac633e4f64a4924b0ed7b25946", "bf38f8ac633e4f64a4924b0ed7b25946", false},
+		{"bf38f8ac633e4f64a4924b0ed7b25946\n", "bf38f8ac633e4f64a4924b0ed7b25946", false},
+		{"bf38f8ac633e4f64a4924b0ed7b25946", "0d0a2b2c1e2f4e8b9c1d2e3f4a5b6c7d", true},
+	}
+
+	for _, testCase := range testsCases {
+		err := checkNodenameConflict("node-0", testCase.local, testCase.remote)
+		if (err != nil) != testCase.conflict {
+			t.Fatalf("Error: unexpected result for %q/%q: %v", testCase.local, testCase.remote, err)
+		}
+	}
+}
+
+func TestGetDrainArgs(t *testing.T) {
+	testsCases := []struct {
+		opts     drainOptions
+		expected string
+	}{
+		{
+			drainOptions{Force: true},
+			"drain --delete-local-data=true --force=true --ignore-daemonsets=true node-1",
+		},
+		{
+			drainOptions{Force: true, Timeout: 5 * time.Minute},
+			"drain --delete-local-data=true --force=true --ignore-daemonsets=true --timeout=5m0s node-1",
+		},
+		{
+			drainOptions{PodSelector: "!example.com/keep", DisableEviction: true},
+			"drain --delete-local-data=true --force=false --ignore-daemonsets=true --pod-selector='!example.com/keep' --disable-eviction=true node-1",
+		},
+		{
+			drainOptions{PodSelector: "app in (web, api)"},
+			"drain --delete-local-data=true --force=false --ignore-daemonsets=true --pod-selector='app in (web, api)' node-1",
+		},
+	}
+
+	for _, testCase := range testsCases {
+		args := strings.Join(getDrainArgs("node-1", testCase.opts), " ")
+		if args != testCase.expected {
+			t.Fatalf("Error: unexpected drain args: %q", args)
+		}
+	}
+}
+
+func TestParseBlockingPDBs(t *testing.T) {
+	testsCases := []struct {
+		pdbs     string
+		pods     string
+		expected []string
+	}{
+		{"", "", []string{}},
+		{
+			"'web/web-pdb\t{\"matchLabels\":{\"app\":\"web\"}}\ndefault/db\t{\"matchExpressions\":[{\"key\":\"app\",\"operator\":\"In\",\"values\":[\"db\",\"cache\"]}]}\n'",
+			"'web\t{\"app\":\"web\",\"tier\":\"front\"}\ndefault\t{\"app\":\"db\"}\n'",
+			[]string{"default/db", "web/web-pdb"},
+		},
+		{
+			// PDBs for pods in other nodes/namespaces, or without selector, are ignored
+			"web/web-pdb\t{\"matchLabels\":{\"app\":\"web\"}}\r\nother/web-pdb\t{\"matchLabels\":{\"app\":\"api\"}}\r\nkube-system/empty\t\r\n",
+			"other\t{\"app\":\"web\"}\r\nkube-system\t\r\nkube-system\t{\"k8s-app\":\"kube-dns\"}\r\n",
+			[]string{},
+		},
+		{
+			// an empty selector selects all the pods in the namespace
+			"kube-system/all\t{}\n",
+			"kube-system\t{\"k8s-app\":\"kube-dns\"}\n",
+			[]string{"kube-system/all"},
+		},
+	}
+
+	for _, testCase := range testsCases {
+		pdbs, err := parseBlockingPDBs(testCase.pdbs, testCase.pods)
+		if err != nil {
+			t.Fatalf("Error: could not parse %q: %s", testCase.pdbs, err)
+		}
+		if !reflect.DeepEqual(pdbs, testCase.expected) {
+			t.Fatalf("Error: unexpected PDBs for %q: %v", testCase.pdbs, pdbs)
+		}
+	}
+}
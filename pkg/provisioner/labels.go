@@ -0,0 +1,56 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"context"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+)
+
+// getLabelArg returns a label ("key[=value]") as an argument for `kubectl label`
+func getLabelArg(label string) string {
+	if !strings.Contains(label, "=") {
+		return label + "="
+	}
+	return label
+}
+
+// doSetControlPlaneLabel sets the custom "control_plane_label" in this (control plane) node.
+// The label is overwritten, so running it again is safe.
+func doSetControlPlaneLabel(d *schema.ResourceData) ssh.Action {
+	labelOpt, ok := d.GetOk("config.control_plane_label")
+	if !ok || labelOpt.(string) == "" {
+		return nil
+	}
+	label := getLabelArg(labelOpt.(string))
+
+	localKubeNode := ssh.KubeNode{}
+	return ssh.ActionList{
+		DoGetNodename(d, &localKubeNode),
+		ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+			if localKubeNode.IsEmpty() {
+				return ssh.ActionError("could not find the Kubernetes nodename for setting the control plane label")
+			}
+			return ssh.ActionList{
+				ssh.DoMessageInfo("Setting the %q label in node %q", label, localKubeNode.Nodename),
+				doKubectl(d, "label", "node", localKubeNode.Nodename, label, "--overwrite"),
+			}
+		}),
+	}
+}
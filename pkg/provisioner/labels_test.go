@@ -0,0 +1,36 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"testing"
+)
+
+func TestGetLabelArg(t *testing.T) {
+	testsCases := []struct {
+		label    string
+		expected string
+	}{
+		{"example.com/role=control-plane", "example.com/role=control-plane"},
+		{"node-role.kubernetes.io/infra", "node-role.kubernetes.io/infra="},
+		{"node-role.kubernetes.io/infra=", "node-role.kubernetes.io/infra="},
+	}
+
+	for _, testCase := range testsCases {
+		if arg := getLabelArg(testCase.label); arg != testCase.expected {
+			t.Fatalf("Error: unexpected label arg for %q: %q", testCase.label, arg)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+const (
+	// defaultDenyPolicyTemplate is a NetworkPolicy that denies all the traffic
+	// of some type (Ingress or Egress) for all the pods in a namespace
+	defaultDenyPolicyTemplate = `---
+apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: default-deny-%s
+  namespace: %s
+spec:
+  podSelector: {}
+  policyTypes:
+  - %s
+`
+
+	// interval between checks of the CNI plugin
+	cniReadyInterval = 10 * time.Second
+
+	// max time we wait for the CNI plugin to be ready
+	cniReadyTimeout = 5 * time.Minute
+)
+
+// getNetworkPolicyNamespaces returns the namespaces for some config key (a comma-separated list)
+func getNetworkPolicyNamespaces(d *schema.ResourceData, key string) []string {
+	namespaces := []string{}
+	if namespacesOpt, ok := d.GetOk(key); ok {
+		for _, namespace := range strings.Split(namespacesOpt.(string), ",") {
+			if namespace = strings.TrimSpace(namespace); namespace != "" {
+				namespaces = append(namespaces, namespace)
+			}
+		}
+	}
+	return namespaces
+}
+
+// getDefaultDenyManifest returns a manifest with the default-deny NetworkPolicies
+// for the ingress and the egress traffic in some namespaces
+func getDefaultDenyManifest(denyIngress []string, denyEgress []string) string {
+	var sb strings.Builder
+	for _, namespace := range denyIngress {
+		sb.WriteString(fmt.Sprintf(defaultDenyPolicyTemplate, "ingress", namespace, "Ingress"))
+	}
+	for _, namespace := range denyEgress {
+		sb.WriteString(fmt.Sprintf(defaultDenyPolicyTemplate, "egress", namespace, "Egress"))
+	}
+	return sb.String()
+}
+
+// doLoadNetworkPolicies loads the default NetworkPolicies baseline (if any), once
+// the CNI plugin is ready (when we know how to check it)
+func doLoadNetworkPolicies(d *schema.ResourceData) ssh.Action {
+	denyIngress := getNetworkPolicyNamespaces(d, "config.network_policy_deny_ingress")
+	denyEgress := getNetworkPolicyNamespaces(d, "config.network_policy_deny_egress")
+	if len(denyIngress) == 0 && len(denyEgress) == 0 {
+		return nil
+	}
+
+	actions := ssh.ActionList{}
+
+	plugin := getCNIPluginFromResourceData(d)
+	supported, known := common.CNIPluginsNetworkPolicies[plugin]
+	switch {
+	case !known:
+		actions = append(actions,
+			ssh.DoMessageWarn("could not verify the CNI plugin supports NetworkPolicies: they will be ignored if it does not"))
+	case !supported:
+		return ssh.DoAbort("the %q CNI plugin does not support NetworkPolicies", plugin)
+	}
+
+	if _, ok := cniDaemonSetsSelectors[plugin]; ok {
+		checkReady := ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+			if ok, _ := checkCNIHealthy(d).Check(ctx); !ok {
+				return ssh.ActionError(fmt.Sprintf("the %q CNI plugin is not ready", plugin))
+			}
+			return nil
+		})
+
+		actions = append(actions,
+			ssh.DoMessageInfo("Waiting for the CNI plugin to be ready..."),
+			ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+				res := doPoll(cniReadyTimeout, cniReadyInterval, checkReady).Apply(ctx)
+				if ssh.IsError(res) {
+					return ssh.ActionError(fmt.Sprintf("timeout after %s waiting for the CNI plugin: %s", cniReadyTimeout, res.Error()))
+				}
+				return res
+			}))
+	}
+
+	manifest := ssh.Manifest{Inline: getDefaultDenyManifest(denyIngress, denyEgress)}
+	return append(actions,
+		ssh.DoMessageInfo("Loading the default NetworkPolicies (deny ingress in: %q, deny egress in: %q)", denyIngress, denyEgress),
+		doRemoteKubectlApply(d, []ssh.Manifest{manifest}))
+}
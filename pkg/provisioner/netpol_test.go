@@ -0,0 +1,42 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGetDefaultDenyManifest(t *testing.T) {
+	manifest := getDefaultDenyManifest([]string{"default", "apps"}, []string{"apps"})
+
+	if c := strings.Count(manifest, "kind: NetworkPolicy"); c != 3 {
+		t.Fatalf("Error: unexpected number of NetworkPolicies (%d) in manifest:\n%s", c, manifest)
+	}
+	for _, expected := range []string{
+		"name: default-deny-ingress\n  namespace: default\n",
+		"name: default-deny-ingress\n  namespace: apps\n",
+		"name: default-deny-egress\n  namespace: apps\n",
+		"policyTypes:\n  - Egress\n",
+	} {
+		if !strings.Contains(manifest, expected) {
+			t.Fatalf("Error: %q not found in manifest:\n%s", expected, manifest)
+		}
+	}
+
+	if manifest := getDefaultDenyManifest(nil, nil); manifest != "" {
+		t.Fatalf("Error: unexpected manifest for no namespaces:\n%s", manifest)
+	}
+}
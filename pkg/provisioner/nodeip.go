@@ -0,0 +1,283 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+const (
+	// value for the "node_ip" for detecting the node IP
+	nodeIPAuto = "auto"
+
+	// script for detecting the source address used for reaching some target
+	// (or the default route when no target is provided)
+	nodeIPDetectScript = `
+TARGET="%s"
+ADDR=
+if [ -n "$TARGET" ] ; then
+	ADDR=$(getent ahosts "$TARGET" 2>/dev/null | awk '{ print $1; exit }')
+	[ -n "$ADDR" ] || ADDR="$TARGET"
+fi
+[ -n "$ADDR" ] || ADDR="1.1.1.1"
+ip route get "$ADDR" | sed -n 's/.* src \([^ ]*\).*/\1/p' | head -1
+`
+
+	// command for getting all the addresses in the node
+	nodeAddressesCmd = `ip -o addr show | awk '{ print $4 }' | cut -d/ -f1`
+
+	// command for resolving a hostname in the node (printing the unique addresses, in order)
+	resolveHostCmd = `getent ahosts %s | awk '!seen[$1]++ { print $1 }'`
+)
+
+// getNodeIPDetectTarget returns the host we want to reach from the node
+// when detecting the node IP: the seeder (when joining) or the control plane endpoint
+func getNodeIPDetectTarget(d *schema.ResourceData) string {
+	target := getJoinFromResourceData(d)
+	if target == "" {
+		if endpoint := getControlPlaneEndpointFromResourceData(d); endpoint != "" {
+			target = endpoint
+		}
+	}
+	if target == "" {
+		return ""
+	}
+	host, _, err := common.SplitHostPort(target, common.DefAPIServerPort)
+	if err != nil {
+		return target
+	}
+	return host
+}
+
+// checkNodeIPs checks that all the IPs in the (comma-separated) `nodeIP` are in the
+// list of addresses in the node (as returned by `nodeAddressesCmd`)
+func checkNodeIPs(nodeIP string, addresses string) error {
+	nodeAddresses := []net.IP{}
+	for _, s := range strings.Fields(addresses) {
+		if ip := net.ParseIP(s); ip != nil {
+			nodeAddresses = append(nodeAddresses, ip)
+		}
+	}
+
+	for _, s := range strings.Split(nodeIP, ",") {
+		ip := net.ParseIP(strings.TrimSpace(s))
+		if ip == nil {
+			return fmt.Errorf("%q is not a valid IP address", s)
+		}
+		found := false
+		for _, address := range nodeAddresses {
+			if address.Equal(ip) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s is not an address of this node", ip)
+		}
+	}
+	return nil
+}
+
+// setNodeIPInConfig sets the node IP in the kubelet `--node-ip` and, for control plane
+// nodes where no advertise address has been provided, in the API server advertise address
+func setNodeIPInConfig(d *schema.ResourceData, command string, nodeIP string) error {
+	advertise := strings.TrimSpace(strings.Split(nodeIP, ",")[0])
+
+	switch command {
+	case "init":
+		initConfig, _, err := common.InitConfigFromResourceData(d)
+		if err != nil {
+			return fmt.Errorf("could not get a valid 'config' for init'ing: %s", err)
+		}
+		if initConfig.NodeRegistration.KubeletExtraArgs == nil {
+			initConfig.NodeRegistration.KubeletExtraArgs = map[string]string{}
+		}
+		initConfig.NodeRegistration.KubeletExtraArgs["node-ip"] = nodeIP
+		if initConfig.LocalAPIEndpoint.AdvertiseAddress == "" {
+			initConfig.LocalAPIEndpoint.AdvertiseAddress = advertise
+		}
+		return common.InitConfigToResourceData(d, initConfig)
+
+	case "join":
+		joinConfig, _, err := common.JoinConfigFromResourceData(d)
+		if err != nil {
+			return fmt.Errorf("could not get a valid 'config' for join'ing: %s", err)
+		}
+		if joinConfig.NodeRegistration.KubeletExtraArgs == nil {
+			joinConfig.NodeRegistration.KubeletExtraArgs = map[string]string{}
+		}
+		joinConfig.NodeRegistration.KubeletExtraArgs["node-ip"] = nodeIP
+		if joinConfig.ControlPlane != nil && joinConfig.ControlPlane.LocalAPIEndpoint.AdvertiseAddress == "" {
+			joinConfig.ControlPlane.LocalAPIEndpoint.AdvertiseAddress = advertise
+		}
+		return common.JoinConfigToResourceData(d, joinConfig)
+	}
+	return fmt.Errorf("unknown kubeadm command %q", command)
+}
+
+// doSetNodeIP sets the IP used by the kubelet (and the API server in control plane nodes)
+// when "node_ip" has been provided, detecting it when "auto". The IP must be an address
+// of the node. The `command` can be "init" or "join".
+func doSetNodeIP(d *schema.ResourceData, command string) ssh.Action {
+	nodeIP := getNodeIPFromResourceData(d)
+	if nodeIP == "" {
+		return nil
+	}
+
+	return ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+		if nodeIP == nodeIPAuto {
+			target := getNodeIPDetectTarget(d)
+			script := fmt.Sprintf(nodeIPDetectScript, target)
+
+			var buf bytes.Buffer
+			if res := ssh.DoSendingExecOutputToWriter(ssh.DoExecScript([]byte(script)), &buf).Apply(ctx); ssh.IsError(res) {
+				return ssh.ActionError(fmt.Sprintf("could not detect the node IP: %s", res.Error()))
+			}
+			nodeIP = strings.TrimSpace(buf.String())
+			if net.ParseIP(nodeIP) == nil {
+				return ssh.ActionError(fmt.Sprintf("could not detect the node IP (got %q)", nodeIP))
+			}
+			if target != "" {
+				_ = ssh.DoMessageInfo("Detected node IP %s (used for reaching %s)", nodeIP, target).Apply(ctx)
+			} else {
+				_ = ssh.DoMessageInfo("Detected node IP %s (from the default route)", nodeIP).Apply(ctx)
+			}
+		}
+
+		var buf bytes.Buffer
+		if res := ssh.DoSendingExecOutputToWriter(ssh.DoExec(nodeAddressesCmd), &buf).Apply(ctx); ssh.IsError(res) {
+			return ssh.ActionError(fmt.Sprintf("could not get the addresses of this node: %s", res.Error()))
+		}
+		if err := checkNodeIPs(nodeIP, buf.String()); err != nil {
+			return ssh.ActionError(fmt.Sprintf("invalid 'node_ip': %s", err))
+		}
+
+		if err := setNodeIPInConfig(d, command, nodeIP); err != nil {
+			return ssh.ActionError(err.Error())
+		}
+		return ssh.DoMessageInfo("Using node IP %s", nodeIP)
+	})
+}
+
+// pickLocalAddress returns the first address in the `resolved` ones (as returned by
+// `resolveHostCmd`) that is an address of this node (as returned by `nodeAddressesCmd`)
+func pickLocalAddress(resolved string, addresses string) (string, error) {
+	candidates := strings.Fields(resolved)
+	if len(candidates) == 0 {
+		return "", fmt.Errorf("it could not be resolved")
+	}
+	for _, candidate := range candidates {
+		if net.ParseIP(candidate) == nil {
+			continue
+		}
+		if err := checkNodeIPs(candidate, addresses); err == nil {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("it resolves to %s, but none of them is an address of this node", strings.Join(candidates, ", "))
+}
+
+// getAdvertiseAddress returns the advertise address of the API server in the
+// configuration for the `command` ("init" or "join")
+func getAdvertiseAddress(d *schema.ResourceData, command string) (string, error) {
+	switch command {
+	case "init":
+		initConfig, _, err := common.InitConfigFromResourceData(d)
+		if err != nil {
+			return "", fmt.Errorf("could not get a valid 'config' for init'ing: %s", err)
+		}
+		return initConfig.LocalAPIEndpoint.AdvertiseAddress, nil
+
+	case "join":
+		joinConfig, _, err := common.JoinConfigFromResourceData(d)
+		if err != nil {
+			return "", fmt.Errorf("could not get a valid 'config' for join'ing: %s", err)
+		}
+		if joinConfig.ControlPlane == nil {
+			return "", nil
+		}
+		return joinConfig.ControlPlane.LocalAPIEndpoint.AdvertiseAddress, nil
+	}
+	return "", fmt.Errorf("unknown kubeadm command %q", command)
+}
+
+// setAdvertiseAddress sets the advertise address of the API server in the
+// configuration for the `command` ("init" or "join")
+func setAdvertiseAddress(d *schema.ResourceData, command string, address string) error {
+	switch command {
+	case "init":
+		initConfig, _, err := common.InitConfigFromResourceData(d)
+		if err != nil {
+			return fmt.Errorf("could not get a valid 'config' for init'ing: %s", err)
+		}
+		initConfig.LocalAPIEndpoint.AdvertiseAddress = address
+		return common.InitConfigToResourceData(d, initConfig)
+
+	case "join":
+		joinConfig, _, err := common.JoinConfigFromResourceData(d)
+		if err != nil {
+			return fmt.Errorf("could not get a valid 'config' for join'ing: %s", err)
+		}
+		if joinConfig.ControlPlane == nil {
+			return nil
+		}
+		joinConfig.ControlPlane.LocalAPIEndpoint.AdvertiseAddress = address
+		return common.JoinConfigToResourceData(d, joinConfig)
+	}
+	return fmt.Errorf("unknown kubeadm command %q", command)
+}
+
+// doResolveAdvertiseAddress resolves the API server advertise address in the node when it
+// is a hostname (kubeadm only accepts IPs), using the first address that is local to the node.
+// The `command` can be "init" or "join".
+func doResolveAdvertiseAddress(d *schema.ResourceData, command string) ssh.Action {
+	advertise, err := getAdvertiseAddress(d, command)
+	if err != nil {
+		return ssh.ActionError(err.Error())
+	}
+	if advertise == "" || net.ParseIP(advertise) != nil {
+		return nil
+	}
+
+	return ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+		var resolved bytes.Buffer
+		if res := ssh.DoSendingExecOutputToWriter(ssh.DoExec(fmt.Sprintf(resolveHostCmd, advertise)), &resolved).Apply(ctx); ssh.IsError(res) {
+			return ssh.ActionError(fmt.Sprintf("could not resolve the advertise address %q in the node: %s", advertise, res.Error()))
+		}
+
+		var addresses bytes.Buffer
+		if res := ssh.DoSendingExecOutputToWriter(ssh.DoExec(nodeAddressesCmd), &addresses).Apply(ctx); ssh.IsError(res) {
+			return ssh.ActionError(fmt.Sprintf("could not get the addresses of this node: %s", res.Error()))
+		}
+
+		ip, err := pickLocalAddress(resolved.String(), addresses.String())
+		if err != nil {
+			return ssh.ActionError(fmt.Sprintf("invalid advertise address %q: %s", advertise, err))
+		}
+		if err := setAdvertiseAddress(d, command, ip); err != nil {
+			return ssh.ActionError(err.Error())
+		}
+		return ssh.DoMessageInfo("Resolved the API server advertise address %s to %s", advertise, ip)
+	})
+}
@@ -0,0 +1,80 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"testing"
+)
+
+func TestCheckNodeIPs(t *testing.T) {
+	addresses := `127.0.0.1
+10.0.0.5
+::1
+fd00::5
+`
+	testsCases := []struct {
+		nodeIP      string
+		expectedErr bool
+	}{
+		{"10.0.0.5", false},
+		{"10.0.0.5,fd00::5", false},
+		{"10.0.0.6", true},
+		{"10.0.0.5,fd00::6", true},
+		{"not-an-ip", true},
+	}
+
+	for _, testCase := range testsCases {
+		err := checkNodeIPs(testCase.nodeIP, addresses)
+		if testCase.expectedErr && err == nil {
+			t.Fatalf("Error: expected an error for %q", testCase.nodeIP)
+		}
+		if !testCase.expectedErr && err != nil {
+			t.Fatalf("Error: unexpected error for %q: %s", testCase.nodeIP, err)
+		}
+	}
+}
+
+func TestPickLocalAddress(t *testing.T) {
+	addresses := `127.0.0.1
+10.0.0.5
+fd00::5
+`
+	testsCases := []struct {
+		resolved    string
+		expected    string
+		expectedErr bool
+	}{
+		{"10.0.0.5\n", "10.0.0.5", false},
+		{"192.168.1.1\nfd00::5\n10.0.0.5\n", "fd00::5", false},
+		{"192.168.1.1\n", "", true},
+		{"", "", true},
+	}
+
+	for _, testCase := range testsCases {
+		res, err := pickLocalAddress(testCase.resolved, addresses)
+		if testCase.expectedErr {
+			if err == nil {
+				t.Fatalf("Error: expected an error for %q", testCase.resolved)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Error: unexpected error for %q: %s", testCase.resolved, err)
+		}
+		if res != testCase.expected {
+			t.Fatalf("Error: expected %q for %q, got %q", testCase.expected, testCase.resolved, res)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+const (
+	// restrict the permissions only when some hardening preset is used
+	restrictPermissionsAuto = "auto"
+
+	// default etcd data directory
+	defEtcdDataDir = "/var/lib/etcd"
+
+	// script for restricting the permissions of the kubernetes files (CIS 1.1.x and 4.1.x).
+	// Files are only changed when they are more permissive (or not owned by root), so
+	// files with more restrictive permissions are kept.
+	restrictPermissionsScript = `#!/bin/sh
+PKI_DIR="%[1]s"
+DROPIN="%[2]s"
+ETCD_DATA_DIR="%[3]s"
+CHANGED=0
+
+restrict() {
+    MODE="$1" ; shift
+    for f in "$@" ; do
+        [ -e "$f" ] || continue
+        CURRENT="$(stat -c %%a "$f")"
+        if [ $(( 0$CURRENT & ~0$MODE )) -ne 0 ] ; then
+            chmod "$MODE" "$f" && CHANGED=$((CHANGED + 1))
+        fi
+        if [ "$(stat -c %%U:%%G "$f")" != "root:root" ] ; then
+            chown root:root "$f" && CHANGED=$((CHANGED + 1))
+        fi
+    done
+}
+
+restrict 600 /etc/kubernetes/*.conf /etc/kubernetes/manifests/*.yaml /var/lib/kubelet/config.yaml "$DROPIN"
+[ -d "$PKI_DIR" ] && restrict 600 $(find "$PKI_DIR" -type f)
+[ -d "$PKI_DIR" ] && restrict 755 $(find "$PKI_DIR" -type d)
+[ -d "$ETCD_DATA_DIR" ] && chmod 700 "$ETCD_DATA_DIR"
+
+echo "$CHANGED permissions/ownerships restricted"
+`
+)
+
+var (
+	// valid values for the "restrict_permissions"
+	restrictPermissionsModes = []string{restrictPermissionsAuto, "true", "false"}
+)
+
+// isRestrictPermissionsEnabled returns true if the permissions of the kubernetes files
+// must be restricted: when "restrict_permissions" is "true", or "auto" with some "hardening" preset
+func isRestrictPermissionsEnabled(d *schema.ResourceData) bool {
+	mode := getRestrictPermissionsFromResourceData(d)
+	if mode == restrictPermissionsAuto {
+		hardeningOpt, ok := d.GetOk("config.hardening")
+		return ok && hardeningOpt.(string) != ""
+	}
+	restrict, _ := strconv.ParseBool(mode)
+	return restrict
+}
+
+// doRestrictPermissions sets restrictive permissions (and root ownership) in the kubeconfig
+// files, the static pods manifests, the PKI files and the kubelet configuration
+func doRestrictPermissions(d *schema.ResourceData) ssh.Action {
+	if !isRestrictPermissionsEnabled(d) {
+		return nil
+	}
+
+	certsDir := common.DefPKIDir
+	if certsDirOpt, ok := d.GetOk("config.certs_dir"); ok && certsDirOpt.(string) != "" {
+		certsDir = certsDirOpt.(string)
+	}
+	etcdDataDir := defEtcdDataDir
+	if initConfig, _, err := common.InitConfigFromResourceData(d); err == nil {
+		if initConfig.Etcd.Local != nil && initConfig.Etcd.Local.DataDir != "" {
+			etcdDataDir = initConfig.Etcd.Local.DataDir
+		}
+	}
+
+	script := fmt.Sprintf(restrictPermissionsScript, certsDir, getDropinPathFromResourceData(d), etcdDataDir)
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Restricting the permissions of the kubernetes files..."),
+		ssh.DoExecScript([]byte(script)),
+	}
+}
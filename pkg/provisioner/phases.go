@@ -0,0 +1,67 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+// doKubeadmInitPhase runs a single `kubeadm init phase` (ie, "upload-config" or "addon/coredns")
+// with the init configuration, so some part of the init can be repeated without a full init
+func doKubeadmInitPhase(d *schema.ResourceData, phase string, args ...string) ssh.Action {
+	kubeadmConfigFilename := common.DefKubeadmInitConfPath
+	allArgs := append([]string{fmt.Sprintf("--config=%s", kubeadmConfigFilename)}, args...)
+
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Running 'kubeadm init phase %s'...", strings.TrimSpace(phase+" "+strings.Join(args, " "))),
+		ssh.DoWithCleanup(
+			ssh.ActionList{
+				doUploadKubeadmConfig(d, "init", kubeadmConfigFilename),
+				doExecKubeadmWithConfig(d, "init phase "+phase, "", allArgs...),
+			},
+			doCleanupKubeadmConfig(d, kubeadmConfigFilename)),
+	}
+}
+
+// doKubeadmInitPhases runs some `kubeadm init` phases (with optional flags, ie,
+// "upload-certs --upload-certs") instead of a full `kubeadm init`
+func doKubeadmInitPhases(d *schema.ResourceData, phases []string) ssh.Action {
+	actions := ssh.ActionList{
+		ssh.DoMessageInfo("Running only some 'kubeadm init' phases: %s", strings.Join(phases, ", ")),
+		// some phases (ie, "certs" or "control-plane") need the certificates and configuration files
+		doUploadCerts(d),
+		doUploadAuditConfig(d),
+		doUploadEgressSelectorConfig(d),
+		doUploadSchedulerConfig(d),
+		doUploadEtcdPatch(d),
+	}
+	for _, p := range phases {
+		phase, args, err := common.ParseInitPhase(p)
+		if err != nil {
+			return ssh.ActionError(err.Error())
+		}
+		if strings.HasPrefix(phase, "etcd") {
+			args = append(args, getKubeadmPatchesArgs(d)...)
+		}
+		actions = append(actions, doKubeadmInitPhase(d, phase, args...))
+	}
+	return actions
+}
@@ -0,0 +1,152 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+var (
+	// pkiFiles is the list of files expected in a pre-generated PKI tree
+	pkiFiles = []string{
+		"ca.crt",
+		"ca.key",
+		"apiserver.crt",
+		"apiserver.key",
+		"apiserver-kubelet-client.crt",
+		"apiserver-kubelet-client.key",
+		"front-proxy-ca.crt",
+		"front-proxy-ca.key",
+		"front-proxy-client.crt",
+		"front-proxy-client.key",
+		"sa.key",
+		"sa.pub",
+	}
+
+	// pkiLocalEtcdFiles are the files also expected when using a local etcd
+	pkiLocalEtcdFiles = []string{
+		"apiserver-etcd-client.crt",
+		"apiserver-etcd-client.key",
+		"etcd/ca.crt",
+		"etcd/ca.key",
+		"etcd/server.crt",
+		"etcd/server.key",
+		"etcd/peer.crt",
+		"etcd/peer.key",
+		"etcd/healthcheck-client.crt",
+		"etcd/healthcheck-client.key",
+	}
+
+	// pkiSharedFiles are the files shared by all the control plane nodes: the other
+	// certificates are specific to each node and are generated by `kubeadm join`
+	pkiSharedFiles = []string{
+		"ca.crt",
+		"ca.key",
+		"front-proxy-ca.crt",
+		"front-proxy-ca.key",
+		"sa.key",
+		"sa.pub",
+	}
+
+	// pkiLocalEtcdSharedFiles are the shared files also needed when using a local etcd
+	pkiLocalEtcdSharedFiles = []string{
+		"etcd/ca.crt",
+		"etcd/ca.key",
+	}
+)
+
+// checkPKIDir checks that a local PKI directory contains all the files expected by kubeadm
+// and that its CA is the same CA used in our cluster (when known)
+func checkPKIDir(dir string, localEtcd bool, caCrt string) error {
+	expected := append([]string{}, pkiFiles...)
+	if localEtcd {
+		expected = append(expected, pkiLocalEtcdFiles...)
+	}
+
+	missing := []string{}
+	for _, f := range expected {
+		info, err := os.Stat(filepath.Join(dir, filepath.FromSlash(f)))
+		if err != nil || info.IsDir() {
+			missing = append(missing, f)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing files in %q: %s", dir, strings.Join(missing, ", "))
+	}
+
+	if caCrt != "" {
+		contents, err := ioutil.ReadFile(filepath.Join(dir, "ca.crt"))
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(bytes.TrimSpace(contents), bytes.TrimSpace([]byte(caCrt))) {
+			return fmt.Errorf("the CA in %q is not the cluster CA: use the same CA in the 'certs' block in the 'kubeadm' resource", dir)
+		}
+	}
+	return nil
+}
+
+// doUploadPKIDir uploads the pre-generated PKI tree in "pki_dir" to the certificates directory.
+// When joining the control plane, only the files shared by all the control plane nodes are uploaded.
+func doUploadPKIDir(d *schema.ResourceData, certsConfig *common.CertsConfig) ssh.Action {
+	pkiDir := getPKIDirFromResourceData(d)
+
+	initConfig, _, err := common.InitConfigFromResourceData(d)
+	if err != nil {
+		return ssh.ActionError(fmt.Sprintf("could not get a valid 'config': %s", err))
+	}
+	localEtcd := initConfig.Etcd.External == nil
+
+	if err := checkPKIDir(pkiDir, localEtcd, certsConfig.CaCrt); err != nil {
+		return ssh.ActionError(fmt.Sprintf("invalid 'pki_dir': %s", err))
+	}
+
+	certsDir := common.DefPKIDir
+	if certsDirRaw, ok := d.GetOk("config.certs_dir"); ok {
+		certsDir = certsDirRaw.(string)
+	}
+
+	if getJoinFromResourceData(d) == "" {
+		return ssh.ActionList{
+			ssh.DoMessageInfo("Uploading the pre-generated PKI tree from %q...", pkiDir),
+			ssh.DoUploadDirToDir(pkiDir, certsDir),
+			ssh.DoExec(fmt.Sprintf("find %s -name '*.key' -exec chmod 600 {} +", certsDir)),
+		}
+	}
+
+	shared := append([]string{}, pkiSharedFiles...)
+	if localEtcd {
+		shared = append(shared, pkiLocalEtcdSharedFiles...)
+	}
+
+	actions := ssh.ActionList{
+		ssh.DoMessageInfo("Uploading the shared certificates and keys from the pre-generated PKI tree in %q...", pkiDir),
+	}
+	for _, f := range shared {
+		actions = append(actions, ssh.DoUploadFileToFile(filepath.Join(pkiDir, filepath.FromSlash(f)), path.Join(certsDir, f)))
+	}
+	return append(actions, ssh.DoExec(fmt.Sprintf("find %s -name '*.key' -exec chmod 600 {} +", certsDir)))
+}
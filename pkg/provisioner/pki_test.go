@@ -0,0 +1,46 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckPKIDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pki")
+	if err != nil {
+		t.Fatalf("Error: could not create temporary directory: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, f := range pkiFiles {
+		if err := ioutil.WriteFile(filepath.Join(dir, f), []byte("some-ca"), 0600); err != nil {
+			t.Fatalf("Error: could not write %q: %s", f, err)
+		}
+	}
+
+	if err := checkPKIDir(dir, false, "some-ca\n"); err != nil {
+		t.Fatalf("Error: unexpected error: %s", err)
+	}
+	if err := checkPKIDir(dir, false, "other-ca"); err == nil {
+		t.Fatalf("Error: different CA not detected")
+	}
+	if err := checkPKIDir(dir, true, ""); err == nil {
+		t.Fatalf("Error: missing etcd files not detected")
+	}
+}
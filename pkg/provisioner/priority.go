@@ -0,0 +1,113 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+)
+
+const (
+	// priority class for addons that must run in all the nodes (ie, the CNI)
+	nodeCriticalPriorityClass = "system-node-critical"
+
+	// priority class for addons that are critical for the cluster (ie, the DNS)
+	clusterCriticalPriorityClass = "system-cluster-critical"
+
+	// command for getting the "namespace name priorityClassName" of some objects
+	kubectlGetPriorityClassCmd = `get %s --all-namespaces -l '%s' -o=jsonpath='{range .items[*]}{.metadata.namespace}{" "}{.metadata.name}{" "}{.spec.template.spec.priorityClassName}{"\n"}{end}'`
+
+	// patch for setting the priority class in a DaemonSet or Deployment
+	priorityClassPatch = `{"spec":{"template":{"spec":{"priorityClassName":"%s"}}}}`
+)
+
+// criticalAddon is some addon whose pods must not be evicted before the workloads
+type criticalAddon struct {
+	Name          string
+	Kind          string
+	Selector      string
+	PriorityClass string
+}
+
+// getCriticalAddons returns the critical addons loaded in the cluster
+func getCriticalAddons(d *schema.ResourceData) []criticalAddon {
+	addons := []criticalAddon{}
+	if plugin := getCNIPluginFromResourceData(d); plugin != "" {
+		if selector, ok := cniDaemonSetsSelectors[plugin]; ok {
+			addons = append(addons, criticalAddon{plugin, "daemonsets", selector, nodeCriticalPriorityClass})
+		}
+	}
+	if enabledOpt, ok := d.GetOk("config.multus_enabled"); ok {
+		if enabled, _ := strconv.ParseBool(enabledOpt.(string)); enabled {
+			addons = append(addons, criticalAddon{"multus", "daemonsets", multusDaemonSetSelector, nodeCriticalPriorityClass})
+		}
+	}
+	addons = append(addons, criticalAddon{"coredns", "deployments", "k8s-app=" + coreDNSAppLabel, clusterCriticalPriorityClass})
+	return addons
+}
+
+// getObjectsWithoutPriorityClass returns the "namespace/name" of the objects in the
+// output of `kubectlGetPriorityClassCmd` that do not have a priority class
+func getObjectsWithoutPriorityClass(output string) []string {
+	objects := []string{}
+	for _, line := range strings.Split(output, "\n") {
+		if fields := strings.Fields(line); len(fields) == 2 {
+			objects = append(objects, fields[0]+"/"+fields[1])
+		}
+	}
+	return objects
+}
+
+// doSetCriticalAddonsPriority sets the `system-node-critical`/`system-cluster-critical`
+// priority classes in the critical addons (the CNI, Multus and CoreDNS), so they
+// are not evicted before the workloads. Objects with some priority class are not changed.
+func doSetCriticalAddonsPriority(d *schema.ResourceData) ssh.Action {
+	enabledOpt, ok := d.GetOk("config.critical_addons_priority")
+	if !ok {
+		return nil
+	}
+	if enabled, _ := strconv.ParseBool(enabledOpt.(string)); !enabled {
+		return nil
+	}
+
+	actions := ssh.ActionList{
+		ssh.DoMessageInfo("Setting the priority class of the critical addons..."),
+	}
+	for _, addon := range getCriticalAddons(d) {
+		addon := addon
+		var buf bytes.Buffer
+		actions = append(actions,
+			doKubectlWithOutput(d, &buf, fmt.Sprintf(kubectlGetPriorityClassCmd, addon.Kind, addon.Selector)),
+			ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+				patches := ssh.ActionList{}
+				for _, object := range getObjectsWithoutPriorityClass(buf.String()) {
+					parts := strings.SplitN(object, "/", 2)
+					patches = append(patches,
+						ssh.DoMessageInfo("Setting priority class %q in %s", addon.PriorityClass, object),
+						doKubectl(d, fmt.Sprintf("-n %s patch %s %s -p '%s'",
+							parts[0], addon.Kind, parts[1], fmt.Sprintf(priorityClassPatch, addon.PriorityClass))))
+				}
+				return patches
+			}))
+	}
+	return actions
+}
@@ -0,0 +1,34 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetObjectsWithoutPriorityClass(t *testing.T) {
+	output := `kube-system kube-flannel-ds-amd64 
+kube-system kube-flannel-ds-arm64 system-node-critical
+kube-flannel kube-flannel-ds
+`
+	expected := []string{"kube-system/kube-flannel-ds-amd64", "kube-flannel/kube-flannel-ds"}
+	if res := getObjectsWithoutPriorityClass(output); !reflect.DeepEqual(res, expected) {
+		t.Fatalf("Error: unexpected objects: %v", res)
+	}
+	if res := getObjectsWithoutPriorityClass(""); len(res) != 0 {
+		t.Fatalf("Error: unexpected objects: %v", res)
+	}
+}
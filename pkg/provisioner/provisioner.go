@@ -54,11 +54,11 @@ func applyFn(ctx context.Context) error {
 		return fmt.Errorf("Unsupported connection type: %s. This provisioner currently only supports linux", s.Ephemeral.ConnInfo["type"])
 	}
 
-	preventSudo := d.Get("prevent_sudo").(bool)
-	useSudo := !preventSudo && s.Ephemeral.ConnInfo["user"] != "root"
+	sudoMode := getSudoModeFromResourceData(d)
+	useSudo := sudoMode == ssh.SudoAlways || (sudoMode == ssh.SudoAuto && s.Ephemeral.ConnInfo["user"] != "root")
 
 	// build a communicator for the provisioner to use
-	comm, err := getCommunicator(ctx, o, s)
+	comm, err := getCommunicator(ctx, o, s, getConnectionRetryFromResourceData(d))
 	if err != nil {
 		o.Output("Error when creating communicator")
 		return err
@@ -67,15 +67,26 @@ func applyFn(ctx context.Context) error {
 	// add some extra things to the context
 	newCtx := ssh.WithValues(ctx, o, o, comm, useSudo)
 
+	// in "auto" mode, detect if we can (and must) use sudo in the remote machine
+	if sudoMode == ssh.SudoAuto {
+		if res := ssh.DoDetectSudo().Apply(newCtx); ssh.IsError(res) {
+			return fmt.Errorf("%s", res.Error())
+		}
+	}
+
 	//
 	// resource destruction
 	//
 
 	drain := d.Get("drain").(bool)
-	if drain {
+	if drain || getResetOnlyFromResourceData(d) {
 		ssh.Debug("node will be drained")
-		action := doRemoveNode(d)
-		return action.Apply(newCtx)
+		return ssh.DoWithCleanup(
+			ssh.ActionList{
+				ssh.DoSetupTmpDir(getRemoteTmpDirFromResourceData(d)),
+				doRemoveNode(d),
+			},
+			doCleanupSensitiveFiles(d)).Apply(newCtx)
 	}
 
 	//
@@ -103,7 +114,11 @@ func applyFn(ctx context.Context) error {
 	actions = append(actions,
 		ssh.DoMessageInfo("Checking we have the required binaries..."),
 		doCheckCommonBinaries(d),
-		doPrepareCRI(),
+		doCheckKernelRequirements(d),
+		doWarnIgnoredChecks(d),
+		doPrepareCRI(d),
+		doCheckContainerRuntime(d),
+		doRebootIfNeeded(d),
 		doUploadResolvConf(d),
 		ssh.DoEnableService("kubelet.service"),
 		ssh.DoUploadBytesToFile([]byte(assets.KubeletSysconfigCode), getSysconfigPathFromResourceData(d)),
@@ -113,18 +128,23 @@ func applyFn(ctx context.Context) error {
 
 	if len(join) == 0 {
 		switch role {
-		case "worker":
+		case roleWorker:
 			actions = append(actions, ssh.ActionError(fmt.Sprintf("role is %q while no \"join\" argument has been provided", role)))
 		default:
-			actions = append(actions, doKubeadmInit(d))
+			if phases := getInitPhasesFromResourceData(d); len(phases) > 0 {
+				actions = append(actions, doKubeadmInitPhases(d, phases))
+			} else {
+				actions = append(actions, doKubeadmInit(d))
+			}
 		}
 	} else {
+		if len(getInitPhasesFromResourceData(d)) > 0 {
+			actions = append(actions, ssh.ActionError("'init_phases' can only be used in the node that initializes the cluster (ie, without a 'join')"))
+		}
 		switch role {
-		case "master":
+		case roleMaster:
 			actions = append(actions, doKubeadmJoinControlPlane(d))
-		case "worker":
-			actions = append(actions, doKubeadmJoinWorker(d))
-		case "":
+		case roleWorker:
 			actions = append(actions, doKubeadmJoinWorker(d))
 		default:
 			actions = append(actions, ssh.ActionError(fmt.Sprintf("unknown provisioning profile: join is %q and role is %q", join, role)))
@@ -138,9 +158,15 @@ func applyFn(ctx context.Context) error {
 		doPrintEtcdStatus(d),
 	)
 
+	// note: the remote temporary directory (with all the certificates, tokens and
+	//       so on that could be there) is removed even on failure (but after
+	//       collecting the diagnostics bundle)
 	return ssh.ActionList{
 		ssh.DoWithCleanup(
-			actions,
-			ssh.DoCleanupLeftovers()),
+			ssh.ActionList{
+				ssh.DoSetupTmpDir(getRemoteTmpDirFromResourceData(d)),
+				ssh.DoWithException(actions, doCollectDiagnostics(d)),
+			},
+			doCleanupSensitiveFiles(d)),
 	}.Apply(newCtx)
 }
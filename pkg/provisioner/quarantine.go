@@ -0,0 +1,145 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/hashicorp/terraform/helper/schema"
+	v1 "k8s.io/api/core/v1"
+	kubeadmapi "k8s.io/kubernetes/cmd/kubeadm/app/apis/kubeadm"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+)
+
+const (
+	// command for getting the "Ready" condition of a node
+	kubectlGetNodeReadyCmd = `get node %s -o=jsonpath='{.status.conditions[?(@.type=="Ready")].status}'`
+
+	// interval between checks of the node readiness
+	nodeReadyInterval = 10 * time.Second
+
+	// max time we wait for a quarantined node to be ready
+	nodeReadyTimeout = 10 * time.Minute
+)
+
+// taintKeyRegexp matches a valid taint key (an optional DNS prefix and a name)
+var taintKeyRegexp = regexp.MustCompile(`^([a-z0-9]([-a-z0-9.]*[a-z0-9])?/)?[A-Za-z0-9]([-A-Za-z0-9_.]*[A-Za-z0-9])?$`)
+
+// getQuarantineTaint returns the quarantine taint, or nil when quarantine is disabled
+func getQuarantineTaint(d *schema.ResourceData) *v1.Taint {
+	if !d.Get("quarantine").(bool) {
+		return nil
+	}
+	return &v1.Taint{
+		Key:    d.Get("quarantine_taint").(string),
+		Effect: v1.TaintEffectNoSchedule,
+	}
+}
+
+// addQuarantineTaint adds the quarantine taint (if enabled) to the node registration,
+// so the node is tainted from the very beginning and nothing can be scheduled in it
+func addQuarantineTaint(d *schema.ResourceData, nodeRegistration *kubeadmapi.NodeRegistrationOptions) {
+	taint := getQuarantineTaint(d)
+	if taint == nil {
+		return
+	}
+	for _, t := range nodeRegistration.Taints {
+		if t.Key == taint.Key && t.Effect == taint.Effect {
+			return
+		}
+	}
+	nodeRegistration.Taints = append(nodeRegistration.Taints, *taint)
+}
+
+// doWaitNodeReady waits until a node is "Ready" (or a timeout expires)
+func doWaitNodeReady(d *schema.ResourceData, nodename string) ssh.Action {
+	checkReady := ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+		var buf bytes.Buffer
+		res := doKubectlWithOutput(d, &buf, fmt.Sprintf(kubectlGetNodeReadyCmd, nodename)).Apply(ctx)
+		if ssh.IsError(res) {
+			return res
+		}
+		if !isReadyConditionsOutput(buf.String()) {
+			return ssh.ActionError(fmt.Sprintf("node %q is not ready", nodename))
+		}
+		return nil
+	})
+
+	return ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+		res := doPoll(nodeReadyTimeout, nodeReadyInterval, checkReady).Apply(ctx)
+		if ssh.IsError(res) {
+			return ssh.ActionError(fmt.Sprintf("timeout after %s waiting for node %q to be ready: %s",
+				nodeReadyTimeout, nodename, res.Error()))
+		}
+		return res
+	})
+}
+
+// doReleaseQuarantine waits until the node is "Ready" and then removes the quarantine taint.
+// The taint is kept when the node does not become "Ready".
+func doReleaseQuarantine(d *schema.ResourceData) ssh.Action {
+	taint := getQuarantineTaint(d)
+	if taint == nil {
+		return nil
+	}
+
+	localKubeNode := ssh.KubeNode{}
+	return ssh.ActionList{
+		DoGetNodename(d, &localKubeNode),
+		ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+			if localKubeNode.IsEmpty() {
+				return ssh.ActionError("could not find Kubernetes nodename for this node: the quarantine taint cannot be removed")
+			}
+			nodename := localKubeNode.Nodename
+			return ssh.ActionList{
+				ssh.DoMessageInfo("Waiting for node %q to be ready before removing the quarantine taint...", nodename),
+				doWaitNodeReady(d, nodename),
+				doKubectl(d, "taint", "node", nodename, fmt.Sprintf("%s:%s-", taint.Key, taint.Effect)),
+				ssh.DoMessageInfo("Node %q is ready: quarantine taint %q removed", nodename, taint.Key),
+			}
+		}),
+	}
+}
+
+// doWithQuarantine runs the actions after joining (ie, all the steps run once the node
+// has been registered with the quarantine taint) and then releases the quarantine,
+// even when those actions fail
+func doWithQuarantine(d *schema.ResourceData, action ssh.Action) ssh.Action {
+	if getQuarantineTaint(d) == nil {
+		return action
+	}
+	return doWithRelease(action, doReleaseQuarantine(d))
+}
+
+// doWithRelease runs some actions and then the `release`, in any case, returning the
+// error of the actions (if any) or the result of the `release`
+func doWithRelease(action ssh.Action, release ssh.Action) ssh.Action {
+	return ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+		res := ssh.ActionList{action}.Apply(ctx)
+		released := ssh.ActionList{release}.Apply(ctx)
+		if ssh.IsError(res) {
+			if ssh.IsError(released) {
+				_ = ssh.DoMessageWarn("%s", released.Error()).Apply(ctx)
+			}
+			return res
+		}
+		return released
+	})
+}
@@ -0,0 +1,69 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"context"
+	"testing"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+)
+
+func TestTaintKeyRegexp(t *testing.T) {
+	tests := []struct {
+		key      string
+		expected bool
+	}{
+		{"not-ready", true},
+		{"example.com/not-ready", true},
+		{"kubeadm.terraform.io/not-ready", true},
+		{"", false},
+		{"-not-ready", false},
+		{"Example.com/not-ready", false},
+		{"example.com/", false},
+	}
+	for _, test := range tests {
+		if res := taintKeyRegexp.MatchString(test.key); res != test.expected {
+			t.Fatalf("Error: unexpected result for %q: %t", test.key, res)
+		}
+	}
+}
+
+func TestDoWithRelease(t *testing.T) {
+	released := false
+	release := ssh.ActionFunc(func(context.Context) ssh.Action {
+		released = true
+		return nil
+	})
+	laterStep := false
+	actions := ssh.ActionList{
+		ssh.ActionError("could not verify the join"),
+		ssh.ActionFunc(func(context.Context) ssh.Action {
+			laterStep = true
+			return nil
+		}),
+	}
+
+	res := doWithRelease(actions, release).Apply(ssh.NewTestingContext())
+	if !ssh.IsError(res) || res.Error() != "could not verify the join" {
+		t.Fatalf("Error: the error of the failing step has been lost: %v", res)
+	}
+	if laterStep {
+		t.Fatalf("Error: a step after the failing one has been run")
+	}
+	if !released {
+		t.Fatalf("Error: the quarantine has not been released after a failing step")
+	}
+}
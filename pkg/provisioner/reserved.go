@@ -0,0 +1,223 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+const (
+	// command for getting the number of CPUs and the total memory (in KiB) in the node
+	nodeResourcesCmd = `nproc && awk '/^MemTotal:/ { print $2 }' /proc/meminfo`
+
+	// ephemeral storage reserved for the kubernetes daemons (when scaling to the node size)
+	defKubeReservedEphemeralStorage = "1Gi"
+)
+
+var (
+	// resources reserved for the system daemons (when scaling to the node size)
+	defSystemReserved = map[string]string{
+		"cpu":    "100m",
+		"memory": "100Mi",
+	}
+
+	// fractions of the memory reserved for the kubernetes daemons,
+	// for each tier of the node memory (in MiB, with no limit for the last one)
+	kubeReservedMemoryTiers = []struct {
+		upTo     int64
+		fraction float64
+	}{
+		{4096, 0.25},
+		{8192, 0.20},
+		{16384, 0.10},
+		{131072, 0.06},
+		{0, 0.02},
+	}
+)
+
+// parseNodeResources parses the output of the `nodeResourcesCmd`, returning
+// the number of CPUs and the total memory (in MiB)
+func parseNodeResources(output string) (int, int64, error) {
+	fields := strings.Fields(output)
+	if len(fields) != 2 {
+		return 0, 0, fmt.Errorf("unexpected output when getting the node resources: %q", output)
+	}
+	cpus, err := strconv.Atoi(fields[0])
+	if err != nil || cpus <= 0 {
+		return 0, 0, fmt.Errorf("could not parse the number of CPUs in %q", fields[0])
+	}
+	memoryKiB, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil || memoryKiB <= 0 {
+		return 0, 0, fmt.Errorf("could not parse the total memory in %q", fields[1])
+	}
+	return cpus, memoryKiB / 1024, nil
+}
+
+// kubeReservedCPU returns the millicores reserved for the kubernetes daemons in a
+// node with some CPUs: 6% of the first core, 1% of the second one, 0.5% of the
+// next two cores and 0.25% of any core above four
+func kubeReservedCPU(cpus int) int64 {
+	millicores := 0.0
+	for i := 1; i <= cpus; i++ {
+		switch {
+		case i == 1:
+			millicores += 60
+		case i == 2:
+			millicores += 10
+		case i <= 4:
+			millicores += 5
+		default:
+			millicores += 2.5
+		}
+	}
+	return int64(millicores)
+}
+
+// kubeReservedMemory returns the memory (in MiB) reserved for the kubernetes daemons
+// in a node with some total memory (in MiB), using the kubeReservedMemoryTiers
+func kubeReservedMemory(memory int64) int64 {
+	reserved, prev := 0.0, int64(0)
+	for _, tier := range kubeReservedMemoryTiers {
+		upTo := tier.upTo
+		if upTo == 0 || upTo > memory {
+			upTo = memory
+		}
+		if upTo <= prev {
+			break
+		}
+		reserved += float64(upTo-prev) * tier.fraction
+		prev = upTo
+	}
+	return int64(reserved)
+}
+
+// getScaledReserved returns the resources reserved for the system and for the
+// kubernetes daemons, scaled to the size of a node
+func getScaledReserved(cpus int, memory int64) (map[string]string, map[string]string) {
+	system := map[string]string{}
+	for resource, quantity := range defSystemReserved {
+		system[resource] = quantity
+	}
+	kube := map[string]string{
+		"cpu":               fmt.Sprintf("%dm", kubeReservedCPU(cpus)),
+		"memory":            fmt.Sprintf("%dMi", kubeReservedMemory(memory)),
+		"ephemeral-storage": defKubeReservedEphemeralStorage,
+	}
+	return system, kube
+}
+
+// mergeReserved returns the `defaults` reserved resources, overridden by the `explicit` ones
+func mergeReserved(defaults map[string]string, explicit map[string]string) map[string]string {
+	merged := map[string]string{}
+	for resource, quantity := range defaults {
+		merged[resource] = quantity
+	}
+	for resource, quantity := range explicit {
+		merged[resource] = quantity
+	}
+	return merged
+}
+
+// setKubeletReservedInConfig sets the "--system-reserved" and "--kube-reserved" kubelet
+// arguments, unless they have been already provided in the kubelet extra args
+func setKubeletReservedInConfig(d *schema.ResourceData, command string, system string, kube string) error {
+	setArgs := func(args map[string]string) map[string]string {
+		if args == nil {
+			args = map[string]string{}
+		}
+		if _, ok := args["system-reserved"]; !ok && system != "" {
+			args["system-reserved"] = system
+		}
+		if _, ok := args["kube-reserved"]; !ok && kube != "" {
+			args["kube-reserved"] = kube
+		}
+		return args
+	}
+
+	switch command {
+	case "init":
+		initConfig, _, err := common.InitConfigFromResourceData(d)
+		if err != nil {
+			return fmt.Errorf("could not get a valid 'config' for init'ing: %s", err)
+		}
+		initConfig.NodeRegistration.KubeletExtraArgs = setArgs(initConfig.NodeRegistration.KubeletExtraArgs)
+		return common.InitConfigToResourceData(d, initConfig)
+
+	case "join":
+		joinConfig, _, err := common.JoinConfigFromResourceData(d)
+		if err != nil {
+			return fmt.Errorf("could not get a valid 'config' for join'ing: %s", err)
+		}
+		joinConfig.NodeRegistration.KubeletExtraArgs = setArgs(joinConfig.NodeRegistration.KubeletExtraArgs)
+		return common.JoinConfigToResourceData(d, joinConfig)
+	}
+	return fmt.Errorf("unknown kubeadm command %q", command)
+}
+
+// doSetKubeletReserved reserves some resources for the system and for the kubernetes
+// daemons in the kubelet, so the node does not run out of resources under pressure.
+// Unless disabled, the values not provided are scaled to the size of the node, except
+// when they are already set in the KubeletConfiguration. The `command` can be "init" or "join".
+func doSetKubeletReserved(d *schema.ResourceData, command string) ssh.Action {
+	system, kube, err := getKubeletReservedFromResourceData(d)
+	if err != nil {
+		return ssh.ActionError(fmt.Sprintf("invalid reserved resources: %s", err))
+	}
+	auto := getKubeletReservedAutoFromResourceData(d)
+	if !auto && len(system) == 0 && len(kube) == 0 {
+		return nil
+	}
+
+	kubeletConfig, err := getKubeletConfigFromResourceData(d)
+	if err != nil {
+		return ssh.ActionError(err.Error())
+	}
+
+	return ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+		if auto {
+			var buf bytes.Buffer
+			if res := ssh.DoSendingExecOutputToWriter(ssh.DoExec(nodeResourcesCmd), &buf).Apply(ctx); ssh.IsError(res) {
+				return ssh.ActionError(fmt.Sprintf("could not get the node resources: %s", res.Error()))
+			}
+			cpus, memory, err := parseNodeResources(buf.String())
+			if err != nil {
+				return ssh.ActionError(err.Error())
+			}
+
+			scaledSystem, scaledKube := getScaledReserved(cpus, memory)
+			if !bytes.Contains(kubeletConfig, []byte("systemReserved:")) {
+				system = mergeReserved(scaledSystem, system)
+			}
+			if !bytes.Contains(kubeletConfig, []byte("kubeReserved:")) {
+				kube = mergeReserved(scaledKube, kube)
+			}
+		}
+
+		systemStr, kubeStr := common.ReservedToString(system), common.ReservedToString(kube)
+		if err := setKubeletReservedInConfig(d, command, systemStr, kubeStr); err != nil {
+			return ssh.ActionError(err.Error())
+		}
+		return ssh.DoMessageInfo("Reserving resources in the kubelet: system=[%s] kube=[%s]", systemStr, kubeStr)
+	})
+}
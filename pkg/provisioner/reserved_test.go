@@ -0,0 +1,54 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"testing"
+)
+
+func TestScaledReserved(t *testing.T) {
+	testsCases := []struct {
+		output string
+		cpu    string
+		memory string
+	}{
+		{"1\n2029876\n", "60m", "495Mi"},
+		{"4\n8388608\n", "80m", "1843Mi"},
+		{"8\n33554432\n", "90m", "3645Mi"},
+	}
+
+	for _, testCase := range testsCases {
+		cpus, memory, err := parseNodeResources(testCase.output)
+		if err != nil {
+			t.Fatalf("Error: could not parse %q: %s", testCase.output, err)
+		}
+		system, kube := getScaledReserved(cpus, memory)
+		if kube["cpu"] != testCase.cpu || kube["memory"] != testCase.memory {
+			t.Fatalf("Error: unexpected kube reserved resources for %q: %v", testCase.output, kube)
+		}
+		if system["cpu"] != defSystemReserved["cpu"] {
+			t.Fatalf("Error: unexpected system reserved resources for %q: %v", testCase.output, system)
+		}
+	}
+
+	if _, _, err := parseNodeResources("4\n"); err == nil {
+		t.Fatalf("Error: no error when parsing incomplete node resources")
+	}
+
+	merged := mergeReserved(map[string]string{"cpu": "60m", "memory": "512Mi"}, map[string]string{"memory": "1Gi"})
+	if merged["cpu"] != "60m" || merged["memory"] != "1Gi" {
+		t.Fatalf("Error: unexpected merged reserved resources: %v", merged)
+	}
+}
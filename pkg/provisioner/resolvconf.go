@@ -0,0 +1,128 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+)
+
+const (
+	// the resolv.conf with the real upstream servers when systemd-resolved is used
+	systemdResolvedResolvConf = "/run/systemd/resolve/resolv.conf"
+
+	// resolvConfInfoScript prints if systemd-resolved is active, if its resolv.conf
+	// exists and the nameservers in /etc/resolv.conf
+	resolvConfInfoScript = `
+systemctl is-active -q systemd-resolved 2>/dev/null && echo "resolved=active" || echo "resolved=inactive"
+[ -f %[1]s ] && echo "resolved_conf=true" || echo "resolved_conf=false"
+awk '/^nameserver/ { print "nameserver=" $2 }' /etc/resolv.conf 2>/dev/null
+true
+`
+)
+
+// resolvConfInfo is the DNS resolution setup of a node
+type resolvConfInfo struct {
+	ResolvedActive bool
+	ResolvedConf   bool
+	Nameservers    []string
+}
+
+// parseResolvConfInfo parses the output of the `resolvConfInfoScript`
+func parseResolvConfInfo(out string) resolvConfInfo {
+	info := resolvConfInfo{Nameservers: []string{}}
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "resolved=active":
+			info.ResolvedActive = true
+		case line == "resolved_conf=true":
+			info.ResolvedConf = true
+		case strings.HasPrefix(line, "nameserver="):
+			info.Nameservers = append(info.Nameservers, strings.TrimPrefix(line, "nameserver="))
+		}
+	}
+	return info
+}
+
+// hasOnlyLoopbackNameservers returns true when all the nameservers are loopback
+// addresses (ie, the "127.0.0.53" stub resolver of systemd-resolved)
+func (info resolvConfInfo) hasOnlyLoopbackNameservers() bool {
+	if len(info.Nameservers) == 0 {
+		return false
+	}
+	for _, nameserver := range info.Nameservers {
+		if !strings.HasPrefix(nameserver, "127.") && nameserver != "::1" {
+			return false
+		}
+	}
+	return true
+}
+
+// doSetResolvConf points the kubelet to the resolv.conf with the real upstream servers
+// when systemd-resolved is used in the node, as CoreDNS would forward queries to itself
+// when using the "127.0.0.53" stub resolver (and crash because of the loop). Nothing
+// is done when a resolv.conf is already set in the kubelet args or the KubeletConfiguration.
+// The `command` can be "init" or "join".
+func doSetResolvConf(d *schema.ResourceData, command string) ssh.Action {
+	kubeletConfig, err := getKubeletConfigFromResourceData(d)
+	if err != nil {
+		return ssh.ActionError(err.Error())
+	}
+	if bytes.Contains(kubeletConfig, []byte("resolvConf:")) {
+		ssh.Debug("resolvConf set in the KubeletConfiguration: not checking systemd-resolved")
+		return nil
+	}
+
+	return ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+		var buf bytes.Buffer
+		script := fmt.Sprintf(resolvConfInfoScript, systemdResolvedResolvConf)
+		if res := ssh.DoSendingExecOutputToWriter(ssh.DoExecScript([]byte(script)), &buf).Apply(ctx); ssh.IsError(res) {
+			return ssh.ActionError(fmt.Sprintf("could not get the DNS resolution setup: %s", res.Error()))
+		}
+		info := parseResolvConfInfo(buf.String())
+		if !info.hasOnlyLoopbackNameservers() {
+			return nil
+		}
+		if !info.ResolvedActive || !info.ResolvedConf {
+			return ssh.DoMessageWarn("only loopback nameservers in /etc/resolv.conf (%s): CoreDNS could forward queries to itself",
+				strings.Join(info.Nameservers, ", "))
+		}
+
+		current := ""
+		err := updateKubeletExtraArgs(d, command, func(args map[string]string) error {
+			if value, ok := args["resolv-conf"]; ok {
+				current = value
+				return nil
+			}
+			args["resolv-conf"] = systemdResolvedResolvConf
+			return nil
+		})
+		if err != nil {
+			return ssh.ActionError(err.Error())
+		}
+		if current != "" {
+			ssh.Debug("resolv-conf already set in the kubelet args: %q", current)
+			return nil
+		}
+		return ssh.DoMessageInfo("systemd-resolved detected: using %s in the kubelet", systemdResolvedResolvConf)
+	})
+}
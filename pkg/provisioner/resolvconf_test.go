@@ -0,0 +1,43 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"testing"
+)
+
+func TestParseResolvConfInfo(t *testing.T) {
+	testsCases := []struct {
+		out              string
+		expectedResolved bool
+		expectedLoopback bool
+	}{
+		{"resolved=active\nresolved_conf=true\nnameserver=127.0.0.53\n", true, true},
+		{"resolved=active\r\nresolved_conf=true\r\nnameserver=10.0.0.2\r\n", true, false},
+		{"resolved=inactive\nresolved_conf=false\nnameserver=127.0.0.1\nnameserver=::1\n", false, true},
+		{"resolved=inactive\nresolved_conf=false\nnameserver=127.0.0.1\nnameserver=8.8.8.8\n", false, false},
+		{"resolved=inactive\nresolved_conf=false\n", false, false},
+	}
+
+	for _, testCase := range testsCases {
+		info := parseResolvConfInfo(testCase.out)
+		if info.ResolvedActive != testCase.expectedResolved {
+			t.Fatalf("Error: unexpected systemd-resolved status for %q", testCase.out)
+		}
+		if info.hasOnlyLoopbackNameservers() != testCase.expectedLoopback {
+			t.Fatalf("Error: unexpected loopback nameservers for %q: %v", testCase.out, info.Nameservers)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+)
+
+const (
+	// roleMaster is the role of control-plane nodes
+	roleMaster = "master"
+
+	// roleControlPlane is an alias for the "master" role
+	roleControlPlane = "control-plane"
+
+	// roleWorker is the role of worker nodes
+	roleWorker = "worker"
+)
+
+// validRoles are the values accepted in the `role` argument
+var validRoles = []string{roleMaster, roleControlPlane, roleWorker}
+
+// normalizeRole returns the canonical name for a role (ie, "master" for "control-plane"),
+// defaulting to "master" when not joining a cluster and to "worker" otherwise
+func normalizeRole(role string, join string) string {
+	switch role = strings.ToLower(strings.TrimSpace(role)); role {
+	case "":
+		if len(join) == 0 {
+			return roleMaster
+		}
+		return roleWorker
+	case roleControlPlane:
+		return roleMaster
+	default:
+		return role
+	}
+}
+
+// checkRole checks if this node has some role
+func checkRole(d *schema.ResourceData, role string) ssh.CheckerFunc {
+	return ssh.CheckExpr(getRoleFromResourceData(d) == normalizeRole(role, ""))
+}
+
+// doKubeadmPreflight runs the checks (and setup) shared by all the nodes before
+// running kubeadm `command` ("init" or "join"), with some role-specific steps
+func doKubeadmPreflight(d *schema.ResourceData, command string) ssh.Action {
+	return ssh.ActionList{
+		ssh.DoIf(ssh.CheckExpr(command == "join"), doCheckDuplicateNodename(d)),
+		ssh.DoIf(ssh.CheckExpr(command == "join"), doCheckClockSkew(d)),
+		ssh.DoIf(ssh.CheckExpr(command == "join"), doCheckControlPlaneEndpoint(d)),
+		ssh.DoIf(checkRole(d, roleMaster), doCheckEtcdDataDir(d)),
+		ssh.DoIf(ssh.CheckExpr(command == "init"), doCheckEtcdVersion(d)),
+		doManageSwap(d),
+		doSetKubeletExtraArgs(d, command),
+		doSetNodeIP(d, command),
+		doResolveAdvertiseAddress(d, command),
+		doSetResolvConf(d, command),
+		doSetKubeletReserved(d, command),
+		doAlignCgroupDriver(d, command),
+		doSetTopologyLabels(d, command),
+		doValidateKubeadmConfig(d, command),
+		ssh.DoIfElse(checkRole(d, roleMaster),
+			doVerifyImagesSignatures(d),
+			doPrePullImages(d)),
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import "testing"
+
+func TestNormalizeRole(t *testing.T) {
+	tests := []struct {
+		role     string
+		join     string
+		expected string
+	}{
+		{"", "", roleMaster},
+		{"", "10.0.0.1", roleWorker},
+		{"master", "10.0.0.1", roleMaster},
+		{"Control-Plane", "10.0.0.1", roleMaster},
+		{" worker ", "10.0.0.1", roleWorker},
+		{"worker", "", roleWorker},
+	}
+	for _, test := range tests {
+		if res := normalizeRole(test.role, test.join); res != test.expected {
+			t.Fatalf("Error: unexpected role for %q (join: %q): %q", test.role, test.join, res)
+		}
+	}
+}
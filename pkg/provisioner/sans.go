@@ -0,0 +1,163 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+const (
+	// script for getting the SANs of the certificate presented by the local API server
+	apiServerSANsScript = `
+echo | timeout 10 openssl s_client -connect 127.0.0.1:%d 2>/dev/null | \
+	openssl x509 -noout -text 2>/dev/null | \
+	grep -A1 'Subject Alternative Name' | tail -1
+`
+)
+
+// parseCertSANs parses the SANs printed by `openssl x509 -text`
+// (ie, "DNS:kubernetes, DNS:kubernetes.default, IP Address:10.96.0.1"),
+// returning the DNS names and the IP addresses
+func parseCertSANs(out string) ([]string, []net.IP) {
+	names, ips := []string{}, []net.IP{}
+	for _, san := range strings.Split(out, ",") {
+		san = strings.TrimSpace(san)
+		switch {
+		case strings.HasPrefix(san, "DNS:"):
+			names = append(names, strings.ToLower(strings.TrimPrefix(san, "DNS:")))
+		case strings.HasPrefix(san, "IP Address:"):
+			if ip := net.ParseIP(strings.TrimPrefix(san, "IP Address:")); ip != nil {
+				ips = append(ips, ip)
+			}
+		}
+	}
+	return names, ips
+}
+
+// certSANsMatch returns true if the `host` (a DNS name or an IP) is in the SANs of a certificate
+func certSANsMatch(host string, names []string, ips []net.IP) bool {
+	if ip := net.ParseIP(host); ip != nil {
+		for _, sanIP := range ips {
+			if sanIP.Equal(ip) {
+				return true
+			}
+		}
+		return false
+	}
+
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, name := range names {
+		if name == host {
+			return true
+		}
+		// a wildcard only matches one label (ie, "*.example.com" matches "api.example.com")
+		if strings.HasPrefix(name, "*.") {
+			if i := strings.Index(host, "."); i > 0 && host[i:] == name[1:] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkCertSANs checks that all the `expected` hosts are in the SANs printed by `openssl x509 -text`
+func checkCertSANs(out string, expected []string) error {
+	names, ips := parseCertSANs(out)
+	if len(names) == 0 && len(ips) == 0 {
+		return fmt.Errorf("no SANs found in the API server certificate")
+	}
+
+	missing := []string{}
+	for _, host := range expected {
+		if !certSANsMatch(host, names, ips) {
+			missing = append(missing, host)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("the API server certificate is not valid for %s (certificate SANs: %s)",
+			strings.Join(missing, ", "), strings.TrimSpace(out))
+	}
+	return nil
+}
+
+// getExpectedCertSANs returns the hosts that must be in the SANs of the API server
+// certificate: the host of the control plane endpoint and the extra SANs configured
+func getExpectedCertSANs(d *schema.ResourceData) []string {
+	expected := []string{}
+	if endpoint := getControlPlaneEndpointFromResourceData(d); endpoint != "" {
+		if host, _, err := common.SplitHostPort(endpoint, common.DefAPIServerPort); err == nil {
+			expected = append(expected, host)
+		} else {
+			expected = append(expected, endpoint)
+		}
+	}
+	return append(expected, getCertSANsFromResourceData(d)...)
+}
+
+// getAPIServerBindPort returns the port where the API server listens in this node,
+// from the configuration for `command` ("init" or "join")
+func getAPIServerBindPort(d *schema.ResourceData, command string) int {
+	port := int32(0)
+	switch command {
+	case "init":
+		if initConfig, _, err := common.InitConfigFromResourceData(d); err == nil {
+			port = initConfig.LocalAPIEndpoint.BindPort
+		}
+	case "join":
+		if joinConfig, _, err := common.JoinConfigFromResourceData(d); err == nil && joinConfig.ControlPlane != nil {
+			port = joinConfig.ControlPlane.LocalAPIEndpoint.BindPort
+		}
+	}
+	if port == 0 {
+		return common.DefAPIServerPort
+	}
+	return int(port)
+}
+
+// doCheckAPIServerCertSANs checks that the certificate presented by the API server in this node
+// includes the control plane endpoint and the extra SANs configured, as a mismatch would only
+// be noticed when some client connects through the endpoint (with a "x509: certificate is valid
+// for ..." error). The check is skipped when `openssl` is not available.
+// The `command` can be "init" or "join".
+func doCheckAPIServerCertSANs(d *schema.ResourceData, command string) ssh.Action {
+	expected := getExpectedCertSANs(d)
+	if len(expected) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	return ssh.DoIfElse(
+		ssh.CheckBinaryExists("openssl"),
+		ssh.ActionList{
+			ssh.DoMessageInfo("Checking the API server certificate is valid for %s...", strings.Join(expected, ", ")),
+			ssh.DoSendingExecOutputToWriter(ssh.DoExec(fmt.Sprintf(apiServerSANsScript, getAPIServerBindPort(d, command))), &buf),
+			ssh.ActionFunc(func(context.Context) ssh.Action {
+				if err := checkCertSANs(buf.String(), expected); err != nil {
+					return ssh.DoAbort("%s: check the 'api.alt_names'", err)
+				}
+				return nil
+			}),
+		},
+		ssh.DoMessageWarn("'openssl' not found: the API server certificate SANs will not be checked"))
+}
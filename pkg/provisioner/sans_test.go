@@ -0,0 +1,46 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"testing"
+)
+
+func TestCheckCertSANs(t *testing.T) {
+	out := "                DNS:kubernetes, DNS:kubernetes.default, DNS:*.example.com, DNS:master-0, IP Address:10.96.0.1, IP Address:192.168.1.10, IP Address:FD00:0:0:0:0:0:0:10\n"
+
+	testsCases := []struct {
+		expected []string
+		err      bool
+	}{
+		{[]string{"192.168.1.10"}, false},
+		{[]string{"api.example.com", "fd00::10"}, false},
+		{[]string{"Master-0."}, false},
+		{[]string{"192.168.1.100"}, true},
+		{[]string{"a.b.example.com"}, true},
+		{[]string{"kubernetes", "vip.local"}, true},
+	}
+
+	for _, testCase := range testsCases {
+		err := checkCertSANs(out, testCase.expected)
+		if (err != nil) != testCase.err {
+			t.Fatalf("Error: unexpected result for %v: %v", testCase.expected, err)
+		}
+	}
+
+	if err := checkCertSANs("", []string{"192.168.1.10"}); err == nil {
+		t.Fatalf("Error: no error when there are no SANs")
+	}
+}
@@ -17,12 +17,15 @@ package provisioner
 import (
 	"fmt"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/terraform/helper/schema"
 	"github.com/hashicorp/terraform/helper/validation"
 	"github.com/hashicorp/terraform/terraform"
 
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
 	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
 )
 
@@ -42,18 +45,107 @@ func Provisioner() terraform.ResourceProvisioner {
 				Default:     "",
 				Description: "seeder node to join. Or start a seeder when not provided",
 			},
+			"join_endpoints": {
+				Type:        schema.TypeList,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Optional:    true,
+				Description: "additional API server endpoints to try (in order) when the seeder cannot be used for joining",
+			},
 			"role": {
 				Type:         schema.TypeString,
 				Optional:     true,
 				Default:      "",
-				Description:  "role of this machine: master or worker",
-				ValidateFunc: validation.StringInSlice([]string{"master", "worker"}, true),
+				Description:  "role of this machine: master (or control-plane) or worker",
+				ValidateFunc: validation.StringInSlice(validRoles, true),
+			},
+			"pre_init": {
+				Type:        schema.TypeList,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Optional:    true,
+				Description: "list of commands to run in the node before 'kubeadm init'",
+			},
+			"post_init": {
+				Type:        schema.TypeList,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Optional:    true,
+				Description: "list of commands to run in the node after 'kubeadm init'",
+			},
+			"pre_join": {
+				Type:        schema.TypeList,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Optional:    true,
+				Description: "list of commands to run in the node before 'kubeadm join'",
+			},
+			"post_join": {
+				Type:        schema.TypeList,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Optional:    true,
+				Description: "list of commands to run in the node after 'kubeadm join'",
+			},
+			"ignore_hook_failures": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "do not fail when some command in the pre/post init/join hooks fails",
+			},
+			"prepull_images": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "pre-pull the images used in workers (kube-proxy, pause and CNI images) before joining the cluster",
+			},
+			"verify_join": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     true,
+				Description: "after joining, verify the node is registered in the cluster and it becomes Ready",
+			},
+			"quarantine": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "register workers with a NoSchedule taint, removed once the node is Ready",
+			},
+			"quarantine_taint": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      common.DefQuarantineTaintKey,
+				Description:  "key of the taint used for quarantining workers until they are Ready",
+				ValidateFunc: validation.StringMatch(taintKeyRegexp, "must be a valid taint key (ie, 'example.com/not-ready')"),
+			},
+			"wait_addons": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     true,
+				Description: "wait until the core addons (kube-proxy and CoreDNS) are rolled out after 'kubeadm init'",
+			},
+			"check_dns": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "check the cluster DNS resolves internal and external names (with a throwaway pod) after 'kubeadm init'",
+			},
+			"check_dns_external": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     common.DefDNSCheckExternalName,
+				Description: "external name resolved when checking the cluster DNS (empty for only checking internal names)",
 			},
 			"ignore_checks": {
 				Type:        schema.TypeList,
 				Elem:        &schema.Schema{Type: schema.TypeString},
 				Optional:    true,
 				Description: "list of preflight checks to ignore by kubeadm",
+				Deprecated:  "use 'ignore_preflight_errors' instead",
+			},
+			"ignore_preflight_errors": {
+				Type: schema.TypeList,
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: common.ValidatePreflightCheck,
+				},
+				Optional:    true,
+				Description: "list of kubeadm preflight checks whose errors will be ignored (ie, NumCPU, Mem, Swap)",
 			},
 			"drain": {
 				Type:        schema.TypeBool,
@@ -61,12 +153,137 @@ func Provisioner() terraform.ResourceProvisioner {
 				Default:     false,
 				Description: "when true, remove this node from the cluster instead of adding it",
 			},
+			"drain_options": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"pod_selector": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Description:  "only evict the pods matching this label selector",
+							ValidateFunc: common.ValidateLabelSelector,
+						},
+						"disable_eviction": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     false,
+							Description: "delete the pods instead of evicting them (bypassing the PodDisruptionBudgets)",
+						},
+						"force": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     true,
+							Description: "also remove the pods not managed by a controller",
+						},
+						"timeout": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Default:      defDrainTimeout,
+							Description:  "max time waiting for the pods to be evicted (ie, when blocked by some PodDisruptionBudgets)",
+							ValidateFunc: common.ValidateDuration,
+						},
+						"delete_on_timeout": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     false,
+							Description: "delete the pods (bypassing the PodDisruptionBudgets) when they cannot be evicted before the timeout",
+						},
+					},
+				},
+			},
+			"reset_only": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "when true, remove this node from the cluster (drain, reset and delete) but keep the machine",
+			},
+			"ignore_etcd_quorum": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "when true, remove this node even if the etcd cluster would lose its quorum",
+			},
+			"max_clock_skew": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      defMaxClockSkew,
+				Description:  "max clock skew between a joining node and the control plane (ie, 30s)",
+				ValidateFunc: common.ValidateDuration,
+			},
+			"init_retries": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      common.DefInitRetries,
+				Description:  "number of times a failed 'kubeadm init' is retried (resetting the node between attempts)",
+				ValidateFunc: validation.IntAtLeast(0),
+			},
+			"ephemeral_token": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "create a fresh (short-lived) token for joining this node, deleting it after the join",
+			},
+			"init_phases": {
+				Type: schema.TypeList,
+				Elem: &schema.Schema{
+					Type:         schema.TypeString,
+					ValidateFunc: common.ValidateInitPhase,
+				},
+				Optional:    true,
+				Description: "only run these 'kubeadm init' phases, with optional flags (ie, 'addon/coredns' or 'upload-certs --upload-certs')",
+			},
+			"validate_config": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     true,
+				Description: "validate the kubeadm configuration with 'kubeadm config validate' before running kubeadm",
+			},
+			"config_backups": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      0,
+				Description:  "number of backups of the kubeadm configs (and static pods manifests) to keep in the node (0 disables backups)",
+				ValidateFunc: validation.IntAtLeast(0),
+			},
+			"diagnostics_dir": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "",
+				Description: "local directory where a diagnostics bundle (logs, containers, configs...) is saved when provisioning fails (empty disables it)",
+			},
+			"keep_sensitive_files": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "keep the sensitive files uploaded (kubeadm configs, kubeconfigs...) in the node, for debugging",
+			},
 			"nodename": {
 				Type:        schema.TypeString,
 				Optional:    true,
 				Default:     "",
 				Description: "name used for registering the node in the kubernetes cluster (defaults to the hostname)",
 			},
+			"kubeadm_api_version": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "",
+				Description:  "kubeadm config API version (ie, v1beta2) used in the configuration files (detected from the kubeadm version when empty)",
+				ValidateFunc: validation.StringInSlice(common.KubeadmAPIVersions(), false),
+			},
+			"pki_dir": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     "",
+				Description: "local directory with a pre-generated PKI tree to upload to the control plane node (instead of generating certificates)",
+			},
+			"node_ip": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Description:  "IP address(es) used by the kubelet for this node (use a comma-separated IPv4 and IPv6 addresses for dual-stack, or 'auto' for detecting it)",
+				ValidateFunc: validation.Any(validation.StringInSlice([]string{nodeIPAuto}, false), common.ValidateIPs),
+			},
 			"listen": {
 				Type:         schema.TypeString,
 				Optional:     true,
@@ -79,12 +296,197 @@ func Provisioner() terraform.ResourceProvisioner {
 				Default:     false,
 				Description: "prevent the use of sudo",
 			},
+			"sudo": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      ssh.SudoAuto,
+				Description:  "use sudo for running commands: 'auto' (unless connected as root or sudo is not installed), 'always' or 'never'",
+				ValidateFunc: validation.StringInSlice(ssh.SudoModes, false),
+			},
+			"kubelet_extra_args": {
+				Type:         schema.TypeMap,
+				Elem:         &schema.Schema{Type: schema.TypeString},
+				Optional:     true,
+				Description:  "extra arguments for the kubelet in this node (args set by the provider take precedence)",
+				ValidateFunc: common.ValidateKubeletExtraArgs,
+			},
+			"registry_auth": {
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"registry": {
+							Type:        schema.TypeString,
+							Required:    true,
+							Description: "registry (with some 'registry_mirrors' in the kubeadm resource) where the credentials are used (ie, docker.io)",
+						},
+						"username": {
+							Type:        schema.TypeString,
+							Required:    true,
+							Description: "username for authenticating in the mirrors",
+						},
+						"password": {
+							Type:        schema.TypeString,
+							Required:    true,
+							Sensitive:   true,
+							Description: "password for authenticating in the mirrors",
+						},
+					},
+				},
+			},
+			"restrict_permissions": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      restrictPermissionsAuto,
+				Description:  "restrict the permissions of the kubernetes files: 'auto' (only with a 'hardening' preset), 'true' or 'false'",
+				ValidateFunc: validation.StringInSlice(restrictPermissionsModes, false),
+			},
+			"remote_tmp_dir": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      common.DefRemoteTmpDir,
+				Description:  "remote directory where a private temporary directory will be created for uploaded files",
+				ValidateFunc: common.ValidateAbsPath,
+			},
+			"wait_for_workers": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      0,
+				Description:  "for the bootstrap master, wait until this number of workers are ready",
+				ValidateFunc: validation.IntAtLeast(0),
+			},
+			"reboot_if_needed": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "reboot the machine (and wait for it) when some changes require it",
+			},
+			"heartbeat_interval": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      int(ssh.DefHeartbeatInterval / time.Second),
+				Description:  "seconds between 'still working...' messages while running long operations (0 disables them)",
+				ValidateFunc: validation.IntAtLeast(0),
+			},
 			"manifests": {
 				Type:        schema.TypeList,
 				Elem:        &schema.Schema{Type: schema.TypeString},
 				Optional:    true,
 				Description: "list of manifests to load in the API server once the master is setup",
 			},
+			"kustomizations": {
+				Type:        schema.TypeList,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Optional:    true,
+				Description: "list of local kustomize directories to apply in the API server once the master is setup",
+			},
+			"kubectl_commands": {
+				Type:        schema.TypeList,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+				Optional:    true,
+				Description: "list of kubectl commands (arguments) to run in the master once it is setup",
+			},
+			"apply": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"server_side": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     false,
+							Description: "use server-side apply when loading manifests",
+						},
+						"field_manager": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Default:     "",
+							Description: "name of the manager used for tracking field ownership in server-side apply",
+						},
+						"force_conflicts": {
+							Type:        schema.TypeBool,
+							Optional:    true,
+							Default:     false,
+							Description: "force the changes against conflicts in server-side apply",
+						},
+					},
+				},
+			},
+			"kubectl_retry": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"attempts": {
+							Type:         schema.TypeInt,
+							Optional:     true,
+							Default:      defKubectlRetryAttempts,
+							Description:  "max number of attempts for kubectl commands failing with transient errors (ie, 'connection refused')",
+							ValidateFunc: validation.IntAtLeast(1),
+						},
+						"interval": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Default:      defKubectlRetryInterval,
+							Description:  "initial interval between kubectl attempts (doubled after each attempt)",
+							ValidateFunc: common.ValidateDuration,
+						},
+					},
+				},
+			},
+			"connection_retry": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"attempts": {
+							Type:         schema.TypeInt,
+							Optional:     true,
+							Default:      defConnectionRetryAttempts,
+							Description:  "max number of attempts for connecting to the machine",
+							ValidateFunc: validation.IntAtLeast(1),
+						},
+						"interval": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Default:      defConnectionRetryInterval,
+							Description:  "interval between attempts for connecting to the machine",
+							ValidateFunc: common.ValidateDuration,
+						},
+					},
+				},
+			},
+			"topology": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"zone": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Default:     "",
+							Description: "value for the 'topology.kubernetes.io/zone' label of this node",
+						},
+						"region": {
+							Type:        schema.TypeString,
+							Optional:    true,
+							Default:     "",
+							Description: "value for the 'topology.kubernetes.io/region' label of this node",
+						},
+						"metadata": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							Default:      "",
+							Description:  "detect the zone and region from the metadata service of a cloud: " + strings.Join(topologyMetadataSources, ","),
+							ValidateFunc: validation.StringInSlice(topologyMetadataSources, false),
+						},
+					},
+				},
+			},
 			"install": {
 				// NOTE: default values for nested blocks are not available if the "install" block
 				// has not been provided at all.
@@ -145,6 +547,18 @@ func Provisioner() terraform.ResourceProvisioner {
 							Optional:    true,
 							Description: "full path where kubectl should be present (if no absolute path is provided, it will use the default PATH for finding it).",
 						},
+						"crictl_path": {
+							Type:        schema.TypeString,
+							Default:     common.DefCrictlPath,
+							Optional:    true,
+							Description: "full path where crictl should be present (if no absolute path is provided, it will use the default PATH for finding it).",
+						},
+						"cosign_path": {
+							Type:        schema.TypeString,
+							Default:     common.DefCosignPath,
+							Optional:    true,
+							Description: "full path where cosign should be present, when verifying images signatures (if no absolute path is provided, it will use the default PATH for finding it).",
+						},
 					},
 				},
 			},
@@ -169,12 +583,34 @@ func getJoinFromResourceData(d *schema.ResourceData) string {
 	return ""
 }
 
-// getRoleFromResourceData returns the "role" host from the ResourceData
+// getJoinEndpointsFromResourceData returns the list of API server endpoints to try when
+// joining: the seeder, the extra "join_endpoints" and the control plane endpoint (if any)
+func getJoinEndpointsFromResourceData(d *schema.ResourceData) []string {
+	endpoints := []string{}
+	if seeder := getJoinFromResourceData(d); seeder != "" {
+		endpoints = append(endpoints, common.AddressWithPort(seeder, common.DefAPIServerPort))
+	}
+	if endpointsOpt, ok := d.GetOk("join_endpoints"); ok {
+		for _, endpoint := range endpointsOpt.([]interface{}) {
+			if e := strings.TrimSpace(endpoint.(string)); e != "" {
+				endpoints = append(endpoints, common.AddressWithPort(e, common.DefAPIServerPort))
+			}
+		}
+	}
+	if controlPlaneEndpoint := getControlPlaneEndpointFromResourceData(d); controlPlaneEndpoint != "" {
+		endpoints = append(endpoints, common.AddressWithPort(controlPlaneEndpoint, common.DefAPIServerPort))
+	}
+	return common.StringSliceUnique(endpoints)
+}
+
+// getRoleFromResourceData returns the (normalized) "role" from the ResourceData:
+// "master" or "worker", depending on the `join` when no role has been provided
 func getRoleFromResourceData(d *schema.ResourceData) string {
+	role := ""
 	if opt, ok := d.GetOk("role"); ok {
-		return strings.TrimSpace(opt.(string))
+		role = opt.(string)
 	}
-	return ""
+	return normalizeRole(role, getJoinFromResourceData(d))
 }
 
 // getKubeconfigFromResourceData returns the kubeconfig parameter passed in the `config_path`
@@ -190,6 +626,27 @@ func getKubeconfigFromResourceData(d *schema.ResourceData) string {
 	return f
 }
 
+// getKubeadmAPIVersionFromResourceData returns the kubeadm config API version requested by the user
+func getKubeadmAPIVersionFromResourceData(d *schema.ResourceData) string {
+	if opt, ok := d.GetOk("kubeadm_api_version"); ok {
+		return strings.TrimSpace(opt.(string))
+	}
+	return ""
+}
+
+// getPKIDirFromResourceData returns the (absolute) local directory with a pre-generated PKI tree
+func getPKIDirFromResourceData(d *schema.ResourceData) string {
+	pkiDirOpt, ok := d.GetOk("pki_dir")
+	if !ok || pkiDirOpt.(string) == "" {
+		return ""
+	}
+	f, err := filepath.Abs(pkiDirOpt.(string))
+	if err != nil {
+		return ""
+	}
+	return f
+}
+
 func getSysconfigPathFromResourceData(d *schema.ResourceData) string {
 	// NOTE: the "install" block is optional, so there will be no
 	// default values for "install.0.XXX" if the "install" block has not been given...
@@ -235,6 +692,14 @@ func getTokenFromResourceData(d *schema.ResourceData) string {
 	return ""
 }
 
+// getBootstrapKubeconfigTokenFromResourceData returns the token in the exported bootstrap kubeconfig (if any)
+func getBootstrapKubeconfigTokenFromResourceData(d *schema.ResourceData) string {
+	if tokenOpt, ok := d.GetOk("config.bootstrap_kubeconfig_token"); ok {
+		return tokenOpt.(string)
+	}
+	return ""
+}
+
 // getKubectlFromResourceData returns the kubectl binary path from the config
 func getKubectlFromResourceData(d *schema.ResourceData) string {
 	if kubectlPathOpt, ok := d.GetOk("install.0.kubectl_path"); ok {
@@ -243,6 +708,22 @@ func getKubectlFromResourceData(d *schema.ResourceData) string {
 	return common.DefKubectlPath
 }
 
+// getCrictlFromResourceData returns the crictl binary path from the config
+func getCrictlFromResourceData(d *schema.ResourceData) string {
+	if crictlPathOpt, ok := d.GetOk("install.0.crictl_path"); ok {
+		return crictlPathOpt.(string)
+	}
+	return common.DefCrictlPath
+}
+
+// getCosignFromResourceData returns the cosign binary path from the config
+func getCosignFromResourceData(d *schema.ResourceData) string {
+	if cosignPathOpt, ok := d.GetOk("install.0.cosign_path"); ok {
+		return cosignPathOpt.(string)
+	}
+	return common.DefCosignPath
+}
+
 // getNodenameFromResourceData returns the nodename specified in the ResourceData
 func getNodenameFromResourceData(d *schema.ResourceData) string {
 	if nodenameOpt, ok := d.GetOk("nodename"); ok {
@@ -250,3 +731,396 @@ func getNodenameFromResourceData(d *schema.ResourceData) string {
 	}
 	return ""
 }
+
+// getNodeIPFromResourceData returns the IP address(es) for the kubelet in this node
+func getNodeIPFromResourceData(d *schema.ResourceData) string {
+	if nodeIPOpt, ok := d.GetOk("node_ip"); ok {
+		return nodeIPOpt.(string)
+	}
+	return ""
+}
+
+// getSudoModeFromResourceData returns the sudo mode (`prevent_sudo` forces "never")
+func getSudoModeFromResourceData(d *schema.ResourceData) string {
+	if d.Get("prevent_sudo").(bool) {
+		return ssh.SudoNever
+	}
+	if sudoOpt, ok := d.GetOk("sudo"); ok {
+		return sudoOpt.(string)
+	}
+	return ssh.SudoAuto
+}
+
+// getKubeletExtraArgsFromResourceData returns the extra arguments for the kubelet in this node
+func getKubeletExtraArgsFromResourceData(d *schema.ResourceData) map[string]string {
+	args := map[string]string{}
+	if argsOpt, ok := d.GetOk("kubelet_extra_args"); ok {
+		for arg, value := range argsOpt.(map[string]interface{}) {
+			args[arg] = value.(string)
+		}
+	}
+	return args
+}
+
+// getRegistryAuthFromResourceData returns the credentials for the mirrors of some registries
+func getRegistryAuthFromResourceData(d *schema.ResourceData) map[string]registryAuth {
+	res := map[string]registryAuth{}
+	for _, authOpt := range d.Get("registry_auth").([]interface{}) {
+		auth := authOpt.(map[string]interface{})
+		res[auth["registry"].(string)] = registryAuth{
+			Username: auth["username"].(string),
+			Password: auth["password"].(string),
+		}
+	}
+	return res
+}
+
+// getRestrictPermissionsFromResourceData returns the "restrict_permissions" mode
+func getRestrictPermissionsFromResourceData(d *schema.ResourceData) string {
+	if restrictOpt, ok := d.GetOk("restrict_permissions"); ok {
+		return restrictOpt.(string)
+	}
+	return restrictPermissionsAuto
+}
+
+// getRemoteTmpDirFromResourceData returns the remote directory used for temporary files
+func getRemoteTmpDirFromResourceData(d *schema.ResourceData) string {
+	if tmpDirOpt, ok := d.GetOk("remote_tmp_dir"); ok {
+		return tmpDirOpt.(string)
+	}
+	return common.DefRemoteTmpDir
+}
+
+// getKeepSensitiveFilesFromResourceData returns true if sensitive uploaded files should be kept in the node
+func getKeepSensitiveFilesFromResourceData(d *schema.ResourceData) bool {
+	return d.Get("keep_sensitive_files").(bool)
+}
+
+// getHeartbeatIntervalFromResourceData returns the interval between heartbeat messages
+func getHeartbeatIntervalFromResourceData(d *schema.ResourceData) time.Duration {
+	if intervalOpt, ok := d.GetOk("heartbeat_interval"); ok {
+		return time.Duration(intervalOpt.(int)) * time.Second
+	}
+	return 0
+}
+
+// getKubectlApplyOptionsFromResourceData returns the options for `kubectl apply`
+func getKubectlApplyOptionsFromResourceData(d *schema.ResourceData) ssh.KubectlApplyOptions {
+	opts := ssh.KubectlApplyOptions{}
+	if serverSideOpt, ok := d.GetOk("apply.0.server_side"); ok {
+		opts.ServerSide = serverSideOpt.(bool)
+	}
+	if fieldManagerOpt, ok := d.GetOk("apply.0.field_manager"); ok {
+		opts.FieldManager = fieldManagerOpt.(string)
+	}
+	if forceConflictsOpt, ok := d.GetOk("apply.0.force_conflicts"); ok {
+		opts.ForceConflicts = forceConflictsOpt.(bool)
+	}
+	return opts
+}
+
+// getKubectlRetryFromResourceData returns the retries for kubectl commands failing with transient errors
+func getKubectlRetryFromResourceData(d *schema.ResourceData) ssh.Retry {
+	retry := ssh.Retry{Times: defKubectlRetryAttempts, Backoff: kubectlRetryBackoff}
+	retry.Interval, _ = time.ParseDuration(defKubectlRetryInterval)
+	if attemptsOpt, ok := d.GetOk("kubectl_retry.0.attempts"); ok {
+		retry.Times = attemptsOpt.(int)
+	}
+	if intervalOpt, ok := d.GetOk("kubectl_retry.0.interval"); ok {
+		if interval, err := time.ParseDuration(intervalOpt.(string)); err == nil {
+			retry.Interval = interval
+		}
+	}
+	return retry
+}
+
+// getConnectionRetryFromResourceData returns the retries for connecting to the machine,
+// or nil if no "connection_retry" has been provided
+func getConnectionRetryFromResourceData(d *schema.ResourceData) *ssh.Retry {
+	if _, ok := d.GetOk("connection_retry.0"); !ok {
+		return nil
+	}
+	retry := ssh.Retry{Times: defConnectionRetryAttempts}
+	retry.Interval, _ = time.ParseDuration(defConnectionRetryInterval)
+	if attemptsOpt, ok := d.GetOk("connection_retry.0.attempts"); ok {
+		retry.Times = attemptsOpt.(int)
+	}
+	if intervalOpt, ok := d.GetOk("connection_retry.0.interval"); ok {
+		if interval, err := time.ParseDuration(intervalOpt.(string)); err == nil {
+			retry.Interval = interval
+		}
+	}
+	return &retry
+}
+
+// getDrainOptionsFromResourceData returns the options for draining the node
+func getDrainOptionsFromResourceData(d *schema.ResourceData) drainOptions {
+	opts := drainOptions{Force: true}
+	opts.Timeout, _ = time.ParseDuration(defDrainTimeout)
+	if _, ok := d.GetOk("drain_options.0"); !ok {
+		return opts
+	}
+	if timeoutOpt, ok := d.GetOk("drain_options.0.timeout"); ok {
+		if timeout, err := time.ParseDuration(timeoutOpt.(string)); err == nil {
+			opts.Timeout = timeout
+		}
+	}
+	if deleteOpt, ok := d.GetOk("drain_options.0.delete_on_timeout"); ok {
+		opts.DeleteOnTimeout = deleteOpt.(bool)
+	}
+	if selectorOpt, ok := d.GetOk("drain_options.0.pod_selector"); ok {
+		opts.PodSelector = selectorOpt.(string)
+	}
+	if disableOpt, ok := d.GetOk("drain_options.0.disable_eviction"); ok {
+		opts.DisableEviction = disableOpt.(bool)
+	}
+	// "force" defaults to true, so it has been explicitly disabled when it is not set
+	forceOpt, ok := d.GetOk("drain_options.0.force")
+	opts.Force = ok && forceOpt.(bool)
+	return opts
+}
+
+// getTopologyFromResourceData returns the explicit zone and region for this node,
+// as well as the cloud metadata source for detecting them
+func getTopologyFromResourceData(d *schema.ResourceData) (string, string, string) {
+	zone, region, metadata := "", "", ""
+	if zoneOpt, ok := d.GetOk("topology.0.zone"); ok {
+		zone = zoneOpt.(string)
+	}
+	if regionOpt, ok := d.GetOk("topology.0.region"); ok {
+		region = regionOpt.(string)
+	}
+	if metadataOpt, ok := d.GetOk("topology.0.metadata"); ok {
+		metadata = metadataOpt.(string)
+	}
+	return zone, region, metadata
+}
+
+// getControlPlaneEndpointFromResourceData returns the control plane endpoint (ie, a VIP
+// or a load balancer) in the `config.init`, or an empty string if not configured
+func getControlPlaneEndpointFromResourceData(d *schema.ResourceData) string {
+	initConfig, _, err := common.InitConfigFromResourceData(d)
+	if err != nil {
+		return ""
+	}
+	return initConfig.ControlPlaneEndpoint
+}
+
+// getCertSANsFromResourceData returns the extra SANs for the API server certificate
+func getCertSANsFromResourceData(d *schema.ResourceData) []string {
+	initConfig, _, err := common.InitConfigFromResourceData(d)
+	if err != nil {
+		return []string{}
+	}
+	return initConfig.APIServer.CertSANs
+}
+
+// getKubeconfigNamesFromResourceData returns the names for the cluster, context and user in the kubeconfig
+func getKubeconfigNamesFromResourceData(d *schema.ResourceData) common.KubeconfigNames {
+	names := common.KubeconfigNames{}
+	if clusterOpt, ok := d.GetOk("config.kubeconfig_cluster"); ok {
+		names.Cluster = clusterOpt.(string)
+	}
+	if contextOpt, ok := d.GetOk("config.kubeconfig_context"); ok {
+		names.Context = contextOpt.(string)
+	}
+	if userOpt, ok := d.GetOk("config.kubeconfig_user"); ok {
+		names.User = userOpt.(string)
+	}
+	return names
+}
+
+// getRuntimeEngineFromResourceData returns the runtime engine used in the cluster
+func getRuntimeEngineFromResourceData(d *schema.ResourceData) string {
+	if engineOpt, ok := d.GetOk("config.runtime_engine"); ok {
+		return engineOpt.(string)
+	}
+	return common.DefRuntimeEngine
+}
+
+// getRuntimeManageConfigFromResourceData returns true if we must manage the runtime engine config
+func getRuntimeManageConfigFromResourceData(d *schema.ResourceData) bool {
+	if manageOpt, ok := d.GetOk("config.runtime_manage_config"); ok {
+		manage, err := strconv.ParseBool(manageOpt.(string))
+		if err == nil {
+			return manage
+		}
+	}
+	return true
+}
+
+// getKubeletConfigFromResourceData returns the (optional) KubeletConfiguration document
+func getKubeletConfigFromResourceData(d *schema.ResourceData) ([]byte, error) {
+	kubeletConfigOpt, ok := d.GetOk("config.kubelet_config")
+	if !ok {
+		return nil, nil
+	}
+	kubeletConfig, err := common.FromTerraformSafeString(kubeletConfigOpt.(string))
+	if err != nil {
+		return nil, fmt.Errorf("could not decode the kubelet configuration: %s", err)
+	}
+	return kubeletConfig, nil
+}
+
+// setKubeletConfigInResourceData replaces the KubeletConfiguration in the `config.kubelet_config`
+func setKubeletConfigInResourceData(d *schema.ResourceData, kubeletConfig []byte) error {
+	config := common.GetProvisionerConfig(d)
+	config["kubelet_config"] = common.ToTerraformSafeString(kubeletConfig)
+	if err := d.Set("config", config); err != nil {
+		return fmt.Errorf("cannot update config.kubelet_config")
+	}
+	return nil
+}
+
+// getKubeletReservedAutoFromResourceData returns true if the resources reserved in
+// the kubelet must be scaled to the node size (for the values not provided)
+func getKubeletReservedAutoFromResourceData(d *schema.ResourceData) bool {
+	if autoOpt, ok := d.GetOk("config.kubelet_reserved_auto"); ok {
+		auto, err := strconv.ParseBool(autoOpt.(string))
+		if err == nil {
+			return auto
+		}
+	}
+	return true
+}
+
+// getKubeletReservedFromResourceData returns the resources explicitly reserved
+// for the system and for the kubernetes daemons
+func getKubeletReservedFromResourceData(d *schema.ResourceData) (map[string]string, map[string]string, error) {
+	system, kube := map[string]string{}, map[string]string{}
+	var err error
+	if systemOpt, ok := d.GetOk("config.kubelet_system_reserved"); ok {
+		if system, err = common.ReservedFromString(systemOpt.(string)); err != nil {
+			return nil, nil, err
+		}
+	}
+	if kubeOpt, ok := d.GetOk("config.kubelet_kube_reserved"); ok {
+		if kube, err = common.ReservedFromString(kubeOpt.(string)); err != nil {
+			return nil, nil, err
+		}
+	}
+	return system, kube, nil
+}
+
+// getResetOnlyFromResourceData returns true if we must reset the node (keeping the machine) instead of adding it
+func getResetOnlyFromResourceData(d *schema.ResourceData) bool {
+	return d.Get("reset_only").(bool)
+}
+
+// getIgnoreEtcdQuorumFromResourceData returns true if the node must be removed even if etcd loses its quorum
+func getIgnoreEtcdQuorumFromResourceData(d *schema.ResourceData) bool {
+	return d.Get("ignore_etcd_quorum").(bool)
+}
+
+// getMaxClockSkewFromResourceData returns the max clock skew between a joining node and the control plane
+func getMaxClockSkewFromResourceData(d *schema.ResourceData) time.Duration {
+	max, _ := time.ParseDuration(defMaxClockSkew)
+	if maxOpt, ok := d.GetOk("max_clock_skew"); ok {
+		if m, err := time.ParseDuration(maxOpt.(string)); err == nil {
+			max = m
+		}
+	}
+	return max
+}
+
+// getEphemeralTokenFromResourceData returns true if a fresh token must be created (and deleted) for joining
+func getEphemeralTokenFromResourceData(d *schema.ResourceData) bool {
+	if ephemeralOpt, ok := d.GetOk("ephemeral_token"); ok {
+		return ephemeralOpt.(bool)
+	}
+	return false
+}
+
+// getKubeProxyModeFromResourceData returns the mode of the kube-proxy ("none" when it is not deployed)
+func getKubeProxyModeFromResourceData(d *schema.ResourceData) string {
+	if modeOpt, ok := d.GetOk("config.kube_proxy_mode"); ok {
+		return modeOpt.(string)
+	}
+	return ""
+}
+
+// getInitPhasesFromResourceData returns the list of `kubeadm init` phases to run (instead of a full init)
+func getInitPhasesFromResourceData(d *schema.ResourceData) []string {
+	if phasesOpt, ok := d.GetOk("init_phases"); ok {
+		return common.InterfacesToStrings(phasesOpt.([]interface{}))
+	}
+	return []string{}
+}
+
+// getInitRetriesFromResourceData returns the number of times a failed `kubeadm init` must be retried
+func getInitRetriesFromResourceData(d *schema.ResourceData) int {
+	return d.Get("init_retries").(int)
+}
+
+// getConfigBackupsFromResourceData returns the number of backups of the kubeadm configs to keep in the node
+func getConfigBackupsFromResourceData(d *schema.ResourceData) int {
+	if backupsOpt, ok := d.GetOk("config_backups"); ok {
+		return backupsOpt.(int)
+	}
+	return 0
+}
+
+// getDiagnosticsDirFromResourceData returns the local directory for the diagnostics bundles
+func getDiagnosticsDirFromResourceData(d *schema.ResourceData) string {
+	if dirOpt, ok := d.GetOk("diagnostics_dir"); ok {
+		return dirOpt.(string)
+	}
+	return ""
+}
+
+// getValidateConfigFromResourceData returns true if we must validate the kubeadm configuration before using it
+func getValidateConfigFromResourceData(d *schema.ResourceData) bool {
+	return d.Get("validate_config").(bool)
+}
+
+// getDiscoveryFileFromResourceData returns true if nodes must join with a discovery kubeconfig file
+func getDiscoveryFileFromResourceData(d *schema.ResourceData) bool {
+	if discoveryOpt, ok := d.GetOk("config.discovery_file"); ok {
+		discovery, err := strconv.ParseBool(discoveryOpt.(string))
+		if err == nil {
+			return discovery
+		}
+	}
+	return false
+}
+
+// getTLSBootstrapFromResourceData returns true if nodes must join with a bootstrap kubeconfig file
+func getTLSBootstrapFromResourceData(d *schema.ResourceData) bool {
+	if bootstrapOpt, ok := d.GetOk("config.tls_bootstrap"); ok {
+		bootstrap, err := strconv.ParseBool(bootstrapOpt.(string))
+		if err == nil {
+			return bootstrap
+		}
+	}
+	return false
+}
+
+// getKubeletServingCertsFromResourceData returns true if we must approve the CSRs for the kubelet serving certificates
+func getKubeletServingCertsFromResourceData(d *schema.ResourceData) bool {
+	if servingOpt, ok := d.GetOk("config.kubelet_serving_certs"); ok {
+		serving, err := strconv.ParseBool(servingOpt.(string))
+		if err == nil {
+			return serving
+		}
+	}
+	return false
+}
+
+// getSkipTokenPrintFromResourceData returns true if the token should not be printed in `kubeadm init`
+func getSkipTokenPrintFromResourceData(d *schema.ResourceData) bool {
+	if skipOpt, ok := d.GetOk("config.skip_token_print"); ok {
+		skip, err := strconv.ParseBool(skipOpt.(string))
+		if err == nil {
+			return skip
+		}
+	}
+	return true
+}
+
+// getWaitForWorkersFromResourceData returns the number of workers we must wait for
+func getWaitForWorkersFromResourceData(d *schema.ResourceData) int {
+	if waitOpt, ok := d.GetOk("wait_for_workers"); ok {
+		return waitOpt.(int)
+	}
+	return 0
+}
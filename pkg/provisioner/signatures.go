@@ -0,0 +1,107 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+const (
+	// command for verifying the signature of an image with some public key
+	cosignVerifyCmd = "%s verify --key %s %s"
+
+	// name of the public key file uploaded to the remote temporary directory
+	cosignPublicKeyFilename = "cosign.pub"
+)
+
+// getImagesFromImagesList gets all the images from the output of `kubeadm config images list`
+func getImagesFromImagesList(output string) []string {
+	images := []string{}
+	for _, line := range strings.Split(output, "\n") {
+		image := strings.TrimSpace(line)
+		if image == "" || strings.ContainsAny(image, " \t") || !strings.Contains(image, ":") {
+			continue
+		}
+		images = append(images, image)
+	}
+	return images
+}
+
+// doVerifyImagesSignatures verifies the signatures of the control plane images (as
+// reported by `kubeadm config images list`) with cosign and the public key provided,
+// aborting when any image cannot be verified.
+// This is only done when a public key has been configured.
+func doVerifyImagesSignatures(d *schema.ResourceData) ssh.Action {
+	keyOpt, ok := d.GetOk("config.images_verify_key")
+	if !ok || keyOpt.(string) == "" {
+		return nil
+	}
+
+	key, err := common.FromTerraformSafeString(keyOpt.(string))
+	if err != nil {
+		return ssh.ActionError(fmt.Sprintf("could not decode the images verification key: %s", err))
+	}
+
+	listCmd, err := getKubeadmImagesListCmd(d)
+	if err != nil {
+		return ssh.ActionError(fmt.Sprintf("could not get a valid 'config' for getting the list of images: %s", err))
+	}
+
+	cosign := getCosignFromResourceData(d)
+	verify := ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+		var buf bytes.Buffer
+		if res := ssh.DoSendingExecOutputToWriter(ssh.DoExec(listCmd), &buf).Apply(ctx); ssh.IsError(res) {
+			return ssh.DoAbort("could not get the list of images for verifying their signatures: %s", res.Error())
+		}
+		images := getImagesFromImagesList(buf.String())
+		if len(images) == 0 {
+			return ssh.DoAbort("could not get the list of images for verifying their signatures")
+		}
+
+		keyFile := path.Join(ssh.GetTmpDirFromContext(ctx), cosignPublicKeyFilename)
+		actions := ssh.ActionList{
+			ssh.DoMessageInfo("Verifying the signatures of %d images...", len(images)),
+			ssh.DoUploadBytesToFile(key, keyFile),
+		}
+		for _, image := range images {
+			image := image
+			actions = append(actions,
+				ssh.DoMessageInfo("- %s", image),
+				ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+					var out bytes.Buffer
+					cmd := fmt.Sprintf(cosignVerifyCmd, cosign, keyFile, image)
+					if res := ssh.DoSendingExecOutputToWriter(ssh.DoExec(cmd), &out).Apply(ctx); ssh.IsError(res) {
+						return ssh.DoAbort("signature verification failed for %q:\n%s", image, strings.TrimSpace(out.String()))
+					}
+					return nil
+				}))
+		}
+		return ssh.DoWithCleanup(actions, ssh.DoTry(ssh.DoDeleteFile(keyFile)))
+	})
+
+	return ssh.DoIfElse(
+		ssh.CheckBinaryExists(cosign),
+		verify,
+		ssh.DoAbort("%s NOT found: it is necessary for verifying the images signatures (you can specify a custom path in 'install.cosign_path')", cosign))
+}
@@ -0,0 +1,42 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetImagesFromImagesList(t *testing.T) {
+	output := `
+I0710 10:11:12.123456    1234 version.go:251] remote version is much newer: v1.30.0
+registry.k8s.io/kube-apiserver:v1.26.3
+registry.k8s.io/kube-controller-manager:v1.26.3
+registry.k8s.io/pause:3.9
+
+registry.k8s.io/etcd:3.5.6-0
+`
+	expected := []string{
+		"registry.k8s.io/kube-apiserver:v1.26.3",
+		"registry.k8s.io/kube-controller-manager:v1.26.3",
+		"registry.k8s.io/pause:3.9",
+		"registry.k8s.io/etcd:3.5.6-0",
+	}
+
+	images := getImagesFromImagesList(output)
+	if !reflect.DeepEqual(images, expected) {
+		t.Fatalf("Error: unexpected images: %v", images)
+	}
+}
@@ -16,28 +16,112 @@ package provisioner
 
 import (
 	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
 
 	"github.com/hashicorp/terraform/communicator"
 	"github.com/hashicorp/terraform/terraform"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+)
+
+const (
+	// default number of attempts for connecting to the machine (with a "connection_retry")
+	defConnectionRetryAttempts = 30
+
+	// default interval between attempts for connecting to the machine
+	defConnectionRetryInterval = "10s"
+
+	// timeout for checking if the bastion host is reachable after a connection error
+	bastionDialTimeout = 10 * time.Second
+)
+
+var (
+	// bastion settings in the "connection" block that require a "bastion_host"
+	bastionConnInfoKeys = []string{
+		"bastion_port",
+		"bastion_user",
+		"bastion_password",
+		"bastion_private_key",
+		"bastion_certificate",
+		"bastion_host_key",
+	}
 )
 
+// getBastionAddress returns the address (host:port) of the bastion host in the connection
+// info, or an empty string if the connection does not use a bastion host.
+// Like Terraform, the bastion port defaults to the port of the target machine.
+func getBastionAddress(connInfo map[string]string) (string, error) {
+	host := connInfo["bastion_host"]
+	if host == "" {
+		for _, key := range bastionConnInfoKeys {
+			if connInfo[key] != "" {
+				return "", fmt.Errorf("'%s' in the connection requires a 'bastion_host'", key)
+			}
+		}
+		return "", nil
+	}
+
+	port := connInfo["bastion_port"]
+	if port == "" {
+		port = connInfo["port"]
+	}
+	if port == "" {
+		port = "22"
+	}
+	if p, err := strconv.Atoi(port); err != nil || p <= 0 || p > 65535 {
+		return "", fmt.Errorf("invalid 'bastion_port' in the connection: %q", port)
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// getConnectionError returns an error for a failed connection, distinguishing (when
+// using a bastion host) between failures reaching the bastion and the target machine
+func getConnectionError(connInfo map[string]string, bastion string, err error) error {
+	if bastion == "" {
+		return err
+	}
+	conn, dialErr := net.DialTimeout("tcp", bastion, bastionDialTimeout)
+	if dialErr != nil {
+		return fmt.Errorf("could not reach the bastion host %s: %s", bastion, dialErr)
+	}
+	_ = conn.Close()
+	return fmt.Errorf("could not connect to %s through the bastion host %s: %s", connInfo["host"], bastion, err)
+}
+
 // getCommunicator gets a new communicator for the remote machine
-func getCommunicator(ctx context.Context, o terraform.UIOutput, s *terraform.InstanceState) (communicator.Communicator, error) {
-	// Get a new communicator
+// When a `retry` is provided, we try to connect `retry.Times` times (waiting `retry.Interval`
+// between attempts), otherwise we retry until the connection timeout.
+func getCommunicator(ctx context.Context, o terraform.UIOutput, s *terraform.InstanceState, retry *ssh.Retry) (communicator.Communicator, error) {
+	bastion, err := getBastionAddress(s.Ephemeral.ConnInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get a new communicator (that will tunnel through the bastion host, if any)
 	comm, err := communicator.New(s)
 	if err != nil {
 		return nil, err
 	}
+	if bastion != "" {
+		o.Output(fmt.Sprintf("Connecting to %s through the bastion host %s", s.Ephemeral.ConnInfo["host"], bastion))
+	}
 
-	retryCtx, cancel := context.WithTimeout(ctx, comm.Timeout())
-	defer cancel()
+	if retry != nil {
+		err = connectWithRetries(ctx, o, comm, *retry)
+	} else {
+		retryCtx, cancel := context.WithTimeout(ctx, comm.Timeout())
+		defer cancel()
 
-	// Wait and retry until we establish the connection
-	err = communicator.Retry(retryCtx, func() error {
-		return comm.Connect(o)
-	})
+		// Wait and retry until we establish the connection
+		err = communicator.Retry(retryCtx, func() error {
+			return comm.Connect(o)
+		})
+	}
 	if err != nil {
-		return nil, err
+		return nil, getConnectionError(s.Ephemeral.ConnInfo, bastion, err)
 	}
 
 	// Wait for the context to end and then disconnect
@@ -48,3 +132,28 @@ func getCommunicator(ctx context.Context, o terraform.UIOutput, s *terraform.Ins
 
 	return comm, err
 }
+
+// connectWithRetries connects the communicator, retrying while the machine is not
+// ready for accepting connections (ie, a freshly-booted VM where sshd is not running yet)
+func connectWithRetries(ctx context.Context, o terraform.UIOutput, comm communicator.Communicator, retry ssh.Retry) error {
+	var err error
+	for attempt := 1; attempt <= retry.Times; attempt++ {
+		if err = comm.Connect(o); err == nil {
+			if attempt > 1 {
+				o.Output(fmt.Sprintf("Connected to the machine after %d attempts", attempt))
+			}
+			return nil
+		}
+		if attempt == retry.Times {
+			break
+		}
+
+		o.Output(fmt.Sprintf("Waiting for the machine to accept connections (attempt %d/%d): %s", attempt, retry.Times, err))
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("interrupted while waiting for the machine to accept connections: %s", err)
+		case <-time.After(retry.Interval):
+		}
+	}
+	return fmt.Errorf("could not connect to the machine after %d attempts: %s", retry.Times, err)
+}
@@ -0,0 +1,93 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform/terraform"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+)
+
+// failingCommunicator is a communicator that fails the first `failures` connections
+type failingCommunicator struct {
+	ssh.DummyCommunicator
+	failures int
+	attempts *int
+}
+
+func (c failingCommunicator) Connect(terraform.UIOutput) error {
+	*c.attempts++
+	if *c.attempts <= c.failures {
+		return fmt.Errorf("connection refused")
+	}
+	return nil
+}
+
+func TestConnectWithRetries(t *testing.T) {
+	testsCases := []struct {
+		failures int
+		times    int
+		attempts int
+		err      bool
+	}{
+		{0, 3, 1, false},
+		{2, 3, 3, false},
+		{3, 3, 3, true},
+	}
+
+	for _, testCase := range testsCases {
+		attempts := 0
+		comm := failingCommunicator{failures: testCase.failures, attempts: &attempts}
+		retry := ssh.Retry{Times: testCase.times, Interval: time.Millisecond}
+
+		err := connectWithRetries(context.Background(), ssh.DummyOutput{}, comm, retry)
+		if (err != nil) != testCase.err {
+			t.Fatalf("Error: unexpected result with %d failures: %v", testCase.failures, err)
+		}
+		if attempts != testCase.attempts {
+			t.Fatalf("Error: unexpected number of attempts with %d failures: %d (expected %d)", testCase.failures, attempts, testCase.attempts)
+		}
+	}
+}
+
+func TestGetBastionAddress(t *testing.T) {
+	testsCases := []struct {
+		connInfo map[string]string
+		expected string
+		err      bool
+	}{
+		{map[string]string{"host": "10.0.0.2"}, "", false},
+		{map[string]string{"host": "10.0.0.2", "bastion_host": "bastion.example.com"}, "bastion.example.com:22", false},
+		{map[string]string{"host": "10.0.0.2", "port": "2222", "bastion_host": "1.2.3.4"}, "1.2.3.4:2222", false},
+		{map[string]string{"host": "10.0.0.2", "bastion_host": "1.2.3.4", "bastion_port": "2200"}, "1.2.3.4:2200", false},
+		{map[string]string{"host": "10.0.0.2", "bastion_host": "1.2.3.4", "bastion_port": "ssh"}, "", true},
+		{map[string]string{"host": "10.0.0.2", "bastion_user": "admin"}, "", true},
+	}
+
+	for _, testCase := range testsCases {
+		address, err := getBastionAddress(testCase.connInfo)
+		if (err != nil) != testCase.err {
+			t.Fatalf("Error: unexpected result for %v: %v", testCase.connInfo, err)
+		}
+		if address != testCase.expected {
+			t.Fatalf("Error: unexpected bastion address for %v: %q (expected %q)", testCase.connInfo, address, testCase.expected)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+const (
+	// swapInfoScript prints the number of active swap devices
+	swapInfoScript = `
+echo "swaps=$(tail -n +2 /proc/swaps 2>/dev/null | wc -l)"
+true
+`
+
+	// disableSwapScript turns off the swap, and comments out the swap
+	// entries in the fstab so it is not turned on again after a reboot
+	disableSwapScript = `
+swapoff -a
+[ -f /etc/fstab ] && sed -i.bak -E 's/^([^#[:space:]][^[:space:]]*[[:space:]]+[^[:space:]]+[[:space:]]+swap[[:space:]].*)$/# \1/' /etc/fstab
+true
+`
+)
+
+// parseSwapInfo parses the output of the `swapInfoScript`, returning the number
+// of active swap devices
+func parseSwapInfo(out string) (int, error) {
+	for _, line := range strings.Split(out, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "swaps=") {
+			n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "swaps=")))
+			if err != nil {
+				return 0, fmt.Errorf("could not parse the number of swap devices in %q", line)
+			}
+			return n, nil
+		}
+	}
+	return 0, fmt.Errorf("could not get the number of swap devices")
+}
+
+// doManageSwap makes the swap in the node consistent with the swap settings of the kubelet.
+// When the swap is disabled, it is turned off (or the provisioning fails when it is not
+// managed, as the kubelet would not start). When enabled, it checks there is some swap
+// and that cgroups v2 is used (as the kubelet only supports swap with cgroups v2).
+func doManageSwap(d *schema.ResourceData) ssh.Action {
+	swapOpt, ok := d.GetOk("config.swap")
+	if !ok {
+		return nil
+	}
+	swap := swapOpt.(string)
+	manage := false
+	if manageOpt, ok := d.GetOk("config.manage_swap"); ok {
+		manage = manageOpt.(string) == "true"
+	}
+
+	cgroups := cgroupInfo{}
+	return ssh.ActionList{
+		doDetectCgroups(d, &cgroups),
+		ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+			var buf bytes.Buffer
+			if res := ssh.DoSendingExecOutputToWriter(ssh.DoExecScript([]byte(swapInfoScript)), &buf).Apply(ctx); ssh.IsError(res) {
+				return ssh.ActionError(fmt.Sprintf("could not get the swap in the node: %s", res.Error()))
+			}
+			swaps, err := parseSwapInfo(buf.String())
+			if err != nil {
+				return ssh.ActionError(err.Error())
+			}
+
+			switch swap {
+			case common.SwapEnabled:
+				return ssh.ActionList{
+					ssh.DoIf(ssh.CheckExpr(swaps == 0),
+						ssh.DoMessageWarn("swap is enabled in the kubelet, but there is no active swap in the node")),
+					ssh.DoIf(ssh.CheckExpr(cgroups.Version != 2),
+						ssh.DoMessageWarn("swap is only supported by the kubelet with cgroups v2: the pods will not use the swap")),
+				}
+			case common.SwapDisabled:
+				if swaps == 0 {
+					return nil
+				}
+				if !manage {
+					return ssh.DoAbort("the swap is on in the node (%d devices) but it is not enabled in the kubelet: turn it off or enable it in the 'swap'", swaps)
+				}
+				return ssh.ActionList{
+					ssh.DoMessageInfo("Turning off the swap in the node..."),
+					ssh.DoExecScript([]byte(disableSwapScript)),
+				}
+			}
+			return ssh.ActionError(fmt.Sprintf("unknown swap mode %q", swap))
+		}),
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"testing"
+)
+
+func TestParseSwapInfo(t *testing.T) {
+	testCases := []struct {
+		out         string
+		swaps       int
+		expectedErr bool
+	}{
+		{"swaps=0\n", 0, false},
+		{"swaps=2\n", 2, false},
+		{"swaps=       1\n", 1, false},
+		{"\n", 0, true},
+		{"swaps=many\n", 0, true},
+	}
+
+	for i, testCase := range testCases {
+		swaps, err := parseSwapInfo(testCase.out)
+		if testCase.expectedErr {
+			if err == nil {
+				t.Fatalf("Error: test case %d: error expected but not found", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Error: test case %d: unexpected error: %s", i, err)
+		}
+		if swaps != testCase.swaps {
+			t.Fatalf("Error: test case %d: expected %d swap devices, got %d", i, testCase.swaps, swaps)
+		}
+	}
+}
@@ -34,6 +34,13 @@ import (
 const (
 	// TTL for tokens created for a new join, when no previous token is available
 	newJoinTokenTTL = "1h"
+
+	// TTL for the ephemeral tokens created for a single join (they are deleted after the join,
+	// but the TTL makes sure they expire even when the deletion fails)
+	ephemeralJoinTokenTTL = "30m"
+
+	// TTL for the token in the exported bootstrap kubeconfig
+	bootstrapKubeconfigTokenTTL = "24h"
 )
 
 var (
@@ -106,24 +113,26 @@ func (kt KubeadmTokensSet) FromString(s string) error {
 
 // DoExecKubeadmToken runs a "kubeadm token" command, with a auto-uploaded kubeconfig file
 func DoExecKubeadmToken(d *schema.ResourceData, cmd string) ssh.Action {
-	// upload the local kubeconfig to some temporary remote file
-	remoteKubeconfig, err := ssh.GetTempFilename()
-	if err != nil {
-		return ssh.ActionError(fmt.Sprintf("Could not create temporary file: %s", err))
-	}
-
 	kubeconfig := getKubeconfigFromResourceData(d)
 	if kubeconfig == "" {
-		return ssh.ActionError(fmt.Sprintf("Could not get the local kubeconfig: %s", err))
+		return ssh.ActionError("Could not get the local kubeconfig")
 	}
 
 	kubeadm := getKubeadmFromResourceData(d)
 
-	return ssh.DoWithCleanup(ssh.ActionList{
-		ssh.DoUploadFileToFile(kubeconfig, remoteKubeconfig),
-		ssh.DoExec(fmt.Sprintf("%s token --kubeconfig=%s %s", kubeadm, remoteKubeconfig, cmd)),
-	}, ssh.ActionList{
-		ssh.DoTry(ssh.DoDeleteFile(remoteKubeconfig)),
+	return ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+		// upload the local kubeconfig to some temporary remote file
+		remoteKubeconfig, err := ssh.GetTempFilenameFromContext(ctx)
+		if err != nil {
+			return ssh.ActionError(fmt.Sprintf("Could not create temporary file: %s", err))
+		}
+
+		return ssh.DoWithCleanup(ssh.ActionList{
+			ssh.DoUploadFileToFile(kubeconfig, remoteKubeconfig),
+			ssh.DoExec(fmt.Sprintf("%s token --kubeconfig=%s %s", kubeadm, remoteKubeconfig, cmd)),
+		}, ssh.ActionList{
+			ssh.DoTry(ssh.DoDeleteFile(remoteKubeconfig)),
+		})
 	})
 }
 
@@ -155,16 +164,29 @@ func DoSetNewToken(d *schema.ResourceData, newToken string) ssh.Action {
 		if err != nil {
 			return ssh.ActionError(fmt.Sprintf("could not get a valid 'config' for join'ing: %s", err))
 		}
-		joinConfig.Discovery.BootstrapToken = &kubeadmapi.BootstrapTokenDiscovery{
-			Token:                    newToken,
-			UnsafeSkipCAVerification: true,
+		// when using a discovery file, the token is only used for the TLS bootstrap
+		// (and it is not needed when the file is a bootstrap kubeconfig with the token)
+		if joinConfig.Discovery.File == nil {
+			joinConfig.Discovery.BootstrapToken = &kubeadmapi.BootstrapTokenDiscovery{
+				Token:                    newToken,
+				UnsafeSkipCAVerification: true,
+			}
+		}
+		if !getTLSBootstrapFromResourceData(d) {
+			joinConfig.Discovery.TLSBootstrapToken = newToken
 		}
-		joinConfig.Discovery.TLSBootstrapToken = newToken
 
 		if err := common.JoinConfigToResourceData(d, joinConfig); err != nil {
 			return ssh.ActionError(err.Error())
 		}
 
+		// update the "config.token" too (ie, for the bootstrap kubeconfig)
+		config := common.GetProvisionerConfig(d)
+		config["token"] = newToken
+		if err := d.Set("config", config); err != nil {
+			return ssh.ActionError("cannot update config.token")
+		}
+
 		return nil
 	})
 }
@@ -226,3 +248,48 @@ func doRefreshToken(d *schema.ResourceData) ssh.Action {
 			}),
 	}
 }
+
+// doJoinToken returns the actions for getting a valid token before joining and
+// for cleaning it up afterwards. When "ephemeral_token" is enabled, a fresh
+// (short-lived) token is created for this join and deleted after it. Otherwise, the
+// current token is refreshed when it is not valid anymore (and nothing is cleaned up).
+func doJoinToken(d *schema.ResourceData) (ssh.Action, ssh.Action) {
+	if !getEphemeralTokenFromResourceData(d) {
+		return doRefreshToken(d), nil
+	}
+
+	newToken, err := common.GetRandomToken()
+	if err != nil {
+		return ssh.ActionError(fmt.Sprintf("cannot create new random token: %s", err)), nil
+	}
+
+	// (the token is created in a retry loop, so delete it first in case a previous attempt created it)
+	create := ssh.ActionList{
+		ssh.DoMessageInfo("Creating an ephemeral token for joining (ttl: %s)...", ephemeralJoinTokenTTL),
+		ssh.DoTry(ssh.DoSendingExecOutputToDevNull(DoExecKubeadmToken(d, fmt.Sprintf("delete %s", newToken)))),
+		ssh.DoSendingExecOutputToDevNull(DoExecKubeadmToken(d,
+			fmt.Sprintf("create --ttl=%s --description='ephemeral token for joining' %s", ephemeralJoinTokenTTL, newToken))),
+		DoSetNewToken(d, newToken),
+	}
+	cleanup := ssh.DoTry(ssh.ActionList{
+		ssh.DoMessageInfo("Deleting the ephemeral token..."),
+		ssh.DoSendingExecOutputToDevNull(DoExecKubeadmToken(d, fmt.Sprintf("delete %s", newToken))),
+	})
+	return create, cleanup
+}
+
+// doCreateBootstrapKubeconfigToken creates the (short-lived) token used in the exported
+// bootstrap kubeconfig, replacing any previous token with the same ID
+func doCreateBootstrapKubeconfigToken(d *schema.ResourceData) ssh.Action {
+	token := getBootstrapKubeconfigTokenFromResourceData(d)
+	if token == "" {
+		return nil
+	}
+
+	return ssh.ActionList{
+		ssh.DoMessageInfo("Creating the token for the bootstrap kubeconfig (ttl: %s)...", bootstrapKubeconfigTokenTTL),
+		ssh.DoTry(ssh.DoSendingExecOutputToDevNull(DoExecKubeadmToken(d, fmt.Sprintf("delete %s", token)))),
+		ssh.DoSendingExecOutputToDevNull(DoExecKubeadmToken(d,
+			fmt.Sprintf("create --ttl=%s --description='token for the bootstrap kubeconfig' %s", bootstrapKubeconfigTokenTTL, token))),
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/terraform/helper/schema"
+
+	"github.com/inercia/terraform-provider-kubeadm/internal/ssh"
+	"github.com/inercia/terraform-provider-kubeadm/pkg/common"
+)
+
+const (
+	// max number of minor versions the kubelet can be older than kubeadm
+	maxKubeletVersionSkew = 1
+
+	// max number of minor versions kubectl can be older/newer than kubeadm
+	maxKubectlVersionSkew = 1
+
+	// name of the kubeadm preflight check for the kubelet version: when
+	// ignored, a kubelet version mismatch is only a warning
+	kubeletVersionPreflightCheck = "KubeletVersion"
+)
+
+// kubeVersionRegexp matches a kubernetes version (ie, "v1.15.0") in some output
+var kubeVersionRegexp = regexp.MustCompile(`v[0-9]+\.[0-9]+\.[0-9]+[^\s",]*`)
+
+// getKubeVersionFromOutput returns the first kubernetes version found in the
+// output of some "version" command (ie, "Kubernetes v1.15.0")
+func getKubeVersionFromOutput(output string) string {
+	return kubeVersionRegexp.FindString(output)
+}
+
+// getMinorVersionSkew returns the number of minor versions `version` is older than `reference`
+// (a negative number when it is newer)
+func getMinorVersionSkew(reference, version string) (int, error) {
+	refMajor, refMinor, err := common.ParseKubeVersion(reference)
+	if err != nil {
+		return 0, err
+	}
+	major, minor, err := common.ParseKubeVersion(version)
+	if err != nil {
+		return 0, err
+	}
+	if refMajor != major {
+		return 0, fmt.Errorf("major versions do not match (%s vs %s)", reference, version)
+	}
+	return refMinor - minor, nil
+}
+
+// checkKubeletVersionSkew checks the kubelet version is supported by kubeadm,
+// returning an error when it is newer than kubeadm or older than the max skew
+func checkKubeletVersionSkew(kubeadmVersion, kubeletVersion string) error {
+	skew, err := getMinorVersionSkew(kubeadmVersion, kubeletVersion)
+	if err != nil {
+		return err
+	}
+	if skew < 0 {
+		return fmt.Errorf("kubelet %s is newer than kubeadm %s", kubeletVersion, kubeadmVersion)
+	}
+	if skew > maxKubeletVersionSkew {
+		return fmt.Errorf("kubelet %s is too old for kubeadm %s (max skew: %d minor versions)",
+			kubeletVersion, kubeadmVersion, maxKubeletVersionSkew)
+	}
+	return nil
+}
+
+// checkKubectlVersionSkew checks the kubectl version is within the supported skew with kubeadm
+func checkKubectlVersionSkew(kubeadmVersion, kubectlVersion string) error {
+	skew, err := getMinorVersionSkew(kubeadmVersion, kubectlVersion)
+	if err != nil {
+		return err
+	}
+	if skew > maxKubectlVersionSkew || skew < -maxKubectlVersionSkew {
+		return fmt.Errorf("kubectl %s is not within the supported skew with kubeadm %s (max skew: %d minor versions)",
+			kubectlVersion, kubeadmVersion, maxKubectlVersionSkew)
+	}
+	return nil
+}
+
+// getRemoteKubeVersion runs some "version" command in the remote machine and returns the version
+func getRemoteKubeVersion(ctx context.Context, command string) (string, error) {
+	var buf bytes.Buffer
+	if res := ssh.DoSendingExecOutputToWriter(ssh.DoExec(command), &buf).Apply(ctx); ssh.IsError(res) {
+		return "", fmt.Errorf("%s", res.Error())
+	}
+	version := getKubeVersionFromOutput(buf.String())
+	if version == "" {
+		return "", fmt.Errorf("no version found in %q", strings.TrimSpace(buf.String()))
+	}
+	return version, nil
+}
+
+// doCheckBinariesVersions checks that the kubelet (and kubectl) versions match the kubeadm version.
+// A kubelet beyond the allowed skew aborts the provisioning (unless the "KubeletVersion"
+// preflight check is ignored), while a kubectl mismatch is only a warning.
+func doCheckBinariesVersions(d *schema.ResourceData) ssh.Action {
+	kubeadm := getKubeadmFromResourceData(d)
+	kubectl := getKubectlFromResourceData(d)
+
+	kubeletSkewIgnored := false
+	for _, check := range getUserIgnoredChecks(d) {
+		if strings.EqualFold(check, kubeletVersionPreflightCheck) || strings.EqualFold(check, "all") {
+			kubeletSkewIgnored = true
+		}
+	}
+
+	return ssh.ActionFunc(func(ctx context.Context) ssh.Action {
+		kubeadmVersion, err := getRemoteKubeVersion(ctx, fmt.Sprintf("%s version -o short", kubeadm))
+		if err != nil {
+			return ssh.DoMessageWarn("could not get the kubeadm version: %s", err)
+		}
+
+		actions := ssh.ActionList{}
+
+		// the kubelet could be installed later on (ie, by the CRI preparation), so we skip the check when not found
+		if found, _ := ssh.CheckBinaryExists("kubelet").Check(ctx); found {
+			kubeletVersion, err := getRemoteKubeVersion(ctx, "kubelet --version")
+			if err != nil {
+				actions = append(actions, ssh.DoMessageWarn("could not get the kubelet version: %s", err))
+			} else if err := checkKubeletVersionSkew(kubeadmVersion, kubeletVersion); err != nil {
+				if kubeletSkewIgnored {
+					actions = append(actions, ssh.DoMessageWarn("%s (ignored)", err))
+				} else {
+					actions = append(actions,
+						ssh.DoMessageWarn("%s: maybe a partially upgraded machine?", err),
+						ssh.DoMessageWarn("You can ignore this check with a %q in 'ignore_preflight_errors'.", kubeletVersionPreflightCheck),
+						ssh.DoAbort("kubelet and kubeadm versions do not match"))
+				}
+			} else {
+				actions = append(actions, ssh.DoMessageInfo("- kubelet %s matches kubeadm %s", kubeletVersion, kubeadmVersion))
+			}
+		}
+
+		kubectlVersion, err := getRemoteKubeVersion(ctx, fmt.Sprintf("%s version --client", kubectl))
+		if err != nil {
+			actions = append(actions, ssh.DoMessageWarn("could not get the kubectl version: %s", err))
+		} else if err := checkKubectlVersionSkew(kubeadmVersion, kubectlVersion); err != nil {
+			actions = append(actions, ssh.DoMessageWarn("%s", err))
+		}
+
+		return actions
+	})
+}
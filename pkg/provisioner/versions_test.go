@@ -0,0 +1,57 @@
+// Copyright © 2019 Alvaro Saurin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package provisioner
+
+import (
+	"testing"
+)
+
+func TestGetKubeVersionFromOutput(t *testing.T) {
+	tests := []struct {
+		output   string
+		expected string
+	}{
+		{"Kubernetes v1.15.0\n", "v1.15.0"},
+		{"v1.16.2\n", "v1.16.2"},
+		{`Client Version: version.Info{Major:"1", Minor:"15", GitVersion:"v1.15.3", GitCommit:"2d3c76f"}`, "v1.15.3"},
+		{"Client Version: v1.28.1\nKustomize Version: v5.0.4", "v1.28.1"},
+		{"command not found", ""},
+	}
+	for _, test := range tests {
+		if res := getKubeVersionFromOutput(test.output); res != test.expected {
+			t.Fatalf("Error: unexpected version for %q: %q, expected %q", test.output, res, test.expected)
+		}
+	}
+}
+
+func TestCheckKubeletVersionSkew(t *testing.T) {
+	tests := []struct {
+		kubeadm     string
+		kubelet     string
+		expectedErr bool
+	}{
+		{"v1.15.0", "v1.15.3", false},
+		{"v1.15.0", "v1.14.1", false},
+		{"v1.15.0", "v1.13.1", true},
+		{"v1.15.0", "v1.16.0", true},
+		{"v1.15.0", "v2.15.0", true},
+	}
+	for _, test := range tests {
+		err := checkKubeletVersionSkew(test.kubeadm, test.kubelet)
+		if test.expectedErr != (err != nil) {
+			t.Fatalf("Error: unexpected result for kubeadm %s and kubelet %s: %v", test.kubeadm, test.kubelet, err)
+		}
+	}
+}